@@ -0,0 +1,382 @@
+package admin
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"path"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/actanonv/dbx"
+	"github.com/pressly/goose/v3"
+	"github.com/uptrace/bun/dialect"
+)
+
+var (
+	ErrNoAuth          = errors.New("admin: no token or authorizer")
+	ErrNotConfigured   = errors.New("admin: operation not configured")
+	ErrUnsupportedOp   = errors.New("admin: operation not supported by the dialect")
+	errUnauthenticated = errors.New("unauthenticated")
+)
+
+type Options struct {
+	token       string
+	authorize   func(r *http.Request) bool
+	source      fs.FS
+	srcFolder   string
+	migrateOpts []dbx.CreateOptFn
+	migrateDSN  func(name string) (string, error)
+	sink        dbx.BackupSink
+	timeout     time.Duration
+}
+
+type OptFn func(options *Options)
+
+// WithToken requires requests to carry the header "Authorization: Bearer <token>"
+func WithToken(token string) OptFn {
+	return func(opt *Options) {
+		opt.token = token
+	}
+}
+
+// WithAuthorizer requires fn to accept requests, e.g. to check a session or a client certificate.
+// With WithToken too, a request must pass both.
+func WithAuthorizer(fn func(r *http.Request) bool) OptFn {
+	return func(opt *Options) {
+		opt.authorize = fn
+	}
+}
+
+// WithMigrations enables the migration status and the migrate operation, with the migration files of folder in
+// source. opts are passed on to MigrateDBContext, e.g. dbx.CreateWithDbFolder for the folder of the cached databases.
+func WithMigrations(source fs.FS, folder string, opts ...dbx.CreateOptFn) OptFn {
+	return func(opt *Options) {
+		opt.source = source
+		opt.srcFolder = folder
+		opt.migrateOpts = opts
+	}
+}
+
+// WithMigrationDSN resolves the DSN the migrate operation opens for the cached database name, for the databases other
+// than SQLite, whose name in the cache is not their DSN. Without it their migrate fails with ErrUnsupportedOp.
+func WithMigrationDSN(resolve func(name string) (string, error)) OptFn {
+	return func(opt *Options) {
+		opt.migrateDSN = resolve
+	}
+}
+
+// WithBackupSink enables the backup operation, writing the backups of each database under the prefix of its name in
+// sink, like Cache.EnableAutoBackup
+func WithBackupSink(sink dbx.BackupSink) OptFn {
+	return func(opt *Options) {
+		opt.sink = sink
+	}
+}
+
+// WithTimeout bounds every operation (default: 5 minutes)
+func WithTimeout(d time.Duration) OptFn {
+	return func(opt *Options) {
+		opt.timeout = d
+	}
+}
+
+// Server is an http.Handler serving a JSON API over the databases of a dbx.Cache:
+//
+//	GET  /databases                    the cached databases, with their pool stats and migration version
+//	GET  /databases/{name}             a single one of them
+//	GET  /databases/{name}/migrations  the applied and pending migrations (WithMigrations)
+//	POST /databases/{name}/migrate     runs the pending migrations (WithMigrations)
+//	POST /databases/{name}/backup      backs the database up incrementally (WithBackupSink)
+//	POST /databases/{name}/vacuum      runs VACUUM
+//	GET  /stats                        the open transactions and the query statistics (see dbx.WithQueryStats)
+//
+// Errors are returned as {"error": "...", "kind": "..."}. Mount it with http.StripPrefix, e.g. under /admin/dbx/.
+type Server struct {
+	cache *dbx.Cache
+	opt   Options
+	mux   *http.ServeMux
+}
+
+var _ http.Handler = (*Server)(nil)
+
+// NewServer returns a Server over the databases of cache. Requests are authenticated by WithToken, WithAuthorizer or
+// both; without either NewServer fails with ErrNoAuth.
+func NewServer(cache *dbx.Cache, opts ...OptFn) (*Server, error) {
+	if cache == nil {
+		return nil, errors.New("admin: NewServer with nil cache")
+	}
+
+	var opt Options
+	for _, optFn := range opts {
+		optFn(&opt)
+	}
+	if opt.token == "" && opt.authorize == nil {
+		return nil, ErrNoAuth
+	}
+	if opt.timeout == 0 {
+		WithTimeout(5 * time.Minute)(&opt)
+	}
+
+	s := &Server{cache: cache, opt: opt, mux: http.NewServeMux()}
+	s.mux.HandleFunc("GET /databases", s.listDatabases)
+	s.mux.HandleFunc("GET /databases/{name}", s.getDatabase)
+	s.mux.HandleFunc("GET /databases/{name}/migrations", s.migrations)
+	s.mux.HandleFunc("POST /databases/{name}/migrate", s.migrate)
+	s.mux.HandleFunc("POST /databases/{name}/backup", s.backup)
+	s.mux.HandleFunc("POST /databases/{name}/vacuum", s.vacuum)
+	s.mux.HandleFunc("GET /stats", s.stats)
+	return s, nil
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !s.authenticated(r) {
+		writeError(w, http.StatusUnauthorized, errUnauthenticated)
+		return
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), s.opt.timeout)
+	defer cancel()
+	s.mux.ServeHTTP(w, r.WithContext(ctx))
+}
+
+func (s *Server) authenticated(r *http.Request) bool {
+	if s.opt.token != "" {
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(s.opt.token)) != 1 {
+			return false
+		}
+	}
+	return s.opt.authorize == nil || s.opt.authorize(r)
+}
+
+func (s *Server) listDatabases(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, dbx.NewDebugReport(r.Context(), s.cache).Databases)
+}
+
+func (s *Server) getDatabase(w http.ResponseWriter, r *http.Request) {
+	d, err := s.database(r)
+	if err != nil {
+		writeErr(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, d)
+}
+
+// MigrationStatus is the response of GET /databases/{name}/migrations
+type MigrationStatus struct {
+	Name    string `json:"name"`
+	Version int64  `json:"version"`
+	// Latest is the version of the last migration file
+	Latest  int64   `json:"latest"`
+	Pending []int64 `json:"pending"`
+}
+
+func (s *Server) migrations(w http.ResponseWriter, r *http.Request) {
+	status, err := s.migrationStatus(r)
+	if err != nil {
+		writeErr(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, status)
+}
+
+func (s *Server) migrate(w http.ResponseWriter, r *http.Request) {
+	dsn, err := s.migrationDSN(r)
+	if err == nil {
+		_, err = s.migrationStatus(r)
+	}
+	if err != nil {
+		writeErr(w, err)
+		return
+	}
+	opts := append([]dbx.CreateOptFn{dbx.CreateWithSource(s.opt.source), dbx.CreateWithSrcFolder(s.opt.srcFolder)},
+		s.opt.migrateOpts...)
+	if err := dbx.MigrateDBContext(r.Context(), dsn, opts...); err != nil {
+		writeErr(w, err)
+		return
+	}
+	status, err := s.migrationStatus(r)
+	if err != nil {
+		writeErr(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, status)
+}
+
+// migrationDSN returns the DSN of the database of r for MigrateDBContext: its cache name for SQLite, the one of
+// WithMigrationDSN otherwise
+func (s *Server) migrationDSN(r *http.Request) (string, error) {
+	name := r.PathValue("name")
+	db := s.cache.Has(name)
+	if db == nil {
+		return "", fmt.Errorf("%w: %s", dbx.ErrDatabaseNotFound, name)
+	}
+	if dName := db.Dialect().Name(); dName != dialect.SQLite {
+		if s.opt.migrateDSN == nil {
+			return "", fmt.Errorf("%w: migrate of %s without WithMigrationDSN", ErrUnsupportedOp, dName)
+		}
+		return s.opt.migrateDSN(name)
+	}
+	return name, nil
+}
+
+// migrationStatus compares the version of the database of r with the migration files
+func (s *Server) migrationStatus(r *http.Request) (*MigrationStatus, error) {
+	if s.opt.source == nil {
+		return nil, fmt.Errorf("%w: migrations", ErrNotConfigured)
+	}
+	d, err := s.database(r)
+	if err != nil {
+		return nil, err
+	}
+	if d.Error != "" {
+		return nil, errors.New(d.Error)
+	}
+	versions, err := migrationVersions(s.opt.source, s.opt.srcFolder)
+	if err != nil {
+		return nil, err
+	}
+
+	status := &MigrationStatus{Name: d.Name, Version: d.MigrationVersion, Pending: []int64{}}
+	for _, v := range versions {
+		status.Latest = v
+		if v > d.MigrationVersion {
+			status.Pending = append(status.Pending, v)
+		}
+	}
+	return status, nil
+}
+
+// migrationVersions returns the sorted versions of the migration files of folder
+func migrationVersions(source fs.FS, folder string) ([]int64, error) {
+	entries, err := fs.ReadDir(source, folder)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read migrations: %w", err)
+	}
+	var versions []int64
+	for _, e := range entries {
+		if e.IsDir() || (path.Ext(e.Name()) != ".sql" && path.Ext(e.Name()) != ".go") {
+			continue
+		}
+		if v, err := goose.NumericComponent(e.Name()); err == nil {
+			versions = append(versions, v)
+		}
+	}
+	slices.Sort(versions)
+	return versions, nil
+}
+
+// BackupResult is the response of POST /databases/{name}/backup
+type BackupResult struct {
+	Name string `json:"name"`
+	// Backup is the name of the backup written, empty when nothing was committed since the last one
+	Backup  string    `json:"backup,omitempty"`
+	Full    bool      `json:"full"`
+	Created time.Time `json:"created,omitzero"`
+	Size    int64     `json:"size"`
+}
+
+func (s *Server) backup(w http.ResponseWriter, r *http.Request) {
+	if s.opt.sink == nil {
+		writeErr(w, fmt.Errorf("%w: backup", ErrNotConfigured))
+		return
+	}
+	name := r.PathValue("name")
+	db, err := s.cache.Get(name)
+	if err != nil {
+		writeErr(w, err)
+		return
+	}
+	if db.Dialect().Name() != dialect.SQLite {
+		writeErr(w, fmt.Errorf("%w: backup of %s", ErrUnsupportedOp, db.Dialect().Name()))
+		return
+	}
+	info, err := dbx.BackupIncremental(r.Context(), db, dbx.PrefixSink(s.opt.sink, name+"/"))
+	if err != nil {
+		writeErr(w, err)
+		return
+	}
+	res := BackupResult{Name: name}
+	if info != nil {
+		res.Backup, res.Full, res.Created, res.Size = info.Name, info.Full, info.Created, info.Size
+	}
+	writeJSON(w, http.StatusOK, res)
+}
+
+func (s *Server) vacuum(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+	db, err := s.cache.Get(name)
+	if err != nil {
+		writeErr(w, err)
+		return
+	}
+	switch dName := db.Dialect().Name(); dName {
+	case dialect.SQLite, dialect.PG:
+	default:
+		writeErr(w, fmt.Errorf("%w: vacuum of %s", ErrUnsupportedOp, dName))
+		return
+	}
+	if _, err := db.ExecContext(r.Context(), "VACUUM"); err != nil {
+		writeErr(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Stats is the response of GET /stats
+type Stats struct {
+	Time         time.Time       `json:"time"`
+	Transactions []dbx.DebugTx   `json:"transactions"`
+	Queries      []dbx.QueryStat `json:"queries"`
+}
+
+func (s *Server) stats(w http.ResponseWriter, r *http.Request) {
+	report := dbx.NewDebugReport(r.Context(), s.cache)
+	writeJSON(w, http.StatusOK, Stats{Time: report.Time, Transactions: report.Transactions, Queries: report.Queries})
+}
+
+// database returns the state of the cached database of r
+func (s *Server) database(r *http.Request) (*dbx.DebugDB, error) {
+	return dbx.NewDebugDB(r.Context(), s.cache, r.PathValue("name"))
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+type errorResponse struct {
+	Error string `json:"error"`
+	Kind  string `json:"kind"`
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, errorResponse{Error: err.Error(), Kind: dbx.KindOf(err).String()})
+}
+
+// writeErr writes err with the status of its kind
+func writeErr(w http.ResponseWriter, err error) {
+	status := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, ErrNotConfigured), errors.Is(err, ErrUnsupportedOp):
+		status = http.StatusNotImplemented
+	default:
+		switch dbx.KindOf(err) {
+		case dbx.KindNotFound:
+			status = http.StatusNotFound
+		case dbx.KindInvalid:
+			status = http.StatusBadRequest
+		case dbx.KindBusy, dbx.KindClosed:
+			status = http.StatusServiceUnavailable
+		case dbx.KindTimeout:
+			status = http.StatusGatewayTimeout
+		}
+	}
+	writeError(w, status, err)
+}
@@ -0,0 +1,178 @@
+package admin
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/actanonv/dbx"
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/dialect/pgdialect"
+)
+
+var testMigrations = fstest.MapFS{
+	"migrations/00001_items.sql": {Data: []byte("-- +goose Up\nCREATE TABLE items (id INTEGER PRIMARY KEY);\n" +
+		"-- +goose Down\nDROP TABLE items;\n")},
+	"migrations/00002_tags.sql": {Data: []byte("-- +goose Up\nCREATE TABLE tags (id INTEGER PRIMARY KEY);\n" +
+		"-- +goose Down\nDROP TABLE tags;\n")},
+}
+
+func setupServer(t *testing.T, opts ...OptFn) *Server {
+	t.Helper()
+
+	tmp := t.TempDir()
+	cache := dbx.NewCache(30 * time.Minute)
+	t.Cleanup(func() { _ = cache.Close() })
+	if _, err := cache.GetOrOpen("app", dbx.WithDbFolder(tmp), dbx.WithCreateIfMissing()); err != nil {
+		t.Fatalf("GetOrOpen failed: %v", err)
+	}
+
+	opts = append([]OptFn{
+		WithToken("secret"),
+		WithMigrations(testMigrations, "migrations", dbx.CreateWithDbFolder(tmp), dbx.CreateWithLogger(nil)),
+		WithBackupSink(dbx.NewDirSink(filepath.Join(tmp, "backups"))),
+	}, opts...)
+	s, err := NewServer(cache, opts...)
+	if err != nil {
+		t.Fatalf("NewServer failed: %v", err)
+	}
+	return s
+}
+
+// call serves a request to s with the token and decodes the response into v, unless nil
+func call(t *testing.T, s http.Handler, method, target string, wantStatus int, v any) {
+	t.Helper()
+	req := httptest.NewRequest(method, target, nil)
+	req.Header.Set("Authorization", "Bearer secret")
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, req)
+	if rec.Code != wantStatus {
+		t.Fatalf("%s %s: expected status %d, got %d: %s", method, target, wantStatus, rec.Code, rec.Body)
+	}
+	if v != nil {
+		if err := json.Unmarshal(rec.Body.Bytes(), v); err != nil {
+			t.Fatalf("%s %s: decode failed: %v", method, target, err)
+		}
+	}
+}
+
+func TestNewServer_RequiresAuth(t *testing.T) {
+	cache := dbx.NewCache(time.Minute)
+	defer cache.Close()
+	if _, err := NewServer(cache); !errors.Is(err, ErrNoAuth) {
+		t.Fatalf("expected ErrNoAuth, got %v", err)
+	}
+}
+
+func TestServer_Auth(t *testing.T) {
+	s := setupServer(t, WithAuthorizer(func(r *http.Request) bool { return r.Header.Get("X-Admin") == "yes" }))
+
+	for _, header := range []map[string]string{
+		{},
+		{"Authorization": "Bearer wrong", "X-Admin": "yes"},
+		{"Authorization": "Bearer secret"},
+	} {
+		req := httptest.NewRequest(http.MethodGet, "/databases", nil)
+		for k, v := range header {
+			req.Header.Set(k, v)
+		}
+		rec := httptest.NewRecorder()
+		s.ServeHTTP(rec, req)
+		if rec.Code != http.StatusUnauthorized {
+			t.Errorf("headers %v: expected 401, got %d", header, rec.Code)
+		}
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/databases", nil)
+	req.Header.Set("Authorization", "Bearer secret")
+	req.Header.Set("X-Admin", "yes")
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200 with the token and the authorizer, got %d", rec.Code)
+	}
+}
+
+func TestServer_Operations(t *testing.T) {
+	s := setupServer(t)
+
+	var dbs []dbx.DebugDB
+	call(t, s, http.MethodGet, "/databases", http.StatusOK, &dbs)
+	if len(dbs) != 1 || dbs[0].Name != "app" || dbs[0].Dialect != "sqlite" {
+		t.Fatalf("expected the cached db, got %+v", dbs)
+	}
+	call(t, s, http.MethodGet, "/databases/nope", http.StatusNotFound, nil)
+
+	var status MigrationStatus
+	call(t, s, http.MethodGet, "/databases/app/migrations", http.StatusOK, &status)
+	if status.Version != 0 || status.Latest != 2 || len(status.Pending) != 2 {
+		t.Fatalf("expected 2 pending migrations, got %+v", status)
+	}
+	call(t, s, http.MethodPost, "/databases/app/migrate", http.StatusOK, &status)
+	if status.Version != 2 || len(status.Pending) != 0 {
+		t.Fatalf("expected no pending migrations after migrate, got %+v", status)
+	}
+
+	var backup BackupResult
+	call(t, s, http.MethodPost, "/databases/app/backup", http.StatusOK, &backup)
+	if backup.Backup == "" || !backup.Full {
+		t.Fatalf("expected a full backup, got %+v", backup)
+	}
+	call(t, s, http.MethodPost, "/databases/app/vacuum", http.StatusNoContent, nil)
+	call(t, s, http.MethodPost, "/databases/nope/vacuum", http.StatusNotFound, nil)
+
+	var stats Stats
+	call(t, s, http.MethodGet, "/stats", http.StatusOK, &stats)
+	if stats.Time.IsZero() {
+		t.Fatalf("expected the stats, got %+v", stats)
+	}
+}
+
+func TestServer_NotConfigured(t *testing.T) {
+	cache := dbx.NewCache(time.Minute)
+	defer cache.Close()
+	s, err := NewServer(cache, WithToken("secret"))
+	if err != nil {
+		t.Fatalf("NewServer failed: %v", err)
+	}
+	var resp errorResponse
+	call(t, s, http.MethodPost, "/databases/app/backup", http.StatusNotImplemented, &resp)
+	if resp.Error == "" {
+		t.Fatalf("expected an error message, got %+v", resp)
+	}
+	call(t, s, http.MethodGet, "/databases/app/migrations", http.StatusNotImplemented, nil)
+}
+
+func TestServer_MigrateNotSQLite(t *testing.T) {
+	var resolved []string
+	s := setupServer(t, WithMigrationDSN(func(name string) (string, error) {
+		resolved = append(resolved, name)
+		return "", errors.New("no dsn")
+	}))
+	// A db of another dialect, whose cache name is not a DSN
+	sqldb, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("sql.Open failed: %v", err)
+	}
+	s.cache.Set("pg", bun.NewDB(sqldb, pgdialect.New()))
+
+	call(t, s, http.MethodPost, "/databases/pg/migrate", http.StatusInternalServerError, nil)
+	if len(resolved) != 1 || resolved[0] != "pg" {
+		t.Fatalf("expected the DSN of pg to be resolved, got %v", resolved)
+	}
+
+	s.opt.migrateDSN = nil
+	var resp errorResponse
+	call(t, s, http.MethodPost, "/databases/pg/migrate", http.StatusNotImplemented, &resp)
+	if resp.Error == "" {
+		t.Fatalf("expected an error message, got %+v", resp)
+	}
+	call(t, s, http.MethodPost, "/databases/nope/migrate", http.StatusNotFound, nil)
+}
@@ -0,0 +1,133 @@
+package dbx
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/dialect"
+)
+
+const archiveSchema = "dbx_archive"
+
+// ArchiveRows moves the rows of table matching where (with args as its placeholder values) into the same table of
+// the archive database archiveDBName, and returns how many rows were moved.
+// The archive is a SQLite file next to the main db file, named archiveDBName (".db" is added when it has no
+// extension); it is ATTACHed to a dedicated connection. The archive table is created on first use with the columns
+// of table, without its constraints; add the same columns to it when the main table gains new ones.
+//
+// SQLite only makes a transaction spanning attached files atomic in rollback journal mode, so the move runs as two
+// transactions touching one file each: the matching rows not archived yet are copied, then the rows having an
+// identical copy in the archive are deleted. When it is interrupted between the two, or the rows change in between,
+// running it again resumes the move without losing or duplicating rows.
+// ATTACH is not allowed inside a transaction, so t must not have one active.
+// Errors are returned as an *Error of op "archive".
+func ArchiveRows(ctx context.Context, t *Transact, table, where, archiveDBName string, args ...any) (_ int64, err error) {
+	defer func() { err = wrapErr("archive", "", table, err) }()
+	if dName := t.db.Dialect().Name(); dName != dialect.SQLite {
+		return 0, fmt.Errorf("unsupported dialect: %s", dName)
+	}
+	if archiveDBName == "" || strings.ContainsAny(archiveDBName, `/\`) || strings.Contains(archiveDBName, "..") {
+		return 0, fmt.Errorf("%w: archive db name %q must be a file name", ErrInvalidOptions, archiveDBName)
+	}
+	active := t.active()
+	if active {
+		return 0, fmt.Errorf("cannot archive rows: %w", ErrTxActive)
+	}
+
+	conn, err := t.db.Conn(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer conn.Close()
+
+	var mainFile string
+	if err = conn.NewRaw("SELECT file FROM pragma_database_list WHERE name = 'main'").Scan(ctx, &mainFile); err != nil {
+		return 0, fmt.Errorf("failed to locate main db file: %w", err)
+	}
+	if mainFile == "" {
+		return 0, fmt.Errorf("cannot archive rows of an in-memory db")
+	}
+	archiveFile := filepath.Join(filepath.Dir(mainFile), archiveDBName)
+	if filepath.Ext(archiveFile) == "" {
+		archiveFile += ".db"
+	}
+
+	var columns []struct {
+		Name string `bun:"name"`
+		PK   int    `bun:"pk"`
+	}
+	if err = conn.NewRaw("SELECT name, pk FROM pragma_table_info(?, 'main') ORDER BY cid", table).Scan(ctx, &columns); err != nil {
+		return 0, fmt.Errorf("failed to read columns: %w", err)
+	}
+	if len(columns) == 0 {
+		return 0, fmt.Errorf("no such table: %s", table)
+	}
+
+	// Rows are matched on all their columns, looked up through an archive index on the primary key (or all columns)
+	var key []string
+	var match strings.Builder
+	var matchArgs []any
+	for _, c := range columns {
+		if c.PK > 0 {
+			key = append(key, c.Name)
+		}
+		if match.Len() > 0 {
+			match.WriteString(" AND ")
+		}
+		match.WriteString("a.? IS s.?")
+		matchArgs = append(matchArgs, bun.Ident(c.Name), bun.Ident(c.Name))
+	}
+	if len(key) == 0 {
+		for _, c := range columns {
+			key = append(key, c.Name)
+		}
+	}
+
+	if _, err = conn.ExecContext(ctx, "ATTACH DATABASE ? AS ?", archiveFile, bun.Ident(archiveSchema)); err != nil {
+		return 0, fmt.Errorf("failed to attach archive %s: %w", archiveFile, err)
+	}
+	defer func() {
+		_, _ = conn.ExecContext(context.WithoutCancel(ctx), "DETACH DATABASE ?", bun.Ident(archiveSchema))
+	}()
+
+	src, dst := bun.Ident("main."+table), bun.Ident(archiveSchema+"."+table)
+	archived := "EXISTS (SELECT 1 FROM ? AS a WHERE " + match.String() + ")"
+	err = conn.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		if _, err := tx.ExecContext(ctx, "CREATE TABLE IF NOT EXISTS ? AS SELECT * FROM ? WHERE 0", dst, src); err != nil {
+			return fmt.Errorf("failed to create archive table: %w", err)
+		}
+		if _, err := tx.ExecContext(ctx, "CREATE INDEX IF NOT EXISTS ? ON ? (?)",
+			bun.Ident(archiveSchema+"."+table+"_archive_key"), bun.Ident(table), bun.In(idents(key))); err != nil {
+			return fmt.Errorf("failed to create archive index: %w", err)
+		}
+		copyArgs := append([]any{dst, src}, args...)
+		copyArgs = append(append(copyArgs, dst), matchArgs...)
+		if _, err := tx.ExecContext(ctx, "INSERT INTO ? SELECT * FROM ? AS s WHERE ("+where+") AND NOT "+archived, copyArgs...); err != nil {
+			return fmt.Errorf("failed to copy rows: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	var moved int64
+	err = conn.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		deleteArgs := append([]any{src}, args...)
+		deleteArgs = append(append(deleteArgs, dst), matchArgs...)
+		res, err := tx.ExecContext(ctx, "DELETE FROM ? AS s WHERE ("+where+") AND "+archived, deleteArgs...)
+		if err != nil {
+			return fmt.Errorf("failed to delete rows: %w", err)
+		}
+		moved, err = res.RowsAffected()
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return moved, nil
+}
@@ -0,0 +1,124 @@
+package dbx
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+func TestArchiveRows(t *testing.T) {
+	ctx := context.Background()
+	db := setupTestDB(t)
+	for _, name := range []string{"old 1", "old 2", "new"} {
+		insertItem(t, db, name)
+	}
+
+	tx, err := NewTransact(ctx, db)
+	if err != nil {
+		t.Fatalf("NewTransact failed: %v", err)
+	}
+
+	moved, err := ArchiveRows(ctx, tx, "items", "name LIKE ?", "archive", "old%")
+	if err != nil {
+		t.Fatalf("ArchiveRows failed: %v", err)
+	}
+	if moved != 2 {
+		t.Fatalf("expected 2 rows moved, got %d", moved)
+	}
+	if n := countItems(t, db); n != 1 {
+		t.Fatalf("expected 1 row left in items, got %d", n)
+	}
+
+	archive, err := sql.Open(string(DriverSQLite), filepath.Join(dbFolder, "archive.db"))
+	if err != nil {
+		t.Fatalf("open archive failed: %v", err)
+	}
+	defer archive.Close()
+	var archived int
+	if err := archive.QueryRowContext(ctx, "SELECT COUNT(*) FROM items WHERE name LIKE 'old%'").Scan(&archived); err != nil {
+		t.Fatalf("count archive failed: %v", err)
+	}
+	if archived != 2 {
+		t.Fatalf("expected 2 archived rows, got %d", archived)
+	}
+
+	// Archiving again appends to the existing archive table
+	if moved, err = ArchiveRows(ctx, tx, "items", "1 = 1", "archive"); err != nil || moved != 1 {
+		t.Fatalf("expected 1 more row moved, got %d (err %v)", moved, err)
+	}
+
+	if _, err := tx.Start(nil); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	defer tx.Rollback()
+	if _, err := ArchiveRows(ctx, tx, "items", "1 = 1", "archive"); !errors.Is(err, ErrTxActive) {
+		t.Fatalf("expected ErrTxActive inside a transaction, got %v", err)
+	}
+}
+
+func TestArchiveRows_Resume(t *testing.T) {
+	ctx := context.Background()
+	db := setupTestDB(t)
+	for _, name := range []string{"old 1", "old 2", "new"} {
+		insertItem(t, db, name)
+	}
+	tx, err := NewTransact(ctx, db)
+	if err != nil {
+		t.Fatalf("NewTransact failed: %v", err)
+	}
+	if _, err := ArchiveRows(ctx, tx, "items", "0 = 1", "resume"); err != nil {
+		t.Fatalf("ArchiveRows failed: %v", err)
+	}
+
+	// A move interrupted after the copy: "old 1" is in both files
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		t.Fatalf("Conn failed: %v", err)
+	}
+	if _, err := conn.ExecContext(ctx, "ATTACH DATABASE ? AS resume", filepath.Join(dbFolder, "resume.db")); err != nil {
+		t.Fatalf("attach failed: %v", err)
+	}
+	if _, err := conn.ExecContext(ctx, "INSERT INTO resume.items SELECT * FROM main.items WHERE name = 'old 1'"); err != nil {
+		t.Fatalf("partial copy failed: %v", err)
+	}
+	if _, err := conn.ExecContext(ctx, "DETACH DATABASE resume"); err != nil {
+		t.Fatalf("detach failed: %v", err)
+	}
+	_ = conn.Close()
+
+	moved, err := ArchiveRows(ctx, tx, "items", "name LIKE ?", "resume", "old%")
+	if err != nil || moved != 2 {
+		t.Fatalf("expected 2 rows moved, got %d (err %v)", moved, err)
+	}
+	if n := countItems(t, db); n != 1 {
+		t.Fatalf("expected 1 row left in items, got %d", n)
+	}
+
+	archive, err := sql.Open(string(DriverSQLite), filepath.Join(dbFolder, "resume.db"))
+	if err != nil {
+		t.Fatalf("open archive failed: %v", err)
+	}
+	defer archive.Close()
+	var archived int
+	if err := archive.QueryRowContext(ctx, "SELECT COUNT(*) FROM items").Scan(&archived); err != nil {
+		t.Fatalf("count archive failed: %v", err)
+	}
+	if archived != 2 {
+		t.Fatalf("expected 2 archived rows without duplicates, got %d", archived)
+	}
+}
+
+func TestArchiveRows_InvalidName(t *testing.T) {
+	ctx := context.Background()
+	tx, err := NewTransact(ctx, setupTestDB(t))
+	if err != nil {
+		t.Fatalf("NewTransact failed: %v", err)
+	}
+	for _, name := range []string{"", "../archive", "sub/archive", `sub\archive`, ".."} {
+		if _, err := ArchiveRows(ctx, tx, "items", "1 = 1", name); !errors.Is(err, ErrInvalidOptions) {
+			t.Errorf("ArchiveRows(%q): expected ErrInvalidOptions, got %v", name, err)
+		}
+	}
+}
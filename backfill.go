@@ -0,0 +1,145 @@
+package dbx
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/uptrace/bun"
+)
+
+// BackfillSpec describes a Backfill
+type BackfillSpec struct {
+	// Name identifies the checkpoint in the dbx_backfill table, Table when empty
+	Name string
+	// Table is the table walked, with its prefix and suffix added like TableName
+	Table string
+	// Key is the integer primary key of Table, "id" when empty
+	Key string
+	// Transform updates the rows of keys, in ascending order, in the transaction saving the checkpoint after them
+	Transform func(ctx context.Context, idb bun.IDB, keys []int64) error
+	// BatchSize is the largest number of rows per transaction, 1000 when 0
+	BatchSize int
+	// MaxLatency throttles the backfill when set: a batch committing slower than MaxLatency halves the size of the
+	// next one and pauses for as long as it took, letting the writes of the application through; faster batches
+	// grow back to BatchSize
+	MaxLatency time.Duration
+	// Progress, when set, is called after each batch with the rows done out of the total
+	Progress func(done, total int64)
+}
+
+// BackfillState is a row of the dbx_backfill table, the checkpoint of a Backfill
+type BackfillState struct {
+	bun.BaseModel `bun:"table:dbx_backfill"`
+
+	Name      string    `bun:"name,pk"`
+	LastKey   int64     `bun:"last_key,notnull"`
+	Done      int64     `bun:"done,notnull"`
+	UpdatedAt time.Time `bun:"updated_at,notnull"`
+}
+
+// Backfill runs spec.Transform over the rows of spec.Table in primary key order, one batch per transaction, for data
+// migrations too large for a single goose migration. The last key done is saved with each batch, so a Backfill
+// stopped by an error, a cancelled ctx or a crash resumes after it; rows inserted later with larger keys are picked
+// up by the next run. ResetBackfill starts over.
+func Backfill(ctx context.Context, db *bun.DB, spec BackfillSpec) error {
+	if spec.Table == "" || spec.Transform == nil {
+		return fmt.Errorf("%w: backfill needs a table and a transform", ErrInvalidOptions)
+	}
+	if spec.Name == "" {
+		spec.Name = spec.Table
+	}
+	key := spec.Key
+	if key == "" {
+		key = "id"
+	}
+	size := spec.BatchSize
+	if size <= 0 {
+		size = 1000
+	}
+	table := bun.Ident(TableName(db, spec.Table))
+
+	state, err := BackfillStatus(ctx, db, spec.Name)
+	if err != nil {
+		return err
+	}
+	var total int
+	if spec.Progress != nil {
+		if total, err = db.NewSelect().TableExpr("?", table).Count(ctx); err != nil {
+			return fmt.Errorf("failed to count rows of %s: %w", spec.Table, err)
+		}
+	}
+
+	batch := size
+	for {
+		var keys []int64
+		if err := db.NewSelect().TableExpr("?", table).ColumnExpr("?", bun.Ident(key)).
+			Where("? > ?", bun.Ident(key), state.LastKey).OrderExpr("?", bun.Ident(key)).Limit(batch).
+			Scan(ctx, &keys); err != nil {
+			return fmt.Errorf("failed to select backfill batch of %s: %w", spec.Table, err)
+		}
+		if len(keys) == 0 {
+			return nil
+		}
+
+		start := time.Now()
+		next := *state
+		next.LastKey, next.Done, next.UpdatedAt = keys[len(keys)-1], state.Done+int64(len(keys)), time.Now().UTC()
+		err := db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+			if err := spec.Transform(ctx, tx, keys); err != nil {
+				return err
+			}
+			_, err := upsert(tx.NewInsert().Model(&next), "name").Exec(ctx)
+			return err
+		})
+		if err != nil {
+			return fmt.Errorf("failed to backfill %s after key %d: %w", spec.Name, state.LastKey, err)
+		}
+		*state = next
+		if spec.Progress != nil {
+			spec.Progress(state.Done, max(int64(total), state.Done))
+		}
+
+		if spec.MaxLatency <= 0 {
+			continue
+		}
+		elapsed := time.Since(start)
+		if elapsed <= spec.MaxLatency/2 {
+			batch = min(batch*2, size)
+			continue
+		}
+		if elapsed > spec.MaxLatency {
+			batch = max(batch/2, 1)
+			timer := time.NewTimer(elapsed)
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return context.Cause(ctx)
+			case <-timer.C:
+			}
+		}
+	}
+}
+
+// BackfillStatus returns the checkpoint of the backfill name, a zero LastKey when it has not started
+func BackfillStatus(ctx context.Context, idb bun.IDB, name string) (*BackfillState, error) {
+	if _, err := idb.NewCreateTable().Model((*BackfillState)(nil)).IfNotExists().Exec(ctx); err != nil {
+		return nil, fmt.Errorf("failed to create backfill table: %w", err)
+	}
+	state := &BackfillState{Name: name}
+	if err := idb.NewSelect().Model(state).WherePK().Scan(ctx); err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, err
+	}
+	return state, nil
+}
+
+// ResetBackfill deletes the checkpoint of the backfill name, so the next run starts from the first row
+func ResetBackfill(ctx context.Context, idb bun.IDB, name string) error {
+	if _, err := idb.NewCreateTable().Model((*BackfillState)(nil)).IfNotExists().Exec(ctx); err != nil {
+		return fmt.Errorf("failed to create backfill table: %w", err)
+	}
+	_, err := idb.NewDelete().Model((*BackfillState)(nil)).Where("name = ?", name).Exec(ctx)
+	return err
+}
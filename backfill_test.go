@@ -0,0 +1,125 @@
+package dbx
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/uptrace/bun"
+)
+
+func TestBackfill(t *testing.T) {
+	db := setupTestDB(t)
+	ctx := context.Background()
+	for _, name := range []string{"A", "B", "C", "D", "E"} {
+		insertItem(t, db, name)
+	}
+	if _, err := db.ExecContext(ctx, `ALTER TABLE items ADD COLUMN slug TEXT`); err != nil {
+		t.Fatal(err)
+	}
+
+	errBoom := errors.New("boom")
+	var progress []int64
+	spec := BackfillSpec{
+		Table:     "items",
+		BatchSize: 2,
+		Transform: func(ctx context.Context, idb bun.IDB, keys []int64) error {
+			if slices.Contains(keys, 4) {
+				return errBoom
+			}
+			_, err := idb.NewUpdate().Table("items").Set("slug = lower(name)").Where("id IN (?)", bun.In(keys)).
+				Exec(ctx)
+			return err
+		},
+		Progress: func(done, total int64) {
+			progress = append(progress, done, total)
+		},
+	}
+	if err := Backfill(ctx, db, spec); !errors.Is(err, errBoom) {
+		t.Fatalf("expected the transform error, got %v", err)
+	}
+	state, err := BackfillStatus(ctx, db, "items")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if state.LastKey != 2 || state.Done != 2 {
+		t.Errorf("expected checkpoint after key 2, got %+v", state)
+	}
+
+	transform := spec.Transform
+	spec.Transform = func(ctx context.Context, idb bun.IDB, keys []int64) error {
+		if keys[0] <= 2 {
+			t.Errorf("batch %v done twice", keys)
+		}
+		_, err := idb.NewUpdate().Table("items").Set("slug = lower(name)").Where("id IN (?)", bun.In(keys)).Exec(ctx)
+		return err
+	}
+	if err := Backfill(ctx, db, spec); err != nil {
+		t.Fatalf("resume: %v", err)
+	}
+	if want := []int64{2, 5, 4, 5, 5, 5}; !slices.Equal(progress, want) {
+		t.Errorf("expected progress %v, got %v", want, progress)
+	}
+	var slugs []string
+	if err := db.NewRaw(`SELECT slug FROM items ORDER BY id`).Scan(ctx, &slugs); err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"a", "b", "c", "d", "e"}; !slices.Equal(slugs, want) {
+		t.Errorf("expected slugs %v, got %v", want, slugs)
+	}
+
+	// A later run only picks up the new rows
+	insertItem(t, db, "F")
+	var batches [][]int64
+	spec.Transform = func(ctx context.Context, idb bun.IDB, keys []int64) error {
+		batches = append(batches, keys)
+		return transform(ctx, idb, keys)
+	}
+	spec.Progress = nil
+	if err := Backfill(ctx, db, spec); err != nil {
+		t.Fatal(err)
+	}
+	if len(batches) != 1 || !slices.Equal(batches[0], []int64{6}) {
+		t.Errorf("expected one batch of the new row, got %v", batches)
+	}
+
+	if err := ResetBackfill(ctx, db, "items"); err != nil {
+		t.Fatal(err)
+	}
+	if state, err := BackfillStatus(ctx, db, "items"); err != nil || state.LastKey != 0 {
+		t.Errorf("expected no checkpoint after reset, got %+v, %v", state, err)
+	}
+}
+
+func TestBackfill_Throttle(t *testing.T) {
+	db := setupTestDB(t)
+	ctx := context.Background()
+	for range 8 {
+		insertItem(t, db, "x")
+	}
+
+	var sizes []int
+	err := Backfill(ctx, db, BackfillSpec{
+		Name:       "slow",
+		Table:      "items",
+		BatchSize:  4,
+		MaxLatency: time.Millisecond,
+		Transform: func(ctx context.Context, idb bun.IDB, keys []int64) error {
+			sizes = append(sizes, len(keys))
+			time.Sleep(5 * time.Millisecond)
+			return nil
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []int{4, 2, 1, 1}; !slices.Equal(sizes, want) {
+		t.Errorf("expected slow batches to shrink to %v, got %v", want, sizes)
+	}
+
+	if err := Backfill(ctx, db, BackfillSpec{Table: "items"}); !errors.Is(err, ErrInvalidOptions) {
+		t.Errorf("expected ErrInvalidOptions without a transform, got %v", err)
+	}
+}
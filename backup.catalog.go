@@ -0,0 +1,58 @@
+package dbx
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"time"
+)
+
+// BackupCatalog lists the backups of the databases kept in a sink under the prefix of their name, as
+// Cache.EnableAutoBackup stores them, and restores them to a point in time
+type BackupCatalog struct {
+	sink     BackupSink
+	dbFolder string
+}
+
+// NewBackupCatalog returns the catalog of the backups in sink of the databases of dbFolder
+func NewBackupCatalog(sink BackupSink, dbFolder string) *BackupCatalog {
+	return &BackupCatalog{sink: sink, dbFolder: dbFolder}
+}
+
+// Databases returns the names of the databases with backups, sorted
+func (c *BackupCatalog) Databases(ctx context.Context) ([]string, error) {
+	objects, err := c.sink.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, o := range objects {
+		name, file, _ := strings.Cut(o.Name, "/")
+		if _, ok := backupCreated(file); ok && !slices.Contains(names, name) {
+			names = append(names, name)
+		}
+	}
+	slices.Sort(names)
+	return names, nil
+}
+
+// Backups returns the backups of the database name, full and incremental, oldest first
+func (c *BackupCatalog) Backups(ctx context.Context, name string) ([]BackupInfo, error) {
+	return ListBackups(ctx, c.Sink(name))
+}
+
+// Sink returns the sink holding the backups of the database name
+func (c *BackupCatalog) Sink(name string) BackupSink {
+	return PrefixSink(c.sink, name+"/")
+}
+
+// RestoreToTime restores the database name of the folder of c to its last backup taken at or before t, and returns
+// that backup. The database file is replaced: close it first, e.g. with Cache.Close or after its cleanup.
+func (c *BackupCatalog) RestoreToTime(ctx context.Context, name string, t time.Time) (*BackupInfo, error) {
+	dbFile, err := DbFilePath(name, c.dbFolder)
+	if err != nil && !errors.Is(err, ErrDBFileNotFound) {
+		return nil, err
+	}
+	return RestoreDBToTime(ctx, c.Sink(name), t, dbFile)
+}
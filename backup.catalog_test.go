@@ -0,0 +1,70 @@
+package dbx
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestBackupCatalog_RestoreToTime(t *testing.T) {
+	ctx := context.Background()
+	tmp := t.TempDir()
+	db, err := OpenDB("t1", WithDbFolder(tmp), WithCreateIfMissing())
+	if err != nil {
+		t.Fatalf("OpenDB failed: %v", err)
+	}
+	defer db.Close()
+	if _, err := db.Exec("CREATE TABLE items (id INTEGER PRIMARY KEY AUTOINCREMENT, name TEXT NOT NULL)"); err != nil {
+		t.Fatalf("create table failed: %v", err)
+	}
+
+	catalog := NewBackupCatalog(NewDirSink(filepath.Join(tmp, "backups")), tmp)
+	before := time.Now()
+	var marks []time.Time
+	for range 3 {
+		for range 5 {
+			insertItem(t, db, "item")
+		}
+		if _, err := BackupIncremental(ctx, db, catalog.Sink("t1")); err != nil {
+			t.Fatalf("BackupIncremental failed: %v", err)
+		}
+		marks = append(marks, time.Now())
+	}
+	_ = db.Close()
+
+	if names, err := catalog.Databases(ctx); err != nil || !slices.Equal(names, []string{"t1"}) {
+		t.Fatalf("expected databases [t1], got %v (err %v)", names, err)
+	}
+	backups, err := catalog.Backups(ctx, "t1")
+	if err != nil || len(backups) != 3 || !backups[0].Full || backups[1].Full {
+		t.Fatalf("expected a full and 2 incremental backups, got %+v (err %v)", backups, err)
+	}
+
+	for i, mark := range marks {
+		info, err := catalog.RestoreToTime(ctx, "t1", mark)
+		if err != nil {
+			t.Fatalf("RestoreToTime failed: %v", err)
+		}
+		if info.Name != backups[i].Name {
+			t.Fatalf("restored %s, expected %s", info.Name, backups[i].Name)
+		}
+		restored, err := sql.Open(string(DriverSQLite), filepath.Join(tmp, "t1.db"))
+		if err != nil {
+			t.Fatalf("open restored db failed: %v", err)
+		}
+		var n int
+		err = restored.QueryRow("SELECT COUNT(*) FROM items").Scan(&n)
+		_ = restored.Close()
+		if err != nil || n != 5*(i+1) {
+			t.Fatalf("restored %d items at mark %d, expected %d (err %v)", n, i, 5*(i+1), err)
+		}
+	}
+
+	if _, err := catalog.RestoreToTime(ctx, "t1", before); !errors.Is(err, ErrBackupNotFound) {
+		t.Fatalf("expected ErrBackupNotFound before the first backup, got %v", err)
+	}
+}
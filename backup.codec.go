@@ -0,0 +1,459 @@
+package dbx
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// ErrBackupCorrupt is returned when a backup fails its checksum or its decryption
+var ErrBackupCorrupt = errors.New("backup is corrupt")
+
+// Compression is the algorithm EncodeSink compresses backups with
+type Compression byte
+
+const (
+	CompressNone Compression = iota
+	CompressGzip
+	CompressZstd
+)
+
+// KeyProvider returns the AES keys (16, 24 or 32 bytes) EncodeSink encrypts backups with. Keys have an id, stored in
+// the backups, so the current key can be rotated while older backups still restore.
+type KeyProvider interface {
+	// CurrentKey returns the key new backups are encrypted with
+	CurrentKey(ctx context.Context) (id string, key []byte, err error)
+	// Key returns the key of id
+	Key(ctx context.Context, id string) ([]byte, error)
+}
+
+type staticKey struct {
+	id  string
+	key []byte
+}
+
+// StaticKey returns a KeyProvider of the single key id
+func StaticKey(id string, key []byte) KeyProvider {
+	return &staticKey{id: id, key: key}
+}
+
+func (k *staticKey) CurrentKey(context.Context) (string, []byte, error) {
+	return k.id, k.key, nil
+}
+
+func (k *staticKey) Key(_ context.Context, id string) ([]byte, error) {
+	if id != k.id {
+		return nil, fmt.Errorf("unknown backup key %q", id)
+	}
+	return k.key, nil
+}
+
+type BackupOptions struct {
+	compression Compression
+	keys        KeyProvider
+}
+
+type BackupOptFn func(options *BackupOptions)
+
+// BackupWithCompression compresses the backups with c (default: CompressNone)
+func BackupWithCompression(c Compression) BackupOptFn {
+	return func(opt *BackupOptions) {
+		opt.compression = c
+	}
+}
+
+// BackupWithEncryption encrypts the backups with AES-GCM, with the keys of kp
+func BackupWithEncryption(kp KeyProvider) BackupOptFn {
+	return func(opt *BackupOptions) {
+		opt.keys = kp
+	}
+}
+
+// The objects of EncodeSink start with encodedMagic, a byte of Compression, and an encryption byte followed by the
+// key id and a nonce prefix when set. Records follow: a kind byte, a length and the data, sealed when encrypted.
+// Data records hold the compressed content, the final record its SHA-256.
+var encodedMagic = []byte("DBXENC1\n")
+
+const (
+	recordData  = 0
+	recordFinal = 1
+	// recordSize is the size of the data records before sealing
+	recordSize = 64 << 10
+)
+
+type encodeSink struct {
+	sink BackupSink
+	opt  BackupOptions
+}
+
+// EncodeSink returns a BackupSink compressing and encrypting the objects it stores in sink, with a SHA-256 checksum
+// of their content verified when they are read back: Get fails with ErrBackupCorrupt when it does not match.
+// Objects stored before, without encoding, are still read as is. It implements BackupDeleter when sink does.
+func EncodeSink(sink BackupSink, opts ...BackupOptFn) BackupSink {
+	s := &encodeSink{sink: sink}
+	for _, optFn := range opts {
+		optFn(&s.opt)
+	}
+	return s
+}
+
+func (s *encodeSink) Put(ctx context.Context, name string, r io.Reader) error {
+	pr, pw := io.Pipe()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		pw.CloseWithError(s.encode(ctx, pw, r))
+	}()
+	err := s.sink.Put(ctx, name, pr)
+	pr.CloseWithError(errors.New("backup sink stopped reading"))
+	<-done
+	return err
+}
+
+func (s *encodeSink) Get(ctx context.Context, name string) (io.ReadCloser, error) {
+	rc, err := s.sink.Get(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	r, err := s.decode(ctx, rc)
+	if err != nil {
+		_ = rc.Close()
+		return nil, fmt.Errorf("failed to decode backup %s: %w", name, err)
+	}
+	return r, nil
+}
+
+func (s *encodeSink) List(ctx context.Context) ([]BackupObject, error) {
+	return s.sink.List(ctx)
+}
+
+func (s *encodeSink) Delete(ctx context.Context, name string) error {
+	d, ok := s.sink.(BackupDeleter)
+	if !ok {
+		return fmt.Errorf("backup sink %T cannot delete", s.sink)
+	}
+	return d.Delete(ctx, name)
+}
+
+func (s *encodeSink) encode(ctx context.Context, w io.Writer, r io.Reader) error {
+	header := append(bytes.Clone(encodedMagic), byte(s.opt.compression), 0)
+	rw := &recordWriter{w: w}
+	if s.opt.keys != nil {
+		id, key, err := s.opt.keys.CurrentKey(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to get backup key: %w", err)
+		}
+		if len(id) > 255 {
+			return fmt.Errorf("backup key id %q is too long", id)
+		}
+		if rw.aead, err = newGCM(key); err != nil {
+			return err
+		}
+		rw.nonce = make([]byte, rw.aead.NonceSize())
+		if _, err := rand.Read(rw.nonce[:8]); err != nil {
+			return err
+		}
+		header[len(header)-1] = 1
+		header = append(header, byte(len(id)))
+		header = append(append(header, id...), rw.nonce[:8]...)
+	}
+	rw.header = header
+	if _, err := w.Write(header); err != nil {
+		return err
+	}
+
+	var cw io.WriteCloser
+	switch s.opt.compression {
+	case CompressNone:
+		cw = nopWriteCloser{rw}
+	case CompressGzip:
+		cw = gzip.NewWriter(rw)
+	case CompressZstd:
+		zw, err := zstd.NewWriter(rw)
+		if err != nil {
+			return err
+		}
+		cw = zw
+	default:
+		return fmt.Errorf("unknown backup compression %d", s.opt.compression)
+	}
+	sum := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(cw, sum), ctxReader{ctx: ctx, r: r}); err != nil {
+		_ = cw.Close()
+		return err
+	}
+	if err := cw.Close(); err != nil {
+		return err
+	}
+	if err := rw.flush(recordData); err != nil {
+		return err
+	}
+	rw.buf = sum.Sum(nil)
+	return rw.flush(recordFinal)
+}
+
+func (s *encodeSink) decode(ctx context.Context, rc io.ReadCloser) (io.ReadCloser, error) {
+	br := &peekReader{r: rc}
+	magic, err := br.peek(len(encodedMagic) + 2)
+	if err != nil || !bytes.Equal(magic[:len(encodedMagic)], encodedMagic) {
+		// Stored before EncodeSink, or too short to be encoded
+		return struct {
+			io.Reader
+			io.Closer
+		}{br, rc}, nil
+	}
+	header := bytes.Clone(magic)
+	br.skip(len(header))
+	compression, encrypted := Compression(header[len(encodedMagic)]), header[len(encodedMagic)+1] == 1
+
+	rr := &recordReader{r: br}
+	if encrypted {
+		if s.opt.keys == nil {
+			return nil, errors.New("encrypted backup without a key provider")
+		}
+		var n [1]byte
+		if _, err := io.ReadFull(br, n[:]); err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrBackupCorrupt, err)
+		}
+		rest := make([]byte, int(n[0])+8)
+		if _, err := io.ReadFull(br, rest); err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrBackupCorrupt, err)
+		}
+		header = append(append(header, n[0]), rest...)
+		key, err := s.opt.keys.Key(ctx, string(rest[:n[0]]))
+		if err != nil {
+			return nil, fmt.Errorf("failed to get backup key: %w", err)
+		}
+		if rr.aead, err = newGCM(key); err != nil {
+			return nil, err
+		}
+		rr.nonce = make([]byte, rr.aead.NonceSize())
+		copy(rr.nonce, rest[n[0]:])
+	}
+	rr.header = header
+
+	var dr io.Reader
+	closeDR := func() {}
+	switch compression {
+	case CompressNone:
+		dr = rr
+	case CompressGzip:
+		gr, err := gzip.NewReader(rr)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrBackupCorrupt, err)
+		}
+		dr = gr
+	case CompressZstd:
+		zr, err := zstd.NewReader(rr)
+		if err != nil {
+			return nil, err
+		}
+		dr, closeDR = zr, zr.Close
+	default:
+		return nil, fmt.Errorf("%w: unknown compression %d", ErrBackupCorrupt, compression)
+	}
+	return &checkedReader{r: dr, records: rr, sum: sha256.New(), close: func() error {
+		closeDR()
+		return rc.Close()
+	}}, nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("invalid backup key: %w", err)
+	}
+	return cipher.NewGCM(block)
+}
+
+// recordAD returns the additional data sealed with a record of kind, binding it to the header of its object
+func recordAD(header []byte, kind byte) []byte {
+	return append(bytes.Clone(header), kind)
+}
+
+// recordWriter writes its content as data records of recordSize
+type recordWriter struct {
+	w      io.Writer
+	header []byte
+	aead   cipher.AEAD
+	// nonce is the prefix of the object followed by the counter of the records
+	nonce []byte
+	count uint32
+	buf   []byte
+}
+
+func (rw *recordWriter) Write(p []byte) (int, error) {
+	n := len(p)
+	for len(p) > 0 {
+		k := min(len(p), recordSize-len(rw.buf))
+		rw.buf, p = append(rw.buf, p[:k]...), p[k:]
+		if len(rw.buf) == recordSize {
+			if err := rw.flush(recordData); err != nil {
+				return 0, err
+			}
+		}
+	}
+	return n, nil
+}
+
+// flush writes buf as a record of kind; empty data records are skipped
+func (rw *recordWriter) flush(kind byte) error {
+	if kind == recordData && len(rw.buf) == 0 {
+		return nil
+	}
+	data := rw.buf
+	if rw.aead != nil {
+		binary.BigEndian.PutUint32(rw.nonce[8:], rw.count)
+		rw.count++
+		data = rw.aead.Seal(nil, rw.nonce, data, recordAD(rw.header, kind))
+	}
+	var prefix [5]byte
+	prefix[0] = kind
+	binary.BigEndian.PutUint32(prefix[1:], uint32(len(data)))
+	if _, err := rw.w.Write(prefix[:]); err != nil {
+		return err
+	}
+	if _, err := rw.w.Write(data); err != nil {
+		return err
+	}
+	rw.buf = rw.buf[:0]
+	return nil
+}
+
+// recordReader reads the content of data records, until the final record whose data it keeps in final
+type recordReader struct {
+	r      io.Reader
+	header []byte
+	aead   cipher.AEAD
+	nonce  []byte
+	count  uint32
+	buf    []byte
+	final  []byte
+	// err is the last error of next
+	err error
+}
+
+func (rr *recordReader) Read(p []byte) (int, error) {
+	for len(rr.buf) == 0 {
+		if rr.final != nil {
+			return 0, io.EOF
+		}
+		if rr.err = rr.next(); rr.err != nil {
+			return 0, rr.err
+		}
+	}
+	n := copy(p, rr.buf)
+	rr.buf = rr.buf[n:]
+	return n, nil
+}
+
+func (rr *recordReader) next() error {
+	var prefix [5]byte
+	if _, err := io.ReadFull(rr.r, prefix[:]); err != nil {
+		return fmt.Errorf("%w: truncated: %w", ErrBackupCorrupt, err)
+	}
+	kind, size := prefix[0], binary.BigEndian.Uint32(prefix[1:])
+	if kind > recordFinal || size > recordSize+1024 {
+		return fmt.Errorf("%w: bad record", ErrBackupCorrupt)
+	}
+	data := make([]byte, size)
+	if _, err := io.ReadFull(rr.r, data); err != nil {
+		return fmt.Errorf("%w: truncated: %w", ErrBackupCorrupt, err)
+	}
+	if rr.aead != nil {
+		binary.BigEndian.PutUint32(rr.nonce[8:], rr.count)
+		rr.count++
+		var err error
+		if data, err = rr.aead.Open(data[:0], rr.nonce, data, recordAD(rr.header, kind)); err != nil {
+			return fmt.Errorf("%w: %w", ErrBackupCorrupt, err)
+		}
+	}
+	if kind == recordFinal {
+		rr.final = data
+		return nil
+	}
+	rr.buf = data
+	return nil
+}
+
+// checkedReader reads the decompressed content and checks its SHA-256 against the final record at the end
+type checkedReader struct {
+	r       io.Reader
+	records *recordReader
+	sum     hash.Hash
+	close   func() error
+}
+
+func (cr *checkedReader) Read(p []byte) (int, error) {
+	n, err := cr.r.Read(p)
+	cr.sum.Write(p[:n])
+	if err != nil && err != io.EOF && cr.records.err == nil {
+		// Not an error reading the records: the decompressor found the content corrupt
+		return n, fmt.Errorf("%w: %w", ErrBackupCorrupt, err)
+	}
+	if err != io.EOF {
+		return n, err
+	}
+	// Read up to the final record: the decompressor may stop before it
+	if _, err := io.Copy(io.Discard, cr.records); err != nil {
+		return n, err
+	}
+	if !bytes.Equal(cr.sum.Sum(nil), cr.records.final) {
+		return n, fmt.Errorf("%w: checksum mismatch", ErrBackupCorrupt)
+	}
+	return n, io.EOF
+}
+
+func (cr *checkedReader) Close() error {
+	return cr.close()
+}
+
+// peekReader is a reader able to look ahead at its first bytes
+type peekReader struct {
+	r   io.Reader
+	buf []byte
+}
+
+func (pr *peekReader) peek(n int) ([]byte, error) {
+	if len(pr.buf) < n {
+		more := make([]byte, n-len(pr.buf))
+		k, err := io.ReadFull(pr.r, more)
+		pr.buf = append(pr.buf, more[:k]...)
+		if err != nil {
+			return pr.buf, err
+		}
+	}
+	return pr.buf[:n], nil
+}
+
+func (pr *peekReader) skip(n int) {
+	pr.buf = pr.buf[n:]
+}
+
+func (pr *peekReader) Read(p []byte) (int, error) {
+	if len(pr.buf) > 0 {
+		n := copy(p, pr.buf)
+		pr.buf = pr.buf[n:]
+		return n, nil
+	}
+	return pr.r.Read(p)
+}
+
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error {
+	return nil
+}
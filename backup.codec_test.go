@@ -0,0 +1,110 @@
+package dbx
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"testing"
+)
+
+func TestEncodeSink_RoundTrip(t *testing.T) {
+	ctx := context.Background()
+	key := bytes.Repeat([]byte{7}, 32)
+	content := make([]byte, 200<<10)
+	_, _ = rand.Read(content[:100<<10]) // half random, half compressible
+
+	for _, c := range []Compression{CompressNone, CompressGzip, CompressZstd} {
+		for _, encrypted := range []bool{false, true} {
+			t.Run(fmt.Sprintf("%d/%v", c, encrypted), func(t *testing.T) {
+				opts := []BackupOptFn{BackupWithCompression(c)}
+				if encrypted {
+					opts = append(opts, BackupWithEncryption(StaticKey("k1", key)))
+				}
+				mem := newMemSink()
+				sink := EncodeSink(mem, opts...)
+				if err := sink.Put(ctx, "x", bytes.NewReader(content)); err != nil {
+					t.Fatalf("Put failed: %v", err)
+				}
+				if got := readObject(t, sink, "x"); !bytes.Equal(got, content) {
+					t.Fatalf("read back %d bytes, expected the %d put", len(got), len(content))
+				}
+				if encrypted && bytes.Contains(mem.objects["x"], content[:64]) {
+					t.Fatal("content stored in clear")
+				}
+
+				// Flip a byte in the middle of the stored object
+				mem.objects["x"][len(mem.objects["x"])/2] ^= 1
+				r, err := sink.Get(ctx, "x")
+				if err == nil {
+					_, err = io.ReadAll(r)
+					_ = r.Close()
+				}
+				if !errors.Is(err, ErrBackupCorrupt) {
+					t.Fatalf("expected ErrBackupCorrupt, got %v", err)
+				}
+			})
+		}
+	}
+}
+
+func TestEncodeSink_Keys(t *testing.T) {
+	ctx := context.Background()
+	mem := newMemSink()
+	sink := EncodeSink(mem, BackupWithEncryption(StaticKey("k1", bytes.Repeat([]byte{1}, 32))))
+	if err := sink.Put(ctx, "x", bytes.NewReader([]byte("secret"))); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+
+	wrong := EncodeSink(mem, BackupWithEncryption(StaticKey("k1", bytes.Repeat([]byte{2}, 32))))
+	if r, err := wrong.Get(ctx, "x"); err == nil {
+		_, err = io.ReadAll(r)
+		if !errors.Is(err, ErrBackupCorrupt) {
+			t.Fatalf("expected ErrBackupCorrupt with the wrong key, got %v", err)
+		}
+	}
+	if _, err := EncodeSink(mem).Get(ctx, "x"); err == nil {
+		t.Fatal("expected an encrypted backup to need a key provider")
+	}
+
+	// Objects stored before encoding are read as is
+	mem.objects["plain"] = []byte("plain")
+	if got := readObject(t, sink, "plain"); string(got) != "plain" {
+		t.Fatalf("read %q", got)
+	}
+}
+
+func TestEncodeSink_Restore(t *testing.T) {
+	ctx := context.Background()
+	db := openBackupTestDB(t)
+	sink := EncodeSink(NewDirSink(filepath.Join(t.TempDir(), "backups")), BackupWithCompression(CompressZstd),
+		BackupWithEncryption(StaticKey("k1", bytes.Repeat([]byte{3}, 32))))
+	for range 3 {
+		for range 20 {
+			insertItem(t, db, "item")
+		}
+		if _, err := BackupIncremental(ctx, db, sink); err != nil {
+			t.Fatalf("BackupIncremental failed: %v", err)
+		}
+	}
+	if got, want := restoredCount(t, sink), countItems(t, db); got != want {
+		t.Fatalf("restored %d items, expected %d", got, want)
+	}
+}
+
+func readObject(t *testing.T, sink BackupSink, name string) []byte {
+	t.Helper()
+	r, err := sink.Get(context.Background(), name)
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	defer r.Close()
+	data, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatalf("read failed: %v", err)
+	}
+	return data
+}
@@ -0,0 +1,589 @@
+package dbx
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/dialect"
+)
+
+// ErrBackupNotFound is returned when a backup folder holds no full backup to restore from
+var ErrBackupNotFound = errors.New("backup not found")
+
+const (
+	fullBackupExt   = ".full.db"
+	incrBackupExt   = ".incr.wal"
+	backupStateFile = "dbx-backup.json"
+	backupTimeFmt   = "20060102T150405.000000000Z"
+)
+
+// BackupInfo describes a backup written by BackupDB or BackupIncremental
+type BackupInfo struct {
+	// Name is the file name of the backup in its folder
+	Name    string
+	Full    bool
+	Created time.Time
+	Size    int64
+	// Frames is the number of WAL frames archived by an incremental backup, or folded into a full one
+	Frames int
+}
+
+func newBackupInfo(full bool) *BackupInfo {
+	info := &BackupInfo{Full: full, Created: time.Now().UTC()}
+	info.Name = info.Created.Format(backupTimeFmt) + incrBackupExt
+	if full {
+		info.Name = info.Created.Format(backupTimeFmt) + fullBackupExt
+	}
+	return info
+}
+
+// BackupDB writes a full backup of the SQLite database of db to sink, starting a new chain for BackupIncremental.
+// Like HotCopy, it reads the database under a read transaction, so writers are not blocked; the WAL is folded into
+// the backup, a plain SQLite file that opens on its own, streamed to sink without a local copy.
+func BackupDB(ctx context.Context, db *bun.DB, sink BackupSink) (*BackupInfo, error) {
+	conn, src, err := backupConn(ctx, db)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	return backupDone(src)(backupFull(ctx, conn, src, sink))
+}
+
+// BackupIncremental writes to sink the WAL frames committed since the last backup in it, a small object RestoreDB
+// replays onto the last full backup. It returns nil when nothing was committed since.
+//
+// Frames are only found in the WAL until a checkpoint restarts it and they are overwritten, or the last connection
+// closes and deletes it. When the frames since the last backup can no longer all be found, or sink has no full
+// backup yet, BackupIncremental writes a full backup instead, starting a new chain. Each backup checkpoints the WAL,
+// which the next write restarts; back up before it restarts again (every wal_autocheckpoint pages, 1000 by
+// default) to keep the chain going.
+// The WAL is read under the write lock of the database, which blocks writers for as long.
+func BackupIncremental(ctx context.Context, db *bun.DB, sink BackupSink) (*BackupInfo, error) {
+	conn, src, err := backupConn(ctx, db)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	return backupDone(src)(backupIncremental(ctx, conn, src, sink))
+}
+
+func backupIncremental(ctx context.Context, conn bun.Conn, src string, sink BackupSink) (*BackupInfo, error) {
+	state, err := loadBackupState(ctx, sink)
+	if err != nil {
+		return nil, err
+	}
+	if state == nil {
+		return backupFull(ctx, conn, src, sink)
+	}
+
+	var runs []*walRun
+	next, found := *state, false
+	err = lockWAL(ctx, conn, src, func(wal []byte, h *walHeader) error {
+		unchanged, err := state.mainUnchanged(src)
+		if err != nil {
+			return err
+		}
+		if runs, found = state.advance(wal, h, unchanged); found {
+			next.follow(h, runs)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return backupFull(ctx, conn, src, sink)
+	}
+	if err := next.recordMain(ctx, conn, src); err != nil {
+		return nil, err
+	}
+	if len(runs) == 0 {
+		return nil, next.save(ctx, sink)
+	}
+
+	info := newBackupInfo(false)
+	data := encodeWALRuns(runs)
+	for _, r := range runs {
+		info.Frames += r.count()
+	}
+	info.Size = int64(len(data))
+	if err := sink.Put(ctx, info.Name, bytes.NewReader(data)); err != nil {
+		return nil, fmt.Errorf("failed to write incremental backup: %w", err)
+	}
+	next.Last = info.Name
+	return info, next.save(ctx, sink)
+}
+
+// backupDone returns a function publishing the EventBackupCompleted of the database file src, then returning its
+// arguments
+func backupDone(src string) func(*BackupInfo, error) (*BackupInfo, error) {
+	return func(info *BackupInfo, err error) (*BackupInfo, error) {
+		publish(Event{Kind: EventBackupCompleted, DB: src, Backup: info, Err: err})
+		return info, err
+	}
+}
+
+// RestoreDB restores the last backup of sink to the SQLite file dst: it copies the last full backup and replays the
+// incremental backups written after it, checking their checksums and that they follow each other.
+// dst is replaced once the restore is complete; it must not be open.
+func RestoreDB(ctx context.Context, sink BackupSink, dst string) error {
+	_, err := RestoreDBToTime(ctx, sink, time.Now(), dst)
+	return err
+}
+
+// RestoreDBToTime is RestoreDB restoring the state of the last backup taken at or before t, which it returns
+func RestoreDBToTime(ctx context.Context, sink BackupSink, t time.Time, dst string) (*BackupInfo, error) {
+	backups, err := ListBackups(ctx, sink)
+	if err != nil {
+		return nil, err
+	}
+	chain, err := backupChain(backups, t)
+	if err != nil {
+		return nil, err
+	}
+	if err := restoreChain(ctx, sink, chain, dst); err != nil {
+		return nil, err
+	}
+	return &chain[len(chain)-1], nil
+}
+
+// backupChain returns the chain of backups restoring the state at t: the last full backup before it, and the
+// incremental ones after it until t
+func backupChain(backups []BackupInfo, t time.Time) ([]BackupInfo, error) {
+	end := 0
+	for end < len(backups) && !backups[end].Created.After(t) {
+		end++
+	}
+	for base := end - 1; base >= 0; base-- {
+		if backups[base].Full {
+			return backups[base:end], nil
+		}
+	}
+	return nil, fmt.Errorf("%w: no full backup at %s", ErrBackupNotFound, t.Format(time.RFC3339))
+}
+
+// ListBackups returns the backups of sink, oldest first
+func ListBackups(ctx context.Context, sink BackupSink) ([]BackupInfo, error) {
+	objects, err := sink.List(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list backups: %w", err)
+	}
+	var backups []BackupInfo
+	for _, o := range objects {
+		if created, ok := backupCreated(o.Name); ok {
+			full := strings.HasSuffix(o.Name, fullBackupExt)
+			backups = append(backups, BackupInfo{Name: o.Name, Full: full, Created: created, Size: o.Size})
+		}
+	}
+	slices.SortFunc(backups, func(a, b BackupInfo) int { return a.Created.Compare(b.Created) })
+	return backups, nil
+}
+
+// PruneBackups deletes from sink the chains of backups older than the last keep full backups, and returns the number
+// of backups deleted. sink must implement BackupDeleter.
+func PruneBackups(ctx context.Context, sink BackupSink, keep int) (int, error) {
+	d, ok := sink.(BackupDeleter)
+	if !ok {
+		return 0, fmt.Errorf("backup sink %T cannot delete", sink)
+	}
+	if keep < 1 {
+		return 0, fmt.Errorf("cannot keep %d backups", keep)
+	}
+	backups, err := ListBackups(ctx, sink)
+	if err != nil {
+		return 0, err
+	}
+	cut, fulls := 0, 0
+	for i := len(backups) - 1; i >= 0 && fulls < keep; i-- {
+		if backups[i].Full {
+			cut, fulls = i, fulls+1
+		}
+	}
+	if fulls < keep {
+		return 0, nil
+	}
+	for i, b := range backups[:cut] {
+		if err := d.Delete(ctx, b.Name); err != nil {
+			return i, fmt.Errorf("failed to delete backup %s: %w", b.Name, err)
+		}
+	}
+	return cut, nil
+}
+
+// backupCreated returns the time in the name of a backup, false when name is not one
+func backupCreated(name string) (time.Time, bool) {
+	ts, ok := strings.CutSuffix(name, fullBackupExt)
+	if !ok {
+		if ts, ok = strings.CutSuffix(name, incrBackupExt); !ok {
+			return time.Time{}, false
+		}
+	}
+	created, err := time.Parse(backupTimeFmt, ts)
+	return created, err == nil
+}
+
+func restoreChain(ctx context.Context, sink BackupSink, chain []BackupInfo, dst string) error {
+	tmp := dst + ".restore"
+	defer os.Remove(tmp)
+	f, err := os.OpenFile(tmp, os.O_CREATE|os.O_TRUNC|os.O_RDWR, 0o644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if err := getBackup(ctx, sink, chain[0].Name, f); err != nil {
+		return fmt.Errorf("failed to copy full backup %s: %w", chain[0].Name, err)
+	}
+
+	var prev *walRun
+	for _, b := range chain[1:] {
+		var buf bytes.Buffer
+		if err := getBackup(ctx, sink, b.Name, &buf); err != nil {
+			return fmt.Errorf("failed to read incremental backup %s: %w", b.Name, err)
+		}
+		runs, err := decodeWALRuns(buf.Bytes())
+		if err != nil {
+			return fmt.Errorf("failed to read incremental backup %s: %w", b.Name, err)
+		}
+		for _, r := range runs {
+			if prev != nil && !r.continues(prev) {
+				return fmt.Errorf("incremental backup %s does not follow the previous one", b.Name)
+			}
+			if err := r.apply(f); err != nil {
+				return fmt.Errorf("failed to replay incremental backup %s: %w", b.Name, err)
+			}
+			prev = r
+		}
+	}
+	if err := f.Sync(); err != nil {
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	// A WAL left next to dst would be replayed onto the restored file
+	for _, suffix := range []string{"-wal", "-shm", "-journal"} {
+		if err := os.Remove(dst + suffix); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+	}
+	return os.Rename(tmp, dst)
+}
+
+// getBackup copies the object name of sink to w
+func getBackup(ctx context.Context, sink BackupSink, name string, w io.Writer) error {
+	r, err := sink.Get(ctx, name)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	_, err = io.Copy(w, ctxReader{ctx: ctx, r: r})
+	return err
+}
+
+// backupConn returns a dedicated connection of db and its main database file
+func backupConn(ctx context.Context, db *bun.DB) (bun.Conn, string, error) {
+	if dName := db.Dialect().Name(); dName != dialect.SQLite {
+		return bun.Conn{}, "", fmt.Errorf("unsupported dialect: %s", dName)
+	}
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return bun.Conn{}, "", err
+	}
+	src, err := mainDBFile(ctx, conn)
+	if err != nil {
+		conn.Close()
+		return bun.Conn{}, "", err
+	}
+	return conn, src, nil
+}
+
+func backupFull(ctx context.Context, conn bun.Conn, src string, sink BackupSink) (*BackupInfo, error) {
+	info := newBackupInfo(true)
+	state := &backupState{Last: info.Name}
+	err := func() error {
+		tx, err := beginSnapshot(ctx, conn)
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback()
+		wal, err := readWAL(src)
+		if err != nil {
+			return err
+		}
+		return writeFullBackup(ctx, src, wal, sink, info, state)
+	}()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := state.recordMain(ctx, conn, src); err != nil {
+		return nil, err
+	}
+	return info, state.save(ctx, sink)
+}
+
+// beginSnapshot begins a read transaction on conn holding a snapshot of the database.
+// BEGIN is deferred: the snapshot is only taken by the first read. The checkpoints cannot restart the WAL while it is
+// held, nor copy to the main file frames the WAL read after it does not hold.
+func beginSnapshot(ctx context.Context, conn bun.Conn) (bun.Tx, error) {
+	tx, err := conn.BeginTx(ctx, &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		return tx, fmt.Errorf("failed to begin read transaction: %w", err)
+	}
+	var n int
+	if err := tx.QueryRowContext(ctx, "SELECT COUNT(*) FROM sqlite_master").Scan(&n); err != nil {
+		_ = tx.Rollback()
+		return tx, fmt.Errorf("failed to start read snapshot: %w", err)
+	}
+	return tx, nil
+}
+
+// writeFullBackup streams the database file src with the committed frames of wal folded in to sink as the full
+// backup info, moving state to the end of wal. A snapshot of the database must be held since wal was read: the
+// backup holds the state of the last commit in wal.
+func writeFullBackup(ctx context.Context, src string, wal []byte, sink BackupSink, info *BackupInfo, state *backupState) error {
+	var run *walRun
+	if h, ok := parseWALHeader(wal); ok {
+		run = readWALRun(wal, h, 1, h.sum)
+		state.follow(h, []*walRun{run})
+		info.Frames = run.count()
+	}
+	f, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	pr, pw := io.Pipe()
+	var werr error
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		info.Size, werr = writeDBSnapshot(ctx, pw, f, run)
+		pw.CloseWithError(werr)
+	}()
+	err = sink.Put(ctx, info.Name, pr)
+	pr.CloseWithError(errors.New("backup sink stopped reading"))
+	<-done
+	if err != nil {
+		return fmt.Errorf("failed to write full backup: %w", err)
+	}
+	return werr
+}
+
+// lockWAL runs fn holding the write lock of the database of conn, with its WAL read under it (nil without one)
+func lockWAL(ctx context.Context, conn bun.Conn, src string, fn func(wal []byte, h *walHeader) error) (err error) {
+	if _, err := conn.ExecContext(ctx, "BEGIN IMMEDIATE"); err != nil {
+		return fmt.Errorf("failed to lock database: %w", err)
+	}
+	defer func() {
+		if _, rerr := conn.ExecContext(context.WithoutCancel(ctx), "ROLLBACK"); err == nil && rerr != nil {
+			err = fmt.Errorf("failed to unlock database: %w", rerr)
+		}
+	}()
+	wal, err := readWAL(src)
+	if err != nil {
+		return err
+	}
+	h, ok := parseWALHeader(wal)
+	if !ok {
+		h = nil
+	}
+	return fn(wal, h)
+}
+
+// readWAL returns the content of the WAL of the database file src, nil without one
+func readWAL(src string) ([]byte, error) {
+	wal, err := os.ReadFile(src + "-wal")
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("failed to read wal: %w", err)
+	}
+	return wal, nil
+}
+
+// backupState is kept in the backup sink: the position in the WAL of the last backup, so the next incremental one
+// starts from there
+type backupState struct {
+	// Last is the name of the last backup; the state is stale when it is not the last backup of the sink
+	Last string `json:"last"`
+	// Gen is set when following a WAL generation: Header is its header, and Frames the index of the last frame backed
+	// up, whose checksum is Sum
+	Gen    bool      `json:"gen"`
+	Header []byte    `json:"header,omitempty"`
+	Frames int       `json:"frames"`
+	Sum    [2]uint32 `json:"sum"`
+	// MainSize, MainModTime and MainCounter, the file change counter of its header, are those of the main database
+	// file when it held exactly the state backed up, zero when unknown. While they do not change, no frame can have
+	// been lost with the WAL.
+	MainSize    int64     `json:"main_size"`
+	MainModTime time.Time `json:"main_mod_time"`
+	MainCounter uint32    `json:"main_counter"`
+}
+
+// loadBackupState returns the state of sink, nil without one or when it is stale
+func loadBackupState(ctx context.Context, sink BackupSink) (*backupState, error) {
+	var buf bytes.Buffer
+	if err := getBackup(ctx, sink, backupStateFile, &buf); errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to read backup state: %w", err)
+	}
+	var state backupState
+	if err := json.Unmarshal(buf.Bytes(), &state); err != nil {
+		return nil, fmt.Errorf("failed to read backup state: %w", err)
+	}
+	backups, err := ListBackups(ctx, sink)
+	if err != nil {
+		return nil, err
+	}
+	if len(backups) == 0 || backups[len(backups)-1].Name != state.Last {
+		return nil, nil
+	}
+	return &state, nil
+}
+
+func (s *backupState) save(ctx context.Context, sink BackupSink) error {
+	data, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+	if err := sink.Put(ctx, backupStateFile, bytes.NewReader(data)); err != nil {
+		return fmt.Errorf("failed to write backup state: %w", err)
+	}
+	return nil
+}
+
+func (s *backupState) header() *walHeader {
+	h, _ := parseWALHeader(s.Header)
+	return h
+}
+
+// follow moves s to the end of runs in the WAL generation of h, to its start when runs have no frame of it
+func (s *backupState) follow(h *walHeader, runs []*walRun) {
+	if h == nil {
+		s.Gen, s.Header = false, nil
+		return
+	}
+	if !s.Gen || !h.sameGeneration(s.header()) {
+		s.Gen, s.Header, s.Frames, s.Sum = true, h.raw[:], 0, h.sum
+	}
+	for _, r := range runs {
+		if r.header.sameGeneration(h) && r.count() > 0 {
+			s.Frames, s.Sum = r.last(), r.sum()
+		}
+	}
+}
+
+// advance returns the runs of the frames committed in wal since s, or false when they cannot all be found:
+// mainUnchanged tells whether the main database file still holds the state of s
+func (s *backupState) advance(wal []byte, h *walHeader, mainUnchanged bool) ([]*walRun, bool) {
+	old := s.header()
+	switch {
+	case h == nil:
+		// No WAL: it was deleted, and its frames copied to the main file, when the last connection closed
+		return nil, mainUnchanged
+	case s.Gen && h.sameGeneration(old):
+		if sum, ok := walFrameSum(wal, h, s.Frames); !ok || sum != s.Sum {
+			return nil, false
+		}
+		return nonEmptyRuns(readWALRun(wal, h, s.Frames+1, s.Sum)), true
+	case s.Gen && old != nil && h.salt1 == old.salt1+1 && walGenerationLength(wal, h) <= s.Frames:
+		// A checkpoint restarted the WAL once, and the new generation is too short to overwrite the frames of the
+		// old one after s
+		return nonEmptyRuns(readWALRun(wal, old, s.Frames+1, s.Sum), readWALRun(wal, h, 1, h.sum)), true
+	case mainUnchanged:
+		// The WAL was created or restarted after the main file held the state of s: a checkpoint restarts it only
+		// once all its frames are copied to the main file, which would have changed with any frame after s
+		return nonEmptyRuns(readWALRun(wal, h, 1, h.sum)), true
+	}
+	return nil, false
+}
+
+// recordMain records the size, time and change counter of the main database file src when it holds exactly the state of s: a passive
+// checkpoint copies the frames of s to it, then the WAL is checked under the write lock for frames committed since
+func (s *backupState) recordMain(ctx context.Context, conn bun.Conn, src string) error {
+	s.MainSize, s.MainModTime, s.MainCounter = 0, time.Time{}, 0
+	wal, err := readWAL(src)
+	if err != nil {
+		return err
+	}
+	before, _ := parseWALHeader(wal)
+	var busy, log, done int
+	if err := conn.QueryRowContext(ctx, "PRAGMA wal_checkpoint(PASSIVE)").Scan(&busy, &log, &done); err != nil {
+		return fmt.Errorf("failed to checkpoint: %w", err)
+	}
+	return lockWAL(ctx, conn, src, func(wal []byte, h *walHeader) error {
+		if h == nil && s.Gen || h != nil && !(s.Gen && h.sameGeneration(s.header()) && h.sameGeneration(before) &&
+			busy == 0 && log == s.Frames && done == s.Frames && readWALRun(wal, h, s.Frames+1, s.Sum).count() == 0) {
+			return nil
+		}
+		fi, counter, err := statMain(src)
+		if err != nil || fi.ModTime().Nanosecond() == 0 {
+			// A time without sub-second precision may not change with the next checkpoint
+			return err
+		}
+		s.MainSize, s.MainModTime, s.MainCounter = fi.Size(), fi.ModTime(), counter
+		return nil
+	})
+}
+
+// mainUnchanged reports whether the main database file src still has the size, time and change counter recorded by
+// recordMain
+func (s *backupState) mainUnchanged(src string) (bool, error) {
+	if s.MainModTime.IsZero() {
+		return false, nil
+	}
+	fi, counter, err := statMain(src)
+	if err != nil {
+		return false, err
+	}
+	return counter == s.MainCounter && fi.Size() == s.MainSize && fi.ModTime().Equal(s.MainModTime), nil
+}
+
+// statMain returns the file info of the main database file src and the file change counter of its header. Writes in
+// rollback journal mode, or a replaced file, change the counter even when the size and time are kept; checkpoints of
+// the WAL do not, but they change the time.
+func statMain(src string) (os.FileInfo, uint32, error) {
+	f, err := os.Open(src)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		return nil, 0, err
+	}
+	var counter [4]byte
+	if _, err := f.ReadAt(counter[:], 24); err != nil {
+		return nil, 0, fmt.Errorf("failed to read the database header: %w", err)
+	}
+	return fi, binary.BigEndian.Uint32(counter[:]), nil
+}
+
+// walGenerationLength returns the number of frames of the generation of h at the start of wal, committed or not
+func walGenerationLength(wal []byte, h *walHeader) int {
+	n := 0
+	for {
+		if _, ok := walFrameSum(wal, h, n+1); !ok {
+			return n
+		}
+		n++
+	}
+}
+
+func nonEmptyRuns(runs ...*walRun) []*walRun {
+	return slices.DeleteFunc(runs, func(r *walRun) bool { return r.count() == 0 })
+}
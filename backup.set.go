@@ -0,0 +1,162 @@
+package dbx
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/dialect"
+	"github.com/uptrace/bun/dialect/sqlitedialect"
+)
+
+// BackupSet takes a full backup of each of the cached databases names to sink, under the prefix of its name like
+// EnableAutoBackup, all of them at the same instant: for apps splitting related data across several files (main and
+// archive, ...) the backups restore to a consistent whole.
+//
+// The databases are quiesced only briefly: their write locks are all held while a read snapshot of each is taken, on
+// a private read-only connection, then released before the snapshots are streamed to sink. The backups share their
+// name and time, so RestoreToTime of a BackupCatalog restores the set. They start the chains of BackupIncremental.
+func (c *Cache) BackupSet(ctx context.Context, names []string, sink BackupSink) (infos map[string]*BackupInfo, err error) {
+	names = slices.Compact(slices.Sorted(slices.Values(names)))
+	members := make([]*setMember, 0, len(names))
+	defer func() {
+		for _, m := range members {
+			m.close()
+		}
+	}()
+	for _, name := range names {
+		db, err := c.Get(name)
+		if err != nil {
+			return nil, err
+		}
+		m, err := openSetMember(ctx, name, db)
+		if err != nil {
+			return nil, wrapErr("cache.backup", name, "", err)
+		}
+		members = append(members, m)
+	}
+
+	if err := quiesce(ctx, members); err != nil {
+		return nil, err
+	}
+	created := time.Now().UTC()
+
+	infos = make(map[string]*BackupInfo, len(members))
+	for _, m := range members {
+		info := &BackupInfo{Name: created.Format(backupTimeFmt) + fullBackupExt, Full: true, Created: created}
+		if _, err := backupDone(m.src)(info, m.backup(ctx, PrefixSink(sink, m.name+"/"), info)); err != nil {
+			return nil, wrapErr("cache.backup", m.name, "", err)
+		}
+		infos[m.name] = info
+	}
+	return infos, nil
+}
+
+// setMember is a database of a BackupSet
+type setMember struct {
+	name string
+	db   *bun.DB
+	src  string
+	// conn is a connection of the pool of db, holding its write lock during the quiesce
+	conn bun.Conn
+	// snapDB is a private read-only connection to src, snap the snapshot held on its connection snapConn
+	snapDB   *bun.DB
+	snapConn *bun.Conn
+	snap     *bun.Tx
+	// wal is read under the write lock, so it ends at the snapshot
+	wal []byte
+}
+
+func openSetMember(ctx context.Context, name string, db *bun.DB) (*setMember, error) {
+	if dName := db.Dialect().Name(); dName != dialect.SQLite {
+		return nil, fmt.Errorf("unsupported dialect: %s", dName)
+	}
+	m := &setMember{name: name, db: db}
+	conn, src, err := backupConn(ctx, db)
+	if err != nil {
+		return nil, err
+	}
+	m.conn, m.src = conn, src
+	// The pool of db may have a single connection, which the app needs back once the write lock is released
+	sqlDB := sql.OpenDB(&hookConnector{dsn: "file:" + src + "?mode=ro", driver: db.Driver()})
+	sqlDB.SetMaxOpenConns(1)
+	m.snapDB = bun.NewDB(sqlDB, sqlitedialect.New())
+	return m, nil
+}
+
+// quiesce takes the snapshots of members at the same instant: it holds the write locks of all of them, in the order
+// of their names, so no write commits while the snapshots are taken
+func quiesce(ctx context.Context, members []*setMember) (err error) {
+	locked := 0
+	defer func() {
+		for _, m := range members[:locked] {
+			if _, rerr := m.conn.ExecContext(context.WithoutCancel(ctx), "ROLLBACK"); err == nil && rerr != nil {
+				err = wrapErr("cache.backup", m.name, "", fmt.Errorf("failed to unlock database: %w", rerr))
+			}
+			// Give the connection back to the pool
+			_ = m.conn.Close()
+		}
+	}()
+	for _, m := range members {
+		if _, err := m.conn.ExecContext(ctx, "BEGIN IMMEDIATE"); err != nil {
+			return wrapErr("cache.backup", m.name, "", fmt.Errorf("failed to lock database: %w", err))
+		}
+		locked++
+	}
+	for _, m := range members {
+		conn, err := m.snapDB.Conn(ctx)
+		if err != nil {
+			return wrapErr("cache.backup", m.name, "", err)
+		}
+		m.snapConn = &conn
+		tx, err := beginSnapshot(ctx, conn)
+		if err != nil {
+			return wrapErr("cache.backup", m.name, "", err)
+		}
+		m.snap = &tx
+		if m.wal, err = readWAL(m.src); err != nil {
+			return wrapErr("cache.backup", m.name, "", err)
+		}
+	}
+	return nil
+}
+
+// backup streams the snapshot of m to sink as info, then starts its chain
+func (m *setMember) backup(ctx context.Context, sink BackupSink, info *BackupInfo) error {
+	state := &backupState{Last: info.Name}
+	if err := writeFullBackup(ctx, m.src, m.wal, sink, info, state); err != nil {
+		return err
+	}
+	m.release()
+
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	if err := state.recordMain(ctx, conn, m.src); err != nil {
+		return err
+	}
+	return state.save(ctx, sink)
+}
+
+// release ends the snapshot of m
+func (m *setMember) release() {
+	if m.snap != nil {
+		_ = m.snap.Rollback()
+		m.snap = nil
+	}
+	if m.snapConn != nil {
+		_ = m.snapConn.Close()
+		m.snapConn = nil
+	}
+}
+
+func (m *setMember) close() {
+	m.release()
+	_ = m.conn.Close()
+	_ = m.snapDB.Close()
+}
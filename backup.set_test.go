@@ -0,0 +1,75 @@
+package dbx
+
+import (
+	"context"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/uptrace/bun"
+)
+
+func TestCache_BackupSet(t *testing.T) {
+	ctx := context.Background()
+	tmp := t.TempDir()
+	c := NewCache(30 * time.Minute)
+	defer c.Close()
+	dbs := map[string]*bun.DB{}
+	for _, name := range []string{"main", "archive"} {
+		db, err := c.GetOrOpen(name, WithDbFolder(tmp), WithCreateIfMissing())
+		if err != nil {
+			t.Fatalf("GetOrOpen failed: %v", err)
+		}
+		if _, err := db.Exec("CREATE TABLE items (id INTEGER PRIMARY KEY AUTOINCREMENT, name TEXT NOT NULL)"); err != nil {
+			t.Fatalf("create table failed: %v", err)
+		}
+		dbs[name] = db
+	}
+
+	// Every row goes to main, then to archive: at any instant archive holds as many rows as main, or one less
+	var (
+		stop atomic.Bool
+		wg   sync.WaitGroup
+	)
+	wg.Go(func() {
+		for !stop.Load() {
+			for _, name := range []string{"main", "archive"} {
+				if _, err := dbs[name].Exec("INSERT INTO items (name) VALUES ('x')"); err != nil {
+					t.Errorf("insert failed: %v", err)
+					return
+				}
+			}
+		}
+	})
+	time.Sleep(20 * time.Millisecond)
+
+	sink := NewDirSink(filepath.Join(tmp, "backups"))
+	infos, err := c.BackupSet(ctx, []string{"main", "archive"}, sink)
+	stop.Store(true)
+	wg.Wait()
+	if err != nil {
+		t.Fatalf("BackupSet failed: %v", err)
+	}
+	if len(infos) != 2 || infos["main"].Name != infos["archive"].Name {
+		t.Fatalf("expected 2 backups of the same name, got %v", infos)
+	}
+
+	counts := map[string]int{}
+	for _, name := range []string{"main", "archive"} {
+		counts[name] = restoredCount(t, PrefixSink(sink, name+"/"))
+	}
+	if d := counts["main"] - counts["archive"]; d != 0 && d != 1 || counts["main"] == 0 {
+		t.Fatalf("inconsistent backup set: main %d rows, archive %d", counts["main"], counts["archive"])
+	}
+
+	// The set starts the chains of BackupIncremental
+	if _, err := c.BackupSet(ctx, []string{"main", "archive"}, sink); err != nil {
+		t.Fatalf("BackupSet failed: %v", err)
+	}
+	insertItem(t, dbs["main"], "after")
+	if info := mustBackupIncremental(t, dbs["main"], PrefixSink(sink, "main/")); info == nil || info.Full {
+		t.Fatalf("expected an incremental backup, got %+v", info)
+	}
+}
@@ -0,0 +1,191 @@
+package dbx
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// BackupSink stores the backups of BackupDB and BackupIncremental, and the small state file they keep next to them.
+// NewDirSink stores them in a local folder; implement it over S3, GCS or any object storage to back up without
+// staging files on the local disk.
+type BackupSink interface {
+	// Put stores the content of r under name, replacing it. Readers of name must never see part of it: write to a
+	// temporary name and rename, or rely on the atomic uploads of object storage.
+	Put(ctx context.Context, name string, r io.Reader) error
+	// Get opens name; the error wraps fs.ErrNotExist when there is none
+	Get(ctx context.Context, name string) (io.ReadCloser, error)
+	// List returns the objects of the sink, in any order
+	List(ctx context.Context) ([]BackupObject, error)
+}
+
+// BackupDeleter is implemented by the sinks able to delete backups, for PruneBackups
+type BackupDeleter interface {
+	Delete(ctx context.Context, name string) error
+}
+
+// BackupObject is an object listed by a BackupSink
+type BackupObject struct {
+	Name string
+	Size int64
+}
+
+// DirSink is a BackupSink storing backups as files of a local folder, names with slashes in subfolders
+type DirSink struct {
+	dir string
+}
+
+// NewDirSink returns a BackupSink storing backups in the folder dir, created on the first Put
+func NewDirSink(dir string) *DirSink {
+	return &DirSink{dir: filepath.Clean(dir)}
+}
+
+// Dir returns the folder of the sink
+func (s *DirSink) Dir() string {
+	return s.dir
+}
+
+func (s *DirSink) Put(ctx context.Context, name string, r io.Reader) error {
+	if err := s.checkName(name); err != nil {
+		return err
+	}
+	file := filepath.Join(s.dir, filepath.FromSlash(name))
+	if err := os.MkdirAll(filepath.Dir(file), 0o755); err != nil {
+		return fmt.Errorf("failed to create backup folder %s: %w", filepath.Dir(file), err)
+	}
+	tmp := file + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp)
+	if _, err := io.Copy(f, ctxReader{ctx: ctx, r: r}); err != nil {
+		_ = f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		_ = f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp, file)
+}
+
+func (s *DirSink) Get(ctx context.Context, name string) (io.ReadCloser, error) {
+	if err := s.checkName(name); err != nil {
+		return nil, err
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return os.Open(filepath.Join(s.dir, filepath.FromSlash(name)))
+}
+
+func (s *DirSink) Delete(ctx context.Context, name string) error {
+	if err := s.checkName(name); err != nil {
+		return err
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return os.Remove(filepath.Join(s.dir, filepath.FromSlash(name)))
+}
+
+func (s *DirSink) List(ctx context.Context) ([]BackupObject, error) {
+	var objects []BackupObject
+	err := filepath.WalkDir(s.dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if !d.Type().IsRegular() || strings.HasSuffix(path, ".tmp") {
+			return nil
+		}
+		fi, err := d.Info()
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(s.dir, path)
+		if err != nil {
+			return err
+		}
+		objects = append(objects, BackupObject{Name: filepath.ToSlash(rel), Size: fi.Size()})
+		return nil
+	})
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	return objects, err
+}
+
+// checkName rejects names leaving the folder of s
+func (s *DirSink) checkName(name string) error {
+	if !fs.ValidPath(name) || name == "." {
+		return fmt.Errorf("invalid backup name %q", name)
+	}
+	return nil
+}
+
+// ctxReader stops reading r once ctx is done
+type ctxReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (r ctxReader) Read(p []byte) (int, error) {
+	if err := r.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return r.r.Read(p)
+}
+
+// prefixSink is a BackupSink storing its objects under a prefix of another
+type prefixSink struct {
+	sink   BackupSink
+	prefix string
+}
+
+// PrefixSink returns a BackupSink storing its objects in sink under prefix, e.g. "tenant_1/" to keep the backups of
+// several databases in one bucket. It implements BackupDeleter when sink does.
+func PrefixSink(sink BackupSink, prefix string) BackupSink {
+	return &prefixSink{sink: sink, prefix: prefix}
+}
+
+func (s *prefixSink) Put(ctx context.Context, name string, r io.Reader) error {
+	return s.sink.Put(ctx, s.prefix+name, r)
+}
+
+func (s *prefixSink) Get(ctx context.Context, name string) (io.ReadCloser, error) {
+	return s.sink.Get(ctx, s.prefix+name)
+}
+
+func (s *prefixSink) List(ctx context.Context) ([]BackupObject, error) {
+	objects, err := s.sink.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var own []BackupObject
+	for _, o := range objects {
+		if name, ok := strings.CutPrefix(o.Name, s.prefix); ok {
+			own = append(own, BackupObject{Name: name, Size: o.Size})
+		}
+	}
+	return own, nil
+}
+
+func (s *prefixSink) Delete(ctx context.Context, name string) error {
+	d, ok := s.sink.(BackupDeleter)
+	if !ok {
+		return fmt.Errorf("backup sink %T cannot delete", s.sink)
+	}
+	return d.Delete(ctx, s.prefix+name)
+}
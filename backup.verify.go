@@ -0,0 +1,181 @@
+package dbx
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/dialect"
+	"github.com/uptrace/bun/dialect/sqlitedialect"
+)
+
+// BackupVerification is the report of VerifyBackup
+type BackupVerification struct {
+	// Backup is the last backup of the restored chain
+	Backup BackupInfo
+	// Integrity is the result of PRAGMA integrity_check on the restored database, "ok" when it is sound
+	Integrity string
+	// Counts are the row counts compared with VerifyCounts
+	Counts []TableCount
+	// Passed is set when the integrity check and all the counts passed
+	Passed bool
+}
+
+// TableCount is the number of rows of a table in the restored backup and in the live database
+type TableCount struct {
+	Table string
+	// Backup is the count in the restored backup
+	Backup int64
+	// Live is the count in the live database, -1 when the table is missing there
+	Live int64
+}
+
+// Match reports whether the counts are equal
+func (c TableCount) Match() bool {
+	return c.Backup == c.Live
+}
+
+type verifyOptions struct {
+	at         time.Time
+	driverName DriverName
+	live       *bun.DB
+	tables     []string
+}
+
+type VerifyOptFn func(opt *verifyOptions)
+
+// VerifyAt verifies the backup restoring the state at t, instead of the last one
+func VerifyAt(t time.Time) VerifyOptFn {
+	return func(opt *verifyOptions) {
+		opt.at = t
+	}
+}
+
+// VerifyWithDriverName sets the SQLite driver opening the restored backup (default: DriverSQLite, or the driver of
+// the db of VerifyCounts)
+func VerifyWithDriverName(dn DriverName) VerifyOptFn {
+	return func(opt *verifyOptions) {
+		opt.driverName = dn
+	}
+}
+
+// VerifyCounts compares the row counts of tables, all the tables of the backup without any, between the restored
+// backup and live. Writes committed to live after the backup fail the comparison: verify right after backing up, or
+// name the tables not written since.
+func VerifyCounts(live *bun.DB, tables ...string) VerifyOptFn {
+	return func(opt *verifyOptions) {
+		opt.live = live
+		opt.tables = tables
+	}
+}
+
+// VerifyBackup restores the last backup of sink to a temporary file, runs PRAGMA integrity_check on it and, with
+// VerifyCounts, compares its row counts with the live database, since a backup that does not restore is no backup.
+// It reports a failed check in a BackupVerification that did not pass; an error means the backup could not be
+// restored or checked at all. The temporary file is removed before it returns.
+func VerifyBackup(ctx context.Context, sink BackupSink, opts ...VerifyOptFn) (*BackupVerification, error) {
+	opt := verifyOptions{at: time.Now(), driverName: DriverSQLite}
+	for _, fn := range opts {
+		fn(&opt)
+	}
+
+	tmp, err := os.MkdirTemp("", "dbx-verify-*")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(tmp)
+	file := filepath.Join(tmp, "restored.db")
+	info, err := RestoreDBToTime(ctx, sink, opt.at, file)
+	if err != nil {
+		return nil, err
+	}
+
+	restored, err := openRestored(file, opt)
+	if err != nil {
+		return nil, err
+	}
+	defer restored.Close()
+
+	report := &BackupVerification{Backup: *info}
+	if report.Integrity, err = integrityCheck(ctx, restored); err != nil {
+		return nil, err
+	}
+	if opt.live != nil {
+		if report.Counts, err = compareCounts(ctx, restored, opt.live, opt.tables); err != nil {
+			return nil, err
+		}
+	}
+
+	report.Passed = report.Integrity == "ok"
+	for _, c := range report.Counts {
+		report.Passed = report.Passed && c.Match()
+	}
+	return report, nil
+}
+
+// openRestored opens the restored file read-only, with the driver of the live db when it is SQLite
+func openRestored(file string, opt verifyOptions) (*bun.DB, error) {
+	var drv driver.Driver
+	if opt.live != nil && opt.live.Dialect().Name() == dialect.SQLite {
+		drv = opt.live.Driver()
+	} else {
+		// sql.Open does not connect, it only resolves the registered driver
+		probe, err := sql.Open(string(opt.driverName), "")
+		if err != nil {
+			return nil, err
+		}
+		drv = probe.Driver()
+		_ = probe.Close()
+	}
+	sqlDB := sql.OpenDB(&hookConnector{dsn: "file:" + file + "?mode=ro", driver: drv})
+	sqlDB.SetMaxOpenConns(1)
+	return bun.NewDB(sqlDB, sqlitedialect.New()), nil
+}
+
+// integrityCheck returns the result of PRAGMA integrity_check, its rows joined when it found problems
+func integrityCheck(ctx context.Context, db *bun.DB) (string, error) {
+	var rows []string
+	if err := db.NewRaw("PRAGMA integrity_check").Scan(ctx, &rows); err != nil {
+		return "", fmt.Errorf("failed to check restored backup: %w", err)
+	}
+	return strings.Join(rows, "; "), nil
+}
+
+// compareCounts counts the rows of tables, all the tables of restored without any, in restored and live
+func compareCounts(ctx context.Context, restored, live *bun.DB, tables []string) ([]TableCount, error) {
+	if len(tables) == 0 {
+		err := restored.NewRaw(`SELECT name FROM sqlite_master WHERE type = 'table' AND name NOT LIKE 'sqlite\_%' ESCAPE '\'
+			ORDER BY name`).Scan(ctx, &tables)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list restored tables: %w", err)
+		}
+	}
+
+	counts := make([]TableCount, 0, len(tables))
+	for _, table := range tables {
+		c := TableCount{Table: table}
+		if err := restored.NewSelect().TableExpr("?", bun.Ident(table)).ColumnExpr("COUNT(*)").
+			Scan(ctx, &c.Backup); err != nil {
+			return nil, fmt.Errorf("failed to count restored %s: %w", table, err)
+		}
+		exists, err := TableExists(ctx, live, table)
+		if err != nil {
+			return nil, err
+		}
+		c.Live = -1
+		if exists {
+			if err := live.NewSelect().TableExpr("?", bun.Ident(table)).ColumnExpr("COUNT(*)").
+				Scan(ctx, &c.Live); err != nil {
+				return nil, fmt.Errorf("failed to count live %s: %w", table, err)
+			}
+		}
+		counts = append(counts, c)
+	}
+	return counts, nil
+}
@@ -0,0 +1,58 @@
+package dbx
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestVerifyBackup(t *testing.T) {
+	ctx := context.Background()
+	db := openBackupTestDB(t)
+	sink := newMemSink()
+
+	if _, err := VerifyBackup(ctx, sink); !errors.Is(err, ErrBackupNotFound) {
+		t.Fatalf("expected ErrBackupNotFound without backups, got %v", err)
+	}
+
+	for range 5 {
+		insertItem(t, db, "base")
+	}
+	if _, err := BackupDB(ctx, db, sink); err != nil {
+		t.Fatalf("BackupDB failed: %v", err)
+	}
+	insertItem(t, db, "incr")
+	mustBackupIncremental(t, db, sink)
+
+	report, err := VerifyBackup(ctx, sink, VerifyCounts(db))
+	if err != nil {
+		t.Fatalf("VerifyBackup failed: %v", err)
+	}
+	if !report.Passed || report.Integrity != "ok" || report.Backup.Full {
+		t.Fatalf("expected the incremental backup to pass, got %+v", report)
+	}
+	if len(report.Counts) != 1 || report.Counts[0] != (TableCount{Table: "items", Backup: 6, Live: 6}) {
+		t.Fatalf("expected the counts of items, got %+v", report.Counts)
+	}
+
+	// Rows written after the backup fail the comparison
+	insertItem(t, db, "late")
+	report, err = VerifyBackup(ctx, sink, VerifyCounts(db, "items"))
+	if err != nil {
+		t.Fatalf("VerifyBackup failed: %v", err)
+	}
+	if report.Passed || report.Counts[0].Match() || report.Counts[0].Live != 7 {
+		t.Fatalf("expected the count comparison to fail, got %+v", report)
+	}
+
+	if _, err := db.Exec("DROP TABLE items"); err != nil {
+		t.Fatalf("drop table failed: %v", err)
+	}
+	report, err = VerifyBackup(ctx, sink, VerifyCounts(db))
+	if err != nil {
+		t.Fatalf("VerifyBackup failed: %v", err)
+	}
+	if report.Passed || report.Counts[0].Live != -1 {
+		t.Fatalf("expected the missing live table to fail, got %+v", report)
+	}
+}
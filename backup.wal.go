@@ -0,0 +1,286 @@
+package dbx
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+)
+
+// The WAL file format is described at https://www.sqlite.org/fileformat.html#the_write_ahead_log
+const (
+	walHeaderSize      = 32
+	walFrameHeaderSize = 24
+	walMagic           = 0x377f0682
+)
+
+// walRunsMagic starts the files of BackupIncremental
+var walRunsMagic = []byte("DBXWAL1\n")
+
+var be = binary.BigEndian
+
+// walHeader is the header of a WAL file, valid for the generation of frames written after the last checkpoint that
+// restarted it
+type walHeader struct {
+	raw          [walHeaderSize]byte
+	bigEndian    bool // of the checksums
+	pageSize     int
+	salt1, salt2 uint32
+	sum          [2]uint32
+}
+
+// parseWALHeader returns the header at the start of wal, false when it is missing or torn
+func parseWALHeader(wal []byte) (*walHeader, bool) {
+	if len(wal) < walHeaderSize || be.Uint32(wal)&^1 != walMagic {
+		return nil, false
+	}
+	h := &walHeader{
+		bigEndian: be.Uint32(wal)&1 == 1,
+		pageSize:  int(be.Uint32(wal[8:])),
+		salt1:     be.Uint32(wal[16:]),
+		salt2:     be.Uint32(wal[20:]),
+	}
+	if h.pageSize == 1 {
+		h.pageSize = 65536
+	}
+	copy(h.raw[:], wal)
+	h.sum = walChecksum(h.bigEndian, wal[:24], [2]uint32{})
+	if h.sum != [2]uint32{be.Uint32(wal[24:]), be.Uint32(wal[28:])} {
+		return nil, false
+	}
+	return h, true
+}
+
+func (h *walHeader) sameGeneration(o *walHeader) bool {
+	return o != nil && h.salt1 == o.salt1 && h.salt2 == o.salt2
+}
+
+func (h *walHeader) frameSize() int {
+	return walFrameHeaderSize + h.pageSize
+}
+
+// walChecksum extends the cumulative checksum sum of a WAL over b
+func walChecksum(bigEndian bool, b []byte, sum [2]uint32) [2]uint32 {
+	var order binary.ByteOrder = binary.LittleEndian
+	if bigEndian {
+		order = binary.BigEndian
+	}
+	s0, s1 := sum[0], sum[1]
+	for i := 0; i+8 <= len(b); i += 8 {
+		s0 += order.Uint32(b[i:]) + s1
+		s1 += order.Uint32(b[i+4:]) + s0
+	}
+	return [2]uint32{s0, s1}
+}
+
+// walRun is a sequence of frames of one WAL generation, ending with a commit frame
+type walRun struct {
+	header *walHeader
+	// first is the index of the first frame in the generation, from 1
+	first int
+	// seed is the checksum after the frame before first, the checksum of the header for the first frame
+	seed   [2]uint32
+	frames []byte
+}
+
+func (r *walRun) count() int {
+	return len(r.frames) / r.header.frameSize()
+}
+
+// last returns the index of the last frame, first-1 for an empty run
+func (r *walRun) last() int {
+	return r.first + r.count() - 1
+}
+
+// sum returns the checksum after the last frame
+func (r *walRun) sum() [2]uint32 {
+	if len(r.frames) == 0 {
+		return r.seed
+	}
+	f := r.frames[len(r.frames)-r.header.frameSize():]
+	return [2]uint32{be.Uint32(f[16:]), be.Uint32(f[20:])}
+}
+
+// continues reports whether r follows prev in the same generation, or starts a generation
+func (r *walRun) continues(prev *walRun) bool {
+	if r.first == 1 && r.seed == r.header.sum {
+		return true
+	}
+	return r.header.sameGeneration(prev.header) && r.first == prev.last()+1 && r.seed == prev.sum()
+}
+
+// readWALRun returns the committed frames of the generation of h in wal from frame first on, seed being the
+// checksum after the frame before it. The run ends at the last commit frame before a frame of another generation,
+// a torn frame or the end of wal.
+func readWALRun(wal []byte, h *walHeader, first int, seed [2]uint32) *walRun {
+	size := h.frameSize()
+	start := walHeaderSize + (first-1)*size
+	run := &walRun{header: h, first: first, seed: seed}
+	sum, end := seed, start
+	for off := start; off+size <= len(wal); off += size {
+		f := wal[off : off+size]
+		if be.Uint32(f[8:]) != h.salt1 || be.Uint32(f[12:]) != h.salt2 {
+			break
+		}
+		sum = walChecksum(h.bigEndian, f[walFrameHeaderSize:], walChecksum(h.bigEndian, f[:8], sum))
+		if sum != [2]uint32{be.Uint32(f[16:]), be.Uint32(f[20:])} {
+			break
+		}
+		if be.Uint32(f[4:]) != 0 {
+			end = off + size
+		}
+	}
+	run.frames = wal[start:end]
+	return run
+}
+
+// walFrameSum returns the checksum stored in frame i of the generation of h in wal, false when the frame is not
+// there or belongs to another generation
+func walFrameSum(wal []byte, h *walHeader, i int) ([2]uint32, bool) {
+	if i == 0 {
+		return h.sum, true
+	}
+	off := walHeaderSize + (i-1)*h.frameSize()
+	if off+h.frameSize() > len(wal) {
+		return [2]uint32{}, false
+	}
+	f := wal[off:]
+	if be.Uint32(f[8:]) != h.salt1 || be.Uint32(f[12:]) != h.salt2 {
+		return [2]uint32{}, false
+	}
+	return [2]uint32{be.Uint32(f[16:]), be.Uint32(f[20:])}, true
+}
+
+// verify checks the checksums of the frames of r
+func (r *walRun) verify() error {
+	size, sum := r.header.frameSize(), r.seed
+	for off := 0; off < len(r.frames); off += size {
+		f := r.frames[off : off+size]
+		sum = walChecksum(r.header.bigEndian, f[walFrameHeaderSize:], walChecksum(r.header.bigEndian, f[:8], sum))
+		if sum != [2]uint32{be.Uint32(f[16:]), be.Uint32(f[20:])} {
+			return fmt.Errorf("bad checksum in wal frame %d", r.first+off/size)
+		}
+	}
+	return nil
+}
+
+// apply writes the pages of the frames of r to the database file f, as a checkpoint would, truncating it to the
+// size of the database at each commit
+func (r *walRun) apply(f *os.File) error {
+	size, pageSize := r.header.frameSize(), int64(r.header.pageSize)
+	for off := 0; off < len(r.frames); off += size {
+		frame := r.frames[off : off+size]
+		if _, err := f.WriteAt(frame[walFrameHeaderSize:], int64(be.Uint32(frame)-1)*pageSize); err != nil {
+			return err
+		}
+		if pages := be.Uint32(frame[4:]); pages != 0 {
+			if err := f.Truncate(int64(pages) * pageSize); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// encodeWALRuns returns the content of an incremental backup holding runs
+func encodeWALRuns(runs []*walRun) []byte {
+	var buf bytes.Buffer
+	buf.Write(walRunsMagic)
+	for _, r := range runs {
+		buf.Write(r.header.raw[:])
+		buf.Write(be.AppendUint32(nil, uint32(r.first)))
+		buf.Write(be.AppendUint32(nil, r.seed[0]))
+		buf.Write(be.AppendUint32(nil, r.seed[1]))
+		buf.Write(be.AppendUint32(nil, uint32(r.count())))
+		buf.Write(r.frames)
+	}
+	return buf.Bytes()
+}
+
+// decodeWALRuns parses the content of an incremental backup and verifies the checksums of its frames
+func decodeWALRuns(b []byte) ([]*walRun, error) {
+	if !bytes.HasPrefix(b, walRunsMagic) {
+		return nil, errors.New("not an incremental backup")
+	}
+	b = b[len(walRunsMagic):]
+	var runs []*walRun
+	for len(b) > 0 {
+		if len(b) < walHeaderSize+16 {
+			return nil, errors.New("truncated incremental backup")
+		}
+		h, ok := parseWALHeader(b)
+		if !ok {
+			return nil, errors.New("bad wal header in incremental backup")
+		}
+		r := &walRun{header: h, first: int(be.Uint32(b[32:])), seed: [2]uint32{be.Uint32(b[36:]), be.Uint32(b[40:])}}
+		n := int(be.Uint32(b[44:])) * h.frameSize()
+		b = b[walHeaderSize+16:]
+		if len(b) < n {
+			return nil, errors.New("truncated incremental backup")
+		}
+		r.frames, b = b[:n], b[n:]
+		if err := r.verify(); err != nil {
+			return nil, err
+		}
+		runs = append(runs, r)
+	}
+	return runs, nil
+}
+
+// writeDBSnapshot writes to w the database file f with the pages of the frames of run, as a checkpoint would, and
+// returns the number of bytes written. run may be nil.
+func writeDBSnapshot(ctx context.Context, w io.Writer, f *os.File, run *walRun) (int64, error) {
+	fi, err := f.Stat()
+	if err != nil {
+		return 0, err
+	}
+	var pageSize, pages int64
+	latest := map[int64][]byte{}
+	if run != nil && run.count() > 0 {
+		size := run.header.frameSize()
+		pageSize = int64(run.header.pageSize)
+		for off := 0; off < len(run.frames); off += size {
+			frame := run.frames[off : off+size]
+			latest[int64(be.Uint32(frame))] = frame[walFrameHeaderSize:]
+			if n := be.Uint32(frame[4:]); n != 0 {
+				pages = int64(n)
+			}
+		}
+	} else if fi.Size() > 0 {
+		// The page size is at offset 16 of the database header, 1 meaning 65536
+		var hdr [2]byte
+		if _, err := f.ReadAt(hdr[:], 16); err != nil {
+			return 0, fmt.Errorf("failed to read database header: %w", err)
+		}
+		if pageSize = int64(be.Uint16(hdr[:])); pageSize == 1 {
+			pageSize = 65536
+		}
+		pages = fi.Size() / pageSize
+	}
+
+	var written int64
+	buf := make([]byte, pageSize)
+	for p := int64(1); p <= pages; p++ {
+		if p%256 == 0 {
+			if err := ctx.Err(); err != nil {
+				return written, err
+			}
+		}
+		page, ok := latest[p]
+		if !ok {
+			if _, err := f.ReadAt(buf, (p-1)*pageSize); err != nil {
+				return written, fmt.Errorf("failed to read page %d: %w", p, err)
+			}
+			page = buf
+		}
+		n, err := w.Write(page)
+		written += int64(n)
+		if err != nil {
+			return written, err
+		}
+	}
+	return written, nil
+}
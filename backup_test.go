@@ -0,0 +1,277 @@
+package dbx
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/binary"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/uptrace/bun"
+)
+
+// memSink is a BackupSink in memory, like an object storage
+type memSink struct {
+	mu      sync.Mutex
+	objects map[string][]byte
+}
+
+func newMemSink() *memSink {
+	return &memSink{objects: map[string][]byte{}}
+}
+
+func (s *memSink) Put(ctx context.Context, name string, r io.Reader) error {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.objects[name] = data
+	return nil
+}
+
+func (s *memSink) Get(ctx context.Context, name string) (io.ReadCloser, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	data, ok := s.objects[name]
+	if !ok {
+		return nil, fs.ErrNotExist
+	}
+	return io.NopCloser(bytes.NewReader(data)), nil
+}
+
+func (s *memSink) List(ctx context.Context) ([]BackupObject, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var objects []BackupObject
+	for name, data := range s.objects {
+		objects = append(objects, BackupObject{Name: name, Size: int64(len(data))})
+	}
+	return objects, nil
+}
+
+func openBackupTestDB(t *testing.T) *bun.DB {
+	t.Helper()
+	tmp := t.TempDir()
+	dbFolder = tmp
+	db, err := OpenDB(filepath.Join(tmp, "live.sqlite"), WithDbFolder(tmp), WithCreateIfMissing(),
+		WithMaxOpenConns(4), WithMaxIdleConns(4))
+	if err != nil {
+		t.Fatalf("OpenDB failed: %v", err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+	if _, err := db.Exec("CREATE TABLE items (id INTEGER PRIMARY KEY AUTOINCREMENT, name TEXT NOT NULL)"); err != nil {
+		t.Fatalf("create table failed: %v", err)
+	}
+	return db
+}
+
+// restoredCount restores sink and returns the number of items of the restored database
+func restoredCount(t *testing.T, sink BackupSink) int {
+	t.Helper()
+	dst := filepath.Join(t.TempDir(), "restored.sqlite")
+	if err := RestoreDB(context.Background(), sink, dst); err != nil {
+		t.Fatalf("RestoreDB failed: %v", err)
+	}
+	db, err := sql.Open(string(DriverSQLite), dst)
+	if err != nil {
+		t.Fatalf("open restored db failed: %v", err)
+	}
+	defer db.Close()
+	var check string
+	if err := db.QueryRow("PRAGMA integrity_check").Scan(&check); err != nil || check != "ok" {
+		t.Fatalf("restored integrity_check: %q (err %v)", check, err)
+	}
+	var n int
+	if err := db.QueryRow("SELECT COUNT(*) FROM items").Scan(&n); err != nil {
+		t.Fatalf("count restored failed: %v", err)
+	}
+	return n
+}
+
+func mustBackupIncremental(t *testing.T, db *bun.DB, sink BackupSink) *BackupInfo {
+	t.Helper()
+	info, err := BackupIncremental(context.Background(), db, sink)
+	if err != nil {
+		t.Fatalf("BackupIncremental failed: %v", err)
+	}
+	return info
+}
+
+func TestBackupIncremental_Restore(t *testing.T) {
+	ctx := context.Background()
+	db := openBackupTestDB(t)
+	sink := NewDirSink(filepath.Join(t.TempDir(), "backups"))
+	for range 5 {
+		insertItem(t, db, "base")
+	}
+
+	full, err := BackupDB(ctx, db, sink)
+	if err != nil {
+		t.Fatalf("BackupDB failed: %v", err)
+	}
+	if !full.Full {
+		t.Fatalf("expected a full backup, got %+v", full)
+	}
+	if info := mustBackupIncremental(t, db, sink); info != nil {
+		t.Fatalf("expected no backup without writes, got %+v", info)
+	}
+
+	for range 10 {
+		insertItem(t, db, "incr")
+	}
+	info := mustBackupIncremental(t, db, sink)
+	if info == nil || info.Full || info.Frames == 0 {
+		t.Fatalf("expected an incremental backup with frames, got %+v", info)
+	}
+	if info := mustBackupIncremental(t, db, sink); info != nil {
+		t.Fatalf("expected no backup without writes, got %+v", info)
+	}
+
+	if _, err := db.Exec("DELETE FROM items WHERE id <= 3"); err != nil {
+		t.Fatalf("delete failed: %v", err)
+	}
+	insertItem(t, db, "last")
+	if info := mustBackupIncremental(t, db, sink); info == nil || info.Full {
+		t.Fatalf("expected an incremental backup, got %+v", info)
+	}
+
+	if got, want := restoredCount(t, sink), countItems(t, db); got != want {
+		t.Fatalf("restored %d items, expected %d", got, want)
+	}
+	backups, err := ListBackups(ctx, sink)
+	if err != nil || len(backups) != 3 {
+		t.Fatalf("expected 3 backups, got %v (err %v)", backups, err)
+	}
+}
+
+func TestBackupIncremental_WALRestart(t *testing.T) {
+	db := openBackupTestDB(t)
+	sink := newMemSink()
+	if info := mustBackupIncremental(t, db, sink); info == nil || !info.Full {
+		t.Fatalf("expected a full backup to start the chain, got %+v", info)
+	}
+
+	// The backup checkpointed the WAL, so the next write restarts it: the chain goes on
+	for range 50 {
+		insertItem(t, db, "restarted")
+	}
+	if info := mustBackupIncremental(t, db, sink); info == nil || info.Full {
+		t.Fatalf("expected an incremental backup across the restart, got %+v", info)
+	}
+	if got, want := restoredCount(t, sink), countItems(t, db); got != want {
+		t.Fatalf("restored %d items, expected %d", got, want)
+	}
+
+	// A second restart overwrites frames not backed up yet: a full backup starts a new chain
+	for range 50 {
+		insertItem(t, db, "lost")
+	}
+	if _, err := db.Exec("PRAGMA wal_checkpoint(RESTART)"); err != nil {
+		t.Fatalf("checkpoint failed: %v", err)
+	}
+	insertItem(t, db, "overwriting")
+	if info := mustBackupIncremental(t, db, sink); info == nil || !info.Full {
+		t.Fatalf("expected a full backup after frames were lost, got %+v", info)
+	}
+	insertItem(t, db, "after")
+	if info := mustBackupIncremental(t, db, sink); info == nil || info.Full {
+		t.Fatalf("expected an incremental backup, got %+v", info)
+	}
+	if got, want := restoredCount(t, sink), countItems(t, db); got != want {
+		t.Fatalf("restored %d items, expected %d", got, want)
+	}
+
+	// A truncated WAL lost its frames too
+	insertItem(t, db, "lost")
+	if _, err := db.Exec("PRAGMA wal_checkpoint(TRUNCATE)"); err != nil {
+		t.Fatalf("checkpoint failed: %v", err)
+	}
+	if info := mustBackupIncremental(t, db, sink); info == nil || !info.Full {
+		t.Fatalf("expected a full backup after the WAL was truncated, got %+v", info)
+	}
+	if got, want := restoredCount(t, sink), countItems(t, db); got != want {
+		t.Fatalf("restored %d items, expected %d", got, want)
+	}
+}
+
+func TestRestoreDB_NoBackup(t *testing.T) {
+	err := RestoreDB(context.Background(), NewDirSink(t.TempDir()), filepath.Join(t.TempDir(), "x.sqlite"))
+	if !errors.Is(err, ErrBackupNotFound) {
+		t.Fatalf("expected ErrBackupNotFound, got %v", err)
+	}
+}
+
+func TestDirSink_Names(t *testing.T) {
+	ctx := context.Background()
+	sink := NewDirSink(filepath.Join(t.TempDir(), "backups"))
+	if objects, err := sink.List(ctx); err != nil || len(objects) != 0 {
+		t.Fatalf("expected an empty sink before the folder exists, got %v (err %v)", objects, err)
+	}
+	for _, name := range []string{"", ".", "..", "../x", "/x", "a/../b"} {
+		if err := sink.Put(ctx, name, bytes.NewReader(nil)); err == nil {
+			t.Fatalf("expected Put(%q) to fail", name)
+		}
+	}
+	if err := sink.Put(ctx, "a", bytes.NewReader([]byte("abc"))); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+	objects, err := sink.List(ctx)
+	if err != nil || len(objects) != 1 || objects[0] != (BackupObject{Name: "a", Size: 3}) {
+		t.Fatalf("unexpected objects %v (err %v)", objects, err)
+	}
+	if _, err := sink.Get(ctx, "missing"); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("expected fs.ErrNotExist, got %v", err)
+	}
+}
+
+func TestBackupState_MainUnchanged(t *testing.T) {
+	ctx := context.Background()
+	db := openBackupTestDB(t)
+	sink := newMemSink()
+	mustBackupIncremental(t, db, sink)
+	state, err := loadBackupState(ctx, sink)
+	if err != nil || state == nil {
+		t.Fatalf("expected a backup state, got %+v (err %v)", state, err)
+	}
+	conn, src, err := backupConn(ctx, db)
+	if err != nil {
+		t.Fatalf("backupConn failed: %v", err)
+	}
+	_ = conn.Close()
+	if unchanged, err := state.mainUnchanged(src); err != nil || !unchanged {
+		t.Fatalf("expected the main file unchanged, got %v (err %v)", unchanged, err)
+	}
+
+	// A write keeping the size and the time of the file, as a copy preserving them would, still bumps the counter
+	fi, err := os.Stat(src)
+	if err != nil {
+		t.Fatalf("stat failed: %v", err)
+	}
+	f, err := os.OpenFile(src, os.O_RDWR, 0)
+	if err != nil {
+		t.Fatalf("open failed: %v", err)
+	}
+	var counter [4]byte
+	binary.BigEndian.PutUint32(counter[:], state.MainCounter+1)
+	_, err = f.WriteAt(counter[:], 24)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		t.Fatalf("write failed: %v", err)
+	}
+	if err := os.Chtimes(src, fi.ModTime(), fi.ModTime()); err != nil {
+		t.Fatalf("chtimes failed: %v", err)
+	}
+	if unchanged, err := state.mainUnchanged(src); err != nil || unchanged {
+		t.Fatalf("expected the change counter to reveal the write, got %v (err %v)", unchanged, err)
+	}
+}
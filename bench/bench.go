@@ -0,0 +1,314 @@
+// Package bench holds reproducible benchmarks of dbx: insert throughput, read QPS, transaction overhead and
+// Cache contention. RunBench runs them against a fresh SQLite database and returns a Report that marshals to JSON,
+// so the results of two revisions can be stored and compared when measuring a performance-affecting change.
+package bench
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/actanonv/dbx"
+	"github.com/uptrace/bun"
+)
+
+var ErrUnknownBenchmark = errors.New("unknown benchmark")
+
+// Profile sets the size of a run. The same profile on the same machine runs the same workload,
+// so results are comparable between revisions.
+type Profile struct {
+	Name       string   `json:"name"`
+	Ops        int      `json:"ops"`        // operations per benchmark
+	Workers    int      `json:"workers"`    // concurrent goroutines of the read and cache benchmarks
+	Rows       int      `json:"rows"`       // rows loaded before the read benchmark
+	Benchmarks []string `json:"benchmarks"` // benchmarks to run, all when empty
+	DbFolder   string   `json:"-"`          // parent folder of the database files, the temporary folder when empty
+}
+
+var (
+	// Quick is small enough to run in tests and CI
+	Quick = Profile{Name: "quick", Ops: 200, Workers: 4, Rows: 100}
+	// Full gives stable numbers for comparing revisions
+	Full = Profile{Name: "full", Ops: 20000, Workers: 8, Rows: 10000}
+)
+
+// Result is the measurement of a single benchmark
+type Result struct {
+	Name      string        `json:"name"`
+	Ops       int           `json:"ops"`
+	Elapsed   time.Duration `json:"elapsed_ns"`
+	NsPerOp   float64       `json:"ns_per_op"`
+	OpsPerSec float64       `json:"ops_per_sec"`
+}
+
+// Report is the outcome of RunBench
+type Report struct {
+	Profile   Profile   `json:"profile"`
+	GoVersion string    `json:"go_version"`
+	GOOS      string    `json:"goos"`
+	GOARCH    string    `json:"goarch"`
+	NumCPU    int       `json:"num_cpu"`
+	Started   time.Time `json:"started"`
+	Results   []Result  `json:"results"`
+}
+
+// WriteJSON writes r as indented JSON
+func (r *Report) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(r)
+}
+
+// Benchmark runs ops operations of one workload on db
+type Benchmark func(ctx context.Context, env *Env, ops int) error
+
+// Env is the state shared by the benchmarks of a run
+type Env struct {
+	DB       *bun.DB
+	Cache    *dbx.Cache
+	DbFolder string
+	Profile  Profile
+}
+
+type benchItem struct {
+	bun.BaseModel `bun:"table:bench_items"`
+
+	ID      int64  `bun:"id,pk,autoincrement"`
+	Name    string `bun:"name,notnull"`
+	Payload string `bun:"payload,notnull"`
+}
+
+// Benchmarks are the benchmarks run by RunBench, in order
+var Benchmarks = []struct {
+	Name string
+	Fn   Benchmark
+}{
+	{"insert", Insert},
+	{"insert_tx", InsertTx},
+	{"read", Read},
+	{"tx_overhead", TxOverhead},
+	{"cache_contention", CacheContention},
+}
+
+// RunBench runs the benchmarks of profile on a fresh SQLite database and returns their results.
+// As with dbx itself, the caller imports the SQLite driver.
+func RunBench(ctx context.Context, profile Profile) (*Report, error) {
+	if profile.Ops <= 0 {
+		profile.Ops = Quick.Ops
+	}
+	if profile.Workers <= 0 {
+		profile.Workers = runtime.GOMAXPROCS(0)
+	}
+
+	selected := make(map[string]bool, len(profile.Benchmarks))
+	for _, name := range profile.Benchmarks {
+		if !knownBenchmark(name) {
+			return nil, fmt.Errorf("%w: %s", ErrUnknownBenchmark, name)
+		}
+		selected[name] = true
+	}
+
+	env, cleanup, err := NewEnv(ctx, profile)
+	if err != nil {
+		return nil, err
+	}
+	defer cleanup()
+
+	report := &Report{
+		Profile:   profile,
+		GoVersion: runtime.Version(),
+		GOOS:      runtime.GOOS,
+		GOARCH:    runtime.GOARCH,
+		NumCPU:    runtime.NumCPU(),
+		Started:   time.Now().UTC(),
+	}
+	for _, b := range Benchmarks {
+		if len(selected) > 0 && !selected[b.Name] {
+			continue
+		}
+		runtime.GC()
+		start := time.Now()
+		if err := b.Fn(ctx, env, profile.Ops); err != nil {
+			return nil, fmt.Errorf("benchmark %s failed: %w", b.Name, err)
+		}
+		report.Results = append(report.Results, newResult(b.Name, profile.Ops, time.Since(start)))
+	}
+	return report, nil
+}
+
+// NewEnv creates the benchmark database in a new folder under profile.DbFolder (the temporary folder when empty)
+// and loads profile.Rows rows. The returned function closes the databases and removes the folder.
+func NewEnv(ctx context.Context, profile Profile) (*Env, func(), error) {
+	folder, err := os.MkdirTemp(profile.DbFolder, "dbx-bench-*")
+	if err != nil {
+		return nil, nil, err
+	}
+
+	env := &Env{DbFolder: folder, Profile: profile, Cache: dbx.NewCache(time.Hour)}
+	cleanup := func() {
+		if env.DB != nil {
+			_ = env.DB.Close()
+		}
+		_ = env.Cache.Close()
+		_ = os.RemoveAll(folder)
+	}
+
+	if err := dbx.CreateDBContext(ctx, "bench", dbx.CreateWithDbFolder(folder)); err != nil {
+		cleanup()
+		return nil, nil, fmt.Errorf("failed to create bench db: %w", err)
+	}
+	db, err := dbx.OpenDBContext(ctx, "bench", dbx.WithDbFolder(folder))
+	if err != nil {
+		cleanup()
+		return nil, nil, fmt.Errorf("failed to open bench db: %w", err)
+	}
+	env.DB = db
+
+	if _, err := db.NewCreateTable().Model((*benchItem)(nil)).Exec(ctx); err != nil {
+		cleanup()
+		return nil, nil, err
+	}
+	if profile.Rows > 0 {
+		items := make([]benchItem, profile.Rows)
+		for i := range items {
+			items[i] = benchItem{Name: fmt.Sprintf("item-%d", i), Payload: payload}
+		}
+		if _, err := db.NewInsert().Model(&items).Exec(ctx); err != nil {
+			cleanup()
+			return nil, nil, fmt.Errorf("failed to load bench rows: %w", err)
+		}
+	}
+	return env, cleanup, nil
+}
+
+// Insert inserts ops rows, one autocommitted statement each
+func Insert(ctx context.Context, env *Env, ops int) error {
+	for i := range ops {
+		item := &benchItem{Name: fmt.Sprintf("insert-%d", i), Payload: payload}
+		if _, err := env.DB.NewInsert().Model(item).Exec(ctx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// InsertTx inserts ops rows in a single transaction
+func InsertTx(ctx context.Context, env *Env, ops int) error {
+	return env.DB.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		for i := range ops {
+			item := &benchItem{Name: fmt.Sprintf("insert-tx-%d", i), Payload: payload}
+			if _, err := tx.NewInsert().Model(item).Exec(ctx); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Read selects ops rows by primary key from Workers goroutines
+func Read(ctx context.Context, env *Env, ops int) error {
+	rows := max(env.Profile.Rows, 1)
+	return parallel(env.Profile.Workers, ops, func(i int) error {
+		var item benchItem
+		err := env.DB.NewSelect().Model(&item).Where("id = ?", i%rows+1).Scan(ctx)
+		if err != nil && env.Profile.Rows > 0 {
+			return err
+		}
+		return nil
+	})
+}
+
+// TxOverhead runs ops empty Transact transactions, each with a nested savepoint, measuring the bookkeeping of
+// Transact rather than the work done in it
+func TxOverhead(ctx context.Context, env *Env, ops int) error {
+	t, err := dbx.NewTransact(ctx, env.DB)
+	if err != nil {
+		return err
+	}
+	for range ops {
+		err := t.Transaction(nil, func(ctx context.Context) error {
+			return t.Transaction(nil, func(ctx context.Context) error {
+				_ = t.Db()
+				return nil
+			})
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// CacheContention calls Cache.GetOrOpen ops times from Workers goroutines on a handful of databases
+func CacheContention(ctx context.Context, env *Env, ops int) error {
+	const dbs = 4
+	for i := range dbs {
+		if err := dbx.CreateDBContext(ctx, cacheDBName(i), dbx.CreateWithDbFolder(env.DbFolder)); err != nil {
+			return err
+		}
+	}
+	return parallel(env.Profile.Workers, ops, func(i int) error {
+		_, err := env.Cache.GetOrOpen(cacheDBName(i%dbs), dbx.WithDbFolder(env.DbFolder))
+		return err
+	})
+}
+
+func cacheDBName(i int) string {
+	return fmt.Sprintf("bench-cache-%d", i)
+}
+
+// parallel calls fn ops times from workers goroutines and returns the first error
+func parallel(workers, ops int, fn func(i int) error) error {
+	var (
+		next     atomic.Int64
+		wg       sync.WaitGroup
+		errOnce  sync.Once
+		firstErr error
+	)
+	for range max(workers, 1) {
+		wg.Go(func() {
+			for {
+				i := int(next.Add(1)) - 1
+				if i >= ops {
+					return
+				}
+				if err := fn(i); err != nil {
+					errOnce.Do(func() { firstErr = err })
+					next.Store(int64(ops))
+					return
+				}
+			}
+		})
+	}
+	wg.Wait()
+	return firstErr
+}
+
+func newResult(name string, ops int, elapsed time.Duration) Result {
+	r := Result{Name: name, Ops: ops, Elapsed: elapsed}
+	if ops > 0 {
+		r.NsPerOp = float64(elapsed.Nanoseconds()) / float64(ops)
+	}
+	if elapsed > 0 {
+		r.OpsPerSec = float64(ops) / elapsed.Seconds()
+	}
+	return r
+}
+
+func knownBenchmark(name string) bool {
+	for _, b := range Benchmarks {
+		if b.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+const payload = "Lorem ipsum dolor sit amet, consectetur adipiscing elit, sed do eiusmod tempor incididunt ut labore"
@@ -0,0 +1,76 @@
+package bench
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+func TestRunBench(t *testing.T) {
+	ctx := context.Background()
+	profile := Quick
+	profile.Ops = 20
+	profile.DbFolder = t.TempDir()
+
+	report, err := RunBench(ctx, profile)
+	if err != nil {
+		t.Fatalf("RunBench failed: %v", err)
+	}
+	if len(report.Results) != len(Benchmarks) {
+		t.Fatalf("expected %d results, got %d", len(Benchmarks), len(report.Results))
+	}
+	for i, r := range report.Results {
+		if r.Name != Benchmarks[i].Name || r.Ops != 20 || r.Elapsed <= 0 || r.OpsPerSec <= 0 {
+			t.Fatalf("unexpected result %+v", r)
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := report.WriteJSON(&buf); err != nil {
+		t.Fatalf("WriteJSON failed: %v", err)
+	}
+	var decoded Report
+	if err := json.Unmarshal(buf.Bytes(), &decoded); err != nil {
+		t.Fatalf("invalid JSON report: %v", err)
+	}
+	if decoded.Profile.Name != "quick" || len(decoded.Results) != len(Benchmarks) {
+		t.Fatalf("unexpected decoded report %+v", decoded)
+	}
+
+	// A subset of the benchmarks
+	profile.Benchmarks = []string{"read"}
+	if report, err = RunBench(ctx, profile); err != nil || len(report.Results) != 1 {
+		t.Fatalf("expected a single result, got %v (err %v)", report, err)
+	}
+
+	profile.Benchmarks = []string{"nope"}
+	if _, err := RunBench(ctx, profile); !errors.Is(err, ErrUnknownBenchmark) {
+		t.Fatalf("expected ErrUnknownBenchmark, got %v", err)
+	}
+}
+
+func BenchmarkInsert(b *testing.B)          { runBenchmark(b, Insert) }
+func BenchmarkInsertTx(b *testing.B)        { runBenchmark(b, InsertTx) }
+func BenchmarkRead(b *testing.B)            { runBenchmark(b, Read) }
+func BenchmarkTxOverhead(b *testing.B)      { runBenchmark(b, TxOverhead) }
+func BenchmarkCacheContention(b *testing.B) { runBenchmark(b, CacheContention) }
+
+func runBenchmark(b *testing.B, fn Benchmark) {
+	ctx := context.Background()
+	profile := Quick
+	profile.DbFolder = b.TempDir()
+	env, cleanup, err := NewEnv(ctx, profile)
+	if err != nil {
+		b.Fatalf("NewEnv failed: %v", err)
+	}
+	defer cleanup()
+
+	b.ResetTimer()
+	if err := fn(ctx, env, b.N); err != nil {
+		b.Fatal(err)
+	}
+}
@@ -0,0 +1,159 @@
+package dbx
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/dialect"
+)
+
+// Schedule returns the next time a job is due after t, the zero time when it never is again.
+// The *Schedule of scheduler.ParseCron is one; Interval returns a fixed one.
+type Schedule interface {
+	Next(t time.Time) time.Time
+}
+
+type interval time.Duration
+
+func (d interval) Next(t time.Time) time.Time {
+	return t.Add(time.Duration(d))
+}
+
+// Interval returns a Schedule due every d
+func Interval(d time.Duration) Schedule {
+	return interval(d)
+}
+
+// BackupRetention decides when EnableAutoBackup starts a new chain of backups and how many it keeps
+type BackupRetention struct {
+	// FullEvery starts a new chain with a full backup once the last full backup is older (0: only when the chain
+	// breaks)
+	FullEvery time.Duration
+	// Keep is the number of chains kept, the older ones are deleted with PruneBackups (0: keep them all)
+	Keep int
+}
+
+// BackupEvent reports a scheduled backup of a cached database
+type BackupEvent struct {
+	DB string
+	// Info is the backup written, nil when nothing changed since the last one or on failure
+	Info *BackupInfo
+	// Pruned is the number of old backups deleted
+	Pruned int
+	Err    error
+}
+
+// OnBackup sets fn to receive the BackupEvent of every scheduled backup, to alert on failures.
+// Without one, failures are logged with slog.
+func (c *Cache) OnBackup(fn func(BackupEvent)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.onBackup = fn
+}
+
+// EnableAutoBackup backs up every cached SQLite database to sink when schedule is due, each under the prefix of its
+// name ("tenant_1/"): an incremental backup, or a full one to start a new chain as decided by retention, after which
+// the older chains are pruned. It replaces the previous schedule; Close stops it.
+func (c *Cache) EnableAutoBackup(schedule Schedule, sink BackupSink, retention BackupRetention) error {
+	if schedule == nil || sink == nil {
+		return fmt.Errorf("%w: auto backup needs a schedule and a sink", ErrInvalidOptions)
+	}
+	if _, ok := sink.(BackupDeleter); retention.Keep > 0 && !ok {
+		return fmt.Errorf("%w: backup sink %T cannot delete, needed to keep %d chains", ErrInvalidOptions, sink,
+			retention.Keep)
+	}
+	if retention.Keep < 0 || retention.FullEvery < 0 {
+		return fmt.Errorf("%w: negative backup retention", ErrInvalidOptions)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	select {
+	case <-c.quit:
+		return ErrCacheClosed
+	default:
+	}
+	if c.stopAutoBackup != nil {
+		c.stopAutoBackup()
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	c.stopAutoBackup = cancel
+	go func() {
+		select {
+		case <-c.quit:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	go c.autoBackup(ctx, schedule, sink, retention)
+	return nil
+}
+
+func (c *Cache) autoBackup(ctx context.Context, schedule Schedule, sink BackupSink, retention BackupRetention) {
+	for {
+		next := schedule.Next(time.Now())
+		if next.IsZero() {
+			return
+		}
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+		for _, e := range c.entries() {
+			if ctx.Err() != nil {
+				return
+			}
+			if e.db.Dialect().Name() != dialect.SQLite {
+				continue
+			}
+			c.reportBackup(backupCached(ctx, e.name, e.db, PrefixSink(sink, e.name+"/"), retention))
+		}
+	}
+}
+
+// backupCached backs up db to sink as decided by retention
+func backupCached(ctx context.Context, name string, db *bun.DB, sink BackupSink, retention BackupRetention) BackupEvent {
+	ev := BackupEvent{DB: name}
+	full := false
+	if retention.FullEvery > 0 {
+		backups, err := ListBackups(ctx, sink)
+		if err != nil {
+			ev.Err = err
+			return ev
+		}
+		full = true
+		for _, b := range backups {
+			if b.Full && time.Since(b.Created) < retention.FullEvery {
+				full = false
+			}
+		}
+	}
+	if full {
+		ev.Info, ev.Err = BackupDB(ctx, db, sink)
+	} else {
+		ev.Info, ev.Err = BackupIncremental(ctx, db, sink)
+	}
+	if ev.Err == nil && ev.Info != nil && ev.Info.Full && retention.Keep > 0 {
+		ev.Pruned, ev.Err = PruneBackups(ctx, sink, retention.Keep)
+	}
+	return ev
+}
+
+func (c *Cache) reportBackup(ev BackupEvent) {
+	c.mu.Lock()
+	fn := c.onBackup
+	c.mu.Unlock()
+	if fn != nil {
+		fn(ev)
+		return
+	}
+	if ev.Err != nil {
+		slog.Error("scheduled backup", "name", ev.DB, "err", ev.Err.Error())
+	}
+}
@@ -0,0 +1,73 @@
+package dbx
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestCache_EnableAutoBackup(t *testing.T) {
+	ctx := context.Background()
+	tmp := t.TempDir()
+	c := NewCache(30 * time.Minute)
+	defer c.Close()
+	for _, name := range []string{"t1", "t2"} {
+		db, err := c.GetOrOpen(name, WithDbFolder(tmp), WithCreateIfMissing())
+		if err != nil {
+			t.Fatalf("GetOrOpen failed: %v", err)
+		}
+		if _, err := db.Exec("CREATE TABLE items (id INTEGER PRIMARY KEY AUTOINCREMENT, name TEXT NOT NULL)"); err != nil {
+			t.Fatalf("create table failed: %v", err)
+		}
+	}
+
+	events := make(chan BackupEvent, 100)
+	c.OnBackup(func(ev BackupEvent) { events <- ev })
+	sink := NewDirSink(filepath.Join(tmp, "backups"))
+	if err := c.EnableAutoBackup(Interval(10*time.Millisecond), sink, BackupRetention{FullEvery: time.Nanosecond, Keep: 1}); err != nil {
+		t.Fatalf("EnableAutoBackup failed: %v", err)
+	}
+
+	seen := map[string]int{}
+	for seen["t1"] < 3 || seen["t2"] < 3 {
+		select {
+		case ev := <-events:
+			if ev.Err != nil {
+				t.Fatalf("backup of %s failed: %v", ev.DB, ev.Err)
+			}
+			if ev.Info == nil || !ev.Info.Full {
+				t.Fatalf("expected a full backup, got %+v", ev.Info)
+			}
+			seen[ev.DB]++
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timed out waiting for backups, got %v", seen)
+		}
+	}
+	_ = c.Close()
+
+	// Each new full backup pruned the previous one
+	for _, name := range []string{"t1", "t2"} {
+		backups, err := ListBackups(ctx, PrefixSink(sink, name+"/"))
+		if err != nil || len(backups) != 1 {
+			t.Fatalf("expected 1 backup of %s, got %v (err %v)", name, backups, err)
+		}
+	}
+}
+
+func TestCache_EnableAutoBackup_Invalid(t *testing.T) {
+	c := NewCache(30 * time.Minute)
+	defer c.Close()
+	err := c.EnableAutoBackup(Interval(time.Hour), newMemSink(), BackupRetention{Keep: 2})
+	if !errors.Is(err, ErrInvalidOptions) {
+		t.Fatalf("expected ErrInvalidOptions for a sink that cannot delete, got %v", err)
+	}
+	if err := c.EnableAutoBackup(nil, newMemSink(), BackupRetention{}); !errors.Is(err, ErrInvalidOptions) {
+		t.Fatalf("expected ErrInvalidOptions without a schedule, got %v", err)
+	}
+	_ = c.Close()
+	if err := c.EnableAutoBackup(Interval(time.Hour), newMemSink(), BackupRetention{}); !errors.Is(err, ErrCacheClosed) {
+		t.Fatalf("expected ErrCacheClosed, got %v", err)
+	}
+}
@@ -1,8 +1,11 @@
 package dbx
 
 import (
+	"cmp"
+	"context"
 	"errors"
 	"log/slog"
+	"slices"
 	"sync"
 	"time"
 
@@ -24,6 +27,8 @@ type Cache struct {
 	quit             chan struct{}
 	closeOnce        sync.Once
 	inactiveDuration time.Duration
+	stopAutoBackup   context.CancelFunc
+	onBackup         func(BackupEvent)
 }
 
 func NewCache(inactiveDuration time.Duration) *Cache {
@@ -37,6 +42,7 @@ func NewCache(inactiveDuration time.Duration) *Cache {
 	}
 
 	go c.Cleanup()
+	liveCaches.Store(c, struct{}{})
 
 	return c
 }
@@ -59,6 +65,7 @@ func (c *Cache) Has(name string) *bun.DB {
 }
 
 func (c *Cache) Get(name string) (db *bun.DB, err error) {
+	defer func() { err = wrapErr("cache.get", name, "", err) }()
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
@@ -78,6 +85,7 @@ func (c *Cache) Get(name string) (db *bun.DB, err error) {
 }
 
 func (c *Cache) GetOrOpen(name string, openOptions ...OpenOptFn) (db *bun.DB, err error) {
+	defer func() { err = wrapErr("cache.open", name, "", err) }()
 	c.mu.Lock()
 	select {
 	case <-c.quit:
@@ -176,9 +184,45 @@ func (c *Cache) Set(name string, db *bun.DB) bool {
 	return true
 }
 
+type cacheEntry struct {
+	name         string
+	db           *bun.DB
+	lastAccessed time.Time
+}
+
+// entries returns the cached databases sorted by name, without touching their access time
+func (c *Cache) entries() []cacheEntry {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entries := make([]cacheEntry, 0, len(c.cache))
+	for name, db := range c.cache {
+		entries = append(entries, cacheEntry{name: name, db: db, lastAccessed: c.lastAccessed[name]})
+	}
+	slices.SortFunc(entries, func(a, b cacheEntry) int { return cmp.Compare(a.name, b.name) })
+	return entries
+}
+
+// entry returns the cached database name, without touching its access time
+func (c *Cache) entry(name string) (cacheEntry, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	db, found := c.cache[name]
+	return cacheEntry{name: name, db: db, lastAccessed: c.lastAccessed[name]}, found
+}
+
+// len returns the number of cached databases
+func (c *Cache) len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.cache)
+}
+
 func (c *Cache) Close() error {
 	c.closeOnce.Do(func() {
 		close(c.quit)
+		liveCaches.Delete(c)
 
 		c.mu.Lock()
 		dbs := make([]*bun.DB, 0, len(c.cache))
@@ -243,6 +287,7 @@ func (c *Cache) Cleanup() {
 
 			// Close outside the lock to avoid HOL blocking
 			for _, item := range toClose {
+				publish(Event{Kind: EventDBEvicted, DB: item.name})
 				if item.db != nil {
 					if err := item.db.Close(); err != nil {
 						slog.Error("sqlDB.Close() during cleanup", "name", item.name, "err", err.Error())
@@ -0,0 +1,167 @@
+package dbx
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/dialect"
+)
+
+type ChangeOp string
+
+const (
+	ChangeInsert ChangeOp = "INSERT"
+	ChangeUpdate ChangeOp = "UPDATE"
+	ChangeDelete ChangeOp = "DELETE"
+)
+
+const changePollInterval = 250 * time.Millisecond
+
+// ChangeEvent is a single row of the dbx_changes table, recording one changed row
+type ChangeEvent struct {
+	bun.BaseModel `bun:"table:dbx_changes"`
+
+	Seq       int64     `bun:"seq,pk,autoincrement"`
+	Table     string    `bun:"table_name,notnull"`
+	Op        ChangeOp  `bun:"op,notnull"`
+	RowID     int64     `bun:"row_id,notnull"`
+	ChangedAt time.Time `bun:"changed_at,notnull"`
+}
+
+// TrackChanges creates the dbx_changes table and installs triggers recording every insert, update and delete on
+// tables into it. Rows are identified by their rowid on SQLite and by their id column on Postgres.
+// The change log is durable: consumers can resume from the last Seq they processed with ChangesSince.
+func TrackChanges(ctx context.Context, idb bun.IDB, tables ...string) error {
+	if _, err := idb.NewCreateTable().Model((*ChangeEvent)(nil)).IfNotExists().Exec(ctx); err != nil {
+		return fmt.Errorf("failed to create changes table: %w", err)
+	}
+
+	changes := bun.Ident(TableName(idb, "dbx_changes"))
+	recordFn := bun.Ident(TableName(idb, "dbx_record_change"))
+	dName := idb.Dialect().Name()
+	if dName == dialect.PG {
+		if _, err := idb.ExecContext(ctx, `
+			CREATE OR REPLACE FUNCTION ?0() RETURNS trigger AS $$
+			BEGIN
+				IF TG_OP = 'DELETE' THEN
+					INSERT INTO ?1 (table_name, op, row_id, changed_at) VALUES (TG_TABLE_NAME, TG_OP, OLD.id, now());
+				ELSE
+					INSERT INTO ?1 (table_name, op, row_id, changed_at) VALUES (TG_TABLE_NAME, TG_OP, NEW.id, now());
+				END IF;
+				RETURN NULL;
+			END;
+			$$ LANGUAGE plpgsql`, recordFn, changes); err != nil {
+			return fmt.Errorf("failed to create change function: %w", err)
+		}
+	}
+
+	for _, table := range tables {
+		var statements []string
+		switch dName {
+		case dialect.SQLite:
+			for _, op := range []ChangeOp{ChangeInsert, ChangeUpdate, ChangeDelete} {
+				row := "NEW"
+				if op == ChangeDelete {
+					row = "OLD"
+				}
+				statements = append(statements, fmt.Sprintf(`
+					CREATE TRIGGER IF NOT EXISTS ?0 AFTER %[1]s ON ?1 BEGIN
+						INSERT INTO ?3 (table_name, op, row_id, changed_at)
+						VALUES (?2, '%[1]s', %[2]s.rowid, strftime('%%Y-%%m-%%d %%H:%%M:%%f', 'now'));
+					END`, op, row))
+			}
+		case dialect.PG:
+			statements = []string{
+				`DROP TRIGGER IF EXISTS ?0 ON ?1`,
+				`CREATE TRIGGER ?0 AFTER INSERT OR UPDATE OR DELETE ON ?1 FOR EACH ROW EXECUTE FUNCTION ?4()`,
+			}
+		default:
+			return fmt.Errorf("unsupported dialect: %s", dName)
+		}
+
+		for i, stmt := range statements {
+			name := "dbx_chg_" + table
+			if dName == dialect.SQLite {
+				name += "_" + []string{"ins", "upd", "del"}[i]
+			}
+			if _, err := idb.ExecContext(ctx, stmt, bun.Ident(name), bun.Ident(table), table, changes, recordFn); err != nil {
+				return fmt.Errorf("failed to track changes of %s: %w", table, err)
+			}
+		}
+	}
+	return nil
+}
+
+// ChangesSince returns up to limit changes recorded after seq, oldest first.
+// Without tables, changes of every tracked table are returned.
+func ChangesSince(ctx context.Context, idb bun.IDB, seq int64, limit int, tables ...string) ([]ChangeEvent, error) {
+	var events []ChangeEvent
+	q := idb.NewSelect().Model(&events).Where("seq > ?", seq).Order("seq ASC").Limit(limit)
+	if len(tables) > 0 {
+		q = q.Where("table_name IN (?)", bun.In(tables))
+	}
+	if err := q.Scan(ctx); err != nil {
+		return nil, err
+	}
+	return events, nil
+}
+
+// PruneChanges deletes the recorded changes up to and including seq
+func PruneChanges(ctx context.Context, idb bun.IDB, seq int64) (int64, error) {
+	res, err := idb.NewDelete().Model((*ChangeEvent)(nil)).Where("seq <= ?", seq).Exec(ctx)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// ChangeStream tracks tables (see TrackChanges) and streams the changes made from now on, in commit order,
+// until ctx is cancelled. The change log is polled, so events from other processes sharing the database are
+// delivered too. The channel is closed when the stream ends.
+func ChangeStream(ctx context.Context, db *bun.DB, tables ...string) (<-chan ChangeEvent, error) {
+	if err := TrackChanges(ctx, db, tables...); err != nil {
+		return nil, err
+	}
+
+	var last int64
+	if err := db.NewSelect().Model((*ChangeEvent)(nil)).ColumnExpr("COALESCE(MAX(seq), 0)").Scan(ctx, &last); err != nil {
+		return nil, fmt.Errorf("failed to read change position: %w", err)
+	}
+
+	ch := make(chan ChangeEvent, 64)
+	go func() {
+		defer close(ch)
+
+		ticker := time.NewTicker(changePollInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+
+			events, err := ChangesSince(ctx, db, last, 500, tables...)
+			if err != nil {
+				if ctx.Err() == nil {
+					slog.Error("dbx change stream poll", "err", err.Error())
+				}
+				continue
+			}
+			for _, ev := range events {
+				select {
+				case ch <- ev:
+					last = ev.Seq
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+
+	return ch, nil
+}
@@ -0,0 +1,61 @@
+package dbx
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestChangeStream(t *testing.T) {
+	db := setupTestDB(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	insertItem(t, db, "before")
+
+	ch, err := ChangeStream(ctx, db, "items")
+	if err != nil {
+		t.Fatalf("ChangeStream failed: %v", err)
+	}
+
+	insertItem(t, db, "a")
+	if _, err := db.ExecContext(ctx, "UPDATE items SET name = 'b' WHERE name = 'a'"); err != nil {
+		t.Fatalf("update failed: %v", err)
+	}
+	if _, err := db.ExecContext(ctx, "DELETE FROM items WHERE name = 'b'"); err != nil {
+		t.Fatalf("delete failed: %v", err)
+	}
+
+	want := []ChangeOp{ChangeInsert, ChangeUpdate, ChangeDelete}
+	for i, op := range want {
+		select {
+		case ev := <-ch:
+			if ev.Op != op || ev.Table != "items" || ev.RowID != 2 {
+				t.Fatalf("event %d: unexpected %+v", i, ev)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatalf("timed out waiting for event %d", i)
+		}
+	}
+
+	events, err := ChangesSince(ctx, db, 0, 10)
+	if err != nil {
+		t.Fatalf("ChangesSince failed: %v", err)
+	}
+	if len(events) != 3 {
+		t.Fatalf("expected 3 recorded changes, got %d", len(events))
+	}
+	if n, err := PruneChanges(ctx, db, events[1].Seq); err != nil || n != 2 {
+		t.Fatalf("expected 2 pruned changes, got %d (err %v)", n, err)
+	}
+
+	cancel()
+	select {
+	case _, ok := <-ch:
+		if ok {
+			t.Fatal("expected no more events")
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("expected channel to close after cancel")
+	}
+}
@@ -0,0 +1,176 @@
+package dbx
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"sync"
+	"sync/atomic"
+)
+
+// ChaosKind is a failure injected by WithChaos
+type ChaosKind uint8
+
+const (
+	// ChaosBusy fails with a "database is locked" error, retried by DefaultRetryClassifier
+	ChaosBusy ChaosKind = iota + 1
+	// ChaosTimeout fails with an error wrapping context.DeadlineExceeded
+	ChaosTimeout
+	// ChaosConnection fails with driver.ErrBadConn, as a dropped connection does: database/sql retries the
+	// statement on another connection, except inside a transaction
+	ChaosConnection
+)
+
+// ErrChaos is wrapped by the busy and timeout errors injected by WithChaos
+var ErrChaos = errors.New("dbx chaos")
+
+// WithChaos makes a rate (0 to 1) of the statements and transaction begins of the db fail at random with one of
+// kinds (default: all of them), so tests can check the retry and fallback paths of an application against the
+// errors dbx and the drivers actually return. Failures are injected in the driver connections, below bun's hooks
+// and database/sql's own retries. OpenDB itself never fails because of them. For test environments only.
+func WithChaos(rate float64, kinds ...ChaosKind) OpenOptFn {
+	if len(kinds) == 0 {
+		kinds = []ChaosKind{ChaosBusy, ChaosTimeout, ChaosConnection}
+	}
+	return func(opt *Options) {
+		opt.chaos = &chaos{rate: rate, kinds: kinds}
+	}
+}
+
+// WithRandSource sets the source of the randomness of the db, for tests to get the same run each time.
+// It seeds the failures of WithChaos, which otherwise use the global generator of math/rand/v2.
+func WithRandSource(src rand.Source) OpenOptFn {
+	return func(opt *Options) {
+		opt.randSource = src
+	}
+}
+
+type chaos struct {
+	rate    float64
+	kinds   []ChaosKind
+	enabled atomic.Bool
+	// rng is set by WithRandSource; a rand.Rand is not safe for concurrent use
+	mu  sync.Mutex
+	rng *rand.Rand
+}
+
+// fault returns the error to inject for the next statement, nil most of the time
+func (c *chaos) fault(op string) error {
+	if !c.enabled.Load() {
+		return nil
+	}
+	p, k := c.draw()
+	if p >= c.rate {
+		return nil
+	}
+	switch c.kinds[k] {
+	case ChaosBusy:
+		return fmt.Errorf("%w: %s: database is locked (SQLITE_BUSY)", ErrChaos, op)
+	case ChaosTimeout:
+		return fmt.Errorf("%w: %s: %w", ErrChaos, op, context.DeadlineExceeded)
+	default:
+		return driver.ErrBadConn
+	}
+}
+
+// draw returns the probability draw of a statement and the index of the kind it fails with
+func (c *chaos) draw() (float64, int) {
+	if c.rng == nil {
+		return rand.Float64(), rand.IntN(len(c.kinds))
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.rng.Float64(), c.rng.IntN(len(c.kinds))
+}
+
+func (c *chaos) wrap(conn driver.Conn) driver.Conn {
+	return &chaosConn{Conn: conn, chaos: c}
+}
+
+// chaosConn injects the faults of chaos before the statements it runs, and forwards the optional interfaces of
+// the driver connection database/sql relies on
+type chaosConn struct {
+	driver.Conn
+	chaos *chaos
+}
+
+var (
+	_ driver.ExecerContext      = (*chaosConn)(nil)
+	_ driver.QueryerContext     = (*chaosConn)(nil)
+	_ driver.ConnPrepareContext = (*chaosConn)(nil)
+	_ driver.ConnBeginTx        = (*chaosConn)(nil)
+	_ driver.Pinger             = (*chaosConn)(nil)
+	_ driver.SessionResetter    = (*chaosConn)(nil)
+	_ driver.Validator          = (*chaosConn)(nil)
+	_ driver.NamedValueChecker  = (*chaosConn)(nil)
+)
+
+func (c *chaosConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	if err := c.chaos.fault("exec"); err != nil {
+		return nil, err
+	}
+	if ex, ok := c.Conn.(driver.ExecerContext); ok {
+		return ex.ExecContext(ctx, query, args)
+	}
+	return nil, driver.ErrSkip
+}
+
+func (c *chaosConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	if err := c.chaos.fault("query"); err != nil {
+		return nil, err
+	}
+	if q, ok := c.Conn.(driver.QueryerContext); ok {
+		return q.QueryContext(ctx, query, args)
+	}
+	return nil, driver.ErrSkip
+}
+
+func (c *chaosConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	if err := c.chaos.fault("prepare"); err != nil {
+		return nil, err
+	}
+	if p, ok := c.Conn.(driver.ConnPrepareContext); ok {
+		return p.PrepareContext(ctx, query)
+	}
+	return c.Conn.Prepare(query)
+}
+
+func (c *chaosConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	if err := c.chaos.fault("begin"); err != nil {
+		return nil, err
+	}
+	if b, ok := c.Conn.(driver.ConnBeginTx); ok {
+		return b.BeginTx(ctx, opts)
+	}
+	return c.Conn.Begin()
+}
+
+func (c *chaosConn) Ping(ctx context.Context) error {
+	if p, ok := c.Conn.(driver.Pinger); ok {
+		return p.Ping(ctx)
+	}
+	return nil
+}
+
+func (c *chaosConn) ResetSession(ctx context.Context) error {
+	if r, ok := c.Conn.(driver.SessionResetter); ok {
+		return r.ResetSession(ctx)
+	}
+	return nil
+}
+
+func (c *chaosConn) IsValid() bool {
+	if v, ok := c.Conn.(driver.Validator); ok {
+		return v.IsValid()
+	}
+	return true
+}
+
+func (c *chaosConn) CheckNamedValue(nv *driver.NamedValue) error {
+	if ch, ok := c.Conn.(driver.NamedValueChecker); ok {
+		return ch.CheckNamedValue(nv)
+	}
+	return driver.ErrSkip
+}
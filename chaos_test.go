@@ -0,0 +1,96 @@
+package dbx
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"math/rand/v2"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+func openChaosDB(t *testing.T, opts ...OpenOptFn) *Transact {
+	t.Helper()
+	tmp := t.TempDir()
+	opts = append([]OpenOptFn{WithDbFolder(tmp), WithCreateIfMissing()}, opts...)
+	db, err := OpenDB(filepath.Join(tmp, "chaos.sqlite"), opts...)
+	if err != nil {
+		t.Fatalf("OpenDB failed: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	tx, err := NewTransact(context.Background(), db)
+	if err != nil {
+		t.Fatalf("NewTransact failed: %v", err)
+	}
+	return tx
+}
+
+func TestWithChaos_Kinds(t *testing.T) {
+	ctx := context.Background()
+	for _, tc := range []struct {
+		kind  ChaosKind
+		check func(error) bool
+	}{
+		{ChaosBusy, func(err error) bool { return errors.Is(err, ErrChaos) && DefaultRetryClassifier.Retryable(err) }},
+		{ChaosTimeout, func(err error) bool { return errors.Is(err, context.DeadlineExceeded) }},
+		{ChaosConnection, func(err error) bool { return errors.Is(err, driver.ErrBadConn) }},
+	} {
+		tx := openChaosDB(t, WithChaos(1, tc.kind))
+		_, err := tx.Db().ExecContext(ctx, "SELECT 1")
+		if !tc.check(err) {
+			t.Errorf("kind %d: unexpected error %v", tc.kind, err)
+		}
+		if _, err := tx.Start(nil); !tc.check(err) {
+			t.Errorf("kind %d: unexpected begin error %v", tc.kind, err)
+		}
+	}
+}
+
+func TestWithChaos_RetrySurvives(t *testing.T) {
+	tx := openChaosDB(t, WithChaos(0.2, ChaosBusy))
+
+	for range 20 {
+		err := tx.TransactionRetry(nil, func(ctx context.Context) error {
+			_, err := tx.Db().ExecContext(ctx, "CREATE TABLE IF NOT EXISTS items (id INTEGER PRIMARY KEY AUTOINCREMENT, name TEXT NOT NULL)")
+			if err != nil {
+				return err
+			}
+			_, err = tx.Db().ExecContext(ctx, "INSERT INTO items (name) VALUES ('x')")
+			return err
+		}, RetryWithAttempts(20), RetryWithBackoff(time.Microsecond))
+		if err != nil {
+			t.Fatalf("transaction failed despite retries: %v", err)
+		}
+	}
+
+	var n int
+	err := tx.TransactionRetry(nil, func(ctx context.Context) error {
+		return tx.Db().NewRaw("SELECT COUNT(*) FROM items").Scan(ctx, &n)
+	}, RetryWithAttempts(20), RetryWithBackoff(time.Microsecond))
+	if err != nil || n != 20 {
+		t.Fatalf("expected 20 rows, got %d (err %v)", n, err)
+	}
+}
+
+func TestWithRandSource(t *testing.T) {
+	ctx := context.Background()
+	run := func() []bool {
+		tx := openChaosDB(t, WithChaos(0.5, ChaosBusy), WithRandSource(rand.NewPCG(1, 2)))
+		failures := make([]bool, 50)
+		for i := range failures {
+			_, err := tx.Db().ExecContext(ctx, "SELECT 1")
+			failures[i] = err != nil
+		}
+		return failures
+	}
+
+	first, second := run(), run()
+	if !slices.Equal(first, second) {
+		t.Fatalf("expected the same failures with the same seed:\n%v\n%v", first, second)
+	}
+	if !slices.Contains(first, true) || !slices.Contains(first, false) {
+		t.Fatalf("expected both failures and successes at rate 0.5, got %v", first)
+	}
+}
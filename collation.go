@@ -0,0 +1,137 @@
+package dbx
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Built-in collations of WithCollation
+const (
+	// CollationUnicodeNoCase orders text case-insensitively over all of Unicode, where SQLite's NOCASE only folds
+	// ASCII letters
+	CollationUnicodeNoCase = "UNICODE_NOCASE"
+	// CollationNatural is CollationUnicodeNoCase comparing runs of digits by their numeric value: "file2" sorts
+	// before "file10"
+	CollationNatural = "NATURAL_NOCASE"
+)
+
+var builtinCollations = map[string]func(a, b string) int{
+	CollationUnicodeNoCase: UnicodeNoCaseCompare,
+	CollationNatural:       NaturalCompare,
+}
+
+// collationRegistrar is implemented by mattn/go-sqlite3 connections
+type collationRegistrar interface {
+	RegisterCollation(name string, cmp func(string, string) int) error
+}
+
+type collation struct {
+	name string
+	cmp  func(a, b string) int
+}
+
+// WithCollation registers the built-in collation name (CollationUnicodeNoCase, CollationNatural) on every SQLite
+// connection, for use in COLLATE clauses: ORDER BY title COLLATE NATURAL_NOCASE. OpenDB fails with
+// ErrInvalidOptions on other names. Requires the mattn/go-sqlite3 driver (DriverSQLite).
+func WithCollation(name string) OpenOptFn {
+	return func(opt *Options) {
+		opt.collations = append(opt.collations, collation{name: name, cmp: builtinCollations[name]})
+	}
+}
+
+// WithCollationFunc registers cmp as the collation name on every SQLite connection. cmp returns a negative number,
+// zero or a positive number when a sorts before, with or after b.
+// Requires the mattn/go-sqlite3 driver (DriverSQLite).
+func WithCollationFunc(name string, cmp func(a, b string) int) OpenOptFn {
+	return func(opt *Options) {
+		opt.collations = append(opt.collations, collation{name: name, cmp: cmp})
+	}
+}
+
+func collationHook(collations []collation) connHook {
+	return func(conn driver.Conn) error {
+		registrar, ok := conn.(collationRegistrar)
+		if !ok {
+			return unsupportedConnErr(conn, "registering collations")
+		}
+		for _, c := range collations {
+			if err := registrar.RegisterCollation(c.name, c.cmp); err != nil {
+				return fmt.Errorf("failed to register collation %s: %w", c.name, err)
+			}
+		}
+		return nil
+	}
+}
+
+// UnicodeNoCaseCompare compares a and b rune by rune after simple case folding, the CollationUnicodeNoCase order
+func UnicodeNoCaseCompare(a, b string) int {
+	for a != "" && b != "" {
+		ra, na := utf8.DecodeRuneInString(a)
+		rb, nb := utf8.DecodeRuneInString(b)
+		if c := compareFolded(ra, rb); c != 0 {
+			return c
+		}
+		a, b = a[na:], b[nb:]
+	}
+	return len(a) - len(b)
+}
+
+// NaturalCompare is UnicodeNoCaseCompare comparing runs of ASCII digits by value, the CollationNatural order.
+// Numbers equal in value, such as "7" and "007", compare by length.
+func NaturalCompare(a, b string) int {
+	for a != "" && b != "" {
+		if isDigit(a[0]) && isDigit(b[0]) {
+			da, db := digitRun(a), digitRun(b)
+			if c := compareNumbers(a[:da], b[:db]); c != 0 {
+				return c
+			}
+			a, b = a[da:], b[db:]
+			continue
+		}
+		ra, na := utf8.DecodeRuneInString(a)
+		rb, nb := utf8.DecodeRuneInString(b)
+		if c := compareFolded(ra, rb); c != 0 {
+			return c
+		}
+		a, b = a[na:], b[nb:]
+	}
+	return len(a) - len(b)
+}
+
+func compareFolded(a, b rune) int {
+	if a == b {
+		return 0
+	}
+	return int(unicode.ToLower(unicode.ToUpper(a))) - int(unicode.ToLower(unicode.ToUpper(b)))
+}
+
+func digitRun(s string) int {
+	n := 0
+	for n < len(s) && isDigit(s[n]) {
+		n++
+	}
+	return n
+}
+
+// compareNumbers compares two runs of digits by value, then by length
+func compareNumbers(a, b string) int {
+	ta, tb := trimZeros(a), trimZeros(b)
+	if len(ta) != len(tb) {
+		return len(ta) - len(tb)
+	}
+	for i := range len(ta) {
+		if ta[i] != tb[i] {
+			return int(ta[i]) - int(tb[i])
+		}
+	}
+	return len(a) - len(b)
+}
+
+func trimZeros(s string) string {
+	for len(s) > 1 && s[0] == '0' {
+		s = s[1:]
+	}
+	return s
+}
@@ -0,0 +1,69 @@
+package dbx
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestWithCollation(t *testing.T) {
+	ctx := context.Background()
+	tmp := t.TempDir()
+	db, err := OpenDB("collation", WithDbFolder(tmp), WithCreateIfMissing(),
+		WithCollation(CollationUnicodeNoCase), WithCollation(CollationNatural),
+		WithCollationFunc("REVERSE", func(a, b string) int { return strings.Compare(b, a) }))
+	if err != nil {
+		t.Fatalf("OpenDB failed: %v", err)
+	}
+	defer db.Close()
+
+	if _, err := db.ExecContext(ctx, `
+		CREATE TABLE files (name TEXT NOT NULL);
+		INSERT INTO files (name) VALUES ('file10'), ('File2'), ('été'), ('Étage'), ('file1');
+	`); err != nil {
+		t.Fatalf("create table failed: %v", err)
+	}
+
+	for _, tc := range []struct {
+		collation string
+		want      string
+	}{
+		{"NATURAL_NOCASE", "file1,File2,file10,Étage,été"},
+		{"UNICODE_NOCASE", "file1,file10,File2,Étage,été"},
+		{"REVERSE", "été,Étage,file10,file1,File2"},
+	} {
+		var names []string
+		if err := db.NewRaw("SELECT name FROM files ORDER BY name COLLATE "+tc.collation).Scan(ctx, &names); err != nil {
+			t.Fatalf("%s: query failed: %v", tc.collation, err)
+		}
+		if got := strings.Join(names, ","); got != tc.want {
+			t.Errorf("%s: expected %s, got %s", tc.collation, tc.want, got)
+		}
+	}
+
+	_, err = OpenDB("collation", WithDbFolder(tmp), WithCollation("ICU"))
+	if !errors.Is(err, ErrInvalidOptions) {
+		t.Fatalf("expected ErrInvalidOptions for an unknown collation, got %v", err)
+	}
+}
+
+func TestNaturalCompare(t *testing.T) {
+	for _, tc := range []struct {
+		a, b string
+		want int
+	}{
+		{"a2", "a10", -1},
+		{"a10", "a2", 1},
+		{"a007", "a7", 1},
+		{"a7", "a7", 0},
+		{"A7b", "a7B", 0},
+		{"x", "x1", -1},
+		{"Ölfass", "öl", 1},
+	} {
+		got := NaturalCompare(tc.a, tc.b)
+		if got < 0 && tc.want >= 0 || got > 0 && tc.want <= 0 || got == 0 && tc.want != 0 {
+			t.Errorf("NaturalCompare(%q, %q) = %d, want sign %d", tc.a, tc.b, got, tc.want)
+		}
+	}
+}
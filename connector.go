@@ -0,0 +1,190 @@
+package dbx
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// connHook runs on every new driver connection before it is handed to the pool
+type connHook func(conn driver.Conn) error
+
+// connWrapper replaces every new driver connection, after the hooks ran on it, usually by one wrapping it
+type connWrapper func(conn driver.Conn) driver.Conn
+
+// hookConnector opens connections through the registered driver and runs the hooks on each of them,
+// so per-connection setup (extensions, functions, ...) reaches every pooled connection.
+type hookConnector struct {
+	dsn    string
+	driver driver.Driver
+	hooks  []connHook
+	wraps  []connWrapper
+	// onClose runs when the pool is closed
+	onClose []func() error
+	// counted is set for the pools of OpenDB, counted in metrics.openDBs until closed
+	counted bool
+}
+
+var (
+	_ driver.Connector = (*hookConnector)(nil)
+	_ io.Closer        = (*hookConnector)(nil)
+)
+
+// openSQLDB opens the pool through a hookConnector, which also counts the open pools (see EnableExpvar)
+func openSQLDB(driverName, dsn string, hooks []connHook, wraps []connWrapper, onClose []func() error) (*sql.DB, error) {
+	// sql.Open does not connect, it only resolves the registered driver
+	probe, err := sql.Open(driverName, "")
+	if err != nil {
+		return nil, err
+	}
+	drv := probe.Driver()
+	_ = probe.Close()
+
+	metrics.openDBs.Add(1)
+	return sql.OpenDB(&hookConnector{dsn: dsn, driver: drv, hooks: hooks, wraps: wraps, onClose: onClose,
+		counted: true}), nil
+}
+
+func (c *hookConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	var (
+		conn driver.Conn
+		err  error
+	)
+	if dc, ok := c.driver.(driver.DriverContext); ok {
+		var connector driver.Connector
+		if connector, err = dc.OpenConnector(c.dsn); err != nil {
+			return nil, err
+		}
+		conn, err = connector.Connect(ctx)
+	} else {
+		conn, err = c.driver.Open(c.dsn)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	for _, hook := range c.hooks {
+		if err := hook(conn); err != nil {
+			_ = conn.Close()
+			return nil, err
+		}
+	}
+	for _, wrap := range c.wraps {
+		conn = wrap(conn)
+	}
+	return conn, nil
+}
+
+func (c *hookConnector) Driver() driver.Driver {
+	return c.driver
+}
+
+// Close is called by sql.DB.Close
+func (c *hookConnector) Close() error {
+	if c.counted {
+		metrics.openDBs.Add(-1)
+	}
+	var errs []error
+	for _, fn := range c.onClose {
+		errs = append(errs, fn())
+	}
+	return errors.Join(errs...)
+}
+
+// unsupportedConnErr is returned by hooks needing a capability the driver connection does not have
+func unsupportedConnErr(conn driver.Conn, what string) error {
+	return fmt.Errorf("driver connection %T does not support %s", conn, what)
+}
+
+// checkConn runs check before the statements of the driver connection, failing them with its error. It forwards the
+// optional interfaces of the driver connection database/sql relies on.
+type checkConn struct {
+	driver.Conn
+	check func(ctx context.Context, query string) error
+}
+
+var (
+	_ driver.ExecerContext      = (*checkConn)(nil)
+	_ driver.QueryerContext     = (*checkConn)(nil)
+	_ driver.ConnPrepareContext = (*checkConn)(nil)
+	_ driver.ConnBeginTx        = (*checkConn)(nil)
+	_ driver.Pinger             = (*checkConn)(nil)
+	_ driver.SessionResetter    = (*checkConn)(nil)
+	_ driver.Validator          = (*checkConn)(nil)
+	_ driver.NamedValueChecker  = (*checkConn)(nil)
+)
+
+// checkWrapper returns the connWrapper of check
+func checkWrapper(check func(ctx context.Context, query string) error) connWrapper {
+	return func(conn driver.Conn) driver.Conn {
+		return &checkConn{Conn: conn, check: check}
+	}
+}
+
+func (c *checkConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	if err := c.check(ctx, query); err != nil {
+		return nil, err
+	}
+	if ex, ok := c.Conn.(driver.ExecerContext); ok {
+		return ex.ExecContext(ctx, query, args)
+	}
+	return nil, driver.ErrSkip
+}
+
+func (c *checkConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	if err := c.check(ctx, query); err != nil {
+		return nil, err
+	}
+	if q, ok := c.Conn.(driver.QueryerContext); ok {
+		return q.QueryContext(ctx, query, args)
+	}
+	return nil, driver.ErrSkip
+}
+
+func (c *checkConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	if err := c.check(ctx, query); err != nil {
+		return nil, err
+	}
+	if p, ok := c.Conn.(driver.ConnPrepareContext); ok {
+		return p.PrepareContext(ctx, query)
+	}
+	return c.Conn.Prepare(query)
+}
+
+func (c *checkConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	if b, ok := c.Conn.(driver.ConnBeginTx); ok {
+		return b.BeginTx(ctx, opts)
+	}
+	return c.Conn.Begin()
+}
+
+func (c *checkConn) Ping(ctx context.Context) error {
+	if p, ok := c.Conn.(driver.Pinger); ok {
+		return p.Ping(ctx)
+	}
+	return nil
+}
+
+func (c *checkConn) ResetSession(ctx context.Context) error {
+	if r, ok := c.Conn.(driver.SessionResetter); ok {
+		return r.ResetSession(ctx)
+	}
+	return nil
+}
+
+func (c *checkConn) IsValid() bool {
+	if v, ok := c.Conn.(driver.Validator); ok {
+		return v.IsValid()
+	}
+	return true
+}
+
+func (c *checkConn) CheckNamedValue(nv *driver.NamedValue) error {
+	if ch, ok := c.Conn.(driver.NamedValueChecker); ok {
+		return ch.CheckNamedValue(nv)
+	}
+	return driver.ErrSkip
+}
@@ -0,0 +1,76 @@
+package dbx
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/dialect"
+)
+
+var ErrNotFresh = errors.New("database is not fresh enough for the consistency token")
+
+// ConsistencyToken identifies a point in the write history of a database, so a later read can check that the
+// database it runs on has caught up with it. Tokens are opaque strings, safe to pass through cookies or headers.
+type ConsistencyToken string
+
+// WriteToken returns the token of the writes committed so far. Call it after the write committed, on the primary.
+//
+// On Postgres the token is the current WAL LSN, on MySQL the executed GTID set. SQLite has no replicas: every
+// connection to the file sees all committed writes, so the token only records the dialect.
+func WriteToken(ctx context.Context, idb bun.IDB) (ConsistencyToken, error) {
+	var query string
+	switch dName := idb.Dialect().Name(); dName {
+	case dialect.SQLite:
+		return ConsistencyToken(dName.String()), nil
+	case dialect.PG:
+		query = "SELECT pg_current_wal_lsn()::text"
+	case dialect.MySQL:
+		query = "SELECT @@GLOBAL.gtid_executed"
+	default:
+		return "", fmt.Errorf("unsupported dialect: %s", dName)
+	}
+
+	var pos string
+	if err := idb.NewRaw(query).Scan(ctx, &pos); err != nil {
+		return "", fmt.Errorf("failed to read write position: %w", err)
+	}
+	return ConsistencyToken(idb.Dialect().Name().String() + ":" + pos), nil
+}
+
+// RequireFreshness checks that idb, typically a replica, has applied every write covered by token and fails with
+// ErrNotFresh otherwise. An empty token is always satisfied.
+func RequireFreshness(ctx context.Context, idb bun.IDB, token ConsistencyToken) error {
+	if token == "" {
+		return nil
+	}
+
+	dName := idb.Dialect().Name()
+	kind, pos, _ := strings.Cut(string(token), ":")
+	if kind != dName.String() {
+		return fmt.Errorf("consistency token %q was not issued by a %s database", token, dName)
+	}
+
+	var query string
+	switch dName {
+	case dialect.SQLite:
+		return nil
+	case dialect.PG:
+		query = "SELECT CASE WHEN pg_is_in_recovery() THEN pg_last_wal_replay_lsn() >= ?0::pg_lsn ELSE pg_current_wal_lsn() >= ?0::pg_lsn END"
+	case dialect.MySQL:
+		query = "SELECT GTID_SUBSET(?0, @@GLOBAL.gtid_executed) = 1"
+	default:
+		return fmt.Errorf("unsupported dialect: %s", dName)
+	}
+
+	var fresh bool
+	if err := idb.NewRaw(query, pos).Scan(ctx, &fresh); err != nil {
+		return fmt.Errorf("failed to check freshness: %w", err)
+	}
+	if !fresh {
+		return fmt.Errorf("%w: %s", ErrNotFresh, token)
+	}
+	return nil
+}
@@ -0,0 +1,30 @@
+package dbx
+
+import (
+	"context"
+	"testing"
+)
+
+func TestConsistencyToken_SQLite(t *testing.T) {
+	ctx := context.Background()
+	db := setupTestDB(t)
+	insertItem(t, db, "written")
+
+	token, err := WriteToken(ctx, db)
+	if err != nil {
+		t.Fatalf("WriteToken failed: %v", err)
+	}
+	if token == "" {
+		t.Fatalf("expected a token")
+	}
+
+	if err := RequireFreshness(ctx, db, token); err != nil {
+		t.Fatalf("expected sqlite to be fresh, got %v", err)
+	}
+	if err := RequireFreshness(ctx, db, ""); err != nil {
+		t.Fatalf("expected empty token to be satisfied, got %v", err)
+	}
+	if err := RequireFreshness(ctx, db, "pg:0/16B3748"); err == nil {
+		t.Fatalf("expected error for a token of another dialect")
+	}
+}
@@ -0,0 +1,35 @@
+package dbx
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/uptrace/bun/dialect"
+)
+
+// DeferConstraints defers constraint checks of the current transaction until it commits,
+// so rows with circular references can be inserted in any order.
+// It issues `SET CONSTRAINTS ALL DEFERRED` on Postgres (only DEFERRABLE constraints are affected)
+// and `PRAGMA defer_foreign_keys = ON` on SQLite. Both reset automatically when the transaction ends.
+func DeferConstraints(ctx context.Context, t *Transact) error {
+	active := t.active()
+	if !active {
+		return errors.New("cannot defer constraints: no tx active")
+	}
+
+	var query string
+	switch dName := t.db.Dialect().Name(); dName {
+	case dialect.PG:
+		query = "SET CONSTRAINTS ALL DEFERRED"
+	case dialect.SQLite:
+		query = "PRAGMA defer_foreign_keys = ON"
+	default:
+		return fmt.Errorf("unsupported dialect: %s", dName)
+	}
+
+	if _, err := t.Db().ExecContext(ctx, query); err != nil {
+		return fmt.Errorf("failed to defer constraints: %w", err)
+	}
+	return nil
+}
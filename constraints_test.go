@@ -0,0 +1,49 @@
+package dbx
+
+import (
+	"context"
+	"testing"
+)
+
+func TestDeferConstraints(t *testing.T) {
+	db := setupTestDB(t)
+	ctx := context.Background()
+
+	if _, err := db.ExecContext(ctx, `
+		CREATE TABLE parents (id INTEGER PRIMARY KEY);
+		CREATE TABLE children (id INTEGER PRIMARY KEY, parent_id INTEGER NOT NULL REFERENCES parents(id));
+	`); err != nil {
+		t.Fatalf("failed creating schema: %v", err)
+	}
+
+	tx := mustNewTx(t, db)
+	if err := DeferConstraints(ctx, tx); err == nil {
+		t.Fatal("expected error without active tx")
+	}
+
+	err := tx.Transaction(nil, func(ctx context.Context) error {
+		if err := DeferConstraints(ctx, tx); err != nil {
+			return err
+		}
+		// child first, parent later: only valid once the check is deferred to commit
+		if _, err := tx.Db().ExecContext(ctx, "INSERT INTO children(id, parent_id) VALUES (1, 1)"); err != nil {
+			return err
+		}
+		_, err := tx.Db().ExecContext(ctx, "INSERT INTO parents(id) VALUES (1)")
+		return err
+	})
+	if err != nil {
+		t.Fatalf("Transaction with deferred constraints failed: %v", err)
+	}
+
+	err = tx.Transaction(nil, func(ctx context.Context) error {
+		if err := DeferConstraints(ctx, tx); err != nil {
+			return err
+		}
+		_, err := tx.Db().ExecContext(ctx, "INSERT INTO children(id, parent_id) VALUES (2, 2)")
+		return err
+	})
+	if err == nil {
+		t.Fatal("expected commit to fail on a dangling reference")
+	}
+}
@@ -0,0 +1,84 @@
+package dbx
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/dialect"
+)
+
+// MaintainCounter installs triggers keeping parentTable.countColumn equal to the number of childTable rows whose
+// fkColumn references the parent, and recounts the existing rows once. Inserts, deletes and updates moving a child
+// to another parent are all covered, and the counter changes in the same transaction as the child rows.
+// The parent's primary key column must be named id.
+//
+// Supported dialects are SQLite, Postgres and MySQL. Run it inside a migration or a transaction so the triggers and
+// the recount are applied together.
+func MaintainCounter(ctx context.Context, idb bun.IDB, parentTable, countColumn, childTable, fkColumn string) error {
+	name := fmt.Sprintf("dbx_cnt_%s_%s_%s", childTable, fkColumn, countColumn)
+	parent, count, child, fk := bun.Ident(parentTable), bun.Ident(countColumn), bun.Ident(childTable), bun.Ident(fkColumn)
+
+	var statements []string
+	switch dName := idb.Dialect().Name(); dName {
+	case dialect.SQLite:
+		statements = []string{
+			`CREATE TRIGGER IF NOT EXISTS ?0 AFTER INSERT ON ?3 BEGIN
+				UPDATE ?1 SET ?2 = ?2 + 1 WHERE id = NEW.?4;
+			END`,
+			`CREATE TRIGGER IF NOT EXISTS ?5 AFTER DELETE ON ?3 BEGIN
+				UPDATE ?1 SET ?2 = ?2 - 1 WHERE id = OLD.?4;
+			END`,
+			`CREATE TRIGGER IF NOT EXISTS ?6 AFTER UPDATE OF ?4 ON ?3 WHEN OLD.?4 IS NOT NEW.?4 BEGIN
+				UPDATE ?1 SET ?2 = ?2 - 1 WHERE id = OLD.?4;
+				UPDATE ?1 SET ?2 = ?2 + 1 WHERE id = NEW.?4;
+			END`,
+		}
+	case dialect.PG:
+		statements = []string{
+			`CREATE OR REPLACE FUNCTION ?7() RETURNS trigger AS $$
+			BEGIN
+				IF TG_OP IN ('DELETE', 'UPDATE') AND OLD.?4 IS NOT NULL THEN
+					UPDATE ?1 SET ?2 = ?2 - 1 WHERE id = OLD.?4;
+				END IF;
+				IF TG_OP IN ('INSERT', 'UPDATE') AND NEW.?4 IS NOT NULL THEN
+					UPDATE ?1 SET ?2 = ?2 + 1 WHERE id = NEW.?4;
+				END IF;
+				RETURN NULL;
+			END;
+			$$ LANGUAGE plpgsql`,
+			`DROP TRIGGER IF EXISTS ?8 ON ?3`,
+			`CREATE TRIGGER ?8 AFTER INSERT OR DELETE OR UPDATE OF ?4 ON ?3
+				FOR EACH ROW EXECUTE FUNCTION ?7()`,
+		}
+	case dialect.MySQL:
+		statements = []string{
+			`DROP TRIGGER IF EXISTS ?0`,
+			`CREATE TRIGGER ?0 AFTER INSERT ON ?3 FOR EACH ROW
+				UPDATE ?1 SET ?2 = ?2 + 1 WHERE id = NEW.?4`,
+			`DROP TRIGGER IF EXISTS ?5`,
+			`CREATE TRIGGER ?5 AFTER DELETE ON ?3 FOR EACH ROW
+				UPDATE ?1 SET ?2 = ?2 - 1 WHERE id = OLD.?4`,
+			`DROP TRIGGER IF EXISTS ?6`,
+			`CREATE TRIGGER ?6 AFTER UPDATE ON ?3 FOR EACH ROW
+				UPDATE ?1 SET ?2 = ?2 + IF(id = NEW.?4, 1, 0) - IF(id = OLD.?4, 1, 0)
+				WHERE NOT (OLD.?4 <=> NEW.?4) AND id IN (OLD.?4, NEW.?4)`,
+		}
+	default:
+		return fmt.Errorf("unsupported dialect: %s", dName)
+	}
+
+	// Sync the counter with the rows that already exist
+	statements = append(statements, `UPDATE ?1 SET ?2 = (SELECT COUNT(*) FROM ?3 WHERE ?3.?4 = ?1.id)`)
+
+	args := []any{
+		bun.Ident(name + "_ins"), parent, count, child, fk,
+		bun.Ident(name + "_del"), bun.Ident(name + "_upd"), bun.Ident(name + "_fn"), bun.Ident(name),
+	}
+	for _, stmt := range statements {
+		if _, err := idb.ExecContext(ctx, stmt, args...); err != nil {
+			return fmt.Errorf("failed to maintain counter %s.%s: %w", parentTable, countColumn, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,57 @@
+package dbx
+
+import (
+	"context"
+	"testing"
+)
+
+func TestMaintainCounter(t *testing.T) {
+	db := setupTestDB(t)
+	ctx := context.Background()
+
+	if _, err := db.ExecContext(ctx, `
+		CREATE TABLE posts (id INTEGER PRIMARY KEY, comment_count INTEGER NOT NULL DEFAULT 0);
+		CREATE TABLE comments (id INTEGER PRIMARY KEY, post_id INTEGER REFERENCES posts(id));
+		INSERT INTO posts (id) VALUES (1), (2);
+		INSERT INTO comments (id, post_id) VALUES (1, 1);
+	`); err != nil {
+		t.Fatalf("failed creating schema: %v", err)
+	}
+
+	if err := MaintainCounter(ctx, db, "posts", "comment_count", "comments", "post_id"); err != nil {
+		t.Fatalf("MaintainCounter failed: %v", err)
+	}
+
+	counts := func() (int, int) {
+		t.Helper()
+		var a, b int
+		if err := db.QueryRowContext(ctx,
+			"SELECT (SELECT comment_count FROM posts WHERE id = 1), (SELECT comment_count FROM posts WHERE id = 2)",
+		).Scan(&a, &b); err != nil {
+			t.Fatalf("count query failed: %v", err)
+		}
+		return a, b
+	}
+
+	if a, b := counts(); a != 1 || b != 0 {
+		t.Fatalf("expected existing rows to be counted (1, 0), got (%d, %d)", a, b)
+	}
+
+	for _, stmt := range []string{
+		"INSERT INTO comments (id, post_id) VALUES (2, 1), (3, 2)",
+		"UPDATE comments SET post_id = 2 WHERE id = 1",
+		"DELETE FROM comments WHERE id = 2",
+	} {
+		if _, err := db.ExecContext(ctx, stmt); err != nil {
+			t.Fatalf("%s failed: %v", stmt, err)
+		}
+	}
+	if a, b := counts(); a != 0 || b != 2 {
+		t.Fatalf("expected counts (0, 2), got (%d, %d)", a, b)
+	}
+
+	// Installing again is a no-op
+	if err := MaintainCounter(ctx, db, "posts", "comment_count", "comments", "post_id"); err != nil {
+		t.Fatalf("second MaintainCounter failed: %v", err)
+	}
+}
@@ -1,17 +1,25 @@
 package dbx
 
 import (
+	"context"
 	"database/sql"
-	"embed"
 	"fmt"
+	"io/fs"
+	"log/slog"
 	"path/filepath"
+
+	"github.com/pressly/goose/v3"
 )
 
 type CreateOptions struct {
-	driverName DriverName
-	dbFolder   string
-	source     *embed.FS
-	srcFolder  string
+	driverName   DriverName
+	dbFolder     string
+	source       fs.FS
+	srcFolder    string
+	logger       goose.Logger
+	versionTable string
+	seed         SeedFunc
+	attachments  []attachment
 }
 
 type CreateOptFn func(options *CreateOptions)
@@ -21,19 +29,32 @@ type CreateOptFn func(options *CreateOptions)
 //
 //   - CreateWithDriverName(driverName DriverName) - specify the database driver (default: DriverSQLite)
 //   - CreateWithDbFolder(folder string) - specify the folder to create the SQLite database file in (default: "./data")
-//   - CreateWithSource(fs embed.FS) - specify the embedded filesystem containing migration files
+//   - CreateWithSource(fsys fs.FS) - specify the (usually embedded) filesystem containing migration files
 //   - CreateWithSrcFolder(folder string) - specify the folder within the embedded filesystem containing migration files
+//   - CreateWithSources(sources map[string]fs.FS) - merge the migrations of several filesystems into one stream
+//   - CreateWithLogger(logger *slog.Logger) - route migration output to a slog.Logger, or discard it when nil
+//   - CreateWithVersionTable(name string) - specify the goose version table (default: "goose_db_version")
+//   - CreateWithSeed(fn SeedFunc) - specify the fixtures loaded by ResetDB after the migrations
+//   - CreateWithAttach(schema, name string) - ATTACH another SQLite database before migrating, for migrations using schema.table
 //
 // For SQLite, if the database file already exists, it will not be overwritten.
 // For other databases, ensure that the user has the necessary permissions to create a new database.
 func CreateDB(dsn string, opts ...CreateOptFn) error {
+	return CreateDBContext(context.Background(), dsn, opts...)
+}
+
+// CreateDBContext is CreateDB with a context that is honored by file creation, ping, pragma setup and migrations.
+func CreateDBContext(ctx context.Context, dsn string, opts ...CreateOptFn) error {
 	option := CreateOptions{}
 	setCreateOptions(&option, opts...)
+	if err := validateCreateOptions(&option); err != nil {
+		return err
+	}
 
 	// If no source is provided, we just want to ensure the database can be opened (and file created for SQLite)
 	if option.source == nil {
 		if IsSQLite(option.driverName) {
-			dbFile, err := createSQLiteDBFile(dsn, option.dbFolder)
+			dbFile, err := createSQLiteDBFile(ctx, dsn, option.dbFolder)
 			if err != nil {
 				return err
 			}
@@ -46,12 +67,12 @@ func CreateDB(dsn string, opts ...CreateOptFn) error {
 		}
 		defer db.Close()
 
-		if err := db.Ping(); err != nil {
+		if err := db.PingContext(ctx); err != nil {
 			return err
 		}
 
 		if IsSQLite(option.driverName) {
-			if _, err := db.Exec(`
+			if _, err := db.ExecContext(ctx, `
 				PRAGMA journal_mode = WAL;
 				PRAGMA synchronous = NORMAL;
 				PRAGMA busy_timeout = 5000;
@@ -67,7 +88,7 @@ func CreateDB(dsn string, opts ...CreateOptFn) error {
 	}
 
 	// Run migrations (that also includes opening/pinging the DB)
-	return MigrateDB(dsn, opts...)
+	return MigrateDBContext(ctx, dsn, opts...)
 }
 
 func CreateWithDriverName(dn DriverName) CreateOptFn {
@@ -82,9 +103,10 @@ func CreateWithDbFolder(nme string) CreateOptFn {
 	}
 }
 
-func CreateWithSource(fs embed.FS) CreateOptFn {
+// CreateWithSource sets the filesystem holding the migration files, typically an embed.FS
+func CreateWithSource(fsys fs.FS) CreateOptFn {
 	return func(opt *CreateOptions) {
-		opt.source = &fs
+		opt.source = fsys
 	}
 }
 
@@ -94,6 +116,25 @@ func CreateWithSrcFolder(n string) CreateOptFn {
 	}
 }
 
+// CreateWithLogger routes the migration output of goose to logger instead of the standard logger.
+// Passing nil discards the output.
+func CreateWithLogger(logger *slog.Logger) CreateOptFn {
+	return func(opt *CreateOptions) {
+		if logger == nil {
+			opt.logger = goose.NopLogger()
+			return
+		}
+		opt.logger = &slogGooseLogger{logger: logger}
+	}
+}
+
+// CreateWithVersionTable sets the table goose records applied migrations in (default: "goose_db_version")
+func CreateWithVersionTable(name string) CreateOptFn {
+	return func(opt *CreateOptions) {
+		opt.versionTable = name
+	}
+}
+
 func setCreateOptions(opt *CreateOptions, opts ...CreateOptFn) {
 
 	// Apply all options
@@ -107,4 +148,7 @@ func setCreateOptions(opt *CreateOptions, opts ...CreateOptFn) {
 	if opt.dbFolder == "" && IsSQLite(opt.driverName) {
 		CreateWithDbFolder("./data")(opt)
 	}
+	if opt.versionTable == "" {
+		CreateWithVersionTable(goose.DefaultTablename)(opt)
+	}
 }
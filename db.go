@@ -9,6 +9,7 @@ import (
 	"strings"
 
 	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/dialect"
 )
 
 var ErrDBFileNotFound = errors.New("db file not found")
@@ -33,7 +34,11 @@ func DbFilePath(name, dbFolder string) (string, error) {
 	return filepath.Abs(dbFile)
 }
 
-func createSQLiteDBFile(name, dbFolder string) (dbFile string, err error) {
+func createSQLiteDBFile(ctx context.Context, name, dbFolder string) (dbFile string, err error) {
+	if err = ctx.Err(); err != nil {
+		return "", err
+	}
+
 	dbFile, err = DbFilePath(name, dbFolder)
 	if err != nil && !errors.Is(err, ErrDBFileNotFound) {
 		return "", err
@@ -62,12 +67,12 @@ func TableExists(ctx context.Context, db *bun.DB, tableName string) (bool, error
 	dName := db.Dialect().Name()
 
 	var query string
-	switch dName := DriverName(dName.String()); {
-	case IsSQLite(dName):
+	switch dName {
+	case dialect.SQLite:
 		query = `SELECT name FROM sqlite_master WHERE type='table' AND name = ?`
-	case dName == DriverPostgres || dName == DriverPgx:
+	case dialect.PG:
 		query = `SELECT to_regclass(?)`
-	case dName == DriverMySQL:
+	case dialect.MySQL:
 		query = `SELECT table_name FROM information_schema.tables WHERE table_schema = DATABASE() AND table_name = ?`
 	default:
 		return false, fmt.Errorf("unsupported dialect: %s", dName)
@@ -1,19 +1,34 @@
 package dbx
 
 import (
+	"bytes"
 	"context"
 	"database/sql"
 	"embed"
+	"errors"
+	"io/fs"
+	"log/slog"
 	"os"
+	"path"
 	"path/filepath"
+	"reflect"
+	"strings"
 	"testing"
+	"testing/fstest"
+	"time"
 
 	_ "github.com/mattn/go-sqlite3"
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/dialect"
+	"github.com/uptrace/bun/dialect/pgdialect"
 )
 
 //go:embed testmigrations/*.sql
 var testMigrations embed.FS
 
+//go:embed testattachmigrations/*.sql
+var testAttachMigrations embed.FS
+
 func TestDbFilePath(t *testing.T) {
 	type args struct {
 		name     string
@@ -51,12 +66,67 @@ func TestDbFilePath(t *testing.T) {
 	}
 }
 
+func TestSetOptions_PartialOptions(t *testing.T) {
+	tests := []struct {
+		name string
+		opts []OpenOptFn
+		want Options
+	}{
+		{
+			name: "no options",
+			want: Options{driverName: string(DriverSQLite), dbFolder: "data", maxOpenConns: 1, maxIdleConns: 1,
+				connMaxIdleTime: 15 * time.Minute},
+		},
+		{
+			name: "only db folder",
+			opts: []OpenOptFn{WithDbFolder("x")},
+			want: Options{driverName: string(DriverSQLite), dbFolder: "x", maxOpenConns: 1, maxIdleConns: 1,
+				connMaxIdleTime: 15 * time.Minute},
+		},
+		{
+			name: "only max open conns",
+			opts: []OpenOptFn{WithMaxOpenConns(4)},
+			want: Options{driverName: string(DriverSQLite), dbFolder: "data", maxOpenConns: 4, maxIdleConns: 1,
+				connMaxIdleTime: 15 * time.Minute},
+		},
+		{
+			name: "postgres driver",
+			opts: []OpenOptFn{WithDriverName(DriverPostgres), WithMaxIdleConns(5)},
+			want: Options{driverName: string(DriverPostgres), maxOpenConns: 10, maxIdleConns: 5},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got Options
+			setOptions(&got, tt.opts...)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("setOptions() got = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSetCreateOptions_PartialOptions(t *testing.T) {
+	var got CreateOptions
+	setCreateOptions(&got, CreateWithSrcFolder("migrations"))
+	if got.driverName != DriverSQLite || got.dbFolder != "data" || got.versionTable != "goose_db_version" ||
+		got.srcFolder != "migrations" {
+		t.Errorf("setCreateOptions() got = %+v", got)
+	}
+
+	got = CreateOptions{}
+	setCreateOptions(&got, CreateWithDriverName(DriverPostgres), CreateWithVersionTable("versions"))
+	if got.dbFolder != "" || got.versionTable != "versions" {
+		t.Errorf("setCreateOptions() got = %+v", got)
+	}
+}
+
 func TestOpenDB_SQLitePragmas(t *testing.T) {
 	tmp := t.TempDir()
 
 	// ensure sqlite file exists via helper
 	dsn := filepath.Join(tmp, "opendbtest")
-	if _, err := createSQLiteDBFile(dsn, tmp); err != nil {
+	if _, err := createSQLiteDBFile(context.Background(), dsn, tmp); err != nil {
 		t.Fatalf("createSQLiteDBFile failed: %v", err)
 	}
 
@@ -86,6 +156,112 @@ func TestOpenDB_SQLitePragmas(t *testing.T) {
 	}
 }
 
+func TestOpenDB_DiscardUnknownColumns(t *testing.T) {
+	ctx := context.Background()
+	type nameOnly struct {
+		Name string `bun:"name"`
+	}
+
+	for _, discard := range []bool{true, false} {
+		tmp := t.TempDir()
+		db, err := OpenDB("columns", WithDbFolder(tmp), WithCreateIfMissing(), WithDiscardUnknownColumns(discard))
+		if err != nil {
+			t.Fatalf("OpenDB failed: %v", err)
+		}
+		var row nameOnly
+		err = db.NewRaw("SELECT 'a' AS name, 1 AS extra").Scan(ctx, &row)
+		_ = db.Close()
+		if discard && (err != nil || row.Name != "a") {
+			t.Fatalf("expected the extra column to be discarded, got %+v, %v", row, err)
+		}
+		if !discard && err == nil {
+			t.Fatalf("expected an error on the extra column")
+		}
+	}
+}
+
+func TestDriverDialect(t *testing.T) {
+	for driver, want := range map[DriverName]dialect.Name{
+		DriverSQLite:   dialect.SQLite,
+		DriverSQLiteMc: dialect.SQLite,
+		DriverPostgres: dialect.PG,
+		DriverPgx:      dialect.PG,
+		DriverMySQL:    dialect.MySQL,
+	} {
+		d, err := driverDialect(driver)
+		if err != nil || d.Name() != want {
+			t.Errorf("driverDialect(%s): expected %s, got %v, %v", driver, want, d, err)
+		}
+	}
+	for _, driver := range []DriverName{DriverMSSQL, "nope"} {
+		if _, err := driverDialect(driver); !errors.Is(err, ErrInvalidOptions) {
+			t.Errorf("driverDialect(%s): expected ErrInvalidOptions, got %v", driver, err)
+		}
+	}
+
+	opt := Options{driverName: string(DriverMSSQL), dialect: pgdialect.New()}
+	if d, err := openDialect(&opt); err != nil || d.Name() != dialect.PG {
+		t.Errorf("expected WithDialect to win over the driver, got %v, %v", d, err)
+	}
+}
+
+// countHook counts the queries run through it
+type countHook struct{ n int }
+
+func (h *countHook) BeforeQuery(ctx context.Context, _ *bun.QueryEvent) context.Context { return ctx }
+func (h *countHook) AfterQuery(context.Context, *bun.QueryEvent)                        { h.n++ }
+
+func TestOpenDB_QueryHooks(t *testing.T) {
+	if debugQueryHook(QueryLogOff) != nil || debugQueryHook(QueryLogErrors) == nil || debugQueryHook(QueryLogAll) == nil {
+		t.Fatalf("expected a bundebug hook for QueryLogErrors and QueryLogAll only")
+	}
+	t.Setenv("BUNDEBUG", "0")
+	if debugQueryHook(QueryLogEnv) != nil {
+		t.Fatalf("expected no hook with BUNDEBUG=0")
+	}
+	t.Setenv("BUNDEBUG", "2")
+	if debugQueryHook(QueryLogEnv) == nil {
+		t.Fatalf("expected a hook with BUNDEBUG=2")
+	}
+
+	hook := &countHook{}
+	db, err := OpenDB("hooks", WithDbFolder(t.TempDir()), WithCreateIfMissing(), WithQueryHook(hook))
+	if err != nil {
+		t.Fatalf("OpenDB failed: %v", err)
+	}
+	defer db.Close()
+	if _, err := db.NewSelect().ColumnExpr("1").Exec(context.Background()); err != nil {
+		t.Fatalf("select failed: %v", err)
+	}
+	if hook.n != 1 {
+		t.Fatalf("expected the hook to see 1 query, got %d", hook.n)
+	}
+
+	if err := ValidateOptions(WithQueryLogging(QueryLogEnv + 1)); !errors.Is(err, ErrInvalidOptions) {
+		t.Fatalf("expected an unknown mode to be invalid, got %v", err)
+	}
+}
+
+func TestOpenDB_CreateIfMissing(t *testing.T) {
+	folder := filepath.Join(t.TempDir(), "nested")
+
+	if _, err := OpenDB("fresh", WithDbFolder(folder)); !errors.Is(err, ErrDBFileNotFound) {
+		t.Fatalf("expected ErrDBFileNotFound without the option, got %v", err)
+	}
+
+	db, err := OpenDB("fresh", WithDbFolder(folder), WithCreateIfMissing())
+	if err != nil {
+		t.Fatalf("OpenDB failed: %v", err)
+	}
+	defer db.Close()
+	if _, err := db.ExecContext(context.Background(), "CREATE TABLE t (id INTEGER PRIMARY KEY)"); err != nil {
+		t.Fatalf("expected a usable db, got %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(folder, "fresh.db")); err != nil {
+		t.Fatalf("expected the db file to exist: %v", err)
+	}
+}
+
 func TestMigrateDB_RunsMigrations(t *testing.T) {
 	tmp := t.TempDir()
 	name := "migratedbtest"
@@ -127,6 +303,126 @@ func TestMigrateDB_RunsMigrations(t *testing.T) {
 	}
 }
 
+// cancelFS cancels a migration run once goose opens its first migration file, i.e. after the db file exists
+type cancelFS struct {
+	fs.FS
+	cancel func()
+}
+
+func (c cancelFS) Open(name string) (fs.File, error) {
+	if path.Ext(name) == ".sql" {
+		time.AfterFunc(50*time.Millisecond, c.cancel)
+	}
+	return c.FS.Open(name)
+}
+
+func TestMigrateDBContext_Cancelled(t *testing.T) {
+	tmp := t.TempDir()
+	name := "migratectxtest"
+
+	// The migration creates items, then runs a query that never ends until the context is cancelled
+	migrations := fstest.MapFS{
+		"00001_create_items.sql": {Data: []byte(`-- +goose Up
+CREATE TABLE items (id INTEGER PRIMARY KEY);
+WITH RECURSIVE c(x) AS (SELECT 1 UNION ALL SELECT x + 1 FROM c) SELECT count(*) FROM c;
+-- +goose Down
+DROP TABLE items;
+`)},
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	err := MigrateDBContext(ctx, name,
+		CreateWithDbFolder(tmp),
+		CreateWithSource(cancelFS{FS: migrations, cancel: cancel}),
+		CreateWithSrcFolder("."),
+		CreateWithLogger(nil),
+	)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+
+	db, err := OpenDB(name, WithDbFolder(tmp))
+	if err != nil {
+		t.Fatalf("OpenDB failed: %v", err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+
+	if exists, err := TableExists(context.Background(), db, "items"); err != nil || exists {
+		t.Fatalf("expected no items table after cancelled migration, got %v (err %v)", exists, err)
+	}
+}
+
+func TestMigrateDBContext_CancelledBeforeStart(t *testing.T) {
+	tmp := t.TempDir()
+	name := "migratectxtest"
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err := MigrateDBContext(ctx, name,
+		CreateWithDbFolder(tmp),
+		CreateWithSource(testMigrations),
+		CreateWithSrcFolder("testmigrations"),
+	)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+
+	if _, err := os.Stat(filepath.Join(tmp, name+".db")); !os.IsNotExist(err) {
+		t.Fatalf("expected no db file after cancelled migration, got %v", err)
+	}
+}
+
+func TestMigrateDB_WithLogger(t *testing.T) {
+	tmp := t.TempDir()
+
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&buf, nil))
+	if err := MigrateDB("migratelogtest",
+		CreateWithDbFolder(tmp),
+		CreateWithSource(testMigrations),
+		CreateWithSrcFolder("testmigrations"),
+		CreateWithLogger(logger),
+	); err != nil {
+		t.Fatalf("MigrateDB failed: %v", err)
+	}
+
+	if !strings.Contains(buf.String(), "00001_create_items.sql") {
+		t.Fatalf("expected migration output in slog logger, got %q", buf.String())
+	}
+}
+
+func TestMigrateDB_WithAttach(t *testing.T) {
+	ctx := context.Background()
+	tmp := t.TempDir()
+
+	err := MigrateDB("main", CreateWithDbFolder(tmp), CreateWithSource(testAttachMigrations),
+		CreateWithSrcFolder("testattachmigrations"), CreateWithLogger(nil), CreateWithAttach("archive", "main_archive"))
+	if err != nil {
+		t.Fatalf("MigrateDB failed: %v", err)
+	}
+
+	for name, want := range map[string]bool{"main": true, "main_archive": true} {
+		db, err := OpenDB(name, WithDbFolder(tmp))
+		if err != nil {
+			t.Fatalf("OpenDB(%s) failed: %v", name, err)
+		}
+		exists, err := TableExists(ctx, db, "items")
+		db.Close()
+		if err != nil || exists != want {
+			t.Fatalf("expected items in %s, got %v (err %v)", name, exists, err)
+		}
+	}
+
+	// Without the attachment the migration cannot reach the archive schema
+	err = MigrateDB("other", CreateWithDbFolder(tmp), CreateWithSource(testAttachMigrations),
+		CreateWithSrcFolder("testattachmigrations"), CreateWithLogger(nil))
+	if err == nil || !strings.Contains(err.Error(), "archive") {
+		t.Fatalf("expected an unknown database error, got %v", err)
+	}
+}
+
 func TestCreateDB_CreatesFileAndRunsMigrations(t *testing.T) {
 	tmp := t.TempDir()
 	name := "createdbtest"
@@ -183,6 +479,26 @@ func TestCreateDB_CreatesFileAndRunsMigrations(t *testing.T) {
 		t.Fatalf("insert after CreateDB failed: %v", err)
 	}
 }
+func TestContextVariants_Cancelled(t *testing.T) {
+	tmp := t.TempDir()
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if err := CreateDBContext(ctx, "ctxtest", CreateWithDbFolder(tmp)); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled from CreateDBContext, got %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(tmp, "ctxtest.db")); !os.IsNotExist(err) {
+		t.Fatalf("expected no db file after cancelled CreateDBContext, got %v", err)
+	}
+
+	if err := CreateDB("ctxtest", CreateWithDbFolder(tmp)); err != nil {
+		t.Fatalf("CreateDB failed: %v", err)
+	}
+	if _, err := OpenDBContext(ctx, "ctxtest", WithDbFolder(tmp)); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled from OpenDBContext, got %v", err)
+	}
+}
+
 func TestTableExists(t *testing.T) {
 	tmp := t.TempDir()
 	name := "tableexiststest"
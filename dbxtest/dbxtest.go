@@ -0,0 +1,147 @@
+package dbxtest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/actanonv/dbx"
+	"github.com/uptrace/bun"
+)
+
+// sampleRows is the number of rows of the table shown when an assertion fails
+const sampleRows = 5
+
+// AssertRowExists checks that table has a row matching where (a bun condition, "" for any row) with args.
+// On failure it reports the condition and a sample of the rows of the table. It returns whether the check passed.
+func AssertRowExists(t testing.TB, idb bun.IDB, table, where string, args ...any) bool {
+	t.Helper()
+	n, err := count(idb, table, where, args)
+	if err != nil {
+		t.Errorf("AssertRowExists: %v", err)
+		return false
+	}
+	if n == 0 {
+		t.Errorf("AssertRowExists: no row of %s matches %s\n%s", table, condition(where, args), sample(idb, table))
+		return false
+	}
+	return true
+}
+
+// AssertCount checks that want rows of table match where (a bun condition, "" for all rows) with args.
+// It returns whether the check passed.
+func AssertCount(t testing.TB, idb bun.IDB, table string, want int, where string, args ...any) bool {
+	t.Helper()
+	n, err := count(idb, table, where, args)
+	if err != nil {
+		t.Errorf("AssertCount: %v", err)
+		return false
+	}
+	if n != want {
+		t.Errorf("AssertCount: %d rows of %s match %s, want %d\n%s", n, table, condition(where, args), want, sample(idb, table))
+		return false
+	}
+	return true
+}
+
+// AssertTableEmpty checks that table has no rows, reporting a sample of them otherwise.
+// It returns whether the check passed.
+func AssertTableEmpty(t testing.TB, idb bun.IDB, table string) bool {
+	t.Helper()
+	n, err := count(idb, table, "", nil)
+	if err != nil {
+		t.Errorf("AssertTableEmpty: %v", err)
+		return false
+	}
+	if n != 0 {
+		t.Errorf("AssertTableEmpty: %s has %d rows\n%s", table, n, sample(idb, table))
+		return false
+	}
+	return true
+}
+
+// AssertTenantGuarded checks that the dbx.WithTenantGuard of the db of idb rejects a select of each of tables that
+// did not go through its dbx.TenantPolicy, so a test of the setup of an application catches a table left unguarded.
+// It returns whether the check passed.
+func AssertTenantGuarded(t testing.TB, idb bun.IDB, tables ...string) bool {
+	t.Helper()
+	ok := true
+	for _, table := range tables {
+		_, err := idb.NewSelect().TableExpr("?", bun.Ident(table)).Exists(context.Background())
+		if !errors.Is(err, dbx.ErrTenantUnscoped) {
+			t.Errorf("AssertTenantGuarded: unscoped select of %s not rejected, got %v", table, err)
+			ok = false
+		}
+	}
+	return ok
+}
+
+func count(idb bun.IDB, table, where string, args []any) (int, error) {
+	q := idb.NewSelect().TableExpr("?", bun.Ident(table))
+	if where != "" {
+		q = q.Where(where, args...)
+	}
+	// The assertions see the rows of all the tenants
+	n, err := q.Count(dbx.Unscoped(context.Background()))
+	if err != nil {
+		return 0, fmt.Errorf("failed to count rows of %s matching %s: %w", table, condition(where, args), err)
+	}
+	return n, nil
+}
+
+func condition(where string, args []any) string {
+	if where == "" {
+		return "(all rows)"
+	}
+	if len(args) == 0 {
+		return fmt.Sprintf("%q", where)
+	}
+	return fmt.Sprintf("%q with args %v", where, args)
+}
+
+// sample renders the first rows of table, one per line, for failure messages
+func sample(idb bun.IDB, table string) string {
+	rows, err := idb.QueryContext(context.Background(), "SELECT * FROM ? LIMIT ?", bun.Ident(table), sampleRows+1)
+	if err != nil {
+		return fmt.Sprintf("(cannot read %s: %v)", table, err)
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return fmt.Sprintf("(cannot read %s: %v)", table, err)
+	}
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "rows of %s:", table)
+	n := 0
+	for rows.Next() {
+		if n++; n > sampleRows {
+			sb.WriteString("\n\t...")
+			break
+		}
+		values := make([]any, len(columns))
+		ptrs := make([]any, len(columns))
+		for i := range values {
+			ptrs[i] = &values[i]
+		}
+		if err := rows.Scan(ptrs...); err != nil {
+			return fmt.Sprintf("(cannot read %s: %v)", table, err)
+		}
+		sb.WriteString("\n\t")
+		for i, col := range columns {
+			if i > 0 {
+				sb.WriteString(", ")
+			}
+			if b, ok := values[i].([]byte); ok {
+				values[i] = string(b)
+			}
+			fmt.Fprintf(&sb, "%s=%v", col, values[i])
+		}
+	}
+	if n == 0 {
+		sb.WriteString(" (none)")
+	}
+	return sb.String()
+}
@@ -0,0 +1,123 @@
+package dbxtest
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/actanonv/dbx"
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/uptrace/bun"
+)
+
+// recordingT captures the failures of the assertions under test
+type recordingT struct {
+	testing.TB
+	errors []string
+}
+
+func (r *recordingT) Helper() {}
+
+func (r *recordingT) Errorf(format string, args ...any) {
+	r.errors = append(r.errors, fmt.Sprintf(format, args...))
+}
+
+func setupDB(t *testing.T) *bun.DB {
+	t.Helper()
+	tmp := t.TempDir()
+	db, err := dbx.OpenDB("dbxtest", dbx.WithDbFolder(tmp), dbx.WithCreateIfMissing())
+	if err != nil {
+		t.Fatalf("OpenDB failed: %v", err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+	if _, err := db.ExecContext(context.Background(), `
+		CREATE TABLE items (id INTEGER PRIMARY KEY, name TEXT NOT NULL);
+		CREATE TABLE empty (id INTEGER PRIMARY KEY);
+		INSERT INTO items (name) VALUES ('a'), ('b'), ('b');
+	`); err != nil {
+		t.Fatalf("create tables failed: %v", err)
+	}
+	return db
+}
+
+func TestAssertions_Pass(t *testing.T) {
+	db := setupDB(t)
+	rt := &recordingT{}
+	ok := AssertRowExists(rt, db, "items", "name = ?", "a") &&
+		AssertRowExists(rt, db, "items", "") &&
+		AssertCount(rt, db, "items", 2, "name = ?", "b") &&
+		AssertCount(rt, db, "items", 3, "") &&
+		AssertTableEmpty(rt, db, "empty")
+	if !ok || len(rt.errors) != 0 {
+		t.Fatalf("expected all assertions to pass, got %v", rt.errors)
+	}
+}
+
+func TestAssertions_Fail(t *testing.T) {
+	db := setupDB(t)
+	for _, tc := range []struct {
+		name   string
+		assert func(t testing.TB) bool
+		want   []string
+	}{
+		{"row exists", func(t testing.TB) bool { return AssertRowExists(t, db, "items", "name = ?", "z") },
+			[]string{`no row of items matches "name = ?" with args [z]`, "id=1, name=a", "id=3, name=b"}},
+		{"count", func(t testing.TB) bool { return AssertCount(t, db, "items", 1, "name = ?", "b") },
+			[]string{`2 rows of items match "name = ?" with args [b], want 1`}},
+		{"empty", func(t testing.TB) bool { return AssertTableEmpty(t, db, "items") },
+			[]string{"items has 3 rows", "rows of items:"}},
+		{"missing table", func(t testing.TB) bool { return AssertCount(t, db, "missing", 0, "") },
+			[]string{"failed to count rows of missing", "no such table"}},
+	} {
+		rt := &recordingT{}
+		if tc.assert(rt) {
+			t.Errorf("%s: expected the assertion to fail", tc.name)
+			continue
+		}
+		if len(rt.errors) != 1 {
+			t.Errorf("%s: expected one failure, got %v", tc.name, rt.errors)
+			continue
+		}
+		for _, want := range tc.want {
+			if !strings.Contains(rt.errors[0], want) {
+				t.Errorf("%s: expected %q in failure:\n%s", tc.name, want, rt.errors[0])
+			}
+		}
+	}
+
+	rt := &recordingT{}
+	AssertRowExists(rt, db, "empty", "id = ?", 1)
+	if len(rt.errors) != 1 || !strings.Contains(rt.errors[0], "rows of empty: (none)") {
+		t.Fatalf("expected an empty sample, got %v", rt.errors)
+	}
+}
+
+func TestAssertTenantGuarded(t *testing.T) {
+	tenant := func(ctx context.Context) (any, bool) { return nil, false }
+	db, err := dbx.OpenDB("guarded", dbx.WithDbFolder(t.TempDir()), dbx.WithCreateIfMissing(),
+		dbx.WithQueryPolicy(dbx.TenantPolicy("tenant_id", tenant, "items")), dbx.WithTenantGuard())
+	if err != nil {
+		t.Fatalf("OpenDB failed: %v", err)
+	}
+	defer db.Close()
+	if _, err := db.ExecContext(context.Background(), `
+		CREATE TABLE items (id INTEGER PRIMARY KEY, tenant_id INTEGER NOT NULL);
+		CREATE TABLE tags (id INTEGER PRIMARY KEY);
+		INSERT INTO items (tenant_id) VALUES (1), (2);
+	`); err != nil {
+		t.Fatalf("create tables failed: %v", err)
+	}
+
+	rt := &recordingT{}
+	if !AssertTenantGuarded(rt, db, "items") || len(rt.errors) != 0 {
+		t.Fatalf("expected items to be guarded, got %v", rt.errors)
+	}
+	if AssertTenantGuarded(rt, db, "tags") || len(rt.errors) != 1 || !strings.Contains(rt.errors[0], "tags") {
+		t.Fatalf("expected tags not to be guarded, got %v", rt.errors)
+	}
+	// The assertions are not scoped
+	if rt := (&recordingT{}); !AssertCount(rt, db, "items", 2, "") {
+		t.Fatalf("expected the count of all the items, got %v", rt.errors)
+	}
+}
@@ -0,0 +1,163 @@
+package dbxtest
+
+import (
+	"context"
+	"fmt"
+	"math/rand/v2"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/actanonv/dbx"
+	"github.com/uptrace/bun"
+)
+
+const (
+	hammerTable    = "dbxtest_hammer"
+	hammerMaxDepth = 4
+)
+
+// HammerTx runs workers goroutines, each doing ops random operations through its own Transact on db: start a
+// transaction or savepoint, insert a row, commit or roll back the current level. After each operation a worker
+// checks that it sees exactly its committed and pending rows, and at the end the rows of the db must be exactly the
+// committed ones. Transactions failing with a busy error are rolled back and dropped, as an application would.
+//
+// The rows go to a dbxtest_hammer table, created and dropped by HammerTx. Failures report the seed of the run.
+func HammerTx(t testing.TB, db *bun.DB, workers, ops int) {
+	t.Helper()
+	ctx := context.Background()
+
+	if _, err := db.NewRaw("CREATE TABLE ? (id INTEGER PRIMARY KEY, worker INTEGER NOT NULL, op INTEGER NOT NULL)",
+		bun.Ident(hammerTable)).Exec(ctx); err != nil {
+		t.Fatalf("HammerTx: failed to create %s: %v", hammerTable, err)
+	}
+	defer func() {
+		if _, err := db.NewDropTable().Table(hammerTable).Exec(ctx); err != nil {
+			t.Errorf("HammerTx: failed to drop %s: %v", hammerTable, err)
+		}
+	}()
+
+	seed := uint64(time.Now().UnixNano())
+	committed := make([][]int64, workers)
+	errs := make([]error, workers)
+	var wg sync.WaitGroup
+	for w := range workers {
+		wg.Go(func() {
+			committed[w], errs[w] = hammerWorker(ctx, db, w, ops, rand.New(rand.NewPCG(seed, uint64(w))))
+		})
+	}
+	wg.Wait()
+
+	for w, err := range errs {
+		if err != nil {
+			t.Errorf("HammerTx (seed %d): worker %d: %v", seed, w, err)
+		}
+	}
+	for w := range workers {
+		var got []int64
+		if err := db.NewSelect().Table(hammerTable).Column("op").Where("worker = ?", w).Order("op").Scan(ctx, &got); err != nil {
+			t.Fatalf("HammerTx: failed to read rows: %v", err)
+		}
+		want := slices.Sorted(slices.Values(committed[w]))
+		if !slices.Equal(got, want) {
+			t.Errorf("HammerTx (seed %d): worker %d committed ops %v, the db has %v", seed, w, want, got)
+		}
+	}
+}
+
+// hammerWorker returns the ops whose rows it committed
+func hammerWorker(ctx context.Context, db *bun.DB, worker, ops int, rng *rand.Rand) ([]int64, error) {
+	tx, err := dbx.NewTransact(ctx, db)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		committed []int64
+		// handles and pending are the open levels, outermost first, with the ops inserted in each
+		handles []*dbx.TxHandle
+		pending [][]int64
+	)
+	abort := func() error {
+		for len(handles) > 0 {
+			if err := handles[len(handles)-1].Rollback(); err != nil {
+				return fmt.Errorf("rollback after busy error: %w", err)
+			}
+			handles, pending = handles[:len(handles)-1], pending[:len(pending)-1]
+		}
+		return nil
+	}
+
+	for op := range ops {
+		var err error
+		switch r := rng.IntN(10); {
+		case len(handles) == 0 || r < 2 && len(handles) < hammerMaxDepth:
+			var h *dbx.TxHandle
+			if h, err = tx.Start(nil); err == nil {
+				handles, pending = append(handles, h), append(pending, nil)
+			}
+		case r < 6:
+			_, err = tx.Db().NewRaw("INSERT INTO ? (worker, op) VALUES (?, ?)", bun.Ident(hammerTable), worker, op).Exec(ctx)
+			if err == nil {
+				pending[len(pending)-1] = append(pending[len(pending)-1], int64(op))
+			}
+		case r < 8:
+			if err = handles[len(handles)-1].Commit(); err == nil {
+				last := pending[len(pending)-1]
+				handles, pending = handles[:len(handles)-1], pending[:len(pending)-1]
+				if len(pending) == 0 {
+					committed = append(committed, last...)
+				} else {
+					pending[len(pending)-1] = append(pending[len(pending)-1], last...)
+				}
+			}
+		default:
+			if err = handles[len(handles)-1].Rollback(); err == nil {
+				handles, pending = handles[:len(handles)-1], pending[:len(pending)-1]
+			}
+		}
+
+		if err == nil && len(handles) > 0 {
+			err = checkVisible(ctx, tx, worker, committed, pending)
+		}
+		if dbx.KindOf(err) == dbx.KindBusy {
+			err = abort()
+		}
+		if err != nil {
+			return committed, fmt.Errorf("op %d: %w", op, err)
+		}
+	}
+
+	for len(handles) > 0 {
+		if err := handles[len(handles)-1].Commit(); err != nil {
+			if dbx.KindOf(err) == dbx.KindBusy {
+				return committed, abort()
+			}
+			return committed, fmt.Errorf("final commit: %w", err)
+		}
+		last := pending[len(pending)-1]
+		handles, pending = handles[:len(handles)-1], pending[:len(pending)-1]
+		if len(pending) == 0 {
+			committed = append(committed, last...)
+		} else {
+			pending[len(pending)-1] = append(pending[len(pending)-1], last...)
+		}
+	}
+	return committed, nil
+}
+
+// checkVisible checks that the transaction of tx sees the committed rows of the worker and those pending in its
+// open levels, and no others
+func checkVisible(ctx context.Context, tx *dbx.Transact, worker int, committed []int64, pending [][]int64) error {
+	want := slices.Concat(append([][]int64{committed}, pending...)...)
+	slices.Sort(want)
+	var got []int64
+	if err := tx.Db().NewSelect().Table(hammerTable).Column("op").Where("worker = ?", worker).Order("op").Scan(ctx, &got); err != nil {
+		return err
+	}
+	if !slices.Equal(got, want) {
+		return fmt.Errorf("sees ops %v, want %v", got, want)
+	}
+	return nil
+}
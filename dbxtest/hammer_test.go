@@ -0,0 +1,33 @@
+package dbxtest
+
+import (
+	"testing"
+
+	"github.com/actanonv/dbx"
+)
+
+func TestHammerTx(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		conns int
+	}{
+		{"single conn", 1},
+		{"pool", 4},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			db, err := dbx.OpenDB("hammer", dbx.WithDbFolder(t.TempDir()), dbx.WithCreateIfMissing(),
+				dbx.WithMaxOpenConns(tc.conns), dbx.WithMaxIdleConns(tc.conns))
+			if err != nil {
+				t.Fatalf("OpenDB failed: %v", err)
+			}
+			defer db.Close()
+
+			HammerTx(t, db, 4, 100)
+
+			rt := &recordingT{TB: t}
+			if !AssertTableEmpty(rt, db, "sqlite_master") {
+				t.Fatalf("expected HammerTx to drop its table: %v", rt.errors)
+			}
+		})
+	}
+}
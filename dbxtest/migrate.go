@@ -0,0 +1,122 @@
+package dbxtest
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"slices"
+	"testing"
+
+	"github.com/actanonv/dbx"
+	"github.com/pressly/goose/v3"
+	"github.com/uptrace/bun"
+)
+
+// MigrationRoundTrip runs the goose migrations in folder of fsys up, down to zero and up again on a throwaway
+// SQLite database, and fails t when a step fails, when the down migrations leave tables, indexes, views or triggers
+// behind, or when the second up ends with another schema than the first one, as irreversible or order-dependent
+// migrations do. The sqlite3 driver must be registered by the test.
+// The round trip stops at the first step that fails.
+func MigrationRoundTrip(t testing.TB, fsys fs.FS, folder string) {
+	t.Helper()
+	ctx := context.Background()
+
+	db, err := dbx.OpenDB("roundtrip", dbx.WithDbFolder(t.TempDir()), dbx.WithCreateIfMissing())
+	if err != nil {
+		t.Fatalf("MigrationRoundTrip: %v", err)
+	}
+	defer db.Close()
+
+	sub, err := fs.Sub(fsys, folder)
+	if err != nil {
+		t.Fatalf("MigrationRoundTrip: %v", err)
+	}
+	provider, err := goose.NewProvider(goose.DialectSQLite3, db.DB, sub, goose.WithDisableGlobalRegistry(true))
+	if err != nil {
+		t.Fatalf("MigrationRoundTrip: %v", err)
+	}
+
+	if _, err := provider.Up(ctx); err != nil {
+		t.Errorf("MigrationRoundTrip: first up: %v", err)
+		return
+	}
+	migrated, err := schema(ctx, db)
+	if err != nil {
+		t.Fatalf("MigrationRoundTrip: %v", err)
+	}
+
+	if _, err := provider.DownTo(ctx, 0); err != nil {
+		t.Errorf("MigrationRoundTrip: down to zero: %v", err)
+		return
+	}
+	left, err := schema(ctx, db)
+	if err != nil {
+		t.Fatalf("MigrationRoundTrip: %v", err)
+	}
+	if len(left) > 0 {
+		t.Errorf("MigrationRoundTrip: down migrations left objects behind:\n%s", lines(left))
+	}
+
+	if _, err := provider.Up(ctx); err != nil {
+		t.Errorf("MigrationRoundTrip: second up: %v", err)
+		return
+	}
+	again, err := schema(ctx, db)
+	if err != nil {
+		t.Fatalf("MigrationRoundTrip: %v", err)
+	}
+	if missing, extra := diff(migrated, again), diff(again, migrated); len(missing) > 0 || len(extra) > 0 {
+		t.Errorf("MigrationRoundTrip: the second up ends with another schema\nonly after the first up:\n%s\nonly after the second up:\n%s",
+			lines(missing), lines(extra))
+	}
+}
+
+// schema returns the definitions of the tables, indexes, views and triggers of db, sorted, without the goose
+// version table
+func schema(ctx context.Context, db *bun.DB) ([]string, error) {
+	var objects []struct {
+		Type string
+		Name string
+		SQL  *string `bun:"sql"`
+	}
+	err := db.NewRaw(`SELECT type, name, sql FROM sqlite_master WHERE name NOT LIKE 'sqlite\_%' ESCAPE '\' AND tbl_name != ?
+		ORDER BY type, name`, goose.DefaultTablename).Scan(ctx, &objects)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read schema: %w", err)
+	}
+
+	defs := make([]string, 0, len(objects))
+	for _, o := range objects {
+		def := o.Type + " " + o.Name
+		if o.SQL != nil {
+			def += ": " + *o.SQL
+		}
+		defs = append(defs, def)
+	}
+	return defs, nil
+}
+
+// diff returns the elements of a that are not in b
+func diff(a, b []string) []string {
+	var only []string
+	for _, s := range a {
+		if !slices.Contains(b, s) {
+			only = append(only, s)
+		}
+	}
+	return only
+}
+
+func lines(defs []string) string {
+	if len(defs) == 0 {
+		return "\t(none)"
+	}
+	var out string
+	for i, def := range defs {
+		if i > 0 {
+			out += "\n"
+		}
+		out += "\t" + def
+	}
+	return out
+}
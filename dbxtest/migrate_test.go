@@ -0,0 +1,38 @@
+package dbxtest
+
+import (
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestMigrationRoundTrip(t *testing.T) {
+	file := func(up, down string) *fstest.MapFile {
+		return &fstest.MapFile{Data: []byte("-- +goose Up\n" + up + "\n-- +goose Down\n" + down + "\n")}
+	}
+
+	good := fstest.MapFS{
+		"migrations/00001_items.sql": file("CREATE TABLE items (id INTEGER PRIMARY KEY, name TEXT);", "DROP TABLE items;"),
+		"migrations/00002_index.sql": file("CREATE INDEX items_name ON items(name);", "DROP INDEX items_name;"),
+	}
+	MigrationRoundTrip(t, good, "migrations")
+
+	for _, tc := range []struct {
+		name string
+		fsys fstest.MapFS
+		want string
+	}{
+		{"leftover", fstest.MapFS{
+			"migrations/00001_items.sql": file("CREATE TABLE items (id INTEGER PRIMARY KEY);", "SELECT 1;"),
+		}, "down migrations left objects behind:\n\ttable items"},
+		{"failing down", fstest.MapFS{
+			"migrations/00001_items.sql": file("CREATE TABLE items (id INTEGER PRIMARY KEY);", "DROP TABLE item;"),
+		}, "down to zero"},
+	} {
+		rt := &recordingT{TB: t}
+		MigrationRoundTrip(rt, tc.fsys, "migrations")
+		if len(rt.errors) == 0 || !strings.Contains(strings.Join(rt.errors, "\n"), tc.want) {
+			t.Errorf("%s: expected a failure containing %q, got %v", tc.name, tc.want, rt.errors)
+		}
+	}
+}
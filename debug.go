@@ -0,0 +1,158 @@
+package dbx
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"net/http"
+	"runtime"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/uptrace/bun"
+)
+
+// debugTimeout bounds the queries of a debug page on a db busy with a long transaction
+const debugTimeout = 200 * time.Millisecond
+
+// DebugDB is the state of a cached database in the debug page
+type DebugDB struct {
+	Name             string      `json:"name"`
+	Dialect          string      `json:"dialect"`
+	LastAccessed     time.Time   `json:"last_accessed"`
+	Pool             sql.DBStats `json:"pool"`
+	MigrationVersion int64       `json:"migration_version"`
+	// Error is set when the migration version could not be read
+	Error string `json:"error,omitempty"`
+}
+
+// DebugTx is a transaction open on a Transact in the debug page
+type DebugTx struct {
+	// Database is the cache name of the db of the transaction, empty when it is not cached
+	Database string        `json:"database"`
+	Depth    int           `json:"depth"`
+	Started  time.Time     `json:"started"`
+	Age      time.Duration `json:"age_ns"`
+	// Site is the function that started the outermost level
+	Site string `json:"site"`
+}
+
+// DebugReport is the content of the debug page
+type DebugReport struct {
+	Time         time.Time   `json:"time"`
+	Databases    []DebugDB   `json:"databases"`
+	Transactions []DebugTx   `json:"transactions"`
+	Queries      []QueryStat `json:"queries"`
+}
+
+// DebugHandler returns an http.Handler rendering the databases of cache (pool stats, migration version), the open
+// transactions of every Transact, oldest first, and the statistics of DefaultQueryStats (see WithQueryStats).
+// It renders HTML, or JSON with ?format=json or an Accept: application/json header.
+// Mount it under /debug/dbx/ behind the same access control as net/http/pprof.
+func DebugHandler(cache *Cache) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		report := NewDebugReport(r.Context(), cache)
+
+		if r.URL.Query().Get("format") == "json" || strings.Contains(r.Header.Get("Accept"), "application/json") {
+			w.Header().Set("Content-Type", "application/json")
+			enc := json.NewEncoder(w)
+			enc.SetIndent("", "  ")
+			_ = enc.Encode(report)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		if err := debugTemplate.Execute(w, report); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	})
+}
+
+// NewDebugReport returns the content of the page of DebugHandler, e.g. for an admin API of its own
+func NewDebugReport(ctx context.Context, cache *Cache) *DebugReport {
+	report := &DebugReport{
+		Time:         time.Now(),
+		Databases:    []DebugDB{},
+		Transactions: []DebugTx{},
+		Queries:      QueryStats(),
+	}
+
+	names := make(map[*bun.DB]string)
+	for _, e := range cache.entries() {
+		names[e.db] = e.name
+		report.Databases = append(report.Databases, newDebugDB(ctx, e))
+	}
+
+	openTxs.Range(func(key, _ any) bool {
+		t := key.(*Transact)
+		st := t.state.Load()
+		if st == nil {
+			return true
+		}
+		root := st.root()
+		frame, _ := runtime.CallersFrames([]uintptr{root.site}).Next()
+		report.Transactions = append(report.Transactions, DebugTx{
+			Database: names[t.db],
+			Depth:    st.nested,
+			Started:  root.started,
+			Age:      report.Time.Sub(root.started),
+			Site:     frame.Function,
+		})
+		return true
+	})
+	slices.SortFunc(report.Transactions, func(a, b DebugTx) int { return a.Started.Compare(b.Started) })
+	return report
+}
+
+// NewDebugDB returns the state of the cached database name, as listed by NewDebugReport, without touching its access
+// time. It fails with ErrDatabaseNotFound when name is not cached.
+func NewDebugDB(ctx context.Context, cache *Cache, name string) (*DebugDB, error) {
+	e, found := cache.entry(name)
+	if !found {
+		return nil, fmt.Errorf("%w: %s", ErrDatabaseNotFound, name)
+	}
+	d := newDebugDB(ctx, e)
+	return &d, nil
+}
+
+func newDebugDB(ctx context.Context, e cacheEntry) DebugDB {
+	d := DebugDB{
+		Name:         e.name,
+		Dialect:      e.db.Dialect().Name().String(),
+		LastAccessed: e.lastAccessed,
+		Pool:         e.db.Stats(),
+	}
+	vctx, cancel := context.WithTimeout(ctx, debugTimeout)
+	defer cancel()
+	version, err := migrationVersion(vctx, e.db)
+	if err != nil {
+		d.Error = err.Error()
+	}
+	d.MigrationVersion = version
+	return d
+}
+
+var debugTemplate = template.Must(template.New("dbx").Parse(`<!DOCTYPE html>
+<html><head><title>dbx</title>
+<style>body{font-family:sans-serif}table{border-collapse:collapse;margin-bottom:2em}td,th{border:1px solid #ccc;padding:2px 8px;text-align:left}</style>
+</head><body>
+<p>{{.Time.Format "2006-01-02 15:04:05"}} &middot; <a href="?format=json">json</a></p>
+<h2>Databases</h2>
+<table>
+<tr><th>name</th><th>dialect</th><th>last accessed</th><th>open</th><th>in use</th><th>idle</th><th>wait count</th><th>wait</th><th>migration</th></tr>
+{{range .Databases}}<tr><td>{{.Name}}</td><td>{{.Dialect}}</td><td>{{.LastAccessed.Format "15:04:05"}}</td><td>{{.Pool.OpenConnections}}</td><td>{{.Pool.InUse}}</td><td>{{.Pool.Idle}}</td><td>{{.Pool.WaitCount}}</td><td>{{.Pool.WaitDuration}}</td><td>{{if .Error}}{{.Error}}{{else}}{{.MigrationVersion}}{{end}}</td></tr>
+{{end}}</table>
+<h2>Open transactions</h2>
+<table>
+<tr><th>database</th><th>depth</th><th>age</th><th>started by</th></tr>
+{{range .Transactions}}<tr><td>{{.Database}}</td><td>{{.Depth}}</td><td>{{.Age}}</td><td>{{.Site}}</td></tr>
+{{end}}</table>
+<h2>Queries</h2>
+<table>
+<tr><th>fingerprint</th><th>count</th><th>errors</th><th>total</th><th>avg</th><th>max</th></tr>
+{{range .Queries}}<tr><td><code>{{.Fingerprint}}</code></td><td>{{.Count}}</td><td>{{.Errors}}</td><td>{{.Total}}</td><td>{{.Avg}}</td><td>{{.Max}}</td></tr>
+{{end}}</table>
+</body></html>
+`))
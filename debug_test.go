@@ -0,0 +1,93 @@
+package dbx
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDebugHandler(t *testing.T) {
+	ctx := context.Background()
+	db := setupTestDB(t)
+	cache := NewCache(time.Hour)
+	defer cache.Close()
+	cache.Set("tenant_debug", db)
+
+	get := func(query string) *DebugReport {
+		t.Helper()
+		rec := httptest.NewRecorder()
+		DebugHandler(cache).ServeHTTP(rec, httptest.NewRequest("GET", "/debug/dbx/"+query, nil))
+		if ct := rec.Header().Get("Content-Type"); ct != "application/json" {
+			t.Fatalf("expected json, got %q", ct)
+		}
+		var report DebugReport
+		if err := json.Unmarshal(rec.Body.Bytes(), &report); err != nil {
+			t.Fatalf("decode report failed: %v", err)
+		}
+		return &report
+	}
+	tenantTxs := func(report *DebugReport) []DebugTx {
+		var txs []DebugTx
+		for _, tx := range report.Transactions {
+			if tx.Database == "tenant_debug" {
+				txs = append(txs, tx)
+			}
+		}
+		return txs
+	}
+
+	report := get("?format=json")
+	if len(report.Databases) != 1 || report.Databases[0].Name != "tenant_debug" || report.Databases[0].Dialect != "sqlite" {
+		t.Fatalf("unexpected databases: %+v", report.Databases)
+	}
+	if report.Databases[0].Error != "" {
+		t.Fatalf("unexpected migration version error: %s", report.Databases[0].Error)
+	}
+	if len(tenantTxs(report)) != 0 {
+		t.Fatalf("expected no open transactions, got %+v", report.Transactions)
+	}
+	if d, err := NewDebugDB(ctx, cache, "tenant_debug"); err != nil || d.Name != "tenant_debug" || d.Dialect != "sqlite" {
+		t.Fatalf("unexpected database: %+v (err %v)", d, err)
+	}
+	if _, err := NewDebugDB(ctx, cache, "missing"); !errors.Is(err, ErrDatabaseNotFound) {
+		t.Fatalf("expected ErrDatabaseNotFound, got %v", err)
+	}
+
+	tx, err := NewTransact(ctx, db)
+	if err != nil {
+		t.Fatalf("NewTransact failed: %v", err)
+	}
+	outer, err := tx.Start(nil)
+	if err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	inner, err := tx.Start(nil)
+	if err != nil {
+		t.Fatalf("nested Start failed: %v", err)
+	}
+
+	txs := tenantTxs(get("?format=json"))
+	if len(txs) != 1 || txs[0].Depth != 2 || !strings.HasSuffix(txs[0].Site, "TestDebugHandler") {
+		t.Fatalf("unexpected open transactions: %+v", txs)
+	}
+
+	rec := httptest.NewRecorder()
+	DebugHandler(cache).ServeHTTP(rec, httptest.NewRequest("GET", "/debug/dbx/", nil))
+	if body := rec.Body.String(); !strings.Contains(body, "tenant_debug") || !strings.Contains(body, "TestDebugHandler") {
+		t.Fatalf("expected the db and the transaction in the html page, got:\n%s", body)
+	}
+
+	if err := inner.Commit(); err != nil {
+		t.Fatalf("inner Commit failed: %v", err)
+	}
+	if err := outer.Rollback(); err != nil {
+		t.Fatalf("Rollback failed: %v", err)
+	}
+	if txs := tenantTxs(get("?format=json")); len(txs) != 0 {
+		t.Fatalf("expected no open transactions after rollback, got %+v", txs)
+	}
+}
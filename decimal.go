@@ -0,0 +1,331 @@
+package dbx
+
+import (
+	"cmp"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"math/bits"
+	"strconv"
+	"strings"
+
+	"github.com/uptrace/bun/dialect"
+)
+
+var ErrInvalidDecimal = errors.New("invalid decimal")
+
+const maxDecimalScale = 18
+
+var pow10 = func() [maxDecimalScale + 1]int64 {
+	var p [maxDecimalScale + 1]int64
+	p[0] = 1
+	for i := 1; i < len(p); i++ {
+		p[i] = p[i-1] * 10
+	}
+	return p
+}()
+
+// Decimal is an exact decimal number stored as int64 units of 10^-scale, e.g. 12.34 is 1234 units at scale 2.
+// Use it for money instead of float64. Arithmetic panics when a result does not fit in int64 units,
+// the same way an out-of-range index does, rather than returning a silently wrong amount.
+//
+// Values are written to the db as decimal strings, so declare the column with DecimalColumnType:
+// NUMERIC on Postgres and MySQL keep them exact, while SQLite needs TEXT (its NUMERIC affinity converts to floating point).
+type Decimal struct {
+	units int64
+	scale int32
+}
+
+// NewDecimal returns units * 10^-scale, e.g. NewDecimal(1999, 2) is 19.99
+func NewDecimal(units int64, scale int32) Decimal {
+	if scale < 0 || scale > maxDecimalScale {
+		panic(fmt.Sprintf("dbx: decimal scale %d out of range 0-%d", scale, maxDecimalScale))
+	}
+	return Decimal{units: units, scale: scale}
+}
+
+// ParseDecimal parses a plain decimal string such as "-12.340"; the scale is the number of fraction digits
+func ParseDecimal(s string) (Decimal, error) {
+	str := strings.TrimSpace(s)
+	str, neg := strings.CutPrefix(str, "-")
+	if !neg {
+		str = strings.TrimPrefix(str, "+")
+	}
+	intPart, frac, _ := strings.Cut(str, ".")
+	if intPart == "" && frac == "" || len(frac) > maxDecimalScale || strings.ContainsAny(intPart+frac, "+-_") {
+		return Decimal{}, fmt.Errorf("%w: %q", ErrInvalidDecimal, s)
+	}
+	if intPart == "" {
+		intPart = "0"
+	}
+
+	units, err := strconv.ParseInt(intPart+frac, 10, 64)
+	if err != nil {
+		return Decimal{}, fmt.Errorf("%w: %q", ErrInvalidDecimal, s)
+	}
+	if neg {
+		units = -units
+	}
+	return Decimal{units: units, scale: int32(len(frac))}, nil
+}
+
+// MustParseDecimal is ParseDecimal panicking on error, for constants
+func MustParseDecimal(s string) Decimal {
+	d, err := ParseDecimal(s)
+	if err != nil {
+		panic(err)
+	}
+	return d
+}
+
+// DecimalColumnType returns the column type storing decimals exactly on the dialect
+func DecimalColumnType(d dialect.Name, precision, scale int) string {
+	if d == dialect.SQLite {
+		return "TEXT"
+	}
+	return fmt.Sprintf("NUMERIC(%d, %d)", precision, scale)
+}
+
+// Units returns d as an integer number of 10^-scale units
+func (d Decimal) Units() int64 {
+	return d.units
+}
+
+// Scale returns the number of fraction digits of d
+func (d Decimal) Scale() int32 {
+	return d.scale
+}
+
+func (d Decimal) IsZero() bool {
+	return d.units == 0
+}
+
+// Sign returns -1, 0 or 1 for a negative, zero or positive d
+func (d Decimal) Sign() int {
+	return cmp.Compare(d.units, 0)
+}
+
+func (d Decimal) Neg() Decimal {
+	return Decimal{units: checkedMul(d.units, -1), scale: d.scale}
+}
+
+// Float64 returns the nearest float64, for display and statistics only
+func (d Decimal) Float64() float64 {
+	return float64(d.units) / float64(pow10[d.scale])
+}
+
+func (d Decimal) String() string {
+	abs := strconv.FormatUint(absUnits(d.units), 10)
+	if d.scale > 0 {
+		if pad := int(d.scale) + 1 - len(abs); pad > 0 {
+			abs = strings.Repeat("0", pad) + abs
+		}
+		abs = abs[:len(abs)-int(d.scale)] + "." + abs[len(abs)-int(d.scale):]
+	}
+	if d.units < 0 {
+		return "-" + abs
+	}
+	return abs
+}
+
+// Rescale returns d with the given scale, rounding half away from zero when digits are dropped
+func (d Decimal) Rescale(scale int32) Decimal {
+	switch {
+	case scale < 0 || scale > maxDecimalScale:
+		panic(fmt.Sprintf("dbx: decimal scale %d out of range 0-%d", scale, maxDecimalScale))
+	case scale >= d.scale:
+		return Decimal{units: checkedMul(d.units, pow10[scale-d.scale]), scale: scale}
+	}
+
+	div := pow10[d.scale-scale]
+	q, r := d.units/div, d.units%div
+	if 2*absUnits(r) >= uint64(div) {
+		if d.units < 0 {
+			q--
+		} else {
+			q++
+		}
+	}
+	return Decimal{units: q, scale: scale}
+}
+
+// Add returns d + o at the larger of both scales. It only panics when the sum does not fit, not when an operand
+// alone does not at that scale.
+func (d Decimal) Add(o Decimal) Decimal {
+	scale := max(d.scale, o.scale)
+	a, b := wideUnits(d, scale), wideUnits(o, scale)
+	if a.neg == b.neg {
+		return Decimal{units: a.add(b).int64(), scale: scale}
+	}
+	if a.cmp(b) < 0 {
+		a, b = b, a
+	}
+	return Decimal{units: a.sub(b).int64(), scale: scale}
+}
+
+func (d Decimal) Sub(o Decimal) Decimal {
+	return d.Add(o.Neg())
+}
+
+// Mul returns d * o at the sum of both scales, capped at 18 fraction digits
+func (d Decimal) Mul(o Decimal) Decimal {
+	scale := d.scale + o.scale
+	if scale <= maxDecimalScale {
+		return Decimal{units: checkedMul(d.units, o.units), scale: scale}
+	}
+	// Drop fraction digits first so the intermediate product fits
+	return d.Rescale(min(d.scale, maxDecimalScale-o.scale)).Mul(o)
+}
+
+// Cmp returns -1, 0 or 1 when d is less than, equal to or greater than o. It never panics.
+func (d Decimal) Cmp(o Decimal) int {
+	if s, t := d.Sign(), o.Sign(); s != t || s == 0 {
+		return cmp.Compare(s, t)
+	}
+	scale := max(d.scale, o.scale)
+	c := wideUnits(d, scale).cmp(wideUnits(o, scale))
+	if d.units < 0 {
+		return -c
+	}
+	return c
+}
+
+func (d Decimal) Equal(o Decimal) bool {
+	return d.Cmp(o) == 0
+}
+
+// Split divides d into n parts at its scale that add up to d exactly, handing the remainder units out one by one
+// to the first parts, e.g. 10.00 split 3 ways is 3.34, 3.33, 3.33
+func (d Decimal) Split(n int) []Decimal {
+	if n <= 0 {
+		return nil
+	}
+	q, r := d.units/int64(n), d.units%int64(n)
+	step := int64(1)
+	if r < 0 {
+		step, r = -1, -r
+	}
+
+	parts := make([]Decimal, n)
+	for i := range parts {
+		parts[i] = Decimal{units: q, scale: d.scale}
+		if int64(i) < r {
+			parts[i].units += step
+		}
+	}
+	return parts
+}
+
+func (d Decimal) Value() (driver.Value, error) {
+	return d.String(), nil
+}
+
+func (d *Decimal) Scan(src any) error {
+	var err error
+	switch v := src.(type) {
+	case string:
+		*d, err = ParseDecimal(v)
+	case []byte:
+		*d, err = ParseDecimal(string(v))
+	case int64:
+		*d = Decimal{units: v}
+	case float64:
+		*d, err = ParseDecimal(strconv.FormatFloat(v, 'f', -1, 64))
+	default:
+		return fmt.Errorf("%w: cannot scan %T", ErrInvalidDecimal, src)
+	}
+	return err
+}
+
+// MarshalJSON encodes d as a string, since JSON numbers are usually decoded as float64
+func (d Decimal) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.String())
+}
+
+// UnmarshalJSON accepts a string or a number
+func (d *Decimal) UnmarshalJSON(b []byte) error {
+	s := string(b)
+	if strings.HasPrefix(s, `"`) {
+		if err := json.Unmarshal(b, &s); err != nil {
+			return err
+		}
+	}
+	if strings.ContainsAny(s, "eE") {
+		return fmt.Errorf("%w: exponent not supported: %s", ErrInvalidDecimal, s)
+	}
+	v, err := ParseDecimal(s)
+	if err != nil {
+		return err
+	}
+	*d = v
+	return nil
+}
+
+// wide is a signed 128-bit number of units, holding any Decimal at a larger scale
+type wide struct {
+	hi, lo uint64
+	neg    bool
+}
+
+// wideUnits returns the units of d at scale, which must not be lower than the scale of d
+func wideUnits(d Decimal, scale int32) wide {
+	hi, lo := bits.Mul64(absUnits(d.units), uint64(pow10[scale-d.scale]))
+	return wide{hi: hi, lo: lo, neg: d.units < 0}
+}
+
+// cmp compares the magnitudes of w and v
+func (w wide) cmp(v wide) int {
+	if c := cmp.Compare(w.hi, v.hi); c != 0 {
+		return c
+	}
+	return cmp.Compare(w.lo, v.lo)
+}
+
+// add adds the magnitude of v to w
+func (w wide) add(v wide) wide {
+	lo, carry := bits.Add64(w.lo, v.lo, 0)
+	hi, carry := bits.Add64(w.hi, v.hi, carry)
+	if carry != 0 {
+		panic("dbx: decimal overflow")
+	}
+	return wide{hi: hi, lo: lo, neg: w.neg}
+}
+
+// sub subtracts the magnitude of v from w, which must not be smaller
+func (w wide) sub(v wide) wide {
+	lo, borrow := bits.Sub64(w.lo, v.lo, 0)
+	hi, _ := bits.Sub64(w.hi, v.hi, borrow)
+	return wide{hi: hi, lo: lo, neg: w.neg}
+}
+
+// int64 returns w, panicking when it does not fit
+func (w wide) int64() int64 {
+	if w.hi != 0 || w.lo > math.MaxInt64 && !(w.neg && w.lo == 1<<63) {
+		panic("dbx: decimal overflow")
+	}
+	if w.neg {
+		return int64(-w.lo)
+	}
+	return int64(w.lo)
+}
+
+func absUnits(v int64) uint64 {
+	if v < 0 {
+		return uint64(-(v + 1)) + 1
+	}
+	return uint64(v)
+}
+
+func checkedMul(a, b int64) int64 {
+	hi, lo := bits.Mul64(absUnits(a), absUnits(b))
+	neg := (a < 0) != (b < 0)
+	if hi != 0 || lo > math.MaxInt64 && !(neg && lo == 1<<63) {
+		panic("dbx: decimal overflow")
+	}
+	if neg {
+		return int64(-lo)
+	}
+	return int64(lo)
+}
@@ -0,0 +1,160 @@
+package dbx
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"math"
+	"testing"
+)
+
+func TestParseDecimal(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+		err  bool
+	}{
+		{in: "12.34", want: "12.34"},
+		{in: "-0.05", want: "-0.05"},
+		{in: "+7", want: "7"},
+		{in: ".5", want: "0.5"},
+		{in: "100.00", want: "100.00"},
+		{in: "", err: true},
+		{in: "1.2.3", err: true},
+		{in: "--1", err: true},
+		{in: "1e5", err: true},
+		{in: "99999999999999999999", err: true},
+	}
+	for _, tt := range tests {
+		d, err := ParseDecimal(tt.in)
+		if tt.err {
+			if !errors.Is(err, ErrInvalidDecimal) {
+				t.Fatalf("ParseDecimal(%q): expected ErrInvalidDecimal, got %v", tt.in, err)
+			}
+			continue
+		}
+		if err != nil || d.String() != tt.want {
+			t.Fatalf("ParseDecimal(%q) = %s (err %v), want %s", tt.in, d, err, tt.want)
+		}
+	}
+}
+
+func TestDecimal_Arithmetic(t *testing.T) {
+	price := MustParseDecimal("19.99")
+	qty := NewDecimal(3, 0)
+	rate := MustParseDecimal("0.075")
+
+	total := price.Mul(qty)
+	if total.String() != "59.97" {
+		t.Fatalf("expected 59.97, got %s", total)
+	}
+	tax := total.Mul(rate).Rescale(2)
+	if tax.String() != "4.50" {
+		t.Fatalf("expected tax 4.50 (4.49775 rounded), got %s", tax)
+	}
+	if got := total.Add(tax).Sub(MustParseDecimal("0.1")); got.String() != "64.37" {
+		t.Fatalf("expected 64.37, got %s", got)
+	}
+	if MustParseDecimal("1.50").Cmp(MustParseDecimal("1.5")) != 0 {
+		t.Fatalf("expected 1.50 == 1.5")
+	}
+	if got := MustParseDecimal("-2.5").Rescale(0); got.String() != "-3" {
+		t.Fatalf("expected -2.5 to round to -3, got %s", got)
+	}
+
+	parts := MustParseDecimal("10.00").Split(3)
+	sum := NewDecimal(0, 2)
+	for _, p := range parts {
+		sum = sum.Add(p)
+	}
+	if parts[0].String() != "3.34" || parts[2].String() != "3.33" || sum.String() != "10.00" {
+		t.Fatalf("unexpected split %v (sum %s)", parts, sum)
+	}
+
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Fatal("expected overflow panic")
+			}
+		}()
+		NewDecimal(1<<62, 0).Add(NewDecimal(1<<62, 0))
+	}()
+}
+
+func TestDecimal_StoreAndJSON(t *testing.T) {
+	ctx := context.Background()
+	db := setupTestDB(t)
+	if _, err := db.ExecContext(ctx, "CREATE TABLE prices (amount "+DecimalColumnType(db.Dialect().Name(), 20, 2)+")"); err != nil {
+		t.Fatalf("create failed: %v", err)
+	}
+
+	// A value float64 cannot represent exactly
+	amount := MustParseDecimal("90071992547409.93")
+	if _, err := db.ExecContext(ctx, "INSERT INTO prices VALUES (?)", amount); err != nil {
+		t.Fatalf("insert failed: %v", err)
+	}
+	var got Decimal
+	if err := db.NewRaw("SELECT amount FROM prices").Scan(ctx, &got); err != nil {
+		t.Fatalf("scan failed: %v", err)
+	}
+	if !got.Equal(amount) {
+		t.Fatalf("expected %s, got %s", amount, got)
+	}
+
+	b, err := json.Marshal(got)
+	if err != nil || string(b) != `"90071992547409.93"` {
+		t.Fatalf("unexpected JSON %s (err %v)", b, err)
+	}
+	if err := json.Unmarshal([]byte(`1.25`), &got); err != nil || got.String() != "1.25" {
+		t.Fatalf("expected 1.25 from JSON number, got %s (err %v)", got, err)
+	}
+}
+
+func TestDecimal_MixedScalesNearLimits(t *testing.T) {
+	for _, tc := range []struct {
+		a, b Decimal
+		want int
+	}{
+		{NewDecimal(10, 0), NewDecimal(1, 18), 1},
+		{NewDecimal(1, 18), NewDecimal(10, 0), -1},
+		{NewDecimal(-10, 0), NewDecimal(1, 18), -1},
+		{NewDecimal(-10, 0), NewDecimal(-1, 18), -1},
+		{NewDecimal(math.MaxInt64, 0), NewDecimal(math.MaxInt64, 18), 1},
+		{NewDecimal(math.MinInt64, 0), NewDecimal(math.MinInt64, 18), -1},
+		{NewDecimal(math.MinInt64, 18), NewDecimal(math.MinInt64, 18), 0},
+		{NewDecimal(9, 0), NewDecimal(9_000_000_000_000_000_000, 18), 0},
+		{NewDecimal(0, 0), NewDecimal(0, 18), 0},
+	} {
+		if got := tc.a.Cmp(tc.b); got != tc.want {
+			t.Errorf("%s cmp %s: expected %d, got %d", tc.a, tc.b, tc.want, got)
+		}
+	}
+
+	// The sum fits even though 10 does not at scale 18
+	if got := NewDecimal(10, 0).Add(MustParseDecimal("-9.000000000000000001")); got.String() != "0.999999999999999999" {
+		t.Fatalf("expected 0.999999999999999999, got %s", got)
+	}
+	if got := NewDecimal(math.MinInt64, 2).Add(NewDecimal(math.MaxInt64, 2)); got.String() != "-0.01" {
+		t.Fatalf("expected -0.01, got %s", got)
+	}
+	if got := NewDecimal(math.MaxInt64-1, 0).Add(NewDecimal(1, 0)); got.Units() != math.MaxInt64 {
+		t.Fatalf("expected MaxInt64, got %s", got)
+	}
+	if got := NewDecimal(math.MinInt64+1, 0).Sub(NewDecimal(1, 0)); got.Units() != math.MinInt64 {
+		t.Fatalf("expected MinInt64, got %s", got)
+	}
+	for _, sum := range []func(){
+		func() { NewDecimal(10, 0).Add(NewDecimal(1, 18)) },
+		func() { NewDecimal(math.MaxInt64, 0).Add(NewDecimal(1, 0)) },
+		func() { NewDecimal(math.MinInt64, 0).Add(NewDecimal(-1, 0)) },
+	} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Fatal("expected overflow panic for a sum that does not fit")
+				}
+			}()
+			sum()
+		}()
+	}
+}
@@ -0,0 +1,87 @@
+package dbx
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/uptrace/bun"
+)
+
+// OpenEmbeddedDB opens the SQLite database at path in fsys (typically an embed.FS) read-only, for reference data
+// shipped in the binary. The file is copied to a temporary file, removed when the db is closed, and opened as
+// immutable, so reads take no locks. opts are those of OpenDB; the db folder is ignored.
+//
+// Checkpoint a WAL database before embedding it (PRAGMA wal_checkpoint(TRUNCATE), or VACUUM INTO a new file): the
+// -wal file is not read.
+func OpenEmbeddedDB(fsys fs.FS, path string, opts ...OpenOptFn) (*bun.DB, error) {
+	return OpenEmbeddedDBContext(context.Background(), fsys, path, opts...)
+}
+
+// OpenEmbeddedDBContext is OpenEmbeddedDB with a context that is honored by the copy and OpenDBContext.
+// Errors are returned as an *Error of op "open".
+func OpenEmbeddedDBContext(ctx context.Context, fsys fs.FS, path string, opts ...OpenOptFn) (*bun.DB, error) {
+	var opt Options
+	setOptions(&opt, opts...)
+	if !IsSQLite(DriverName(opt.driverName)) {
+		return nil, wrapErr("open", path, "", fmt.Errorf("%w: embedded databases need sqlite, not %s",
+			ErrInvalidOptions, opt.driverName))
+	}
+
+	file, err := copyEmbeddedDB(ctx, fsys, path)
+	if err != nil {
+		return nil, wrapErr("open", path, "", err)
+	}
+	remove := func() error {
+		if err := os.Remove(file); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("failed to remove embedded db copy %s: %w", file, err)
+		}
+		return nil
+	}
+
+	opts = append(opts, WithDbFolder(filepath.Dir(file)), func(opt *Options) {
+		opt.createIfMissing = false
+		opt.readOnly = true
+		opt.immutable = true
+		opt.onClose = append(opt.onClose, remove)
+	})
+	db, err := OpenDBContext(ctx, filepath.Base(file), opts...)
+	if err != nil {
+		_ = remove()
+		return nil, err
+	}
+	return db, nil
+}
+
+// copyEmbeddedDB copies path of fsys to a new temporary file and returns its name
+func copyEmbeddedDB(ctx context.Context, fsys fs.FS, path string) (_ string, err error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+	src, err := fsys.Open(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to open embedded db: %w", err)
+	}
+	defer src.Close()
+
+	dst, err := os.CreateTemp("", "dbx-embedded-*.db")
+	if err != nil {
+		return "", fmt.Errorf("failed to create embedded db copy: %w", err)
+	}
+	defer func() {
+		if cerr := dst.Close(); err == nil && cerr != nil {
+			err = cerr
+		}
+		if err != nil {
+			_ = os.Remove(dst.Name())
+		}
+	}()
+	if _, err := io.Copy(dst, src); err != nil {
+		return "", fmt.Errorf("failed to copy embedded db: %w", err)
+	}
+	return dst.Name(), nil
+}
@@ -0,0 +1,55 @@
+package dbx
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+)
+
+func TestOpenEmbeddedDB(t *testing.T) {
+	ctx := context.Background()
+	src := setupTestDB(t)
+	insertItem(t, src, "reference")
+	if err := src.Close(); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(filepath.Join(dbFolder, "testdb.sqlite"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	fsys := fstest.MapFS{"data/ref.db": {Data: data}}
+
+	db, err := OpenEmbeddedDB(fsys, "data/ref.db", WithDriverName(DriverSQLite))
+	if err != nil {
+		t.Fatalf("OpenEmbeddedDB: %v", err)
+	}
+	var name string
+	if err := db.NewRaw(`SELECT name FROM items`).Scan(ctx, &name); err != nil || name != "reference" {
+		t.Fatalf("expected the embedded row, got %q, %v", name, err)
+	}
+	if _, err := db.ExecContext(ctx, `INSERT INTO items (name) VALUES ('new')`); err == nil {
+		t.Error("expected writes to an embedded db to fail")
+	}
+
+	var file string
+	if err := db.NewRaw(`SELECT file FROM pragma_database_list WHERE name = 'main'`).Scan(ctx, &file); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	if _, err := os.Stat(file); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("expected the copy %s removed on close, got %v", file, err)
+	}
+
+	if _, err := OpenEmbeddedDB(fsys, "data/missing.db"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("expected fs.ErrNotExist for a missing file, got %v", err)
+	}
+	if _, err := OpenEmbeddedDB(fsys, "data/ref.db", WithDriverName(DriverPostgres)); !errors.Is(err, ErrInvalidOptions) {
+		t.Errorf("expected ErrInvalidOptions for postgres, got %v", err)
+	}
+}
@@ -0,0 +1,147 @@
+package dbx
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/schema"
+)
+
+type entityEntry[T any] struct {
+	value     T
+	expiresAt time.Time
+}
+
+// EntityCache is an in-process read-through cache of bun models of type T, keyed by their single primary key.
+// Get loads missing entries from the db and keeps them for the TTL; Update and Delete write through a Transact and
+// drop the entry right away and again after the commit, so readers never get a value older than the last commit.
+// Reads inside a transaction are not cached, since they may see uncommitted rows.
+type EntityCache[T any] struct {
+	ttl     time.Duration
+	mu      sync.Mutex
+	entries map[string]entityEntry[T]
+	// gens counts the invalidations of each key, so a load racing a commit does not store the row it read before.
+	// It is cleared when no load is in flight, since only loads compare generations.
+	gens    map[string]uint64
+	epoch   uint64
+	loading int
+}
+
+// NewEntityCache returns an empty cache keeping entries for ttl
+func NewEntityCache[T any](ttl time.Duration) *EntityCache[T] {
+	return &EntityCache[T]{ttl: ttl, entries: make(map[string]entityEntry[T]), gens: make(map[string]uint64)}
+}
+
+// Get returns the entity with primary key id, from the cache when present and not expired.
+// A missing row is reported as sql.ErrNoRows.
+func (c *EntityCache[T]) Get(ctx context.Context, idb bun.IDB, id any) (T, error) {
+	key := fmt.Sprint(id)
+	now := time.Now()
+
+	c.mu.Lock()
+	entry, found := c.entries[key]
+	if found && now.After(entry.expiresAt) {
+		delete(c.entries, key)
+		found = false
+	}
+	gen, epoch := c.gens[key], c.epoch
+	if !found {
+		c.loading++
+	}
+	c.mu.Unlock()
+	if found {
+		return entry.value, nil
+	}
+
+	value, err := c.load(ctx, idb, id)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	_, inTx := idb.(bun.Tx)
+	// An invalidation since the load started may be the commit of a newer row than the one read
+	if err == nil && !inTx && c.gens[key] == gen && c.epoch == epoch {
+		c.entries[key] = entityEntry[T]{value: value, expiresAt: now.Add(c.ttl)}
+	}
+	if c.loading--; c.loading == 0 {
+		clear(c.gens)
+	}
+	return value, err
+}
+
+func (c *EntityCache[T]) load(ctx context.Context, idb bun.IDB, id any) (T, error) {
+	var value T
+	pk, err := entityPK[T](idb)
+	if err != nil {
+		return value, err
+	}
+	err = idb.NewSelect().Model(&value).Where("?TableAlias.? = ?", bun.Ident(pk.Name), id).Scan(ctx)
+	return value, err
+}
+
+// Update updates entity by primary key through t and invalidates its entry
+func (c *EntityCache[T]) Update(ctx context.Context, t *Transact, entity *T) error {
+	if _, err := t.Db().NewUpdate().Model(entity).WherePK().Exec(ctx); err != nil {
+		return err
+	}
+	return c.invalidateEntity(t, entity)
+}
+
+// Delete deletes entity by primary key through t and invalidates its entry
+func (c *EntityCache[T]) Delete(ctx context.Context, t *Transact, entity *T) error {
+	if _, err := t.Db().NewDelete().Model(entity).WherePK().Exec(ctx); err != nil {
+		return err
+	}
+	return c.invalidateEntity(t, entity)
+}
+
+// Invalidate drops the entry of id, for writes made without the cache
+func (c *EntityCache[T]) Invalidate(id any) {
+	key := fmt.Sprint(id)
+	c.mu.Lock()
+	delete(c.entries, key)
+	if c.loading > 0 {
+		c.gens[key]++
+	}
+	c.mu.Unlock()
+}
+
+// Purge drops all entries
+func (c *EntityCache[T]) Purge() {
+	c.mu.Lock()
+	clear(c.entries)
+	c.epoch++
+	c.mu.Unlock()
+}
+
+// Len returns the number of cached entries, including expired ones not yet dropped
+func (c *EntityCache[T]) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.entries)
+}
+
+func (c *EntityCache[T]) invalidateEntity(t *Transact, entity *T) error {
+	pk, err := entityPK[T](t.Db())
+	if err != nil {
+		return err
+	}
+	id := pk.Value(reflect.ValueOf(entity).Elem()).Interface()
+
+	// Once now, for readers outside of the transaction, and once after the commit, in case one of them cached
+	// the old row in between
+	c.Invalidate(id)
+	t.OnCommit(func(context.Context) { c.Invalidate(id) })
+	return nil
+}
+
+func entityPK[T any](idb bun.IDB) (*schema.Field, error) {
+	table := idb.Dialect().Tables().Get(reflect.TypeFor[T]())
+	if len(table.PKs) != 1 {
+		return nil, fmt.Errorf("entity cache needs a single primary key, %s has %d", table.TypeName, len(table.PKs))
+	}
+	return table.PKs[0], nil
+}
@@ -0,0 +1,127 @@
+package dbx
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/uptrace/bun"
+)
+
+type cachedItem struct {
+	bun.BaseModel `bun:"table:items"`
+	ID            int64  `bun:"id,pk,autoincrement"`
+	Name          string `bun:"name"`
+}
+
+func TestEntityCache(t *testing.T) {
+	ctx := context.Background()
+	db := setupTestDB(t)
+	insertItem(t, db, "original")
+	tx := mustNewTx(t, db)
+	cache := NewEntityCache[cachedItem](time.Minute)
+
+	item, err := cache.Get(ctx, db, 1)
+	if err != nil || item.Name != "original" {
+		t.Fatalf("expected original, got %+v (err %v)", item, err)
+	}
+
+	// Writes behind the cache's back are not seen until invalidated
+	if _, err := db.ExecContext(ctx, "UPDATE items SET name = 'sneaky' WHERE id = 1"); err != nil {
+		t.Fatalf("update failed: %v", err)
+	}
+	if item, _ = cache.Get(ctx, db, 1); item.Name != "original" {
+		t.Fatalf("expected cached value, got %q", item.Name)
+	}
+	cache.Invalidate(1)
+	if item, _ = cache.Get(ctx, db, 1); item.Name != "sneaky" {
+		t.Fatalf("expected reloaded value, got %q", item.Name)
+	}
+
+	err = tx.Transaction(nil, func(ctx context.Context) error {
+		item.Name = "updated"
+		if err := cache.Update(ctx, tx, &item); err != nil {
+			return err
+		}
+		// Reads inside the transaction see the update but do not cache it
+		if got, _ := cache.Get(ctx, tx.Db(), 1); got.Name != "updated" || cache.Len() != 0 {
+			t.Fatalf("expected uncached update inside the tx, got %q (%d entries)", got.Name, cache.Len())
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Transaction failed: %v", err)
+	}
+	if item, _ = cache.Get(ctx, db, 1); item.Name != "updated" {
+		t.Fatalf("expected entry invalidated on commit, got %q", item.Name)
+	}
+
+	if err := tx.Transaction(nil, func(ctx context.Context) error { return cache.Delete(ctx, tx, &item) }); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	if _, err := cache.Get(ctx, db, 1); !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf("expected sql.ErrNoRows after delete, got %v", err)
+	}
+
+	short := NewEntityCache[cachedItem](time.Millisecond)
+	insertItem(t, db, "expiring")
+	if _, err := short.Get(ctx, db, 2); err != nil || short.Len() != 1 {
+		t.Fatalf("expected 1 cached entry, got %d (err %v)", short.Len(), err)
+	}
+	time.Sleep(5 * time.Millisecond)
+	if _, err := db.ExecContext(ctx, "UPDATE items SET name = 'fresh' WHERE id = 2"); err != nil {
+		t.Fatalf("update failed: %v", err)
+	}
+	if got, _ := short.Get(ctx, db, 2); got.Name != "fresh" {
+		t.Fatalf("expected expired entry to be reloaded, got %q", got.Name)
+	}
+}
+
+// commitDuringLoad commits an update of item 1 right after the first select of items, once its row is read
+type commitDuringLoad struct {
+	t     *testing.T
+	tx    *Transact
+	cache *EntityCache[cachedItem]
+	done  bool
+}
+
+func (h *commitDuringLoad) BeforeQuery(ctx context.Context, _ *bun.QueryEvent) context.Context {
+	return ctx
+}
+
+func (h *commitDuringLoad) AfterQuery(_ context.Context, event *bun.QueryEvent) {
+	if h.done || event.Operation() != "SELECT" {
+		return
+	}
+	h.done = true
+	err := h.tx.Transaction(nil, func(ctx context.Context) error {
+		return h.cache.Update(ctx, h.tx, &cachedItem{ID: 1, Name: "committed"})
+	})
+	if err != nil {
+		h.t.Errorf("Transaction failed: %v", err)
+	}
+}
+
+func TestEntityCacheLoadRacingCommit(t *testing.T) {
+	ctx := context.Background()
+	db := setupTestDB(t)
+	insertItem(t, db, "original")
+	cache := NewEntityCache[cachedItem](time.Minute)
+	db.AddQueryHook(&commitDuringLoad{t: t, tx: mustNewTx(t, db), cache: cache})
+
+	// The load read the row before the commit, so it returns it but must not cache it
+	if item, err := cache.Get(ctx, db, 1); err != nil || item.Name != "original" {
+		t.Fatalf("expected the row read before the commit, got %+v (err %v)", item, err)
+	}
+	if cache.Len() != 0 {
+		t.Fatalf("expected the load racing the commit not to be cached, got %d entries", cache.Len())
+	}
+	if item, err := cache.Get(ctx, db, 1); err != nil || item.Name != "committed" {
+		t.Fatalf("expected the committed row, got %+v (err %v)", item, err)
+	}
+	if cache.Len() != 1 {
+		t.Fatalf("expected the next load to be cached, got %d entries", cache.Len())
+	}
+}
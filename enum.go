@@ -0,0 +1,159 @@
+package dbx
+
+import (
+	"context"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/dialect"
+)
+
+var ErrInvalidEnum = errors.New("invalid enum value")
+
+var (
+	enumsMu sync.RWMutex
+	enums   = map[reflect.Type][]string{}
+)
+
+// RegisterEnum records the allowed values of the string type T. It is meant to be called from an init function,
+// once per type; registering a type again replaces its values.
+func RegisterEnum[T ~string](values ...T) {
+	names := make([]string, len(values))
+	for i, v := range values {
+		names[i] = string(v)
+	}
+
+	enumsMu.Lock()
+	defer enumsMu.Unlock()
+	enums[reflect.TypeFor[T]()] = names
+}
+
+// EnumValues returns the registered values of T, in registration order
+func EnumValues[T ~string]() []T {
+	enumsMu.RLock()
+	defer enumsMu.RUnlock()
+
+	names := enums[reflect.TypeFor[T]()]
+	values := make([]T, len(names))
+	for i, name := range names {
+		values[i] = T(name)
+	}
+	return values
+}
+
+// ValidEnum reports whether v is a registered value of T. Unregistered types accept every value.
+func ValidEnum[T ~string](v T) bool {
+	enumsMu.RLock()
+	defer enumsMu.RUnlock()
+
+	names, found := enums[reflect.TypeFor[T]()]
+	return !found || slices.Contains(names, string(v))
+}
+
+// Enum is a column holding one of the registered values of T (see RegisterEnum). Values are checked when written
+// to and read from the db and when decoded from JSON, so a typo fails loudly instead of being stored.
+// The zero Enum is stored as NULL.
+type Enum[T ~string] struct {
+	Val T
+}
+
+// NewEnum returns an Enum holding v
+func NewEnum[T ~string](v T) Enum[T] {
+	return Enum[T]{Val: v}
+}
+
+func (e Enum[T]) String() string {
+	return string(e.Val)
+}
+
+func (e Enum[T]) Value() (driver.Value, error) {
+	if e.Val == "" {
+		return nil, nil
+	}
+	if !ValidEnum(e.Val) {
+		return nil, fmt.Errorf("%w: %T %q", ErrInvalidEnum, e.Val, e.Val)
+	}
+	return string(e.Val), nil
+}
+
+func (e *Enum[T]) Scan(src any) error {
+	var s string
+	switch v := src.(type) {
+	case nil:
+		e.Val = ""
+		return nil
+	case string:
+		s = v
+	case []byte:
+		s = string(v)
+	default:
+		return fmt.Errorf("%w: cannot scan %T into %T", ErrInvalidEnum, src, e.Val)
+	}
+	return e.set(T(s))
+}
+
+func (e Enum[T]) MarshalJSON() ([]byte, error) {
+	return json.Marshal(string(e.Val))
+}
+
+func (e *Enum[T]) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return err
+	}
+	return e.set(T(s))
+}
+
+func (e *Enum[T]) set(v T) error {
+	if v != "" && !ValidEnum(v) {
+		return fmt.Errorf("%w: %T %q", ErrInvalidEnum, v, v)
+	}
+	e.Val = v
+	return nil
+}
+
+// EnumCheck returns a CHECK constraint limiting column to the registered values of T, for table definitions in
+// migrations, e.g. `status TEXT NOT NULL ` + dbx.EnumCheck[Status]("status")
+func EnumCheck[T ~string](column string) string {
+	return fmt.Sprintf("CHECK (%s IN (%s))", column, enumList[T]())
+}
+
+// CreateEnumType creates the Postgres enum type typeName with the registered values of T, adding the values that are
+// missing when the type exists. Other dialects have no enum types, so it does nothing there; use EnumCheck instead.
+// ALTER TYPE ... ADD VALUE cannot run inside a transaction block before Postgres 12.
+func CreateEnumType[T ~string](ctx context.Context, idb bun.IDB, typeName string) error {
+	if idb.Dialect().Name() != dialect.PG {
+		return nil
+	}
+
+	var exists bool
+	if err := idb.NewRaw("SELECT EXISTS (SELECT 1 FROM pg_type WHERE typname = ?)", typeName).Scan(ctx, &exists); err != nil {
+		return err
+	}
+	if !exists {
+		_, err := idb.ExecContext(ctx, "CREATE TYPE ? AS ENUM ("+enumList[T]()+")", bun.Ident(typeName))
+		return err
+	}
+	for _, v := range EnumValues[T]() {
+		if _, err := idb.ExecContext(ctx, "ALTER TYPE ? ADD VALUE IF NOT EXISTS ?", bun.Ident(typeName), string(v)); err != nil {
+			return fmt.Errorf("failed to add enum value %q: %w", v, err)
+		}
+	}
+	return nil
+}
+
+func enumList[T ~string]() string {
+	values := EnumValues[T]()
+	quoted := make([]string, len(values))
+	for i, v := range values {
+		quoted[i] = "'" + strings.ReplaceAll(string(v), "'", "''") + "'"
+	}
+	return strings.Join(quoted, ", ")
+}
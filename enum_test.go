@@ -0,0 +1,54 @@
+package dbx
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+type testStatus string
+
+func init() {
+	RegisterEnum[testStatus]("active", "archived")
+}
+
+func TestEnum(t *testing.T) {
+	ctx := context.Background()
+	db := setupTestDB(t)
+	_, err := db.ExecContext(ctx, "CREATE TABLE docs (id INTEGER PRIMARY KEY, status TEXT "+EnumCheck[testStatus]("status")+")")
+	if err != nil {
+		t.Fatalf("create failed: %v", err)
+	}
+
+	if _, err := db.ExecContext(ctx, "INSERT INTO docs (status) VALUES (?)", NewEnum[testStatus]("active")); err != nil {
+		t.Fatalf("insert failed: %v", err)
+	}
+	if _, err := db.DB.ExecContext(ctx, "INSERT INTO docs (status) VALUES (?)", NewEnum[testStatus]("deleted")); !errors.Is(err, ErrInvalidEnum) {
+		t.Fatalf("expected ErrInvalidEnum on write, got %v", err)
+	}
+	// The CHECK constraint guards writes bypassing Enum
+	if _, err := db.ExecContext(ctx, "INSERT INTO docs (status) VALUES ('deleted')"); err == nil {
+		t.Fatalf("expected CHECK constraint to reject unknown value")
+	}
+
+	var status Enum[testStatus]
+	if err := db.NewRaw("SELECT status FROM docs").Scan(ctx, &status); err != nil {
+		t.Fatalf("scan failed: %v", err)
+	}
+	if status.Val != "active" {
+		t.Fatalf("expected active, got %q", status)
+	}
+
+	b, err := json.Marshal(status)
+	if err != nil || string(b) != `"active"` {
+		t.Fatalf("unexpected JSON %s (err %v)", b, err)
+	}
+	if err := json.Unmarshal([]byte(`"bogus"`), &status); !errors.Is(err, ErrInvalidEnum) {
+		t.Fatalf("expected ErrInvalidEnum on unmarshal, got %v", err)
+	}
+
+	if err := CreateEnumType[testStatus](ctx, db, "test_status"); err != nil {
+		t.Fatalf("expected CreateEnumType to be a no-op on sqlite, got %v", err)
+	}
+}
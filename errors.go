@@ -0,0 +1,151 @@
+package dbx
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+)
+
+// Kind classifies the cause of an Error, so callers can react to it (retry, 404, 409, ...) without matching
+// driver errors themselves
+type Kind uint8
+
+const (
+	KindUnknown Kind = iota
+	// KindNotFound: no row, or no cached database
+	KindNotFound
+	// KindConflict: a unique, foreign key, check or not null constraint was violated
+	KindConflict
+	// KindBusy: the database is locked or the transaction lost a serialization race; retrying may succeed
+	KindBusy
+	KindTimeout
+	KindCanceled
+	// KindClosed: the database, connection or cache is closed
+	KindClosed
+	// KindInvalid: invalid options or arguments
+	KindInvalid
+	// KindQuery: syntax error, or a table or column that does not exist
+	KindQuery
+	// KindMisuse: the API was used out of order, e.g. unbalanced or cross-goroutine transactions, or a query policy
+	// denied a query
+	KindMisuse
+)
+
+var kindNames = [...]string{"unknown", "not_found", "conflict", "busy", "timeout", "canceled", "closed", "invalid", "query", "misuse"}
+
+func (k Kind) String() string {
+	if int(k) < len(kindNames) {
+		return kindNames[k]
+	}
+	return "unknown"
+}
+
+// Error is returned by the operations of dbx, wrapping the driver or sentinel error Err with the operation that
+// failed and where. errors.Is and errors.As see through it, so checks like errors.Is(err, sql.ErrNoRows) or
+// errors.Is(err, dbx.ErrTxTooDeep) keep working.
+type Error struct {
+	// Op is the operation, e.g. "open", "migrate", "tx.commit"
+	Op string
+	// DB is the name of the database when known; SQLite file names are used, DSNs never are
+	DB string
+	// Table is set by the operations on a single table
+	Table string
+	Kind  Kind
+	Err   error
+}
+
+func (e *Error) Error() string {
+	var sb strings.Builder
+	sb.WriteString("dbx: ")
+	sb.WriteString(e.Op)
+	switch {
+	case e.DB != "" && e.Table != "":
+		sb.WriteString(" " + e.DB + "." + e.Table)
+	case e.DB != "":
+		sb.WriteString(" " + e.DB)
+	case e.Table != "":
+		sb.WriteString(" " + e.Table)
+	}
+	sb.WriteString(": ")
+	sb.WriteString(e.Err.Error())
+	return sb.String()
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// KindOf returns the Kind of err: the one of the *Error it wraps, or else the one err is classified as
+func KindOf(err error) Kind {
+	if err == nil {
+		return KindUnknown
+	}
+	var e *Error
+	if errors.As(err, &e) {
+		return e.Kind
+	}
+	return classifyError(err)
+}
+
+// wrapErr returns err as an *Error of op, unless it is nil or already carries one
+func wrapErr(op, db, table string, err error) error {
+	if err == nil {
+		return nil
+	}
+	var e *Error
+	if errors.As(err, &e) {
+		return err
+	}
+	return &Error{Op: op, DB: db, Table: table, Kind: classifyError(err), Err: err}
+}
+
+var (
+	conflictMessages = []string{"constraint failed", "violates", "duplicate key", "duplicate entry"}
+	queryMessages    = []string{"syntax error", "no such table", "no such column", "does not exist", "unknown column"}
+)
+
+func classifyError(err error) Kind {
+	switch {
+	case errors.Is(err, sql.ErrNoRows), errors.Is(err, ErrDatabaseNotFound):
+		return KindNotFound
+	case errors.Is(err, ErrTxActive), errors.Is(err, ErrTxWrongGoroutine), errors.Is(err, ErrUnbalancedTx),
+		errors.Is(err, ErrTxTooDeep), errors.Is(err, ErrPolicyDenied):
+		return KindMisuse
+	case errors.Is(err, context.DeadlineExceeded):
+		return KindTimeout
+	case errors.Is(err, context.Canceled), errors.Is(err, ErrTxAborted):
+		return KindCanceled
+	case errors.Is(err, ErrCacheClosed), errors.Is(err, sql.ErrConnDone), errors.Is(err, sql.ErrTxDone):
+		return KindClosed
+	case errors.Is(err, ErrInvalidOptions), errors.Is(err, ErrTreeCycle), errors.Is(err, ErrTreeMismatch):
+		return KindInvalid
+	case defaultRetryable(err):
+		return KindBusy
+	}
+
+	var stater interface{ SQLState() string }
+	if errors.As(err, &stater) {
+		switch state := stater.SQLState(); {
+		case strings.HasPrefix(state, "23"):
+			return KindConflict
+		case strings.HasPrefix(state, "42"):
+			return KindQuery
+		}
+	}
+	msg := strings.ToLower(err.Error())
+	for _, m := range conflictMessages {
+		if strings.Contains(msg, m) {
+			return KindConflict
+		}
+	}
+	for _, m := range queryMessages {
+		if strings.Contains(msg, m) {
+			return KindQuery
+		}
+	}
+	if strings.Contains(msg, "database is closed") {
+		return KindClosed
+	}
+	return KindUnknown
+}
@@ -0,0 +1,88 @@
+package dbx
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestError_Wrapping(t *testing.T) {
+	ctx := context.Background()
+
+	_, err := OpenDB("missing", WithDbFolder(t.TempDir()))
+	var dbErr *Error
+	if !errors.As(err, &dbErr) || dbErr.Op != "open" || dbErr.DB != "missing" {
+		t.Fatalf("expected an open *Error for db missing, got %#v", err)
+	}
+
+	cache := NewCache(time.Hour)
+	defer cache.Close()
+	_, err = cache.Get("tenant_x")
+	if !errors.Is(err, ErrDatabaseNotFound) || KindOf(err) != KindNotFound {
+		t.Fatalf("expected a not found error, got %v (kind %s)", err, KindOf(err))
+	}
+	if want := "dbx: cache.get tenant_x: database not found in cache: tenant_x"; err.Error() != want {
+		t.Fatalf("expected %q, got %q", want, err.Error())
+	}
+
+	db := setupTestDB(t)
+	tx, err := NewTransact(ctx, db, TransactWithMaxDepth(1))
+	if err != nil {
+		t.Fatalf("NewTransact failed: %v", err)
+	}
+	if _, err := tx.Start(nil); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	_, err = tx.Start(nil)
+	if !errors.Is(err, ErrTxTooDeep) || !errors.As(err, &dbErr) || dbErr.Op != "tx.start" || dbErr.Kind != KindMisuse {
+		t.Fatalf("expected a tx.start misuse error, got %#v", err)
+	}
+	if err := tx.Rollback(); err != nil {
+		t.Fatalf("Rollback failed: %v", err)
+	}
+	if err := tx.Rollback(); !errors.As(err, &dbErr) || dbErr.Op != "tx.rollback" {
+		t.Fatalf("expected a tx.rollback error, got %#v", err)
+	}
+
+	_, err = ArchiveRows(ctx, tx, "missing_table", "1 = 1", "archive")
+	if !errors.As(err, &dbErr) || dbErr.Table != "missing_table" || dbErr.Kind != KindQuery {
+		t.Fatalf("expected a query error on missing_table, got %#v", err)
+	}
+}
+
+func TestKindOf(t *testing.T) {
+	ctx := context.Background()
+	db := setupTestDB(t)
+	if _, err := db.ExecContext(ctx, "CREATE UNIQUE INDEX items_name ON items(name)"); err != nil {
+		t.Fatalf("create index failed: %v", err)
+	}
+	insertItem(t, db, "a")
+	_, conflict := db.ExecContext(ctx, "INSERT INTO items(name) VALUES ('a')")
+	_, syntax := db.ExecContext(ctx, "SELEC 1")
+
+	for _, tc := range []struct {
+		err  error
+		want Kind
+	}{
+		{nil, KindUnknown},
+		{sql.ErrNoRows, KindNotFound},
+		{fmt.Errorf("load: %w", sql.ErrNoRows), KindNotFound},
+		{conflict, KindConflict},
+		{syntax, KindQuery},
+		{errors.New("database is locked"), KindBusy},
+		{context.DeadlineExceeded, KindTimeout},
+		{context.Canceled, KindCanceled},
+		{ErrCacheClosed, KindClosed},
+		{ErrInvalidOptions, KindInvalid},
+		{ErrUnbalancedTx, KindMisuse},
+		{&Error{Op: "x", Kind: KindBusy, Err: errors.New("boom")}, KindBusy},
+		{errors.New("boom"), KindUnknown},
+	} {
+		if got := KindOf(tc.err); got != tc.want {
+			t.Errorf("KindOf(%v) = %s, want %s", tc.err, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package dbx
+
+import (
+	"sync"
+	"time"
+)
+
+// EventKind is the kind of an Event
+type EventKind uint8
+
+const (
+	// EventDBOpened is published when OpenDB opened a database
+	EventDBOpened EventKind = iota + 1
+	// EventDBClosed is published when a database of OpenDB is closed
+	EventDBClosed
+	// EventDBEvicted is published when a Cache closes a database inactive for too long
+	EventDBEvicted
+	// EventMigrationApplied is published when MigrateDB applied migrations, with the new Version
+	EventMigrationApplied
+	// EventBackupCompleted is published when a backup ended, with its Backup or the Err it failed with
+	EventBackupCompleted
+	// EventTxFailed is published when the Commit of a Transact failed, or its transaction was rolled back because
+	// its context was cancelled
+	EventTxFailed
+)
+
+var eventKindNames = [...]string{"", "db_opened", "db_closed", "db_evicted", "migration_applied", "backup_completed",
+	"tx_failed"}
+
+func (k EventKind) String() string {
+	if int(k) < len(eventKindNames) && k != 0 {
+		return eventKindNames[k]
+	}
+	return "unknown"
+}
+
+// Event is a lifecycle event of dbx, see Subscribe
+type Event struct {
+	Kind EventKind
+	Time time.Time
+	// DB is the name of the database: the SQLite name or the cache name, the database file of
+	// a backup, empty when unknown
+	DB string
+	// Version is the migration version of EventMigrationApplied
+	Version int64
+	// Backup is the backup of EventBackupCompleted, nil when it failed or had nothing to back up
+	Backup *BackupInfo
+	// Err is the error of EventTxFailed, or of a failed EventBackupCompleted
+	Err error
+}
+
+type subscriber struct {
+	kind EventKind
+	fn   func(Event)
+}
+
+var (
+	subscribersMu sync.RWMutex
+	subscribers   []*subscriber
+)
+
+// Subscribe calls fn with every event of kind, or of every kind with kind 0, until the returned function is called.
+// fn runs synchronously in the goroutine publishing the event, possibly concurrently with itself: it must be quick
+// and must not block, hand the event to a goroutine for slow work.
+func Subscribe(kind EventKind, fn func(Event)) (unsubscribe func()) {
+	s := &subscriber{kind: kind, fn: fn}
+	subscribersMu.Lock()
+	subscribers = append(subscribers, s)
+	subscribersMu.Unlock()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			subscribersMu.Lock()
+			defer subscribersMu.Unlock()
+			for i, o := range subscribers {
+				if o == s {
+					subscribers = append(subscribers[:i:i], subscribers[i+1:]...)
+					break
+				}
+			}
+		})
+	}
+}
+
+// publish calls the subscribers of the kind of ev
+func publish(ev Event) {
+	subscribersMu.RLock()
+	subs := subscribers
+	subscribersMu.RUnlock()
+	if len(subs) == 0 {
+		return
+	}
+
+	ev.Time = time.Now()
+	for _, s := range subs {
+		if s.kind == 0 || s.kind == ev.Kind {
+			s.fn(ev)
+		}
+	}
+}
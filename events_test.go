@@ -0,0 +1,105 @@
+package dbx
+
+import (
+	"context"
+	"path/filepath"
+	"slices"
+	"sync"
+	"testing"
+)
+
+// eventLog collects the events of the databases of a test
+type eventLog struct {
+	mu     sync.Mutex
+	events []Event
+}
+
+func (l *eventLog) add(ev Event) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.events = append(l.events, ev)
+}
+
+// kinds returns the kinds of the events of db, in order
+func (l *eventLog) kinds(db string) []EventKind {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	var kinds []EventKind
+	for _, ev := range l.events {
+		if ev.DB == db {
+			kinds = append(kinds, ev.Kind)
+		}
+	}
+	return kinds
+}
+
+// find returns the last event of kind, nil without any
+func (l *eventLog) find(kind EventKind) *Event {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for i := len(l.events) - 1; i >= 0; i-- {
+		if l.events[i].Kind == kind {
+			return &l.events[i]
+		}
+	}
+	return nil
+}
+
+func TestSubscribe(t *testing.T) {
+	ctx := context.Background()
+	tmp := t.TempDir()
+	var all, backups eventLog
+	unsubscribe := Subscribe(0, all.add)
+	defer unsubscribe()
+	defer Subscribe(EventBackupCompleted, backups.add)()
+
+	if err := MigrateDB("events", CreateWithDbFolder(tmp), CreateWithSource(testMigrations),
+		CreateWithSrcFolder("testmigrations"), CreateWithLogger(nil)); err != nil {
+		t.Fatalf("MigrateDB failed: %v", err)
+	}
+	// Nothing left to apply
+	if err := MigrateDB("events", CreateWithDbFolder(tmp), CreateWithSource(testMigrations),
+		CreateWithSrcFolder("testmigrations"), CreateWithLogger(nil)); err != nil {
+		t.Fatalf("MigrateDB failed: %v", err)
+	}
+	db, err := OpenDB("events", WithDbFolder(tmp))
+	if err != nil {
+		t.Fatalf("OpenDB failed: %v", err)
+	}
+	info, err := BackupDB(ctx, db, NewDirSink(filepath.Join(tmp, "backups")))
+	if err != nil {
+		t.Fatalf("BackupDB failed: %v", err)
+	}
+	if err := db.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	want := []EventKind{EventMigrationApplied, EventDBOpened, EventDBClosed}
+	if got := all.kinds("events"); !slices.Equal(got, want) {
+		t.Fatalf("expected events %v, got %v", want, got)
+	}
+	if ev := backups.find(EventBackupCompleted); ev == nil || ev.Backup.Name != info.Name ||
+		ev.DB != filepath.Join(tmp, "events.db") {
+		t.Fatalf("expected the event of the backup, got %+v", ev)
+	}
+
+	tsx, err := NewTransact(ctx, db)
+	if err != nil {
+		t.Fatalf("NewTransact failed: %v", err)
+	}
+	commitErr := tsx.Commit()
+	if ev := all.find(EventTxFailed); commitErr == nil || ev == nil || ev.Err != commitErr {
+		t.Fatalf("expected the failed commit %v, got %+v", commitErr, ev)
+	}
+
+	unsubscribe()
+	n := len(all.kinds("events"))
+	db, err = OpenDB("events", WithDbFolder(tmp))
+	if err != nil {
+		t.Fatalf("OpenDB failed: %v", err)
+	}
+	_ = db.Close()
+	if got := len(all.kinds("events")); got != n {
+		t.Fatalf("expected no events after unsubscribing, got %d more", got-n)
+	}
+}
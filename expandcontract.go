@@ -0,0 +1,329 @@
+package dbx
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/dialect"
+	"github.com/uptrace/bun/schema"
+)
+
+// ErrParityMismatch is returned by the verify step of an ExpandContract when the shadow differs from the source
+var ErrParityMismatch = errors.New("shadow does not match its source")
+
+// ExpandStep is a step of an ExpandContract, in the order they run
+type ExpandStep string
+
+const (
+	// StepExpand runs the Expand statements and installs the dual-write triggers
+	StepExpand ExpandStep = "expand"
+	// StepBackfill copies the existing rows into the shadow, in batches of BatchSize
+	StepBackfill ExpandStep = "backfill"
+	// StepVerify checks the shadow matches its source on every row
+	StepVerify ExpandStep = "verify"
+	// StepContract drops the triggers and runs the Contract statements
+	StepContract ExpandStep = "contract"
+)
+
+var expandSteps = []ExpandStep{StepExpand, StepBackfill, StepVerify, StepContract}
+
+// ExpandContractState is a row of the dbx_expand_contract table, the progress of an ExpandContract
+type ExpandContractState struct {
+	bun.BaseModel `bun:"table:dbx_expand_contract"`
+
+	Name string `bun:"name,pk"`
+	// Step is the last completed step, empty before the expand
+	Step      ExpandStep `bun:"step,notnull"`
+	UpdatedAt time.Time  `bun:"updated_at,notnull"`
+}
+
+// ExpandContract changes the shape of a table without downtime: the new shape (the shadow) is added next to the old
+// one, kept in sync by triggers while both releases of the application run, backfilled, verified, and only then
+// the old shape is dropped. The shadow is either new columns of Table, or a ShadowTable replacing it.
+//
+// Each step runs once: the progress is kept in the dbx_expand_contract table under Name, so Run resumes after a
+// crash or a deploy, the backfill from its last batch (see Backfill, named dbx_ec_<Name>). Until the contract, the old shape is the source of truth:
+// the triggers overwrite the shadow from it on every insert and update.
+//
+// Supported dialects are SQLite and Postgres.
+type ExpandContract struct {
+	// Name identifies the change in the state table
+	Name string
+	// Table is the table changed, with its prefix and suffix added like TableName
+	Table string
+	// Key is the integer primary key of Table walked by the backfill, "id" when empty
+	Key string
+	// Columns maps each shadow column to the SQL expression computing it from a row of Table, e.g.
+	// {"price_cents": "CAST(price * 100 AS INTEGER)"}
+	Columns map[string]string
+	// ShadowTable is the table replacing Table, holding Key and Columns. Empty, Columns are added to Table.
+	ShadowTable string
+	// Expand creates the shadow: ALTER TABLE ... ADD COLUMN of Columns, or CREATE TABLE of ShadowTable
+	Expand []string
+	// Contract switches to the shadow once no release reads the old shape: e.g. drop the old column and rename
+	// the shadow one, or drop Table and rename ShadowTable to it
+	Contract []string
+	// BatchSize is the number of rows backfilled per transaction, 1000 when 0
+	BatchSize int
+	// Progress, when set, is called after each batch of the backfill with the rows done out of the total
+	Progress func(done, total int64)
+}
+
+func (ec *ExpandContract) validate(idb bun.IDB) error {
+	if dName := idb.Dialect().Name(); dName != dialect.SQLite && dName != dialect.PG {
+		return fmt.Errorf("unsupported dialect: %s", dName)
+	}
+	if !moduleNameRe.MatchString(ec.Name) {
+		return fmt.Errorf("%w: invalid expand/contract name %q", ErrInvalidOptions, ec.Name)
+	}
+	if ec.Table == "" || len(ec.Columns) == 0 {
+		return fmt.Errorf("%w: expand/contract %s needs a table and shadow columns", ErrInvalidOptions, ec.Name)
+	}
+	return nil
+}
+
+// State returns the progress of ec, a zero Step when it has not started
+func (ec *ExpandContract) State(ctx context.Context, idb bun.IDB) (*ExpandContractState, error) {
+	if _, err := idb.NewCreateTable().Model((*ExpandContractState)(nil)).IfNotExists().Exec(ctx); err != nil {
+		return nil, fmt.Errorf("failed to create expand/contract state table: %w", err)
+	}
+	state := &ExpandContractState{Name: ec.Name}
+	err := idb.NewSelect().Model(state).WherePK().Scan(ctx)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, err
+	}
+	return state, nil
+}
+
+// Run runs the steps of ec not completed yet, up to and including until. A deploy typically runs up to
+// StepVerify, and the contract once every instance of the new release is up. Each step and each batch of the
+// backfill commits on its own, so t must not have an active transaction.
+func (ec *ExpandContract) Run(ctx context.Context, t *Transact, until ExpandStep) error {
+	if t.active() {
+		return fmt.Errorf("cannot run expand/contract: %w", ErrTxActive)
+	}
+	if err := ec.validate(t.db); err != nil {
+		return err
+	}
+	last := slices.Index(expandSteps, until)
+	if last < 0 {
+		return fmt.Errorf("%w: unknown expand/contract step %q", ErrInvalidOptions, until)
+	}
+	state, err := ec.State(ctx, t.db)
+	if err != nil {
+		return err
+	}
+
+	for _, step := range expandSteps[min(slices.Index(expandSteps, state.Step)+1, last+1) : last+1] {
+		switch step {
+		case StepExpand:
+			err = ec.expand(ctx, t, state)
+		case StepBackfill:
+			err = ec.backfill(ctx, t, state)
+		case StepVerify:
+			err = ec.verify(ctx, t, state)
+		case StepContract:
+			err = ec.contract(ctx, t, state)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to %s %s: %w", step, ec.Name, err)
+		}
+	}
+	return nil
+}
+
+// complete saves step as done in the transaction of t
+func (ec *ExpandContract) complete(ctx context.Context, t *Transact, state *ExpandContractState, step ExpandStep) error {
+	next := *state
+	next.Step, next.UpdatedAt = step, time.Now().UTC()
+	if _, err := upsert(t.Db().NewInsert().Model(&next), "name").Exec(ctx); err != nil {
+		return fmt.Errorf("failed to save expand/contract state: %w", err)
+	}
+	*state = next
+	return nil
+}
+
+func (ec *ExpandContract) expand(ctx context.Context, t *Transact, state *ExpandContractState) error {
+	return t.Transaction(nil, func(ctx context.Context) error {
+		db := t.Db()
+		for _, stmt := range ec.Expand {
+			if _, err := db.ExecContext(ctx, stmt); err != nil {
+				return err
+			}
+		}
+		for _, stmt := range ec.triggers(db) {
+			if _, err := db.ExecContext(ctx, stmt); err != nil {
+				return fmt.Errorf("failed to install dual-write triggers: %w", err)
+			}
+		}
+		return ec.complete(ctx, t, state, StepExpand)
+	})
+}
+
+func (ec *ExpandContract) backfill(ctx context.Context, t *Transact, state *ExpandContractState) error {
+	q := ec.quoter(t.db)
+	err := Backfill(ctx, t.db, BackfillSpec{
+		Name:      "dbx_ec_" + ec.Name,
+		Table:     ec.Table,
+		Key:       ec.Key,
+		BatchSize: ec.BatchSize,
+		Progress:  ec.Progress,
+		Transform: func(ctx context.Context, idb bun.IDB, keys []int64) error {
+			where := fmt.Sprintf("%s >= %d AND %s <= %d", q.key, keys[0], q.key, keys[len(keys)-1])
+			for _, stmt := range ec.syncRows(q, where) {
+				if _, err := idb.ExecContext(ctx, stmt); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	})
+	if err != nil {
+		return err
+	}
+	return t.Transaction(nil, func(ctx context.Context) error {
+		return ec.complete(ctx, t, state, StepBackfill)
+	})
+}
+
+func (ec *ExpandContract) verify(ctx context.Context, t *Transact, state *ExpandContractState) error {
+	q := ec.quoter(t.db)
+	source := fmt.Sprintf("SELECT %s, %s FROM %s", q.key, strings.Join(q.exprs, ", "), q.table)
+	shadow := fmt.Sprintf("SELECT %s, %s FROM %s", q.key, strings.Join(q.columns, ", "), q.shadow)
+	var missing, extra int
+	if err := t.db.NewRaw(fmt.Sprintf("SELECT COUNT(*) FROM (%s EXCEPT %s) AS d", source, shadow)).
+		Scan(ctx, &missing); err != nil {
+		return err
+	}
+	if err := t.db.NewRaw(fmt.Sprintf("SELECT COUNT(*) FROM (%s EXCEPT %s) AS d", shadow, source)).
+		Scan(ctx, &extra); err != nil {
+		return err
+	}
+	if missing > 0 || extra > 0 {
+		return fmt.Errorf("%w: %d rows differ, %d extra in %s", ErrParityMismatch, missing, extra, ec.Name)
+	}
+	return t.Transaction(nil, func(ctx context.Context) error {
+		return ec.complete(ctx, t, state, StepVerify)
+	})
+}
+
+func (ec *ExpandContract) contract(ctx context.Context, t *Transact, state *ExpandContractState) error {
+	return t.Transaction(nil, func(ctx context.Context) error {
+		db := t.Db()
+		name := "dbx_ec_" + ec.Name
+		drops := []string{`DROP TRIGGER IF EXISTS ?0`, `DROP TRIGGER IF EXISTS ?1`, `DROP TRIGGER IF EXISTS ?2`}
+		if db.Dialect().Name() == dialect.PG {
+			drops = []string{`DROP TRIGGER IF EXISTS ?3 ON ?4`, `DROP FUNCTION IF EXISTS ?5()`}
+		}
+		for _, stmt := range drops {
+			if _, err := db.ExecContext(ctx, stmt, bun.Ident(name+"_ins"), bun.Ident(name+"_upd"),
+				bun.Ident(name+"_del"), bun.Ident(name), bun.Ident(TableName(db, ec.Table)),
+				bun.Ident(name+"_fn")); err != nil {
+				return fmt.Errorf("failed to drop dual-write triggers: %w", err)
+			}
+		}
+		for _, stmt := range ec.Contract {
+			if _, err := db.ExecContext(ctx, stmt); err != nil {
+				return err
+			}
+		}
+		return ec.complete(ctx, t, state, StepContract)
+	})
+}
+
+// ecQuoter holds the quoted names and the expressions of an ExpandContract, in the order of its sorted columns
+type ecQuoter struct {
+	table, shadow, key string
+	columns, exprs     []string
+	quote              func(string) string
+}
+
+func (ec *ExpandContract) quoter(idb bun.IDB) ecQuoter {
+	quote := func(s string) string {
+		return string(schema.NewFormatter(idb.Dialect()).AppendIdent(nil, s))
+	}
+	key := ec.Key
+	if key == "" {
+		key = "id"
+	}
+	q := ecQuoter{table: quote(TableName(idb, ec.Table)), key: quote(key), quote: quote}
+	q.shadow = q.table
+	if ec.ShadowTable != "" {
+		q.shadow = quote(TableName(idb, ec.ShadowTable))
+	}
+	for _, column := range slices.Sorted(maps.Keys(ec.Columns)) {
+		q.columns = append(q.columns, quote(column))
+		q.exprs = append(q.exprs, "("+ec.Columns[column]+")")
+	}
+	return q
+}
+
+// syncRows returns the statements copying the rows of the source matching where into the shadow
+func (ec *ExpandContract) syncRows(q ecQuoter, where string) []string {
+	if ec.ShadowTable == "" {
+		sets := make([]string, len(q.columns))
+		for i := range q.columns {
+			sets[i] = q.columns[i] + " = " + q.exprs[i]
+		}
+		return []string{fmt.Sprintf("UPDATE %s SET %s WHERE %s", q.table, strings.Join(sets, ", "), where)}
+	}
+	return []string{
+		fmt.Sprintf("DELETE FROM %s WHERE %s", q.shadow, where),
+		fmt.Sprintf("INSERT INTO %s (%s, %s) SELECT %s, %s FROM %s WHERE %s", q.shadow, q.key,
+			strings.Join(q.columns, ", "), q.key, strings.Join(q.exprs, ", "), q.table, where),
+	}
+}
+
+// triggers returns the statements installing the dual-write triggers of ec
+func (ec *ExpandContract) triggers(idb bun.IDB) []string {
+	q := ec.quoter(idb)
+	quote := q.quote
+	name := "dbx_ec_" + ec.Name
+	syncNew := strings.Join(ec.syncRows(q, q.key+" = NEW."+q.key), ";\n")
+	deleteOld := fmt.Sprintf("DELETE FROM %s WHERE %s = OLD.%s", q.shadow, q.key, q.key)
+
+	if idb.Dialect().Name() == dialect.PG {
+		onDelete, guard := "", ""
+		if ec.ShadowTable != "" {
+			onDelete = fmt.Sprintf("IF TG_OP IN ('DELETE', 'UPDATE') THEN %s; END IF;", deleteOld)
+		} else {
+			// The UPDATE of the shadow columns fires the trigger again
+			guard = "WHEN (pg_trigger_depth() < 1) "
+		}
+		return []string{
+			fmt.Sprintf(`CREATE OR REPLACE FUNCTION %s() RETURNS trigger AS $$
+			BEGIN
+				%s
+				IF TG_OP IN ('INSERT', 'UPDATE') THEN %s; END IF;
+				RETURN NULL;
+			END;
+			$$ LANGUAGE plpgsql`, quote(name+"_fn"), onDelete, syncNew),
+			fmt.Sprintf(`DROP TRIGGER IF EXISTS %s ON %s`, quote(name), q.table),
+			fmt.Sprintf(`CREATE TRIGGER %s AFTER INSERT OR DELETE OR UPDATE ON %s FOR EACH ROW %sEXECUTE FUNCTION %s()`,
+				quote(name), q.table, guard, quote(name+"_fn")),
+		}
+	}
+
+	// SQLite does not fire the triggers again for their own UPDATE unless recursive_triggers is on
+	statements := []string{
+		fmt.Sprintf("CREATE TRIGGER IF NOT EXISTS %s AFTER INSERT ON %s BEGIN %s; END",
+			quote(name+"_ins"), q.table, syncNew),
+	}
+	if ec.ShadowTable == "" {
+		return append(statements, fmt.Sprintf("CREATE TRIGGER IF NOT EXISTS %s AFTER UPDATE ON %s BEGIN %s; END",
+			quote(name+"_upd"), q.table, syncNew))
+	}
+	return append(statements,
+		fmt.Sprintf("CREATE TRIGGER IF NOT EXISTS %s AFTER UPDATE ON %s BEGIN %s; %s; END",
+			quote(name+"_upd"), q.table, deleteOld, syncNew),
+		fmt.Sprintf("CREATE TRIGGER IF NOT EXISTS %s AFTER DELETE ON %s BEGIN %s; END",
+			quote(name+"_del"), q.table, deleteOld),
+	)
+}
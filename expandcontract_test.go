@@ -0,0 +1,176 @@
+package dbx
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+)
+
+func TestExpandContract_Column(t *testing.T) {
+	db := setupTestDB(t)
+	ctx := context.Background()
+	for _, name := range []string{"a", "b", "c", "d", "e"} {
+		insertItem(t, db, name)
+	}
+	tx, err := NewTransact(ctx, db)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	runCtx, cancel := context.WithCancel(ctx)
+	var progress []int64
+	ec := &ExpandContract{
+		Name:    "items_upper",
+		Table:   "items",
+		Columns: map[string]string{"name_upper": "upper(name)"},
+		Expand:  []string{`ALTER TABLE items ADD COLUMN name_upper TEXT`},
+		Contract: []string{
+			`ALTER TABLE items DROP COLUMN name`,
+			`ALTER TABLE items RENAME COLUMN name_upper TO name`,
+		},
+		BatchSize: 2,
+		Progress: func(done, total int64) {
+			progress = append(progress, done)
+			cancel() // the backfill stops after its first batch
+		},
+	}
+	if err := ec.Run(ctx, tx, StepExpand); err != nil {
+		t.Fatalf("expand: %v", err)
+	}
+
+	// The old release keeps writing name; the triggers write the shadow
+	insertItem(t, db, "f")
+	if _, err := db.ExecContext(ctx, `UPDATE items SET name = 'bb' WHERE name = 'b'`); err != nil {
+		t.Fatal(err)
+	}
+	var shadow []string
+	if err := db.NewRaw(`SELECT COALESCE(name_upper, '') FROM items ORDER BY id`).Scan(ctx, &shadow); err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"", "BB", "", "", "", "F"}; !slices.Equal(shadow, want) {
+		t.Errorf("expected shadow %v after dual writes, got %v", want, shadow)
+	}
+
+	if err := ec.Run(runCtx, tx, StepVerify); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected the backfill to be canceled, got %v", err)
+	}
+	state, err := ec.State(ctx, db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkpoint, err := BackfillStatus(ctx, db, "dbx_ec_items_upper")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if state.Step != StepExpand || checkpoint.LastKey != 2 || checkpoint.Done != 2 {
+		t.Errorf("expected backfill checkpoint at key 2, got %+v, %+v", state, checkpoint)
+	}
+
+	ec.Progress = func(done, total int64) {
+		progress = append(progress, done)
+		if total != 6 {
+			t.Errorf("expected 6 rows in total, got %d", total)
+		}
+	}
+	if err := ec.Run(ctx, tx, StepVerify); err != nil {
+		t.Fatalf("resume: %v", err)
+	}
+	if want := []int64{2, 4, 6}; !slices.Equal(progress, want) {
+		t.Errorf("expected progress %v, got %v", want, progress)
+	}
+
+	if err := ec.Run(ctx, tx, StepContract); err != nil {
+		t.Fatalf("contract: %v", err)
+	}
+	var names []string
+	if err := db.NewRaw(`SELECT name FROM items ORDER BY id`).Scan(ctx, &names); err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"A", "BB", "C", "D", "E", "F"}; !slices.Equal(names, want) {
+		t.Errorf("expected names %v after the switch, got %v", want, names)
+	}
+	var triggers int
+	if err := db.NewRaw(`SELECT COUNT(*) FROM sqlite_master WHERE type = 'trigger'`).Scan(ctx, &triggers); err != nil {
+		t.Fatal(err)
+	}
+	if triggers != 0 {
+		t.Errorf("expected the triggers dropped, %d left", triggers)
+	}
+	if err := ec.Run(ctx, tx, StepContract); err != nil {
+		t.Errorf("running a completed change again: %v", err)
+	}
+}
+
+func TestExpandContract_Table(t *testing.T) {
+	db := setupTestDB(t)
+	ctx := context.Background()
+	for _, name := range []string{"a", "b", "c"} {
+		insertItem(t, db, name)
+	}
+	tx, err := NewTransact(ctx, db)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ec := &ExpandContract{
+		Name:        "items_label",
+		Table:       "items",
+		ShadowTable: "items_v2",
+		Columns:     map[string]string{"label": "'#' || name"},
+		Expand:      []string{`CREATE TABLE items_v2 (id INTEGER PRIMARY KEY, label TEXT NOT NULL)`},
+		Contract:    []string{`DROP TABLE items`, `ALTER TABLE items_v2 RENAME TO items`},
+	}
+	if err := ec.Run(ctx, tx, StepBackfill); err != nil {
+		t.Fatalf("expand and backfill: %v", err)
+	}
+	insertItem(t, db, "d")
+	if _, err := db.ExecContext(ctx, `DELETE FROM items WHERE name = 'a'`); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.ExecContext(ctx, `UPDATE items SET name = 'cc' WHERE name = 'c'`); err != nil {
+		t.Fatal(err)
+	}
+
+	// A row lost from the shadow fails the verify, until a write of its source copies it again
+	if _, err := db.ExecContext(ctx, `DELETE FROM items_v2 WHERE label = '#b'`); err != nil {
+		t.Fatal(err)
+	}
+	if err := ec.Run(ctx, tx, StepContract); !errors.Is(err, ErrParityMismatch) {
+		t.Fatalf("expected ErrParityMismatch, got %v", err)
+	}
+	if _, err := db.ExecContext(ctx, `UPDATE items SET name = name WHERE name = 'b'`); err != nil {
+		t.Fatal(err)
+	}
+	if err := ec.Run(ctx, tx, StepContract); err != nil {
+		t.Fatalf("verify and contract: %v", err)
+	}
+
+	var labels []string
+	if err := db.NewRaw(`SELECT label FROM items ORDER BY id`).Scan(ctx, &labels); err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"#b", "#cc", "#d"}; !slices.Equal(labels, want) {
+		t.Errorf("expected labels %v after the switch, got %v", want, labels)
+	}
+}
+
+func TestExpandContract_Invalid(t *testing.T) {
+	db := setupTestDB(t)
+	tx, err := NewTransact(context.Background(), db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, ec := range []*ExpandContract{
+		{Name: "bad name", Table: "items", Columns: map[string]string{"x": "1"}},
+		{Name: "no_columns", Table: "items"},
+	} {
+		if err := ec.Run(context.Background(), tx, StepVerify); !errors.Is(err, ErrInvalidOptions) {
+			t.Errorf("%s: expected ErrInvalidOptions, got %v", ec.Name, err)
+		}
+	}
+	ec := &ExpandContract{Name: "ok", Table: "items", Columns: map[string]string{"x": "1"}}
+	if err := ec.Run(context.Background(), tx, "switch"); !errors.Is(err, ErrInvalidOptions) {
+		t.Errorf("expected ErrInvalidOptions for an unknown step, got %v", err)
+	}
+}
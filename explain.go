@@ -0,0 +1,123 @@
+package dbx
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/dialect"
+)
+
+// WithExplainOnError logs the SQL of queries failing with a syntax, schema or constraint error to logger (slog.Default
+// when nil), along with the DDL of the tables they reference. Meant for development and tests, not production:
+// the log holds the bound values, and reading the DDL costs extra queries.
+func WithExplainOnError(logger *slog.Logger) OpenOptFn {
+	return func(opt *Options) {
+		if logger == nil {
+			logger = slog.Default()
+		}
+		opt.explainLogger = logger
+	}
+}
+
+type explainHook struct {
+	logger *slog.Logger
+}
+
+var _ bun.QueryHook = explainHook{}
+
+func (h explainHook) BeforeQuery(ctx context.Context, _ *bun.QueryEvent) context.Context {
+	return ctx
+}
+
+func (h explainHook) AfterQuery(ctx context.Context, event *bun.QueryEvent) {
+	if event.Err == nil || !explainableError(event.Err) {
+		return
+	}
+
+	attrs := []any{"err", event.Err.Error(), "query", event.Query}
+	for _, table := range referencedTables(event.Query) {
+		if ddl := tableDDL(context.WithoutCancel(ctx), event.DB, table); ddl != "" {
+			attrs = append(attrs, "table."+table, ddl)
+		}
+	}
+	h.logger.Error("query failed", attrs...)
+}
+
+var explainableMessages = []string{"syntax error", "constraint", "no such table", "no such column", "has no column",
+	"does not exist", "unknown column", "doesn't exist", "violates", "duplicate key", "datatype mismatch"}
+
+// explainableError reports whether err is a syntax, schema or constraint error, as opposed to e.g. a lock timeout
+func explainableError(err error) bool {
+	if errors.Is(err, sql.ErrNoRows) || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	var stater interface{ SQLState() string }
+	if errors.As(err, &stater) {
+		// Integrity constraint violations and syntax or access rule violations
+		state := stater.SQLState()
+		return strings.HasPrefix(state, "23") || strings.HasPrefix(state, "42")
+	}
+	msg := strings.ToLower(err.Error())
+	return slices.ContainsFunc(explainableMessages, func(m string) bool { return strings.Contains(msg, m) })
+}
+
+var referencedTableRe = regexp.MustCompile("(?i)\\b(?:FROM|JOIN|INTO|UPDATE|TABLE)\\s+[\"`]?([A-Za-z_][A-Za-z0-9_]*)[\"`]?")
+
+// referencedTables returns the tables named after FROM, JOIN, INTO, UPDATE and TABLE in query, without duplicates
+func referencedTables(query string) []string {
+	var tables []string
+	for _, m := range referencedTableRe.FindAllStringSubmatch(query, -1) {
+		if !slices.Contains(tables, m[1]) {
+			tables = append(tables, m[1])
+		}
+	}
+	return tables
+}
+
+// tableDDL returns the definition of table, or "" when it cannot be read. It queries the pool directly, bypassing
+// the hooks, with a short timeout: the failed query may hold the only connection of the pool in a transaction.
+func tableDDL(ctx context.Context, db *bun.DB, table string) string {
+	ctx, cancel := context.WithTimeout(ctx, 200*time.Millisecond)
+	defer cancel()
+
+	var ddl string
+	var err error
+	switch db.Dialect().Name() {
+	case dialect.SQLite:
+		err = db.DB.QueryRowContext(ctx, "SELECT sql FROM sqlite_master WHERE type = 'table' AND name = ?", table).Scan(&ddl)
+	case dialect.MySQL:
+		var name string
+		err = db.DB.QueryRowContext(ctx, fmt.Sprintf("SHOW CREATE TABLE `%s`", table)).Scan(&name, &ddl)
+	case dialect.PG:
+		var columns []string
+		rows, qErr := db.DB.QueryContext(ctx, `SELECT column_name || ' ' || data_type || CASE WHEN is_nullable = 'NO' THEN ' NOT NULL' ELSE '' END
+			FROM information_schema.columns WHERE table_schema = current_schema() AND table_name = $1 ORDER BY ordinal_position`, table)
+		if qErr != nil {
+			return ""
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var col string
+			if err := rows.Scan(&col); err != nil {
+				return ""
+			}
+			columns = append(columns, col)
+		}
+		if len(columns) > 0 {
+			ddl = fmt.Sprintf("TABLE %s (%s)", table, strings.Join(columns, ", "))
+		}
+		err = rows.Err()
+	}
+	if err != nil {
+		return ""
+	}
+	return ddl
+}
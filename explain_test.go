@@ -0,0 +1,67 @@
+package dbx
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestExplainOnError(t *testing.T) {
+	ctx := context.Background()
+	tmp := t.TempDir()
+	dsn := filepath.Join(tmp, "explain.sqlite")
+	if _, err := createSQLiteDBFile(ctx, dsn, tmp); err != nil {
+		t.Fatalf("createSQLiteDBFile failed: %v", err)
+	}
+	var buf bytes.Buffer
+	db, err := OpenDB(dsn, WithDbFolder(tmp), WithExplainOnError(slog.New(slog.NewTextHandler(&buf, nil))))
+	if err != nil {
+		t.Fatalf("OpenDB failed: %v", err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+	if _, err := db.ExecContext(ctx, "CREATE TABLE items (id INTEGER PRIMARY KEY, name TEXT NOT NULL)"); err != nil {
+		t.Fatalf("create table failed: %v", err)
+	}
+
+	// Constraint error: the interpolated query and the table DDL are logged
+	if _, err := db.ExecContext(ctx, "INSERT INTO items (id, name) VALUES (?, NULL)", 7); err == nil {
+		t.Fatalf("expected a NOT NULL error")
+	}
+	out := buf.String()
+	if !strings.Contains(out, "INSERT INTO items (id, name) VALUES (7, NULL)") || !strings.Contains(out, "CREATE TABLE items") {
+		t.Fatalf("expected the query and DDL in the log, got %q", out)
+	}
+
+	// Other errors are not logged
+	buf.Reset()
+	var n int
+	if err := db.NewRaw("SELECT id FROM items WHERE id = 1").Scan(ctx, &n); err == nil {
+		t.Fatalf("expected sql.ErrNoRows")
+	}
+	if buf.Len() != 0 {
+		t.Fatalf("expected no log for ErrNoRows, got %q", buf.String())
+	}
+
+	// Inside a transaction holding the only connection, the query is still logged
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		t.Fatalf("BeginTx failed: %v", err)
+	}
+	defer tx.Rollback()
+	if _, err := tx.ExecContext(ctx, "SELEC 1"); err == nil {
+		t.Fatalf("expected a syntax error")
+	}
+	if !strings.Contains(buf.String(), "SELEC 1") {
+		t.Fatalf("expected the failed query in the log, got %q", buf.String())
+	}
+}
+
+func TestReferencedTables(t *testing.T) {
+	got := referencedTables(`SELECT * FROM "items" AS i JOIN tags t ON t.item_id = i.id WHERE i.id IN (SELECT id FROM items)`)
+	if strings.Join(got, ",") != "items,tags" {
+		t.Fatalf("unexpected tables %v", got)
+	}
+}
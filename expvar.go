@@ -0,0 +1,65 @@
+package dbx
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"expvar"
+	"sync"
+	"sync/atomic"
+
+	"github.com/uptrace/bun"
+)
+
+// metrics are maintained for every db opened by OpenDB, and only published by EnableExpvar
+var metrics struct {
+	openDBs atomic.Int64
+	queries atomic.Int64
+	errors  atomic.Int64
+}
+
+// liveCaches holds the caches not closed yet, for the dbx.cache_size metric
+var liveCaches sync.Map // *Cache -> struct{}
+
+var expvarOnce sync.Once
+
+// EnableExpvar publishes the core metrics of dbx with expvar, served as JSON on /debug/vars by the default mux,
+// for apps without Prometheus:
+//
+//   - dbx.cache_size: databases held by the caches
+//   - dbx.open_dbs: databases opened by OpenDB and not closed yet
+//   - dbx.queries: queries run on them, transaction statements included
+//   - dbx.query_errors: queries that failed, sql.ErrNoRows excluded
+//
+// The counters run from the start of the process, whenever EnableExpvar is called. Calling it again is a no-op.
+func EnableExpvar() {
+	expvarOnce.Do(func() {
+		expvar.Publish("dbx.cache_size", expvar.Func(func() any {
+			n := 0
+			liveCaches.Range(func(key, _ any) bool {
+				n += key.(*Cache).len()
+				return true
+			})
+			return n
+		}))
+		expvar.Publish("dbx.open_dbs", expvar.Func(func() any { return metrics.openDBs.Load() }))
+		expvar.Publish("dbx.queries", expvar.Func(func() any { return metrics.queries.Load() }))
+		expvar.Publish("dbx.query_errors", expvar.Func(func() any { return metrics.errors.Load() }))
+	})
+}
+
+// metricsHook counts the queries and errors of the dbs opened by OpenDB
+type metricsHook struct{}
+
+var _ bun.QueryHook = metricsHook{}
+
+func (metricsHook) BeforeQuery(ctx context.Context, _ *bun.QueryEvent) context.Context {
+	return ctx
+}
+
+func (metricsHook) AfterQuery(_ context.Context, event *bun.QueryEvent) {
+	metrics.queries.Add(1)
+	if event.Err != nil && !errors.Is(event.Err, sql.ErrNoRows) {
+		metrics.errors.Add(1)
+	}
+}
@@ -0,0 +1,64 @@
+package dbx
+
+import (
+	"context"
+	"expvar"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestEnableExpvar(t *testing.T) {
+	ctx := context.Background()
+	EnableExpvar()
+	EnableExpvar()
+
+	read := func(name string) int64 {
+		t.Helper()
+		v := expvar.Get(name)
+		if v == nil {
+			t.Fatalf("%s not published", name)
+		}
+		n, err := strconv.ParseInt(v.String(), 10, 64)
+		if err != nil {
+			t.Fatalf("%s is not a number: %s", name, v.String())
+		}
+		return n
+	}
+
+	openDBs := read("dbx.open_dbs")
+	db := setupTestDB(t)
+	if n := read("dbx.open_dbs"); n != openDBs+1 {
+		t.Fatalf("expected %d open dbs, got %d", openDBs+1, n)
+	}
+
+	cache := NewCache(time.Hour)
+	cacheSize := read("dbx.cache_size")
+	cache.Set("tenant_expvar", db)
+	if n := read("dbx.cache_size"); n != cacheSize+1 {
+		t.Fatalf("expected cache size %d, got %d", cacheSize+1, n)
+	}
+
+	queries, errs := read("dbx.queries"), read("dbx.query_errors")
+	insertItem(t, db, "a")
+	if _, err := db.ExecContext(ctx, "SELECT * FROM missing_table"); err == nil {
+		t.Fatal("expected an error querying a missing table")
+	}
+	if n := read("dbx.queries"); n != queries+2 {
+		t.Fatalf("expected %d queries, got %d", queries+2, n)
+	}
+	if n := read("dbx.query_errors"); n != errs+1 {
+		t.Fatalf("expected %d query errors, got %d", errs+1, n)
+	}
+
+	// Closing the cache closes its dbs
+	if err := cache.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	if n := read("dbx.cache_size"); n != cacheSize {
+		t.Fatalf("expected cache size %d after close, got %d", cacheSize, n)
+	}
+	if n := read("dbx.open_dbs"); n != openDBs {
+		t.Fatalf("expected %d open dbs after close, got %d", openDBs, n)
+	}
+}
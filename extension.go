@@ -0,0 +1,64 @@
+package dbx
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"unicode"
+)
+
+// extensionLoader is implemented by mattn/go-sqlite3 connections
+type extensionLoader interface {
+	LoadExtension(lib string, entry string) error
+}
+
+// WithExtension loads SQLite runtime extensions (e.g. sqlite-vec, spellfix) on every connection.
+// Paths are passed to sqlite3_load_extension; the entry point is derived from the file name the way SQLite does
+// (vec0.so -> sqlite3_vec_init), falling back to sqlite3_extension_init.
+// Requires the mattn/go-sqlite3 driver (DriverSQLite) built without sqlite_omit_load_extension.
+func WithExtension(path ...string) OpenOptFn {
+	return func(opt *Options) {
+		opt.extensions = append(opt.extensions, path...)
+	}
+}
+
+func extensionHook(paths []string) connHook {
+	return func(conn driver.Conn) error {
+		loader, ok := conn.(extensionLoader)
+		if !ok {
+			return unsupportedConnErr(conn, "loading extensions")
+		}
+
+		for _, path := range paths {
+			err := loader.LoadExtension(path, extensionEntryPoint(path))
+			if err != nil {
+				if err2 := loader.LoadExtension(path, "sqlite3_extension_init"); err2 == nil {
+					continue
+				}
+				return fmt.Errorf("failed to load extension %s: %w", path, err)
+			}
+		}
+		return nil
+	}
+}
+
+// extensionEntryPoint follows sqlite3_load_extension: take the file name without a "lib" prefix,
+// keep the letters up to the first dot and wrap them as sqlite3_<name>_init.
+func extensionEntryPoint(path string) string {
+	base := filepath.Base(path)
+	if len(base) >= 3 && strings.EqualFold(base[:3], "lib") {
+		base = base[3:]
+	}
+
+	var name strings.Builder
+	for _, r := range base {
+		if r == '.' {
+			break
+		}
+		if r < unicode.MaxASCII && unicode.IsLetter(r) {
+			name.WriteRune(unicode.ToLower(r))
+		}
+	}
+	return "sqlite3_" + name.String() + "_init"
+}
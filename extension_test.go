@@ -0,0 +1,32 @@
+package dbx
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestExtensionEntryPoint(t *testing.T) {
+	tests := map[string]string{
+		"vec0.so":                 "sqlite3_vec_init",
+		"/usr/lib/libspellfix.so": "sqlite3_spellfix_init",
+		"./ext/my-ext_1.dylib":    "sqlite3_myext_init",
+	}
+	for path, want := range tests {
+		if got := extensionEntryPoint(path); got != want {
+			t.Errorf("extensionEntryPoint(%q) got = %v, want %v", path, got, want)
+		}
+	}
+}
+
+func TestWithExtension_MissingFile(t *testing.T) {
+	tmp := t.TempDir()
+	if err := CreateDB("exttest", CreateWithDbFolder(tmp)); err != nil {
+		t.Fatalf("CreateDB failed: %v", err)
+	}
+
+	_, err := OpenDB("exttest", WithDbFolder(tmp), WithExtension(filepath.Join(tmp, "missing.so")))
+	if err == nil || !strings.Contains(err.Error(), "failed to load extension") {
+		t.Fatalf("expected extension load error, got %v", err)
+	}
+}
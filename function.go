@@ -0,0 +1,68 @@
+package dbx
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"reflect"
+)
+
+// functionRegistrar is implemented by mattn/go-sqlite3 connections
+type functionRegistrar interface {
+	RegisterFunc(name string, impl any, pure bool) error
+	RegisterAggregator(name string, impl any, pure bool) error
+}
+
+type sqlFunc struct {
+	name string
+	fn   any
+}
+
+// WithSQLFunc registers a Go-implemented SQL function on every SQLite connection, e.g. regexp() or uuid().
+//
+// A scalar function is any func taking and returning values the driver can convert, optionally returning an error
+// as second result: func(pattern, s string) (bool, error). An aggregate function is given as a constructor
+// returning a type with Step and Done methods: func() *sumAgg.
+// Functions are registered as non-deterministic, so SQLite does not cache their results.
+//
+// Requires the mattn/go-sqlite3 driver (DriverSQLite). modernc.org/sqlite (DriverSQLiteMc) only registers functions
+// process-wide, before opening, and dbx does not depend on it: OpenDB rejects WithSQLFunc with DriverSQLiteMc, call
+// its RegisterFunction instead.
+func WithSQLFunc(name string, fn any) OpenOptFn {
+	return func(opt *Options) {
+		opt.sqlFuncs = append(opt.sqlFuncs, sqlFunc{name: name, fn: fn})
+	}
+}
+
+func sqlFuncHook(funcs []sqlFunc) connHook {
+	return func(conn driver.Conn) error {
+		registrar, ok := conn.(functionRegistrar)
+		if !ok {
+			return unsupportedConnErr(conn, "registering SQL functions")
+		}
+
+		for _, f := range funcs {
+			var err error
+			if isAggregateConstructor(f.fn) {
+				err = registrar.RegisterAggregator(f.name, f.fn, false)
+			} else {
+				err = registrar.RegisterFunc(f.name, f.fn, false)
+			}
+			if err != nil {
+				return fmt.Errorf("failed to register SQL function %s: %w", f.name, err)
+			}
+		}
+		return nil
+	}
+}
+
+// isAggregateConstructor reports whether fn is a func() T where T has Step and Done methods
+func isAggregateConstructor(fn any) bool {
+	t := reflect.TypeOf(fn)
+	if t == nil || t.Kind() != reflect.Func || t.NumIn() != 0 || t.NumOut() != 1 {
+		return false
+	}
+	out := t.Out(0)
+	_, hasStep := out.MethodByName("Step")
+	_, hasDone := out.MethodByName("Done")
+	return hasStep && hasDone
+}
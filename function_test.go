@@ -0,0 +1,66 @@
+package dbx
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"testing"
+)
+
+type sumSquares struct {
+	total int64
+}
+
+func (s *sumSquares) Step(v int64) { s.total += v * v }
+func (s *sumSquares) Done() int64  { return s.total }
+
+func TestWithSQLFunc(t *testing.T) {
+	tmp := t.TempDir()
+	if err := CreateDB("functest", CreateWithDbFolder(tmp)); err != nil {
+		t.Fatalf("CreateDB failed: %v", err)
+	}
+
+	db, err := OpenDB("functest", WithDbFolder(tmp),
+		WithMaxOpenConns(2),
+		WithSQLFunc("regexp", func(pattern, s string) (bool, error) {
+			return regexp.MatchString(pattern, s)
+		}),
+		WithSQLFunc("sum_squares", func() *sumSquares { return &sumSquares{} }),
+	)
+	if err != nil {
+		t.Fatalf("OpenDB failed: %v", err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+
+	ctx := context.Background()
+	// Hold one connection so the query runs on a second one: functions must exist on every connection
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		t.Fatalf("Conn failed: %v", err)
+	}
+	defer conn.Close()
+
+	var matched bool
+	if err := db.QueryRowContext(ctx, "SELECT 'dbx-42' REGEXP '^dbx-[0-9]+$'").Scan(&matched); err != nil {
+		t.Fatalf("REGEXP query failed: %v", err)
+	}
+	if !matched {
+		t.Fatal("expected REGEXP to match")
+	}
+
+	var total int64
+	if err := conn.QueryRowContext(ctx, "SELECT sum_squares(value) FROM (SELECT 1 AS value UNION ALL SELECT 2 UNION ALL SELECT 3)").Scan(&total); err != nil {
+		t.Fatalf("aggregate query failed: %v", err)
+	}
+	if total != 14 {
+		t.Fatalf("expected 14, got %d", total)
+	}
+}
+
+func TestWithSQLFunc_Modernc(t *testing.T) {
+	err := ValidateOptions(WithDriverName(DriverSQLiteMc),
+		WithSQLFunc("regexp", func(pattern, s string) (bool, error) { return regexp.MatchString(pattern, s) }))
+	if !errors.Is(err, ErrInvalidOptions) || !contains(err.Error(), "sqlite.RegisterFunction") {
+		t.Fatalf("expected WithSQLFunc to be rejected with modernc, got %v", err)
+	}
+}
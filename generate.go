@@ -0,0 +1,195 @@
+package dbx
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"go/format"
+	"io"
+	"slices"
+	"strings"
+
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/dialect"
+)
+
+type genColumn struct {
+	Table    string `bun:"table_name"`
+	Name     string `bun:"column_name"`
+	Type     string `bun:"data_type"`
+	Nullable bool   `bun:"nullable"`
+	PK       bool   `bun:"pk"`
+	AutoInc  bool   `bun:"autoinc"`
+}
+
+// GenerateModels reads the tables of the live schema and writes a Go source file of package pkg to w, declaring one
+// bun model struct per table. Column types are mapped per dialect; nullable columns become pointers.
+// Internal tables (sqlite_, goose version and dbx_ tables) are skipped. The output is gofmt'ed.
+func GenerateModels(ctx context.Context, db *bun.DB, pkg string, w io.Writer) error {
+	columns, err := introspectColumns(ctx, db)
+	if err != nil {
+		return fmt.Errorf("failed to read schema: %w", err)
+	}
+	dName := db.Dialect().Name()
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "// Code generated by dbx.GenerateModels. DO NOT EDIT.\n\npackage %s\n\n", pkg)
+
+	imports := []string{"github.com/uptrace/bun"}
+	for _, col := range columns {
+		var imp string
+		switch goColumnType(dName, col.Type) {
+		case "time.Time":
+			imp = "time"
+		case "json.RawMessage":
+			imp = "encoding/json"
+		}
+		if imp != "" && !slices.Contains(imports, imp) {
+			imports = append(imports, imp)
+		}
+	}
+	slices.Sort(imports)
+	buf.WriteString("import (\n")
+	for _, imp := range imports {
+		fmt.Fprintf(&buf, "\t%q\n", imp)
+	}
+	buf.WriteString(")\n")
+
+	for i, col := range columns {
+		if i == 0 || columns[i-1].Table != col.Table {
+			if i > 0 {
+				buf.WriteString("}\n")
+			}
+			fmt.Fprintf(&buf, "\ntype %s struct {\n\tbun.BaseModel `bun:\"table:%s\"`\n\n", goIdentifier(col.Table), col.Table)
+		}
+
+		goType := goColumnType(dName, col.Type)
+		if col.Nullable && !col.PK && goType != "[]byte" && goType != "json.RawMessage" {
+			goType = "*" + goType
+		}
+		tag := col.Name
+		if col.PK {
+			tag += ",pk"
+		}
+		if col.AutoInc {
+			tag += ",autoincrement"
+		}
+		if !col.Nullable && !col.PK {
+			tag += ",notnull"
+		}
+		fmt.Fprintf(&buf, "\t%s %s `bun:\"%s\"`\n", goIdentifier(col.Name), goType, tag)
+	}
+	if len(columns) > 0 {
+		buf.WriteString("}\n")
+	}
+
+	src, err := format.Source(buf.Bytes())
+	if err != nil {
+		return fmt.Errorf("failed to format generated code: %w", err)
+	}
+	_, err = w.Write(src)
+	return err
+}
+
+func introspectColumns(ctx context.Context, db *bun.DB) ([]genColumn, error) {
+	var query string
+	switch dName := db.Dialect().Name(); dName {
+	case dialect.SQLite:
+		// An INTEGER PRIMARY KEY is an alias of the rowid and gets values assigned
+		query = `SELECT m.name AS table_name, c.name AS column_name, c.type AS data_type,
+				c."notnull" = 0 AS nullable, c.pk > 0 AS pk,
+				c.pk > 0 AND upper(c.type) = 'INTEGER' AND (SELECT COUNT(*) FROM pragma_table_info(m.name) WHERE pk > 0) = 1 AS autoinc
+			FROM sqlite_master m JOIN pragma_table_info(m.name) c
+			WHERE m.type = 'table' AND m.name NOT LIKE 'sqlite\_%' ESCAPE '\'
+			ORDER BY m.name, c.cid`
+	case dialect.PG:
+		query = `SELECT c.table_name, c.column_name, c.data_type, c.is_nullable = 'YES' AS nullable,
+				EXISTS (SELECT 1 FROM information_schema.table_constraints tc
+					JOIN information_schema.key_column_usage k ON k.constraint_name = tc.constraint_name AND k.table_schema = tc.table_schema
+					WHERE tc.constraint_type = 'PRIMARY KEY' AND tc.table_schema = c.table_schema
+					AND k.table_name = c.table_name AND k.column_name = c.column_name) AS pk,
+				c.is_identity = 'YES' OR coalesce(c.column_default, '') LIKE 'nextval(%' AS autoinc
+			FROM information_schema.columns c
+			JOIN information_schema.tables t ON t.table_schema = c.table_schema AND t.table_name = c.table_name
+			WHERE c.table_schema = current_schema() AND t.table_type = 'BASE TABLE'
+			ORDER BY c.table_name, c.ordinal_position`
+	case dialect.MySQL:
+		query = `SELECT c.table_name AS table_name, c.column_name AS column_name,
+				IF(c.column_type = 'tinyint(1)', 'boolean', c.data_type) AS data_type,
+				c.is_nullable = 'YES' AS nullable, c.column_key = 'PRI' AS pk, c.extra LIKE '%auto_increment%' AS autoinc
+			FROM information_schema.columns c
+			JOIN information_schema.tables t ON t.table_schema = c.table_schema AND t.table_name = c.table_name
+			WHERE c.table_schema = DATABASE() AND t.table_type = 'BASE TABLE'
+			ORDER BY c.table_name, c.ordinal_position`
+	default:
+		return nil, fmt.Errorf("unsupported dialect: %s", dName)
+	}
+
+	var columns []genColumn
+	if err := db.NewRaw(query).Scan(ctx, &columns); err != nil {
+		return nil, err
+	}
+	return slices.DeleteFunc(columns, func(col genColumn) bool {
+		return strings.HasPrefix(col.Table, "goose_db_version") || strings.HasPrefix(col.Table, "dbx_")
+	}), nil
+}
+
+// goColumnType maps a column type to a Go type. SQLite types follow the column affinity rules.
+func goColumnType(dName dialect.Name, dbType string) string {
+	t := strings.ToLower(dbType)
+	if dName == dialect.SQLite {
+		switch {
+		case strings.Contains(t, "bool"):
+			return "bool"
+		case strings.Contains(t, "date"), strings.Contains(t, "time"):
+			return "time.Time"
+		case strings.Contains(t, "int"):
+			return "int64"
+		case strings.Contains(t, "char"), strings.Contains(t, "clob"), strings.Contains(t, "text"):
+			return "string"
+		case strings.Contains(t, "json"):
+			return "json.RawMessage"
+		case t == "", strings.Contains(t, "blob"):
+			return "[]byte"
+		default:
+			return "float64"
+		}
+	}
+
+	switch {
+	case t == "boolean", t == "bool":
+		return "bool"
+	case strings.Contains(t, "int"):
+		return "int64"
+	case strings.HasPrefix(t, "timestamp"), t == "date", t == "datetime", strings.HasPrefix(t, "time"):
+		return "time.Time"
+	case t == "real", t == "float", t == "double", t == "double precision", t == "numeric", t == "decimal":
+		return "float64"
+	case t == "bytea", strings.Contains(t, "blob"), strings.Contains(t, "binary"):
+		return "[]byte"
+	case t == "json", t == "jsonb":
+		return "json.RawMessage"
+	default:
+		return "string"
+	}
+}
+
+var goInitialisms = map[string]string{"id": "ID", "url": "URL", "uri": "URI", "uuid": "UUID", "api": "API",
+	"http": "HTTP", "ip": "IP", "json": "JSON", "sql": "SQL", "html": "HTML"}
+
+// goIdentifier converts a snake_case name into an exported Go identifier
+func goIdentifier(name string) string {
+	var sb strings.Builder
+	for _, part := range strings.FieldsFunc(name, func(r rune) bool { return r == '_' || r == '-' || r == ' ' || r == '.' }) {
+		if initialism, ok := goInitialisms[strings.ToLower(part)]; ok {
+			sb.WriteString(initialism)
+			continue
+		}
+		sb.WriteString(strings.ToUpper(part[:1]) + part[1:])
+	}
+	ident := sb.String()
+	if ident == "" || (ident[0] >= '0' && ident[0] <= '9') {
+		ident = "X" + ident
+	}
+	return ident
+}
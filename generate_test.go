@@ -0,0 +1,62 @@
+package dbx
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestGenerateModels(t *testing.T) {
+	ctx := context.Background()
+	db := setupTestDB(t)
+	_, err := db.ExecContext(ctx, `CREATE TABLE user_profiles (
+		id INTEGER PRIMARY KEY,
+		avatar_url TEXT,
+		score REAL NOT NULL,
+		created_at DATETIME NOT NULL,
+		active BOOLEAN NOT NULL DEFAULT 1,
+		payload BLOB
+	)`)
+	if err != nil {
+		t.Fatalf("create failed: %v", err)
+	}
+
+	var out bytes.Buffer
+	if err := GenerateModels(ctx, db, "models", &out); err != nil {
+		t.Fatalf("GenerateModels failed: %v", err)
+	}
+	src := out.String()
+
+	for _, want := range []string{
+		"package models",
+		`"time"`,
+		"type Items struct",
+		"type UserProfiles struct",
+		"bun.BaseModel `bun:\"table:user_profiles\"`",
+		"ID        int64     `bun:\"id,pk,autoincrement\"`",
+		"AvatarURL *string   `bun:\"avatar_url\"`",
+		"Score     float64   `bun:\"score,notnull\"`",
+		"CreatedAt time.Time `bun:\"created_at,notnull\"`",
+		"Active    bool      `bun:\"active,notnull\"`",
+		"Payload   []byte    `bun:\"payload\"`",
+	} {
+		if !strings.Contains(src, want) {
+			t.Fatalf("expected generated code to contain %q, got:\n%s", want, src)
+		}
+	}
+}
+
+func TestGoIdentifier(t *testing.T) {
+	tests := map[string]string{
+		"user_id":    "UserID",
+		"api_key":    "APIKey",
+		"name":       "Name",
+		"2fa_secret": "X2faSecret",
+	}
+	for in, want := range tests {
+		if got := goIdentifier(in); got != want {
+			t.Fatalf("goIdentifier(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
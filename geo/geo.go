@@ -0,0 +1,218 @@
+package geo
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/dialect"
+)
+
+const earthRadius = 6371008.8 // meters
+
+// Point is a WGS84 location
+type Point struct {
+	Lng float64
+	Lat float64
+}
+
+// BBox is a WGS84 bounding box
+type BBox struct {
+	MinLng, MinLat float64
+	MaxLng, MaxLat float64
+}
+
+// Located is a row returned by Nearest, with its distance to the query point in meters
+type Located struct {
+	ID       int64   `bun:"id"`
+	Point    Point   `bun:"-"`
+	Distance float64 `bun:"distance"`
+}
+
+// CreateSpatialIndex creates a table indexing point locations by an int64 id.
+// On SQLite it is an R-Tree virtual table (the rtree module is built into mattn/go-sqlite3 and modernc.org/sqlite);
+// on Postgres a PostGIS geometry(Point, 4326) column with a GiST index, enabling the extension if needed.
+func CreateSpatialIndex(ctx context.Context, idb bun.IDB, table string) error {
+	switch dName := idb.Dialect().Name(); dName {
+	case dialect.SQLite:
+		_, err := idb.ExecContext(ctx,
+			"CREATE VIRTUAL TABLE IF NOT EXISTS ? USING rtree(id, min_lng, max_lng, min_lat, max_lat)",
+			bun.Ident(table))
+		return err
+	case dialect.PG:
+		if _, err := idb.ExecContext(ctx, "CREATE EXTENSION IF NOT EXISTS postgis"); err != nil {
+			return fmt.Errorf("failed to enable postgis: %w", err)
+		}
+		if _, err := idb.ExecContext(ctx,
+			"CREATE TABLE IF NOT EXISTS ? (id BIGINT PRIMARY KEY, geom geometry(Point, 4326) NOT NULL)",
+			bun.Ident(table)); err != nil {
+			return err
+		}
+		_, err := idb.ExecContext(ctx, "CREATE INDEX IF NOT EXISTS ? ON ? USING GIST (geom)",
+			bun.Ident(table+"_geom_idx"), bun.Ident(table))
+		return err
+	default:
+		return fmt.Errorf("unsupported dialect: %s", dName)
+	}
+}
+
+// UpsertLocation stores p under id, replacing any previous location
+func UpsertLocation(ctx context.Context, idb bun.IDB, table string, id int64, p Point) error {
+	switch dName := idb.Dialect().Name(); dName {
+	case dialect.SQLite:
+		_, err := idb.ExecContext(ctx,
+			"INSERT OR REPLACE INTO ? (id, min_lng, max_lng, min_lat, max_lat) VALUES (?, ?, ?, ?, ?)",
+			bun.Ident(table), id, p.Lng, p.Lng, p.Lat, p.Lat)
+		return err
+	case dialect.PG:
+		_, err := idb.ExecContext(ctx,
+			"INSERT INTO ? (id, geom) VALUES (?, ST_SetSRID(ST_MakePoint(?, ?), 4326)) ON CONFLICT (id) DO UPDATE SET geom = EXCLUDED.geom",
+			bun.Ident(table), id, p.Lng, p.Lat)
+		return err
+	default:
+		return fmt.Errorf("unsupported dialect: %s", dName)
+	}
+}
+
+// DeleteLocation removes the location stored under id
+func DeleteLocation(ctx context.Context, idb bun.IDB, table string, id int64) error {
+	_, err := idb.ExecContext(ctx, "DELETE FROM ? WHERE id = ?", bun.Ident(table), id)
+	return err
+}
+
+// WithinBBox returns the ids of the locations inside box
+func WithinBBox(ctx context.Context, idb bun.IDB, table string, box BBox) ([]int64, error) {
+	located, err := withinBBox(ctx, idb, table, box)
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]int64, 0, len(located))
+	for _, l := range located {
+		ids = append(ids, l.ID)
+	}
+	return ids, nil
+}
+
+func withinBBox(ctx context.Context, idb bun.IDB, table string, box BBox) ([]Located, error) {
+	var (
+		query string
+		args  []any
+	)
+	switch dName := idb.Dialect().Name(); dName {
+	case dialect.SQLite:
+		query = "SELECT id, min_lng, min_lat FROM ? WHERE min_lng >= ? AND max_lng <= ? AND min_lat >= ? AND max_lat <= ? ORDER BY id"
+		args = []any{bun.Ident(table), box.MinLng, box.MaxLng, box.MinLat, box.MaxLat}
+	case dialect.PG:
+		query = "SELECT id, ST_X(geom), ST_Y(geom) FROM ? WHERE geom && ST_MakeEnvelope(?, ?, ?, ?, 4326) ORDER BY id"
+		args = []any{bun.Ident(table), box.MinLng, box.MinLat, box.MaxLng, box.MaxLat}
+	default:
+		return nil, fmt.Errorf("unsupported dialect: %s", dName)
+	}
+
+	rows, err := idb.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var located []Located
+	for rows.Next() {
+		var l Located
+		if err := rows.Scan(&l.ID, &l.Point.Lng, &l.Point.Lat); err != nil {
+			return nil, err
+		}
+		located = append(located, l)
+	}
+	return located, rows.Err()
+}
+
+// Nearest returns the k locations closest to p by great-circle distance, closest first.
+// Postgres uses the PostGIS KNN operator. SQLite R-Trees have no KNN search, so the box around p is widened
+// until it holds k candidates, then widened once more to the kth distance so no closer point outside it is missed.
+func Nearest(ctx context.Context, idb bun.IDB, table string, p Point, k int) ([]Located, error) {
+	if k <= 0 {
+		return nil, nil
+	}
+
+	switch dName := idb.Dialect().Name(); dName {
+	case dialect.PG:
+		var located []Located
+		err := idb.NewRaw(`
+			SELECT id, ST_Distance(geom::geography, ST_SetSRID(ST_MakePoint(?0, ?1), 4326)::geography) AS distance
+			FROM ?2 ORDER BY geom <-> ST_SetSRID(ST_MakePoint(?0, ?1), 4326) LIMIT ?3`,
+			p.Lng, p.Lat, bun.Ident(table), k,
+		).Scan(ctx, &located)
+		return located, err
+	case dialect.SQLite:
+		return nearestSQLite(ctx, idb, table, p, k)
+	default:
+		return nil, fmt.Errorf("unsupported dialect: %s", dName)
+	}
+}
+
+func nearestSQLite(ctx context.Context, idb bun.IDB, table string, p Point, k int) ([]Located, error) {
+	const maxRadius = math.Pi * earthRadius // half the circumference covers the globe
+
+	radius := 1000.0
+	var candidates []Located
+	for {
+		var err error
+		if candidates, err = withinBBox(ctx, idb, table, bboxAround(p, radius)); err != nil {
+			return nil, err
+		}
+		if len(candidates) >= k || radius >= maxRadius {
+			break
+		}
+		radius = math.Min(radius*4, maxRadius)
+	}
+
+	sortByDistance(candidates, p)
+	if len(candidates) >= k && candidates[k-1].Distance > radius && radius < maxRadius {
+		var err error
+		if candidates, err = withinBBox(ctx, idb, table, bboxAround(p, candidates[k-1].Distance)); err != nil {
+			return nil, err
+		}
+		sortByDistance(candidates, p)
+	}
+
+	if len(candidates) > k {
+		candidates = candidates[:k]
+	}
+	return candidates, nil
+}
+
+func sortByDistance(located []Located, p Point) {
+	for i := range located {
+		located[i].Distance = Distance(p, located[i].Point)
+	}
+	sort.SliceStable(located, func(i, j int) bool { return located[i].Distance < located[j].Distance })
+}
+
+// bboxAround returns a box containing every point within radius meters of p.
+// Boxes crossing the antimeridian are widened to all longitudes.
+func bboxAround(p Point, radius float64) BBox {
+	dLat := radius / earthRadius * 180 / math.Pi
+	box := BBox{MinLat: math.Max(p.Lat-dLat, -90), MaxLat: math.Min(p.Lat+dLat, 90), MinLng: -180, MaxLng: 180}
+
+	// near the poles (or for huge radii) every longitude is in reach
+	cosLat := math.Cos(math.Max(math.Abs(box.MinLat), math.Abs(box.MaxLat)) * math.Pi / 180)
+	if cosLat > 1e-9 {
+		dLng := dLat / cosLat
+		if p.Lng-dLng >= -180 && p.Lng+dLng <= 180 {
+			box.MinLng, box.MaxLng = p.Lng-dLng, p.Lng+dLng
+		}
+	}
+	return box
+}
+
+// Distance returns the great-circle distance between a and b in meters (haversine formula)
+func Distance(a, b Point) float64 {
+	lat1, lat2 := a.Lat*math.Pi/180, b.Lat*math.Pi/180
+	dLat := lat2 - lat1
+	dLng := (b.Lng - a.Lng) * math.Pi / 180
+
+	h := math.Sin(dLat/2)*math.Sin(dLat/2) + math.Cos(lat1)*math.Cos(lat2)*math.Sin(dLng/2)*math.Sin(dLng/2)
+	return 2 * earthRadius * math.Asin(math.Min(1, math.Sqrt(h)))
+}
@@ -0,0 +1,71 @@
+package geo
+
+import (
+	"context"
+	"math"
+	"testing"
+
+	"github.com/actanonv/dbx"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+func TestDistance(t *testing.T) {
+	// Paris to London is about 343.5 km
+	d := Distance(Point{Lng: 2.3522, Lat: 48.8566}, Point{Lng: -0.1276, Lat: 51.5072})
+	if math.Abs(d-343_500) > 1_000 {
+		t.Fatalf("Distance() got = %v", d)
+	}
+}
+
+func TestSpatialIndex_SQLite(t *testing.T) {
+	ctx := context.Background()
+	tmp := t.TempDir()
+	if err := dbx.CreateDB("geo", dbx.CreateWithDbFolder(tmp)); err != nil {
+		t.Fatalf("CreateDB failed: %v", err)
+	}
+	db, err := dbx.OpenDB("geo", dbx.WithDbFolder(tmp))
+	if err != nil {
+		t.Fatalf("OpenDB failed: %v", err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+
+	if err := CreateSpatialIndex(ctx, db, "places"); err != nil {
+		t.Fatalf("CreateSpatialIndex failed: %v", err)
+	}
+
+	places := map[int64]Point{
+		1: {Lng: 2.3522, Lat: 48.8566},    // Paris
+		2: {Lng: -0.1276, Lat: 51.5072},   // London
+		3: {Lng: 13.4050, Lat: 52.5200},   // Berlin
+		4: {Lng: 151.2093, Lat: -33.8688}, // Sydney
+	}
+	for id, p := range places {
+		if err := UpsertLocation(ctx, db, "places", id, p); err != nil {
+			t.Fatalf("UpsertLocation failed: %v", err)
+		}
+	}
+
+	ids, err := WithinBBox(ctx, db, "places", BBox{MinLng: -5, MinLat: 45, MaxLng: 5, MaxLat: 55})
+	if err != nil {
+		t.Fatalf("WithinBBox failed: %v", err)
+	}
+	if len(ids) != 2 || ids[0] != 1 || ids[1] != 2 {
+		t.Fatalf("expected Paris and London, got %v", ids)
+	}
+
+	// From Brussels: Paris (~264km), London (~320km), Berlin (~650km)
+	nearest, err := Nearest(ctx, db, "places", Point{Lng: 4.3517, Lat: 50.8503}, 3)
+	if err != nil {
+		t.Fatalf("Nearest failed: %v", err)
+	}
+	if len(nearest) != 3 || nearest[0].ID != 1 || nearest[1].ID != 2 || nearest[2].ID != 3 {
+		t.Fatalf("unexpected nearest %+v", nearest)
+	}
+
+	if err := DeleteLocation(ctx, db, "places", 1); err != nil {
+		t.Fatalf("DeleteLocation failed: %v", err)
+	}
+	if nearest, err = Nearest(ctx, db, "places", Point{Lng: 4.3517, Lat: 50.8503}, 10); err != nil || len(nearest) != 3 {
+		t.Fatalf("expected 3 remaining places, got %+v (err %v)", nearest, err)
+	}
+}
@@ -0,0 +1,157 @@
+package dbx
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/dialect"
+)
+
+// HotCopy copies the SQLite database of db to the file dst while writers keep committing on other connections.
+//
+// It checkpoints the WAL (passively, so writers are not blocked), then holds a read transaction on a dedicated
+// connection while it copies the main file and the WAL: the read snapshot keeps the WAL from being restarted and
+// checkpoints from moving past it, so the two files always recover to a consistent state. The copy is then opened
+// once to fold its WAL into the main file, checked, and renamed to dst, so dst never holds a partial copy.
+// Writes committed during the copy may or may not be part of it.
+//
+// The copy needs a second connection: with the default SQLite pool of one, writers wait until it is done.
+func HotCopy(ctx context.Context, db *bun.DB, dst string) error {
+	if dName := db.Dialect().Name(); dName != dialect.SQLite {
+		return fmt.Errorf("unsupported dialect: %s", dName)
+	}
+
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	src, err := mainDBFile(ctx, conn)
+	if err != nil {
+		return err
+	}
+
+	// Only a short WAL is left to copy; frames still read by other connections stay in it
+	if _, err := conn.ExecContext(ctx, "PRAGMA wal_checkpoint(PASSIVE)"); err != nil {
+		return fmt.Errorf("failed to checkpoint: %w", err)
+	}
+
+	tmp := dst + ".hotcopy"
+	defer removeSQLiteFiles(tmp)
+
+	if err := copyUnderReadTx(ctx, conn, src, tmp); err != nil {
+		return err
+	}
+	if err := foldCopy(ctx, db, tmp); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, dst); err != nil {
+		return fmt.Errorf("failed to move copy to %s: %w", dst, err)
+	}
+	return nil
+}
+
+func mainDBFile(ctx context.Context, conn bun.Conn) (string, error) {
+	rows, err := conn.QueryContext(ctx, "PRAGMA database_list")
+	if err != nil {
+		return "", fmt.Errorf("failed to list databases: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			seq        int
+			name, file string
+		)
+		if err := rows.Scan(&seq, &name, &file); err != nil {
+			return "", err
+		}
+		if name == "main" {
+			if file == "" {
+				return "", errors.New("cannot copy an in-memory or temporary database")
+			}
+			return file, nil
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return "", err
+	}
+	return "", errors.New("main database not found")
+}
+
+func copyUnderReadTx(ctx context.Context, conn bun.Conn, src, tmp string) error {
+	tx, err := conn.BeginTx(ctx, &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		return fmt.Errorf("failed to begin read transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// BEGIN is deferred: the snapshot is only taken by the first read
+	var n int
+	if err := tx.QueryRowContext(ctx, "SELECT COUNT(*) FROM sqlite_master").Scan(&n); err != nil {
+		return fmt.Errorf("failed to start read snapshot: %w", err)
+	}
+
+	if err := copyFile(src, tmp); err != nil {
+		return err
+	}
+	if err := copyFile(src+"-wal", tmp+"-wal"); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// foldCopy opens the copy with the driver of db, which recovers the copied WAL, checkpoints it into the main file
+// and checks the result
+func foldCopy(ctx context.Context, db *bun.DB, file string) error {
+	cp := sql.OpenDB(&hookConnector{dsn: "file:" + file, driver: db.Driver()})
+	defer cp.Close()
+	cp.SetMaxOpenConns(1)
+
+	if _, err := cp.ExecContext(ctx, "PRAGMA wal_checkpoint(TRUNCATE)"); err != nil {
+		return fmt.Errorf("failed to checkpoint copy: %w", err)
+	}
+	var check string
+	if err := cp.QueryRowContext(ctx, "PRAGMA quick_check").Scan(&check); err != nil {
+		return fmt.Errorf("failed to check copy: %w", err)
+	}
+	if check != "ok" {
+		return fmt.Errorf("copy failed quick_check: %s", check)
+	}
+	return cp.Close()
+}
+
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		_ = out.Close()
+		return fmt.Errorf("failed to copy %s: %w", src, err)
+	}
+	if err := out.Sync(); err != nil {
+		_ = out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+func removeSQLiteFiles(file string) {
+	for _, suffix := range []string{"", "-wal", "-shm", "-journal"} {
+		_ = os.Remove(file + suffix)
+	}
+}
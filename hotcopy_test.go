@@ -0,0 +1,105 @@
+package dbx
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+func TestHotCopy_WhileWriting(t *testing.T) {
+	ctx := context.Background()
+	tmp := t.TempDir()
+	dbFolder = tmp
+	dsn := filepath.Join(tmp, "hot.sqlite")
+	db, err := OpenDB(dsn, WithDbFolder(tmp), WithCreateIfMissing(), WithMaxOpenConns(4), WithMaxIdleConns(4))
+	if err != nil {
+		t.Fatalf("OpenDB failed: %v", err)
+	}
+	defer db.Close()
+	if _, err := db.ExecContext(ctx, "CREATE TABLE items (id INTEGER PRIMARY KEY AUTOINCREMENT, name TEXT NOT NULL)"); err != nil {
+		t.Fatalf("create table failed: %v", err)
+	}
+	for range 100 {
+		insertItem(t, db, "before")
+	}
+
+	var (
+		stop    atomic.Bool
+		written atomic.Int64
+		wg      sync.WaitGroup
+	)
+	for range 3 {
+		wg.Go(func() {
+			for !stop.Load() {
+				if _, err := db.ExecContext(ctx, "INSERT INTO items (name) VALUES ('during')"); err != nil {
+					t.Errorf("insert during copy failed: %v", err)
+					return
+				}
+				written.Add(1)
+			}
+		})
+	}
+	defer func() {
+		stop.Store(true)
+		wg.Wait()
+	}()
+
+	for i := range 5 {
+		dst := filepath.Join(tmp, "copy.sqlite")
+		before := countItems(t, db)
+		if err := HotCopy(ctx, db, dst); err != nil {
+			t.Fatalf("HotCopy %d failed: %v", i, err)
+		}
+		after := countItems(t, db)
+
+		cp, err := sql.Open(string(DriverSQLite), dst)
+		if err != nil {
+			t.Fatalf("open copy failed: %v", err)
+		}
+		var check string
+		if err := cp.QueryRowContext(ctx, "PRAGMA integrity_check").Scan(&check); err != nil || check != "ok" {
+			t.Fatalf("copy %d integrity_check: %q (err %v)", i, check, err)
+		}
+		// Rows are only appended, so a consistent copy holds exactly the ids 1..n
+		var n, maxID int
+		if err := cp.QueryRowContext(ctx, "SELECT COUNT(*), COALESCE(MAX(id), 0) FROM items").Scan(&n, &maxID); err != nil {
+			t.Fatalf("count copy failed: %v", err)
+		}
+		_ = cp.Close()
+		if n < before || n > after || n != maxID {
+			t.Fatalf("copy %d holds %d rows (max id %d), expected between %d and %d", i, n, maxID, before, after)
+		}
+	}
+
+	stop.Store(true)
+	wg.Wait()
+	if written.Load() == 0 {
+		t.Fatal("expected writes during the copies")
+	}
+}
+
+func TestHotCopy_NoWAL(t *testing.T) {
+	ctx := context.Background()
+	db := setupTestDB(t)
+	insertItem(t, db, "a")
+	if _, err := db.ExecContext(ctx, "PRAGMA wal_checkpoint(TRUNCATE)"); err != nil {
+		t.Fatalf("checkpoint failed: %v", err)
+	}
+
+	dst := filepath.Join(dbFolder, "copy.sqlite")
+	if err := HotCopy(ctx, db, dst); err != nil {
+		t.Fatalf("HotCopy failed: %v", err)
+	}
+	cp, err := sql.Open(string(DriverSQLite), dst)
+	if err != nil {
+		t.Fatalf("open copy failed: %v", err)
+	}
+	defer cp.Close()
+	var n int
+	if err := cp.QueryRowContext(ctx, "SELECT COUNT(*) FROM items").Scan(&n); err != nil || n != 1 {
+		t.Fatalf("expected 1 row in copy, got %d (err %v)", n, err)
+	}
+}
@@ -0,0 +1,184 @@
+package dbx
+
+import (
+	"database/sql/driver"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/uptrace/bun/dialect"
+)
+
+var ErrInterpolate = errors.New("cannot interpolate query")
+
+// InterpolateQuery renders query with args bound to its placeholders (? on SQLite and MySQL, $1... on Postgres),
+// quoting them as literals of the dialect, for logs and bug reports. The result is not safe to execute: use the
+// parameterized query for that. Placeholders inside string literals, quoted identifiers and comments are left alone.
+func InterpolateQuery(d dialect.Name, query string, args ...any) (string, error) {
+	var sb strings.Builder
+	sb.Grow(len(query) + 16*len(args))
+	used := make([]bool, len(args))
+	next := 0 // next arg of a ? placeholder
+
+	for i := 0; i < len(query); i++ {
+		c := query[i]
+		switch {
+		case c == '\'' || c == '"' || c == '`':
+			end := skipQuoted(query, i, d == dialect.MySQL && c == '\'')
+			sb.WriteString(query[i:end])
+			i = end - 1
+		case c == '-' && strings.HasPrefix(query[i:], "--"):
+			end := strings.IndexByte(query[i:], '\n')
+			if end < 0 {
+				end = len(query) - i
+			}
+			sb.WriteString(query[i : i+end])
+			i += end - 1
+		case c == '/' && strings.HasPrefix(query[i:], "/*"):
+			end := strings.Index(query[i+2:], "*/")
+			if end < 0 {
+				end = len(query) - i - 2
+			} else {
+				end += 2
+			}
+			sb.WriteString(query[i : i+2+end])
+			i += 1 + end
+		case c == '?' && d != dialect.PG:
+			if next >= len(args) {
+				return "", fmt.Errorf("%w: more placeholders than the %d args", ErrInterpolate, len(args))
+			}
+			if err := appendLiteral(&sb, d, args[next]); err != nil {
+				return "", err
+			}
+			used[next] = true
+			next++
+		case c == '$' && d == dialect.PG && i+1 < len(query) && isDigit(query[i+1]):
+			j := i + 1
+			for j < len(query) && isDigit(query[j]) {
+				j++
+			}
+			n, _ := strconv.Atoi(query[i+1 : j])
+			if n < 1 || n > len(args) {
+				return "", fmt.Errorf("%w: placeholder $%d with %d args", ErrInterpolate, n, len(args))
+			}
+			if err := appendLiteral(&sb, d, args[n-1]); err != nil {
+				return "", err
+			}
+			used[n-1] = true
+			i = j - 1
+		default:
+			sb.WriteByte(c)
+		}
+	}
+
+	for i, u := range used {
+		if !u {
+			return "", fmt.Errorf("%w: arg %d has no placeholder", ErrInterpolate, i+1)
+		}
+	}
+	return sb.String(), nil
+}
+
+// skipQuoted returns the index after the quoted token starting at i, the quote doubled being an escape
+func skipQuoted(s string, i int, backslashEscapes bool) int {
+	q := s[i]
+	for j := i + 1; j < len(s); j++ {
+		switch {
+		case backslashEscapes && s[j] == '\\':
+			j++
+		case s[j] == q && j+1 < len(s) && s[j+1] == q:
+			j++
+		case s[j] == q:
+			return j + 1
+		}
+	}
+	return len(s)
+}
+
+func appendLiteral(sb *strings.Builder, d dialect.Name, arg any) error {
+	if valuer, ok := arg.(driver.Valuer); ok {
+		if v := reflect.ValueOf(arg); v.Kind() == reflect.Pointer && v.IsNil() {
+			arg = nil
+		} else {
+			value, err := valuer.Value()
+			if err != nil {
+				return fmt.Errorf("%w: %T: %w", ErrInterpolate, arg, err)
+			}
+			arg = value
+		}
+	}
+
+	switch v := arg.(type) {
+	case nil:
+		sb.WriteString("NULL")
+	case string:
+		appendString(sb, d, v)
+	case []byte:
+		if v == nil {
+			sb.WriteString("NULL")
+		} else if d == dialect.PG {
+			sb.WriteString(`'\x` + hex.EncodeToString(v) + "'")
+		} else {
+			sb.WriteString("X'" + hex.EncodeToString(v) + "'")
+		}
+	case bool:
+		switch {
+		case d == dialect.SQLite && v:
+			sb.WriteString("1")
+		case d == dialect.SQLite:
+			sb.WriteString("0")
+		default:
+			sb.WriteString(strings.ToUpper(strconv.FormatBool(v)))
+		}
+	case time.Time:
+		layout := "2006-01-02 15:04:05.999999999-07:00"
+		if d == dialect.MySQL {
+			layout = "2006-01-02 15:04:05.999999"
+		}
+		appendString(sb, d, v.Format(layout))
+	case float32:
+		return appendFloat(sb, float64(v))
+	case float64:
+		return appendFloat(sb, v)
+	default:
+		rv := reflect.ValueOf(arg)
+		switch rv.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			sb.WriteString(strconv.FormatInt(rv.Int(), 10))
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			sb.WriteString(strconv.FormatUint(rv.Uint(), 10))
+		case reflect.String:
+			appendString(sb, d, rv.String())
+		case reflect.Pointer:
+			if rv.IsNil() {
+				sb.WriteString("NULL")
+				return nil
+			}
+			return appendLiteral(sb, d, rv.Elem().Interface())
+		default:
+			return fmt.Errorf("%w: unsupported arg type %T", ErrInterpolate, arg)
+		}
+	}
+	return nil
+}
+
+func appendString(sb *strings.Builder, d dialect.Name, s string) {
+	if d == dialect.MySQL {
+		// MySQL treats backslashes in literals as escapes, unless NO_BACKSLASH_ESCAPES is set
+		s = strings.ReplaceAll(s, `\`, `\\`)
+	}
+	sb.WriteString("'" + strings.ReplaceAll(s, "'", "''") + "'")
+}
+
+func appendFloat(sb *strings.Builder, f float64) error {
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return fmt.Errorf("%w: %v has no SQL literal", ErrInterpolate, f)
+	}
+	sb.WriteString(strconv.FormatFloat(f, 'g', -1, 64))
+	return nil
+}
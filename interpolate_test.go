@@ -0,0 +1,56 @@
+package dbx
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/uptrace/bun/dialect"
+)
+
+func TestInterpolateQuery(t *testing.T) {
+	ts := time.Date(2024, 3, 1, 12, 30, 0, 0, time.UTC)
+	name := "O'Brien"
+	var nilPtr *string
+
+	cases := []struct {
+		d     dialect.Name
+		query string
+		args  []any
+		want  string
+	}{
+		{dialect.SQLite, "SELECT * FROM t WHERE a = ? AND b = ? AND c = ?", []any{1, "it's", true},
+			"SELECT * FROM t WHERE a = 1 AND b = 'it''s' AND c = 1"},
+		{dialect.SQLite, "SELECT '?' , ? -- ?\n/* ? */", []any{nil}, "SELECT '?' , NULL -- ?\n/* ? */"},
+		{dialect.SQLite, "INSERT INTO t VALUES (?, ?, ?)", []any{[]byte{0xde, 0xad}, 1.5, &name},
+			"INSERT INTO t VALUES (X'dead', 1.5, 'O''Brien')"},
+		{dialect.PG, "SELECT $2, $1, $2", []any{false, ts}, "SELECT '2024-03-01 12:30:00+00:00', FALSE, '2024-03-01 12:30:00+00:00'"},
+		{dialect.PG, "SELECT $1::bytea, $2", []any{[]byte{1}, nilPtr}, `SELECT '\x01'::bytea, NULL`},
+		{dialect.MySQL, "SELECT ?, `col?`, ?", []any{`a\b'`, ts}, "SELECT 'a\\\\b''', `col?`, '2024-03-01 12:30:00'"},
+		{dialect.SQLite, "SELECT ?", []any{NewDecimal(1999, 2)}, "SELECT '19.99'"},
+	}
+	for _, c := range cases {
+		got, err := InterpolateQuery(c.d, c.query, c.args...)
+		if err != nil {
+			t.Fatalf("InterpolateQuery(%q) failed: %v", c.query, err)
+		}
+		if got != c.want {
+			t.Errorf("InterpolateQuery(%q)\n got %q\nwant %q", c.query, got, c.want)
+		}
+	}
+
+	for _, c := range []struct {
+		d     dialect.Name
+		query string
+		args  []any
+	}{
+		{dialect.SQLite, "SELECT ?, ?", []any{1}},
+		{dialect.SQLite, "SELECT ?", []any{1, 2}},
+		{dialect.PG, "SELECT $3", []any{1}},
+		{dialect.SQLite, "SELECT ?", []any{struct{}{}}},
+	} {
+		if _, err := InterpolateQuery(c.d, c.query, c.args...); !errors.Is(err, ErrInterpolate) {
+			t.Errorf("InterpolateQuery(%q, %v): expected ErrInterpolate, got %v", c.query, c.args, err)
+		}
+	}
+}
@@ -0,0 +1,58 @@
+package dbx
+
+import (
+	"math"
+
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/dialect"
+	"github.com/uptrace/bun/dialect/feature"
+)
+
+type ListOptions struct {
+	Where string
+	Args  []any
+	// Order holds order expressions such as "created_at DESC"
+	Order  []string
+	Limit  int
+	Offset int
+}
+
+// ApplyListOptions adds the filter, order and paging of opts to q, in the syntax of the dialect of q:
+//   - LIMIT/OFFSET, with an unbounded LIMIT for an offset alone on SQLite and MySQL, which require one;
+//   - OFFSET ... ROWS FETCH NEXT ... ROWS ONLY on MSSQL and the dialects with bun's feature.OffsetFetch, ordered by
+//     (SELECT NULL) when opts has no order, since the clause needs one. It also stands for TOP n, which bun's
+//     query builder cannot emit.
+//
+// A nil opts leaves q unchanged.
+func ApplyListOptions(q *bun.SelectQuery, opts *ListOptions) *bun.SelectQuery {
+	if opts == nil {
+		return q
+	}
+	if opts.Where != "" {
+		q = q.Where(opts.Where, opts.Args...)
+	}
+	if len(opts.Order) > 0 {
+		q = q.Order(opts.Order...)
+	}
+	if opts.Limit <= 0 && opts.Offset <= 0 {
+		return q
+	}
+
+	d := q.Dialect()
+	switch {
+	case d.Name() == dialect.MSSQL || d.Features().Has(feature.OffsetFetch):
+		if len(opts.Order) == 0 {
+			q = q.OrderExpr("(SELECT NULL)")
+		}
+	case opts.Limit <= 0 && (d.Name() == dialect.SQLite || d.Name() == dialect.MySQL):
+		// bun's limit is an int32
+		return q.Limit(math.MaxInt32).Offset(opts.Offset)
+	}
+	if opts.Limit > 0 {
+		q = q.Limit(opts.Limit)
+	}
+	if opts.Offset > 0 {
+		q = q.Offset(opts.Offset)
+	}
+	return q
+}
@@ -0,0 +1,75 @@
+package dbx
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"testing"
+
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/dialect"
+	"github.com/uptrace/bun/dialect/feature"
+	"github.com/uptrace/bun/dialect/sqlitedialect"
+)
+
+// mssqlDialect formats like SQLite but pages like MSSQL, enough to check the SQL of ApplyListOptions
+type mssqlDialect struct {
+	*sqlitedialect.Dialect
+}
+
+func (mssqlDialect) Name() dialect.Name {
+	return dialect.MSSQL
+}
+
+func (d mssqlDialect) Features() feature.Feature {
+	return d.Dialect.Features() | feature.OffsetFetch
+}
+
+func TestApplyListOptions_SQLite(t *testing.T) {
+	ctx := context.Background()
+	db := setupTestDB(t)
+	for _, name := range []string{"a", "b", "c", "d"} {
+		insertItem(t, db, name)
+	}
+
+	for _, tc := range []struct {
+		opts *ListOptions
+		want string
+	}{
+		{nil, "a,b,c,d"},
+		{&ListOptions{Order: []string{"name DESC"}, Limit: 2}, "d,c"},
+		{&ListOptions{Order: []string{"name"}, Limit: 2, Offset: 1}, "b,c"},
+		{&ListOptions{Order: []string{"name"}, Offset: 3}, "d"},
+		{&ListOptions{Where: "name <> ?", Args: []any{"b"}, Order: []string{"name"}}, "a,c,d"},
+	} {
+		var names []string
+		q := db.NewSelect().Table("items").Column("name")
+		if tc.opts == nil {
+			q = q.Order("name")
+		}
+		if err := ApplyListOptions(q, tc.opts).Scan(ctx, &names); err != nil {
+			t.Fatalf("%+v: query failed: %v", tc.opts, err)
+		}
+		if got := strings.Join(names, ","); got != tc.want {
+			t.Errorf("%+v: expected %s, got %s", tc.opts, tc.want, got)
+		}
+	}
+}
+
+func TestApplyListOptions_MSSQL(t *testing.T) {
+	db := bun.NewDB(&sql.DB{}, mssqlDialect{sqlitedialect.New()})
+
+	for _, tc := range []struct {
+		opts *ListOptions
+		want string
+	}{
+		{&ListOptions{Limit: 10}, `ORDER BY (SELECT NULL) OFFSET 0 ROWS FETCH NEXT 10 ROWS ONLY`},
+		{&ListOptions{Order: []string{"name"}, Limit: 10, Offset: 20}, `ORDER BY "name" OFFSET 20 ROWS FETCH NEXT 10 ROWS ONLY`},
+		{&ListOptions{Order: []string{"name"}, Offset: 20}, `ORDER BY "name" OFFSET 20 ROWS`},
+	} {
+		got := ApplyListOptions(db.NewSelect().Table("items").Column("name"), tc.opts).String()
+		if !strings.HasSuffix(got, tc.want) {
+			t.Errorf("%+v: expected a query ending with %s, got %s", tc.opts, tc.want, got)
+		}
+	}
+}
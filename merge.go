@@ -0,0 +1,270 @@
+package dbx
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/dialect"
+)
+
+// MergeResolver decides whether the src row replaces the dst row when both databases hold a row with the same
+// primary key but different values
+type MergeResolver func(table string, dst, src map[string]any) (useSrc bool)
+
+// MergePolicy controls how MergeDB resolves rows present in both databases
+type MergePolicy struct {
+	// Tables limits the merge to these tables. Empty merges every table present in both databases,
+	// except the sqlite_ and goose version tables.
+	Tables []string
+	// TimestampColumn is the column compared by the default last-write-wins resolution (default: updated_at).
+	// Rows of tables without it keep their dst version.
+	TimestampColumn string
+	// Resolve replaces last-write-wins when set
+	Resolve MergeResolver
+}
+
+// MergeDB merges the rows of src into dst table by table, in a single dst transaction.
+// Rows missing from dst are inserted; rows present in both with different values are resolved by the policy.
+// Rows are matched by primary key, so tables without one are skipped, and only the columns of both tables are
+// merged. Nothing is deleted from dst.
+// Foreign keys are checked at commit, so tables can be merged in any order. Only SQLite is supported.
+func MergeDB(ctx context.Context, dst, src *bun.DB, policy MergePolicy) error {
+	for _, db := range []*bun.DB{dst, src} {
+		if dName := db.Dialect().Name(); dName != dialect.SQLite {
+			return fmt.Errorf("unsupported dialect: %s", dName)
+		}
+	}
+	if policy.TimestampColumn == "" {
+		policy.TimestampColumn = "updated_at"
+	}
+	if policy.Resolve == nil {
+		policy.Resolve = lastWriteWins(policy.TimestampColumn)
+	}
+
+	tables := policy.Tables
+	if len(tables) == 0 {
+		var err error
+		if tables, err = mergeableTables(ctx, src); err != nil {
+			return fmt.Errorf("failed to list tables: %w", err)
+		}
+	}
+
+	return dst.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		if _, err := tx.ExecContext(ctx, "PRAGMA defer_foreign_keys = ON"); err != nil {
+			return fmt.Errorf("failed to defer constraints: %w", err)
+		}
+		for _, table := range tables {
+			if err := mergeTable(ctx, tx, src, table, policy.Resolve); err != nil {
+				return fmt.Errorf("failed to merge %s: %w", table, err)
+			}
+		}
+		return nil
+	})
+}
+
+func mergeableTables(ctx context.Context, db *bun.DB) ([]string, error) {
+	var tables []string
+	err := db.NewRaw(`SELECT name FROM sqlite_master WHERE type = 'table'
+		AND name NOT LIKE 'sqlite\_%' ESCAPE '\' AND name NOT LIKE 'goose\_db\_version%' ESCAPE '\'
+		ORDER BY name`).Scan(ctx, &tables)
+	return tables, err
+}
+
+type mergeColumn struct {
+	Name string `bun:"name"`
+	PK   int    `bun:"pk"`
+}
+
+// mergeBatchSize is the number of src rows read, looked up in dst and upserted at once
+const mergeBatchSize = 500
+
+func tableColumns(ctx context.Context, idb bun.IDB, table string) ([]mergeColumn, error) {
+	var columns []mergeColumn
+	err := idb.NewRaw("SELECT name, pk FROM pragma_table_info(?)", table).Scan(ctx, &columns)
+	return columns, err
+}
+
+func mergeTable(ctx context.Context, tx bun.Tx, src *bun.DB, table string, resolve MergeResolver) error {
+	dstColumns, err := tableColumns(ctx, tx, table)
+	if err != nil {
+		return err
+	}
+	srcColumns, err := tableColumns(ctx, src, table)
+	if err != nil {
+		return err
+	}
+	inSrc := make(map[string]bool, len(srcColumns))
+	for _, col := range srcColumns {
+		inSrc[col.Name] = true
+	}
+
+	// Only the columns of both tables are merged: the others keep their dst value, or default on insert
+	var columns, pk []string
+	for _, col := range dstColumns {
+		switch {
+		case inSrc[col.Name]:
+			columns = append(columns, col.Name)
+			if col.PK > 0 {
+				pk = append(pk, col.Name)
+			}
+		case col.PK > 0:
+			// Rows cannot be matched without the whole primary key
+			return nil
+		}
+	}
+	if len(pk) == 0 {
+		// Not present in dst or src, or no primary key
+		return nil
+	}
+
+	// Rows are read in batches ordered by primary key, each starting after the last key of the previous one
+	var last []any
+	for {
+		q := src.NewSelect().Table(table).Column(columns...)
+		if last != nil {
+			q = q.Where("(?) > (?)", bun.In(idents(pk)), bun.In(last))
+		}
+		for _, col := range pk {
+			q = q.OrderExpr("? ASC", bun.Ident(col))
+		}
+		var rows []map[string]any
+		if err := q.Limit(mergeBatchSize).Scan(ctx, &rows); err != nil {
+			return err
+		}
+		if err := mergeRows(ctx, tx, table, columns, pk, rows, resolve); err != nil {
+			return err
+		}
+		if len(rows) < mergeBatchSize {
+			return nil
+		}
+		last = rowKey(rows[len(rows)-1], pk)
+	}
+}
+
+// mergeRows upserts the src rows missing from dst, or resolved in favor of src, in a single statement
+func mergeRows(ctx context.Context, tx bun.Tx, table string, columns, pk []string, rows []map[string]any,
+	resolve MergeResolver) error {
+	if len(rows) == 0 {
+		return nil
+	}
+	keys := make([][]any, len(rows))
+	for i, row := range rows {
+		keys[i] = rowKey(row, pk)
+	}
+	var existing []map[string]any
+	err := tx.NewSelect().Table(table).Column(columns...).
+		Where("(?) IN (VALUES ?)", bun.In(idents(pk)), bun.In(keys)).Scan(ctx, &existing)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return err
+	}
+	dstRows := make(map[string]map[string]any, len(existing))
+	for _, row := range existing {
+		dstRows[keyString(rowKey(row, pk))] = row
+	}
+
+	var values [][]any
+	for i, row := range rows {
+		if dst, ok := dstRows[keyString(keys[i])]; ok && (reflect.DeepEqual(dst, row) || !resolve(table, dst, row)) {
+			continue
+		}
+		value := make([]any, len(columns))
+		for j, col := range columns {
+			value[j] = row[col]
+		}
+		values = append(values, value)
+	}
+	if len(values) == 0 {
+		return nil
+	}
+
+	var updates []any
+	for _, col := range columns {
+		if !slices.Contains(pk, col) {
+			updates = append(updates, bun.SafeQuery("? = excluded.?", bun.Ident(col), bun.Ident(col)))
+		}
+	}
+	conflict := bun.SafeQuery("DO NOTHING")
+	if len(updates) > 0 {
+		conflict = bun.SafeQuery("DO UPDATE SET ?", bun.In(updates))
+	}
+	_, err = tx.NewRaw("INSERT INTO ? (?) VALUES ? ON CONFLICT (?) ?", bun.Ident(table), bun.In(idents(columns)),
+		bun.In(values), bun.In(idents(pk)), conflict).Exec(ctx)
+	return err
+}
+
+func idents(names []string) []bun.Ident {
+	out := make([]bun.Ident, len(names))
+	for i, name := range names {
+		out[i] = bun.Ident(name)
+	}
+	return out
+}
+
+func rowKey(row map[string]any, pk []string) []any {
+	key := make([]any, len(pk))
+	for i, col := range pk {
+		key[i] = row[col]
+	}
+	return key
+}
+
+func keyString(key []any) string {
+	parts := make([]string, len(key))
+	for i, v := range key {
+		parts[i] = fmt.Sprint(v)
+	}
+	return strings.Join(parts, "\x00")
+}
+
+// lastWriteWins prefers the src row when its timestamp column is newer than the dst one
+func lastWriteWins(column string) MergeResolver {
+	return func(_ string, dst, src map[string]any) bool {
+		srcTS, srcOK := src[column]
+		dstTS, dstOK := dst[column]
+		if !srcOK || !dstOK {
+			return false
+		}
+		return compareValues(srcTS, dstTS) > 0
+	}
+}
+
+// compareValues orders two column values of the same type, treating NULL as the smallest value
+func compareValues(a, b any) int {
+	switch {
+	case a == nil && b == nil:
+		return 0
+	case a == nil:
+		return -1
+	case b == nil:
+		return 1
+	}
+
+	switch a := a.(type) {
+	case time.Time:
+		if b, ok := b.(time.Time); ok {
+			return a.Compare(b)
+		}
+	case int64:
+		if b, ok := b.(int64); ok {
+			return cmp.Compare(a, b)
+		}
+	case float64:
+		if b, ok := b.(float64); ok {
+			return cmp.Compare(a, b)
+		}
+	case []byte:
+		if b, ok := b.([]byte); ok {
+			return bytes.Compare(a, b)
+		}
+	}
+	return strings.Compare(fmt.Sprint(a), fmt.Sprint(b))
+}
@@ -0,0 +1,91 @@
+package dbx
+
+import (
+	"context"
+	"testing"
+)
+
+func TestMergeDB(t *testing.T) {
+	ctx := context.Background()
+	dst, src := setupTestDB(t), setupTestDB(t)
+
+	schema := `CREATE TABLE notes (id INTEGER PRIMARY KEY, body TEXT, updated_at INTEGER)`
+	if _, err := dst.ExecContext(ctx, schema); err != nil {
+		t.Fatalf("create failed: %v", err)
+	}
+	if _, err := src.ExecContext(ctx, schema); err != nil {
+		t.Fatalf("create failed: %v", err)
+	}
+
+	if _, err := dst.ExecContext(ctx, `INSERT INTO notes VALUES (1, 'dst newer', 20), (2, 'dst older', 10), (3, 'dst only', 10)`); err != nil {
+		t.Fatalf("insert failed: %v", err)
+	}
+	if _, err := src.ExecContext(ctx, `INSERT INTO notes VALUES (1, 'src older', 10), (2, 'src newer', 20), (4, 'src only', 10)`); err != nil {
+		t.Fatalf("insert failed: %v", err)
+	}
+	insertItem(t, src, "from src")
+
+	if err := MergeDB(ctx, dst, src, MergePolicy{}); err != nil {
+		t.Fatalf("MergeDB failed: %v", err)
+	}
+
+	expected := map[int]string{1: "dst newer", 2: "src newer", 3: "dst only", 4: "src only"}
+	for id, want := range expected {
+		var body string
+		if err := dst.NewRaw("SELECT body FROM notes WHERE id = ?", id).Scan(ctx, &body); err != nil {
+			t.Fatalf("select %d failed: %v", id, err)
+		}
+		if body != want {
+			t.Fatalf("row %d: expected %q, got %q", id, want, body)
+		}
+	}
+	if n := countItems(t, dst); n != 1 {
+		t.Fatalf("expected items to be merged too, got %d rows", n)
+	}
+
+	// A custom resolver always keeping src
+	preferSrc := func(table string, dst, src map[string]any) bool { return true }
+	if err := MergeDB(ctx, dst, src, MergePolicy{Tables: []string{"notes"}, Resolve: preferSrc}); err != nil {
+		t.Fatalf("MergeDB failed: %v", err)
+	}
+	var body string
+	if err := dst.NewRaw("SELECT body FROM notes WHERE id = 1").Scan(ctx, &body); err != nil || body != "src older" {
+		t.Fatalf("expected custom resolver to take src row, got %q (err %v)", body, err)
+	}
+}
+
+func TestMergeDBSharedColumns(t *testing.T) {
+	ctx := context.Background()
+	dst, src := setupTestDB(t), setupTestDB(t)
+
+	// The columns differ on each side, and the table name needs quoting
+	if _, err := dst.ExecContext(ctx, `CREATE TABLE "odd ""notes""" (id INTEGER PRIMARY KEY, body TEXT, local TEXT DEFAULT 'kept')`); err != nil {
+		t.Fatalf("create failed: %v", err)
+	}
+	if _, err := src.ExecContext(ctx, `CREATE TABLE "odd ""notes""" (id INTEGER PRIMARY KEY, body TEXT, remote TEXT)`); err != nil {
+		t.Fatalf("create failed: %v", err)
+	}
+	if _, err := dst.ExecContext(ctx, `INSERT INTO "odd ""notes""" (id, body, local) VALUES (1, 'dst', 'mine')`); err != nil {
+		t.Fatalf("insert failed: %v", err)
+	}
+	// More rows than a batch
+	rows := mergeBatchSize*2 + 10
+	if _, err := src.ExecContext(ctx, `WITH RECURSIVE n(i) AS (SELECT 1 UNION ALL SELECT i + 1 FROM n WHERE i < ?)
+		INSERT INTO "odd ""notes""" (id, body, remote) SELECT i, 'src ' || i, 'theirs' FROM n`, rows); err != nil {
+		t.Fatalf("insert failed: %v", err)
+	}
+
+	preferSrc := func(string, map[string]any, map[string]any) bool { return true }
+	if err := MergeDB(ctx, dst, src, MergePolicy{Resolve: preferSrc}); err != nil {
+		t.Fatalf("MergeDB failed: %v", err)
+	}
+	var n int
+	if err := dst.NewRaw(`SELECT count(*) FROM "odd ""notes""" WHERE local = 'kept'`).Scan(ctx, &n); err != nil || n != rows-1 {
+		t.Fatalf("expected %d inserted rows with the dst default, got %d (err %v)", rows-1, n, err)
+	}
+	var body, local string
+	if err := dst.NewRaw(`SELECT body, local FROM "odd ""notes""" WHERE id = 1`).Scan(ctx, &body, &local); err != nil ||
+		body != "src 1" || local != "mine" {
+		t.Fatalf("expected the shared column updated and the dst one kept, got %q, %q (err %v)", body, local, err)
+	}
+}
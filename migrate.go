@@ -1,8 +1,11 @@
 package dbx
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
+	"sync"
+
 	"github.com/pressly/goose/v3"
 )
 
@@ -17,17 +20,68 @@ const (
 	DriverMSSQL    DriverName = "mssql"
 )
 
+var gooseMu sync.Mutex
+
 func IsSQLite(dn DriverName) bool {
 	return dn == DriverSQLiteMc || dn == DriverSQLite
 }
 
+type attachment struct {
+	schema string
+	name   string
+}
+
+// CreateWithAttach ATTACHes the SQLite database name (a file in the db folder, created when missing) as schema
+// before running the migrations, so that they can create and alter schema.table, e.g. to migrate a main db and
+// its archive together. The version table stays in the main db.
+func CreateWithAttach(schema, name string) CreateOptFn {
+	return func(opt *CreateOptions) {
+		opt.attachments = append(opt.attachments, attachment{schema: schema, name: name})
+	}
+}
+
+func attachDatabases(ctx context.Context, db *sql.DB, option CreateOptions) error {
+	if len(option.attachments) == 0 {
+		return nil
+	}
+
+	// The driver and the schema names were checked by validateCreateOptions
+	for _, a := range option.attachments {
+		file, err := createSQLiteDBFile(ctx, a.name, option.dbFolder)
+		if err != nil {
+			return err
+		}
+		if _, err := db.ExecContext(ctx, fmt.Sprintf(`ATTACH DATABASE ? AS "%s"`, a.schema), file); err != nil {
+			return fmt.Errorf("failed to attach %s as %s: %w", file, a.schema, err)
+		}
+	}
+	return nil
+}
+
 // MigrateDB runs migrations on the db
 func MigrateDB(dsn string, opts ...CreateOptFn) (err error) {
+	return MigrateDBContext(context.Background(), dsn, opts...)
+}
+
+// MigrateDBContext runs migrations on the db, aborting when ctx is cancelled or its deadline passes.
+// goose runs each migration in its own transaction (unless marked NO TRANSACTION), so an aborted migration
+// is rolled back where the dialect supports transactional DDL, and the migrations applied before it are kept.
+// Errors are returned as an *Error of op "migrate".
+func MigrateDBContext(ctx context.Context, dsn string, opts ...CreateOptFn) (err error) {
 	option := CreateOptions{}
 	setCreateOptions(&option, opts...)
 
+	var name string
+	if IsSQLite(option.driverName) {
+		name = dsn
+	}
+	defer func() { err = wrapErr("migrate", name, "", err) }()
+	if err := validateCreateOptions(&option); err != nil {
+		return err
+	}
+
 	if IsSQLite(option.driverName) {
-		dbFile, err := createSQLiteDBFile(dsn, option.dbFolder)
+		dbFile, err := createSQLiteDBFile(ctx, dsn, option.dbFolder)
 		if err != nil {
 			return err
 		}
@@ -41,12 +95,12 @@ func MigrateDB(dsn string, opts ...CreateOptFn) (err error) {
 	}
 	defer db.Close()
 
-	if err := db.Ping(); err != nil {
+	if err := db.PingContext(ctx); err != nil {
 		return err
 	}
 
 	if IsSQLite(option.driverName) {
-		if _, err = db.Exec(`
+		if _, err = db.ExecContext(ctx, `
 			PRAGMA journal_mode = WAL;
 			PRAGMA synchronous = NORMAL;
 			PRAGMA busy_timeout = 5000;
@@ -62,13 +116,36 @@ func MigrateDB(dsn string, opts ...CreateOptFn) (err error) {
 	db.SetMaxIdleConns(1)
 	db.SetConnMaxLifetime(0)
 
+	// The single connection keeps the attachments for the whole run
+	if err := attachDatabases(ctx, db, option); err != nil {
+		return err
+	}
+
+	// goose keeps its configuration in package globals
+	gooseMu.Lock()
+	defer gooseMu.Unlock()
+	defer setGooseLogger(option.logger)()
+
 	goose.SetBaseFS(option.source)
+	goose.SetTableName(option.versionTable)
+	defer goose.SetTableName(goose.DefaultTablename)
 	if err := goose.SetDialect(string(option.driverName)); err != nil {
 		return fmt.Errorf("failed to set dialect: %w", err)
 	}
-	if err := goose.Up(db, option.srcFolder); err != nil {
+	before, err := goose.GetDBVersionContext(ctx, db)
+	if err != nil {
+		return fmt.Errorf("failed to read migration version: %w", err)
+	}
+	if err := goose.UpContext(ctx, db, option.srcFolder); err != nil {
 		return fmt.Errorf("failed to run migrations: %w", err)
 	}
+	after, err := goose.GetDBVersionContext(ctx, db)
+	if err != nil {
+		return fmt.Errorf("failed to read migration version: %w", err)
+	}
+	if after != before {
+		publish(Event{Kind: EventMigrationApplied, DB: name, Version: after})
+	}
 
 	return nil
 }
@@ -0,0 +1,37 @@
+package dbx
+
+import (
+	"fmt"
+	"log"
+	"log/slog"
+	"os"
+	"strings"
+
+	"github.com/pressly/goose/v3"
+)
+
+// slogGooseLogger routes goose output to a slog.Logger
+type slogGooseLogger struct {
+	logger *slog.Logger
+}
+
+func (l *slogGooseLogger) Printf(format string, v ...any) {
+	l.logger.Info(strings.TrimSpace(fmt.Sprintf(format, v...)))
+}
+
+func (l *slogGooseLogger) Fatalf(format string, v ...any) {
+	l.logger.Error(strings.TrimSpace(fmt.Sprintf(format, v...)))
+	os.Exit(1)
+}
+
+// setGooseLogger installs the migration logger for the duration of a migration run.
+// goose keeps its logger in a package global, so the returned func puts back goose's default stdlib logger.
+func setGooseLogger(l goose.Logger) (restore func()) {
+	if l == nil {
+		return func() {}
+	}
+	goose.SetLogger(l)
+	return func() {
+		goose.SetLogger(log.Default())
+	}
+}
@@ -0,0 +1,161 @@
+package dbx
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"sort"
+	"time"
+
+	"github.com/pressly/goose/v3"
+)
+
+// mergedFS is a read-only fs.FS exposing the migration files of several sources in a single root folder
+type mergedFS struct {
+	files   map[string]mergedFile
+	entries []fs.DirEntry
+	err     error
+}
+
+type mergedFile struct {
+	fsys   fs.FS
+	path   string
+	source string
+}
+
+func (f mergedFile) String() string { return f.source + ":" + f.path }
+
+// MigrationSource is a folder of .sql migrations within a filesystem, see MergeFS.
+type MigrationSource struct {
+	FS     fs.FS
+	Folder string
+}
+
+// MergeFS combines the .sql migrations found in several sources into one migration stream, so schemas
+// contributed by multiple modules (core schema + plugin schemas) can be applied to a single database in version order.
+// The map key names the source in error messages; the merged files live in the root folder ".".
+//
+// A file name or version present in more than one source is a conflict; it is reported when the merged folder is read.
+func MergeFS(sources map[string]MigrationSource) fs.FS {
+	m := &mergedFS{files: make(map[string]mergedFile)}
+
+	names := make([]string, 0, len(sources))
+	for name := range sources {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var errs []error
+	versions := make(map[int64]string)
+	for _, source := range names {
+		src := sources[source]
+		entries, err := fs.ReadDir(src.FS, src.Folder)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to read migrations folder %s of %s: %w", src.Folder, source, err))
+			continue
+		}
+
+		for _, entry := range entries {
+			name := entry.Name()
+			if entry.IsDir() || path.Ext(name) != ".sql" {
+				continue
+			}
+			file := mergedFile{fsys: src.FS, path: path.Join(src.Folder, name), source: source}
+			if other, found := m.files[name]; found {
+				errs = append(errs, fmt.Errorf("%w: %s found in %s and %s", ErrInvalidMigration, name, other, file))
+				continue
+			}
+			if version, err := goose.NumericComponent(name); err == nil {
+				if other, found := versions[version]; found {
+					errs = append(errs, fmt.Errorf("%w: %s: version %d already used by %s", ErrInvalidMigration, file, version, other))
+					continue
+				}
+				versions[version] = file.String()
+			}
+
+			info, err := entry.Info()
+			if err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			m.files[name] = file
+			m.entries = append(m.entries, fs.FileInfoToDirEntry(info))
+		}
+	}
+	sort.Slice(m.entries, func(i, j int) bool { return m.entries[i].Name() < m.entries[j].Name() })
+	m.err = errors.Join(errs...)
+
+	return m
+}
+
+// CreateWithSources runs the migrations of several sources as one stream, see MergeFS.
+// It replaces CreateWithSource and CreateWithSrcFolder.
+func CreateWithSources(sources map[string]MigrationSource) CreateOptFn {
+	return func(opt *CreateOptions) {
+		opt.source = MergeFS(sources)
+		opt.srcFolder = "."
+	}
+}
+
+func (m *mergedFS) Open(name string) (fs.File, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+	}
+	if name == "." {
+		if m.err != nil {
+			return nil, &fs.PathError{Op: "open", Path: name, Err: m.err}
+		}
+		return &mergedDir{entries: m.entries}, nil
+	}
+
+	f, found := m.files[name]
+	if !found {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+	return f.fsys.Open(f.path)
+}
+
+func (m *mergedFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	if name != "." {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrNotExist}
+	}
+	if m.err != nil {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: m.err}
+	}
+	return append([]fs.DirEntry(nil), m.entries...), nil
+}
+
+// mergedDir is the root folder of a mergedFS
+type mergedDir struct {
+	entries []fs.DirEntry
+	offset  int
+}
+
+func (d *mergedDir) Stat() (fs.FileInfo, error) { return d, nil }
+func (d *mergedDir) Read([]byte) (int, error) {
+	return 0, &fs.PathError{Op: "read", Path: ".", Err: errors.New("is a directory")}
+}
+func (d *mergedDir) Close() error { return nil }
+
+func (d *mergedDir) ReadDir(n int) ([]fs.DirEntry, error) {
+	rest := d.entries[d.offset:]
+	if n <= 0 {
+		d.offset = len(d.entries)
+		return rest, nil
+	}
+	if len(rest) == 0 {
+		return nil, io.EOF
+	}
+	n = min(n, len(rest))
+	d.offset += n
+	return rest[:n], nil
+}
+
+func (d *mergedDir) Name() string       { return "." }
+func (d *mergedDir) Size() int64        { return 0 }
+func (d *mergedDir) Mode() fs.FileMode  { return fs.ModeDir | 0555 }
+func (d *mergedDir) ModTime() time.Time { return time.Time{} }
+func (d *mergedDir) IsDir() bool        { return true }
+func (d *mergedDir) Sys() any           { return nil }
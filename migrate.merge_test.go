@@ -0,0 +1,81 @@
+package dbx
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"testing"
+	"testing/fstest"
+)
+
+func TestCreateWithSources(t *testing.T) {
+	tmp := t.TempDir()
+	plugin := fstest.MapFS{
+		"plugin/00002_create_tags.sql": {Data: []byte("-- +goose Up\nCREATE TABLE tags (id INTEGER PRIMARY KEY);\n-- +goose Down\nDROP TABLE tags;\n")},
+	}
+
+	if err := MigrateDB("mergetest",
+		CreateWithDbFolder(tmp),
+		CreateWithSources(map[string]MigrationSource{
+			"core":   {FS: testMigrations, Folder: "testmigrations"},
+			"plugin": {FS: plugin, Folder: "plugin"},
+		}),
+		CreateWithLogger(nil),
+	); err != nil {
+		t.Fatalf("MigrateDB failed: %v", err)
+	}
+
+	db, err := OpenDB("mergetest", WithDbFolder(tmp))
+	if err != nil {
+		t.Fatalf("OpenDB failed: %v", err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+
+	for _, table := range []string{"items", "tags"} {
+		if exists, err := TableExists(context.Background(), db, table); err != nil || !exists {
+			t.Fatalf("expected table %s to exist, got %v (err %v)", table, exists, err)
+		}
+	}
+}
+
+func TestMergeFS_Conflicts(t *testing.T) {
+	plugin := fstest.MapFS{
+		"plugin/00001_other.sql": {Data: []byte("-- +goose Up\n-- +goose Down\n")},
+	}
+	merged := MergeFS(map[string]MigrationSource{
+		"core":   {FS: testMigrations, Folder: "testmigrations"},
+		"plugin": {FS: plugin, Folder: "plugin"},
+	})
+
+	if _, err := fs.ReadDir(merged, "."); !errors.Is(err, ErrInvalidMigration) {
+		t.Fatalf("expected version conflict, got %v", err)
+	}
+}
+
+func TestMergeFS_SameFolder(t *testing.T) {
+	billing := fstest.MapFS{
+		"migrations/00001_create_invoices.sql": {Data: []byte("-- +goose Up\nCREATE TABLE invoices (id INTEGER PRIMARY KEY);\n-- +goose Down\nDROP TABLE invoices;\n")},
+	}
+	audit := fstest.MapFS{
+		"migrations/00002_create_events.sql": {Data: []byte("-- +goose Up\nCREATE TABLE events (id INTEGER PRIMARY KEY);\n-- +goose Down\nDROP TABLE events;\n")},
+	}
+	merged := MergeFS(map[string]MigrationSource{
+		"billing": {FS: billing, Folder: "migrations"},
+		"audit":   {FS: audit, Folder: "migrations"},
+	})
+
+	entries, err := fs.ReadDir(merged, ".")
+	if err != nil {
+		t.Fatalf("ReadDir failed: %v", err)
+	}
+	var names []string
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	if len(names) != 2 || names[0] != "00001_create_invoices.sql" || names[1] != "00002_create_events.sql" {
+		t.Fatalf("expected the migrations of both sources, got %v", names)
+	}
+	if data, err := fs.ReadFile(merged, "00002_create_events.sql"); err != nil || len(data) == 0 {
+		t.Fatalf("failed to read merged file: %v", err)
+	}
+}
@@ -0,0 +1,85 @@
+package dbx
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"regexp"
+	"sync"
+)
+
+type schemaModule struct {
+	name   string
+	source fs.FS
+	folder string
+}
+
+var (
+	schemaModulesMu sync.Mutex
+	schemaModules   []schemaModule
+	moduleNameRe    = regexp.MustCompile(`^[A-Za-z0-9_]+$`)
+)
+
+// RegisterSchemaModule registers the migrations of an independent module, to be applied by MigrateAllModules.
+// Each module keeps its own version table (goose_db_version_<name>), so its version numbers and history are
+// isolated from the other modules sharing the database.
+// It is meant to be called from init functions and panics on an invalid or duplicate name, like sql.Register.
+func RegisterSchemaModule(name string, source fs.FS, folder string) {
+	if !moduleNameRe.MatchString(name) {
+		panic(fmt.Sprintf("dbx: RegisterSchemaModule: invalid module name %q", name))
+	}
+	if source == nil {
+		panic("dbx: RegisterSchemaModule: source is nil for module " + name)
+	}
+
+	schemaModulesMu.Lock()
+	defer schemaModulesMu.Unlock()
+	for _, m := range schemaModules {
+		if m.name == name {
+			panic("dbx: RegisterSchemaModule called twice for module " + name)
+		}
+	}
+	schemaModules = append(schemaModules, schemaModule{name: name, source: source, folder: folder})
+}
+
+// SchemaModules returns the names of the registered schema modules in registration order
+func SchemaModules() []string {
+	schemaModulesMu.Lock()
+	defer schemaModulesMu.Unlock()
+
+	names := make([]string, 0, len(schemaModules))
+	for _, m := range schemaModules {
+		names = append(names, m.name)
+	}
+	return names
+}
+
+// ModuleVersionTable returns the name of the goose version table used for a schema module
+func ModuleVersionTable(name string) string {
+	return "goose_db_version_" + name
+}
+
+// MigrateAllModules applies the migrations of every registered schema module to the db, in registration order.
+// opts apply to every module; the source, folder and version table are set per module.
+func MigrateAllModules(dsn string, opts ...CreateOptFn) error {
+	return MigrateAllModulesContext(context.Background(), dsn, opts...)
+}
+
+// MigrateAllModulesContext is MigrateAllModules with a context, see MigrateDBContext
+func MigrateAllModulesContext(ctx context.Context, dsn string, opts ...CreateOptFn) error {
+	schemaModulesMu.Lock()
+	modules := append([]schemaModule(nil), schemaModules...)
+	schemaModulesMu.Unlock()
+
+	for _, m := range modules {
+		moduleOpts := append(append([]CreateOptFn(nil), opts...),
+			CreateWithSource(m.source),
+			CreateWithSrcFolder(m.folder),
+			CreateWithVersionTable(ModuleVersionTable(m.name)),
+		)
+		if err := MigrateDBContext(ctx, dsn, moduleOpts...); err != nil {
+			return fmt.Errorf("failed to migrate module %s: %w", m.name, err)
+		}
+	}
+	return nil
+}
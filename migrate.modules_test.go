@@ -0,0 +1,56 @@
+package dbx
+
+import (
+	"context"
+	"testing"
+	"testing/fstest"
+)
+
+func TestMigrateAllModules(t *testing.T) {
+	schemaModulesMu.Lock()
+	saved := schemaModules
+	schemaModules = nil
+	schemaModulesMu.Unlock()
+	t.Cleanup(func() {
+		schemaModulesMu.Lock()
+		schemaModules = saved
+		schemaModulesMu.Unlock()
+	})
+
+	// Both modules start at version 1; separate version tables keep them apart
+	plugin := fstest.MapFS{
+		"migrations/00001_create_tags.sql": {Data: []byte("-- +goose Up\nCREATE TABLE tags (id INTEGER PRIMARY KEY);\n-- +goose Down\nDROP TABLE tags;\n")},
+	}
+	RegisterSchemaModule("core", testMigrations, "testmigrations")
+	RegisterSchemaModule("plugin", plugin, "migrations")
+
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Fatal("expected panic on duplicate module")
+			}
+		}()
+		RegisterSchemaModule("core", testMigrations, "testmigrations")
+	}()
+
+	if got := SchemaModules(); len(got) != 2 || got[0] != "core" || got[1] != "plugin" {
+		t.Fatalf("unexpected modules %v", got)
+	}
+
+	tmp := t.TempDir()
+	if err := MigrateAllModules("modulestest", CreateWithDbFolder(tmp), CreateWithLogger(nil)); err != nil {
+		t.Fatalf("MigrateAllModules failed: %v", err)
+	}
+
+	db, err := OpenDB("modulestest", WithDbFolder(tmp))
+	if err != nil {
+		t.Fatalf("OpenDB failed: %v", err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+
+	for _, table := range []string{"items", "tags", ModuleVersionTable("core"), ModuleVersionTable("plugin")} {
+		if exists, err := TableExists(context.Background(), db, table); err != nil || !exists {
+			t.Fatalf("expected table %s to exist, got %v (err %v)", table, exists, err)
+		}
+	}
+}
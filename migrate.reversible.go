@@ -0,0 +1,104 @@
+package dbx
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"regexp"
+	"strings"
+)
+
+// ReversibilityIssue is a migration whose Down section does not undo its Up section
+type ReversibilityIssue struct {
+	File    string
+	Version int64
+	Problem string
+}
+
+func (i ReversibilityIssue) String() string {
+	return fmt.Sprintf("%s: %s", i.File, i.Problem)
+}
+
+const sqlIdent = "([`\"\\[]?[\\w.]+[`\"\\]]?)"
+
+var (
+	reCreateObject = regexp.MustCompile(`(?i)\bCREATE\s+(?:UNIQUE\s+|TEMP\s+|TEMPORARY\s+|VIRTUAL\s+|MATERIALIZED\s+)?(TABLE|INDEX|VIEW|TRIGGER)\s+(?:IF\s+NOT\s+EXISTS\s+)?` + sqlIdent)
+	reDropObject   = regexp.MustCompile(`(?i)\bDROP\s+(TABLE|INDEX|VIEW|TRIGGER|MATERIALIZED\s+VIEW)\s+(?:IF\s+EXISTS\s+)?` + sqlIdent)
+	reAddColumn    = regexp.MustCompile(`(?i)\bALTER\s+TABLE\s+` + sqlIdent + `\s+ADD\s+(?:COLUMN\s+)?(?:IF\s+NOT\s+EXISTS\s+)?` + sqlIdent)
+	reDropColumn   = regexp.MustCompile(`(?i)\bALTER\s+TABLE\s+` + sqlIdent + `\s+DROP\s+(?:COLUMN\s+)?(?:IF\s+EXISTS\s+)?` + sqlIdent)
+	reSQLComment   = regexp.MustCompile(`(?m)--.*$`)
+)
+
+// CheckReversibility parses the goose migrations in folder of fsys and reports the ones whose rollback is missing
+// or incomplete: no Down statements at all, or tables, indexes, views, triggers and added columns created by Up
+// that Down does not drop. Dropping a table also covers its columns, indexes and triggers.
+//
+// The check is a heuristic over the SQL text; it does not execute anything. An error is only returned
+// when the folder cannot be read.
+func CheckReversibility(fsys fs.FS, folder string) ([]ReversibilityIssue, error) {
+	files, err := readMigrations(fsys, folder)
+	if err != nil && !errors.Is(err, ErrInvalidMigration) {
+		return nil, err
+	}
+
+	var issues []ReversibilityIssue
+	for _, mf := range files {
+		issue := func(format string, args ...any) {
+			issues = append(issues, ReversibilityIssue{File: mf.name, Version: mf.version, Problem: fmt.Sprintf(format, args...)})
+		}
+
+		up := reSQLComment.ReplaceAllString(mf.up, "")
+		down := reSQLComment.ReplaceAllString(mf.down, "")
+		if strings.TrimSpace(down) == "" {
+			issue("no Down statements")
+			continue
+		}
+
+		dropped := make(map[string]bool)
+		for _, m := range reDropObject.FindAllStringSubmatch(down, -1) {
+			dropped[objectKey(m[1], m[2])] = true
+		}
+		droppedColumns := make(map[string]bool)
+		for _, m := range reDropColumn.FindAllStringSubmatch(down, -1) {
+			droppedColumns[normalizeIdent(m[1])+"."+normalizeIdent(m[2])] = true
+		}
+
+		for _, m := range reCreateObject.FindAllStringSubmatch(up, -1) {
+			if !dropped[objectKey(m[1], m[2])] && !coveredByTableDrop(m, up, dropped) {
+				issue("%s %s is created but not dropped", strings.ToLower(m[1]), normalizeIdent(m[2]))
+			}
+		}
+		for _, m := range reAddColumn.FindAllStringSubmatch(up, -1) {
+			table, column := normalizeIdent(m[1]), normalizeIdent(m[2])
+			if !droppedColumns[table+"."+column] && !dropped[objectKey("table", table)] {
+				issue("column %s.%s is added but not dropped", table, column)
+			}
+		}
+	}
+
+	return issues, nil
+}
+
+// coveredByTableDrop reports whether an index or trigger belongs to a table that Down drops
+func coveredByTableDrop(m []string, up string, dropped map[string]bool) bool {
+	kind := strings.ToUpper(m[1])
+	if kind != "INDEX" && kind != "TRIGGER" {
+		return false
+	}
+	// find the table the index/trigger is defined ON
+	re := regexp.MustCompile(`(?is)` + regexp.QuoteMeta(m[0]) + `.*?\bON\s+` + sqlIdent)
+	on := re.FindStringSubmatch(up)
+	return on != nil && dropped[objectKey("table", on[1])]
+}
+
+func objectKey(kind, name string) string {
+	kind = strings.ToLower(strings.Join(strings.Fields(kind), " "))
+	if kind == "materialized view" {
+		kind = "view"
+	}
+	return kind + ":" + normalizeIdent(name)
+}
+
+func normalizeIdent(name string) string {
+	return strings.ToLower(strings.Trim(name, "`\"[]"))
+}
@@ -0,0 +1,55 @@
+package dbx
+
+import (
+	"testing"
+	"testing/fstest"
+)
+
+func TestCheckReversibility(t *testing.T) {
+	issues, err := CheckReversibility(testMigrations, "testmigrations")
+	if err != nil {
+		t.Fatalf("CheckReversibility failed: %v", err)
+	}
+	if len(issues) != 0 {
+		t.Fatalf("expected test migrations to be reversible, got %v", issues)
+	}
+
+	fsys := fstest.MapFS{
+		"m/00001_ok.sql": {Data: []byte(`-- +goose Up
+CREATE TABLE "users" (id INT);
+CREATE INDEX users_id_idx ON users (id);
+-- +goose Down
+DROP TABLE IF EXISTS users;
+`)},
+		"m/00002_empty_down.sql": {Data: []byte(`-- +goose Up
+CREATE TABLE a (id INT);
+-- +goose Down
+-- nothing to do
+`)},
+		"m/00003_partial.sql": {Data: []byte(`-- +goose Up
+CREATE TABLE b (id INT);
+CREATE TABLE IF NOT EXISTS c (id INT);
+ALTER TABLE users ADD COLUMN email TEXT;
+-- +goose Down
+DROP TABLE b;
+`)},
+	}
+	issues, err = CheckReversibility(fsys, "m")
+	if err != nil {
+		t.Fatalf("CheckReversibility failed: %v", err)
+	}
+
+	want := []string{
+		"00002_empty_down.sql: no Down statements",
+		"00003_partial.sql: table c is created but not dropped",
+		"00003_partial.sql: column users.email is added but not dropped",
+	}
+	if len(issues) != len(want) {
+		t.Fatalf("expected %d issues, got %v", len(want), issues)
+	}
+	for i, issue := range issues {
+		if issue.String() != want[i] {
+			t.Errorf("issue %d: got %q, want %q", i, issue, want[i])
+		}
+	}
+}
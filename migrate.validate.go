@@ -0,0 +1,132 @@
+package dbx
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/pressly/goose/v3"
+)
+
+var ErrInvalidMigration = errors.New("invalid migration")
+
+// migrationFile is a parsed goose SQL migration
+type migrationFile struct {
+	name    string
+	version int64
+	up      string
+	down    string
+	hasUp   bool
+	hasDown bool
+}
+
+// ValidateMigrations checks the goose migrations in folder of fsys without touching a database, so broken
+// migrations can fail a unit test rather than a deploy. It reports every problem found, joined into one error:
+//
+//   - files that are not .sql migrations
+//   - file names without a valid version prefix
+//   - versions used by more than one file
+//   - files missing the `-- +goose Up` or `-- +goose Down` annotation
+func ValidateMigrations(fsys fs.FS, folder string) error {
+	_, err := readMigrations(fsys, folder)
+	return err
+}
+
+// readMigrations parses the migrations in folder ordered by version, returning all validation problems
+// alongside the files that could be parsed.
+func readMigrations(fsys fs.FS, folder string) ([]migrationFile, error) {
+	entries, err := fs.ReadDir(fsys, folder)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read migrations folder %s: %w", folder, err)
+	}
+
+	var (
+		errs     []error
+		files    []migrationFile
+		versions = make(map[int64]string)
+	)
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() {
+			continue
+		}
+		if path.Ext(name) != ".sql" {
+			errs = append(errs, fmt.Errorf("%w: %s: not a .sql file", ErrInvalidMigration, name))
+			continue
+		}
+
+		version, err := goose.NumericComponent(name)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%w: %s: %v", ErrInvalidMigration, name, err))
+			continue
+		}
+		if other, found := versions[version]; found {
+			errs = append(errs, fmt.Errorf("%w: %s: version %d already used by %s", ErrInvalidMigration, name, version, other))
+			continue
+		}
+		versions[version] = name
+
+		content, err := fs.ReadFile(fsys, path.Join(folder, name))
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%w: %s: %v", ErrInvalidMigration, name, err))
+			continue
+		}
+
+		mf := parseMigration(string(content))
+		mf.name = name
+		mf.version = version
+		if !mf.hasUp {
+			errs = append(errs, fmt.Errorf("%w: %s: missing -- +goose Up annotation", ErrInvalidMigration, name))
+		}
+		if !mf.hasDown {
+			errs = append(errs, fmt.Errorf("%w: %s: missing -- +goose Down section", ErrInvalidMigration, name))
+		}
+		files = append(files, mf)
+	}
+
+	sort.Slice(files, func(i, j int) bool { return files[i].version < files[j].version })
+
+	return files, errors.Join(errs...)
+}
+
+// parseMigration splits a goose SQL migration into its Up and Down sections
+func parseMigration(content string) migrationFile {
+	var (
+		mf      migrationFile
+		up      strings.Builder
+		down    strings.Builder
+		section *strings.Builder
+	)
+
+	scanner := bufio.NewScanner(strings.NewReader(content))
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "--") {
+			annotation := strings.TrimSpace(strings.TrimPrefix(trimmed, "--"))
+			switch {
+			case strings.HasPrefix(annotation, "+goose Up"):
+				mf.hasUp = true
+				section = &up
+				continue
+			case strings.HasPrefix(annotation, "+goose Down"):
+				mf.hasDown = true
+				section = &down
+				continue
+			}
+		}
+		if section != nil {
+			section.WriteString(line)
+			section.WriteByte('\n')
+		}
+	}
+
+	mf.up = up.String()
+	mf.down = down.String()
+	return mf
+}
@@ -0,0 +1,36 @@
+package dbx
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestValidateMigrations(t *testing.T) {
+	if err := ValidateMigrations(testMigrations, "testmigrations"); err != nil {
+		t.Fatalf("expected test migrations to be valid, got %v", err)
+	}
+
+	fsys := fstest.MapFS{
+		"m/00001_a.sql":      {Data: []byte("-- +goose Up\nCREATE TABLE a (id INT);\n-- +goose Down\nDROP TABLE a;\n")},
+		"m/00001_b.sql":      {Data: []byte("-- +goose Up\nCREATE TABLE b (id INT);\n-- +goose Down\nDROP TABLE b;\n")},
+		"m/00002_c.sql":      {Data: []byte("-- +goose Up\nCREATE TABLE c (id INT);\n")},
+		"m/notes.txt":        {Data: []byte("todo")},
+		"m/nounderscore.sql": {Data: []byte("-- +goose Up\n-- +goose Down\n")},
+	}
+	err := ValidateMigrations(fsys, "m")
+	if !errors.Is(err, ErrInvalidMigration) {
+		t.Fatalf("expected ErrInvalidMigration, got %v", err)
+	}
+	for _, want := range []string{
+		"00001_b.sql: version 1 already used by 00001_a.sql",
+		"00002_c.sql: missing -- +goose Down section",
+		"notes.txt: not a .sql file",
+		"nounderscore.sql",
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("expected error to mention %q, got %v", want, err)
+		}
+	}
+}
@@ -0,0 +1,43 @@
+package dbx
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+
+	"github.com/uptrace/bun"
+)
+
+var ErrModelTableMissing = errors.New("model table missing")
+
+// WithModels registers models with the db after opening (see bun.DB.RegisterModel), as needed for the join models
+// of many-to-many relations. Pass pointers to the model structs, e.g. (*OrderToItem)(nil).
+func WithModels(models ...any) OpenOptFn {
+	return func(opt *Options) {
+		opt.models = append(opt.models, models...)
+	}
+}
+
+// WithValidateModels makes OpenDB check that the table of every model passed to WithModels exists,
+// so a db whose schema lags behind the code fails at open instead of on the first query
+func WithValidateModels(validate bool) OpenOptFn {
+	return func(opt *Options) {
+		opt.validateModels = validate
+	}
+}
+
+func validateModelTables(ctx context.Context, db *bun.DB, models []any) error {
+	var errs []error
+	for _, model := range models {
+		table := db.Table(reflect.TypeOf(model))
+		exists, err := TableExists(ctx, db, table.Name)
+		if err != nil {
+			return fmt.Errorf("failed to check table %s: %w", table.Name, err)
+		}
+		if !exists {
+			errs = append(errs, fmt.Errorf("%w: %s (model %s)", ErrModelTableMissing, table.Name, table.TypeName))
+		}
+	}
+	return errors.Join(errs...)
+}
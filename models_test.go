@@ -0,0 +1,45 @@
+package dbx
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/uptrace/bun"
+)
+
+type modelItem struct {
+	bun.BaseModel `bun:"table:items"`
+	ID            int64  `bun:"id,pk"`
+	Name          string `bun:"name"`
+}
+
+type modelTag struct {
+	bun.BaseModel `bun:"table:tags"`
+	ID            int64 `bun:"id,pk"`
+}
+
+func TestWithModels(t *testing.T) {
+	tmp := t.TempDir()
+	if err := CreateDB("models", CreateWithDbFolder(tmp), CreateWithSource(testMigrations),
+		CreateWithSrcFolder("testmigrations"), CreateWithLogger(nil)); err != nil {
+		t.Fatalf("CreateDB failed: %v", err)
+	}
+
+	db, err := OpenDB("models", WithDbFolder(tmp), WithModels((*modelItem)(nil)), WithValidateModels(true))
+	if err != nil {
+		t.Fatalf("OpenDB failed: %v", err)
+	}
+	_ = db.Close()
+
+	_, err = OpenDB("models", WithDbFolder(tmp), WithModels((*modelItem)(nil), (*modelTag)(nil)), WithValidateModels(true))
+	if !errors.Is(err, ErrModelTableMissing) {
+		t.Fatalf("expected ErrModelTableMissing for tags, got %v", err)
+	}
+
+	// Without validation the missing table is not checked
+	db, err = OpenDB("models", WithDbFolder(tmp), WithModels((*modelTag)(nil)))
+	if err != nil {
+		t.Fatalf("OpenDB failed: %v", err)
+	}
+	_ = db.Close()
+}
@@ -0,0 +1,143 @@
+package dbx
+
+import (
+	"reflect"
+	"strings"
+
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/schema"
+)
+
+// WithTableNamer names the tables of the models without a table tag, instead of bun's pluralization.
+// fn gets the model name as bun passes it to its inflector, the snake_case struct name (e.g. user_profile), and
+// returns the table name, e.g. "app_" + inflection.Plural(model).
+func WithTableNamer(fn func(model string) string) OpenOptFn {
+	return func(opt *Options) {
+		opt.tableNamer = fn
+	}
+}
+
+// WithColumnNamer names the columns of the model fields without a column tag, instead of bun's snake_case.
+// fn gets the Go field name, e.g. CreatedAt.
+func WithColumnNamer(fn func(field string) string) OpenOptFn {
+	return func(opt *Options) {
+		opt.columnNamer = fn
+	}
+}
+
+// WithTablePrefix and WithTableSuffix add a prefix and a suffix to the table names of all the models, tagged or not,
+// those of dbx included, so several applications can share a database. Use TableName for the tables of raw SQL.
+func WithTablePrefix(prefix string) OpenOptFn {
+	return func(opt *Options) {
+		opt.tablePrefix = prefix
+	}
+}
+
+func WithTableSuffix(suffix string) OpenOptFn {
+	return func(opt *Options) {
+		opt.tableSuffix = suffix
+	}
+}
+
+// TableName returns the name of table in idb: with the prefix and suffix of WithTablePrefix and WithTableSuffix
+func TableName(idb bun.IDB, table string) string {
+	if d, ok := idb.Dialect().(*modelDialect); ok {
+		return d.affix(table)
+	}
+	return table
+}
+
+// modelDialect applies the namers and the NFC normalization of a db to its models, and holds its query policies.
+// It keeps its own table registry, since bun calls OnTable on the dialect the registry was created with. Optional interfaces of the wrapped dialect (used by bun's
+// migrate/sqlschema package) are not forwarded.
+type modelDialect struct {
+	schema.Dialect
+	tables      *schema.Tables
+	tableNamer  func(string) string
+	columnNamer func(string) string
+	prefix      string
+	suffix      string
+	nfc         *nfcTargets
+	policies    []QueryPolicy
+	guard       *tenantGuard
+}
+
+// withModelDialect returns d, wrapped when an option needs it
+func withModelDialect(d schema.Dialect, opt *Options) schema.Dialect {
+	if opt.tableNamer == nil && opt.columnNamer == nil && opt.tablePrefix == "" && opt.tableSuffix == "" &&
+		opt.nfc == nil && len(opt.policies) == 0 {
+		return d
+	}
+	nd := &modelDialect{Dialect: d, tableNamer: opt.tableNamer, columnNamer: opt.columnNamer,
+		prefix: opt.tablePrefix, suffix: opt.tableSuffix, nfc: opt.nfc, policies: opt.policies}
+	if opt.tenantGuard {
+		nd.guard = newTenantGuard(opt.policies)
+	}
+	nd.tables = schema.NewTables(nd)
+	return nd
+}
+
+func (d *modelDialect) Tables() *schema.Tables {
+	return d.tables
+}
+
+func (d *modelDialect) OnTable(table *schema.Table) {
+	name := table.Name
+	if d.tableNamer != nil && !hasTableTag(table.Type) {
+		name = d.tableNamer(table.ModelName)
+	}
+	if name = d.affix(name); name != table.Name {
+		// A select tag keeps its own name for selects
+		if table.SQLNameForSelects == table.SQLName {
+			table.SQLNameForSelects = d.quoteIdent(name)
+		}
+		table.Name = name
+		table.SQLName = d.quoteIdent(name)
+	}
+	if d.columnNamer != nil {
+		var renamed []*schema.Field
+		for key, field := range table.FieldMap {
+			// FieldMap also holds alt names, which are kept
+			if key == field.Name && field.Tag.Name == "" && !field.Tag.HasOption("column") {
+				renamed = append(renamed, field)
+			}
+		}
+		for _, field := range renamed {
+			delete(table.FieldMap, field.Name)
+			field.Name = d.columnNamer(field.GoName)
+			field.SQLName = d.quoteIdent(field.Name)
+			table.FieldMap[field.Name] = field
+		}
+	}
+	if d.nfc != nil {
+		d.nfc.apply(table)
+	}
+	d.Dialect.OnTable(table)
+}
+
+// affix adds the prefix and suffix to table, after its schema if any
+func (d *modelDialect) affix(table string) string {
+	schemaName, name, ok := strings.Cut(table, ".")
+	if !ok {
+		return d.prefix + table + d.suffix
+	}
+	return schemaName + "." + d.prefix + name + d.suffix
+}
+
+func (d *modelDialect) quoteIdent(s string) schema.Safe {
+	return schema.Safe(schema.NewFormatter(d).AppendIdent(nil, s))
+}
+
+// hasTableTag reports whether the bun.BaseModel of the model type sets the table name
+func hasTableTag(typ reflect.Type) bool {
+	f, ok := typ.FieldByName("BaseModel")
+	if !ok || f.Type != reflect.TypeFor[schema.BaseModel]() {
+		return false
+	}
+	for i, part := range strings.Split(f.Tag.Get("bun"), ",") {
+		if strings.HasPrefix(part, "table:") || i == 0 && part != "" && !strings.Contains(part, ":") {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,108 @@
+package dbx
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/uptrace/bun"
+)
+
+type UserProfile struct {
+	ID          int64 `bun:",pk,autoincrement"`
+	DisplayName string
+	Email       string `bun:"email_address"`
+}
+
+type namedAudit struct {
+	bun.BaseModel `bun:"table:audit_log"`
+
+	ID     int64 `bun:",pk,autoincrement"`
+	Action string
+}
+
+func TestNamers(t *testing.T) {
+	ctx := context.Background()
+	tmp := t.TempDir()
+	db, err := OpenDB("naming", WithDbFolder(tmp), WithCreateIfMissing(),
+		WithTableNamer(func(model string) string { return "app_" + model }),
+		WithColumnNamer(strings.ToLower))
+	if err != nil {
+		t.Fatalf("OpenDB failed: %v", err)
+	}
+	defer db.Close()
+
+	for _, model := range []any{(*UserProfile)(nil), (*namedAudit)(nil)} {
+		if _, err := db.NewCreateTable().Model(model).Exec(ctx); err != nil {
+			t.Fatalf("create table failed: %v", err)
+		}
+	}
+	if _, err := db.NewInsert().Model(&UserProfile{DisplayName: "Ada", Email: "ada@example.com"}).Exec(ctx); err != nil {
+		t.Fatalf("insert failed: %v", err)
+	}
+
+	var columns []string
+	if err := db.NewRaw("SELECT name FROM pragma_table_info('app_user_profile') ORDER BY cid").Scan(ctx, &columns); err != nil {
+		t.Fatalf("read columns failed: %v", err)
+	}
+	if got := strings.Join(columns, ","); got != "id,displayname,email_address" {
+		t.Fatalf("expected columns id,displayname,email_address, got %s", got)
+	}
+	if exists, err := TableExists(ctx, db, "audit_log"); err != nil || !exists {
+		t.Fatalf("expected the tagged table name to be kept, got %v, %v", exists, err)
+	}
+
+	var got UserProfile
+	if err := db.NewSelect().Model(&got).Where("displayname = ?", "Ada").Scan(ctx); err != nil {
+		t.Fatalf("select failed: %v", err)
+	}
+	if got.DisplayName != "Ada" || got.Email != "ada@example.com" {
+		t.Fatalf("unexpected row %+v", got)
+	}
+}
+
+func TestTablePrefix(t *testing.T) {
+	ctx := context.Background()
+	tmp := t.TempDir()
+	db, err := OpenDB("prefix", WithDbFolder(tmp), WithCreateIfMissing(), WithTablePrefix("app1_"))
+	if err != nil {
+		t.Fatalf("OpenDB failed: %v", err)
+	}
+	defer db.Close()
+
+	if got := TableName(db, "orders"); got != "app1_orders" {
+		t.Fatalf("expected app1_orders, got %s", got)
+	}
+	if got := TableName(db, "sales.orders"); got != "sales.app1_orders" {
+		t.Fatalf("expected sales.app1_orders, got %s", got)
+	}
+
+	for _, model := range []any{(*UserProfile)(nil), (*namedAudit)(nil)} {
+		if _, err := db.NewCreateTable().Model(model).Exec(ctx); err != nil {
+			t.Fatalf("create table failed: %v", err)
+		}
+	}
+	if err := CreateSequenceTable(ctx, db); err != nil {
+		t.Fatalf("CreateSequenceTable failed: %v", err)
+	}
+	if n, err := NextSequence(ctx, db, "invoices"); err != nil || n != 1 {
+		t.Fatalf("expected sequence value 1, got %d, %v", n, err)
+	}
+	if err := TrackChanges(ctx, db, TableName(db, "audit_log")); err != nil {
+		t.Fatalf("TrackChanges failed: %v", err)
+	}
+	if _, err := db.NewInsert().Model(&namedAudit{Action: "login"}).Exec(ctx); err != nil {
+		t.Fatalf("insert failed: %v", err)
+	}
+	if events, err := ChangesSince(ctx, db, 0, 10); err != nil || len(events) != 1 {
+		t.Fatalf("expected one change, got %v, %v", events, err)
+	}
+
+	var tables []string
+	if err := db.NewRaw("SELECT name FROM sqlite_master WHERE type = 'table' AND name NOT LIKE 'sqlite_%' ORDER BY name").Scan(ctx, &tables); err != nil {
+		t.Fatalf("read tables failed: %v", err)
+	}
+	if got := strings.Join(tables, ","); got != "app1_audit_log,app1_dbx_changes,app1_dbx_sequences,app1_user_profiles" {
+		t.Fatalf("expected prefixed tables, got %s", got)
+	}
+}
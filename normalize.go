@@ -0,0 +1,74 @@
+package dbx
+
+import (
+	"reflect"
+	"strings"
+
+	"github.com/uptrace/bun/schema"
+	"golang.org/x/text/unicode/norm"
+)
+
+// WithNFC normalizes to Unicode NFC the string fields of the models written by inserts and updates, so text typed
+// on different devices ("é" as one code point or as "e" and a combining accent) compares and indexes as equal.
+// targets selects the fields, as "table" for all the string fields of a model or "table.column" for one, by their
+// names in the database; without targets every string field is normalized. Options accumulate.
+//
+// Only model fields are normalized: the values of raw SQL and of Set/Where arguments are written as given.
+func WithNFC(targets ...string) OpenOptFn {
+	return func(opt *Options) {
+		if opt.nfc == nil {
+			opt.nfc = &nfcTargets{tables: make(map[string]bool), columns: make(map[string]bool)}
+		}
+		if len(targets) == 0 {
+			opt.nfc.all = true
+		}
+		for _, target := range targets {
+			if table, column, ok := strings.Cut(target, "."); ok {
+				opt.nfc.columns[table+"."+column] = true
+			} else {
+				opt.nfc.tables[table] = true
+			}
+		}
+	}
+}
+
+type nfcTargets struct {
+	all     bool
+	tables  map[string]bool
+	columns map[string]bool
+}
+
+// apply wraps the appenders of the targeted string fields of table, which bun uses to write model values
+func (n *nfcTargets) apply(table *schema.Table) {
+	for _, field := range table.Fields {
+		if field.IndirectType.Kind() != reflect.String {
+			continue
+		}
+		if !n.all && !n.tables[table.Name] && !n.columns[table.Name+"."+field.Name] {
+			continue
+		}
+		field.Append = nfcAppender(field.Append)
+	}
+}
+
+func nfcAppender(next schema.AppenderFunc) schema.AppenderFunc {
+	return func(fmter schema.Formatter, b []byte, v reflect.Value) []byte {
+		sv := v
+		if sv.Kind() == reflect.Pointer {
+			if sv.IsNil() {
+				return next(fmter, b, v)
+			}
+			sv = sv.Elem()
+		}
+		s := sv.String()
+		if norm.NFC.IsNormalString(s) {
+			return next(fmter, b, v)
+		}
+		normalized := reflect.New(sv.Type()).Elem()
+		normalized.SetString(norm.NFC.String(s))
+		if v.Kind() == reflect.Pointer {
+			return next(fmter, b, normalized.Addr())
+		}
+		return next(fmter, b, normalized)
+	}
+}
@@ -0,0 +1,71 @@
+package dbx
+
+import (
+	"context"
+	"testing"
+)
+
+type nfcUser struct {
+	ID       int64 `bun:",pk,autoincrement"`
+	Login    string
+	Nickname *string
+	Bio      string
+}
+
+func TestWithNFC(t *testing.T) {
+	ctx := context.Background()
+	const (
+		composed   = "jos\u00e9"
+		decomposed = "jose\u0301"
+	)
+
+	tmp := t.TempDir()
+	db, err := OpenDB("nfc", WithDbFolder(tmp), WithCreateIfMissing(), WithNFC("nfc_users.login", "nfc_users.nickname"))
+	if err != nil {
+		t.Fatalf("OpenDB failed: %v", err)
+	}
+	defer db.Close()
+	if _, err := db.NewCreateTable().Model((*nfcUser)(nil)).Exec(ctx); err != nil {
+		t.Fatalf("create table failed: %v", err)
+	}
+	if _, err := db.ExecContext(ctx, "CREATE UNIQUE INDEX nfc_users_login ON nfc_users(login)"); err != nil {
+		t.Fatalf("create index failed: %v", err)
+	}
+
+	nickname := decomposed
+	user := &nfcUser{Login: decomposed, Nickname: &nickname, Bio: decomposed}
+	if _, err := db.NewInsert().Model(user).Exec(ctx); err != nil {
+		t.Fatalf("insert failed: %v", err)
+	}
+	if user.Login != decomposed {
+		t.Fatalf("expected the model to be left as is, got %q", user.Login)
+	}
+
+	var got nfcUser
+	if err := db.NewSelect().Model(&got).Where("id = ?", user.ID).Scan(ctx); err != nil {
+		t.Fatalf("select failed: %v", err)
+	}
+	if got.Login != composed || *got.Nickname != composed {
+		t.Fatalf("expected targeted fields in NFC, got %q and %q", got.Login, *got.Nickname)
+	}
+	if got.Bio != decomposed {
+		t.Fatalf("expected untargeted field as written, got %q", got.Bio)
+	}
+
+	_, err = db.NewInsert().Model(&nfcUser{Login: composed}).Exec(ctx)
+	if KindOf(err) != KindConflict {
+		t.Fatalf("expected the composed login to conflict with the decomposed one, got %v", err)
+	}
+
+	got.Login = "ze\u0301"
+	if _, err := db.NewUpdate().Model(&got).WherePK().Exec(ctx); err != nil {
+		t.Fatalf("update failed: %v", err)
+	}
+	var login string
+	if err := db.NewRaw("SELECT login FROM nfc_users WHERE id = ?", got.ID).Scan(ctx, &login); err != nil {
+		t.Fatalf("select failed: %v", err)
+	}
+	if login != "z\u00e9" {
+		t.Fatalf("expected the update to be normalized, got %q", login)
+	}
+}
@@ -0,0 +1,62 @@
+package dbx
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+)
+
+// Null is a nullable column value. It scans and is stored like sql.Null[T], and marshals to JSON as the value
+// itself or null, so it can be used directly in API responses.
+type Null[T any] struct {
+	sql.Null[T]
+}
+
+// NewNull returns a valid Null holding v
+func NewNull[T any](v T) Null[T] {
+	return Null[T]{sql.Null[T]{V: v, Valid: true}}
+}
+
+// NullFromPtr returns a Null holding *p, or an invalid Null when p is nil
+func NullFromPtr[T any](p *T) Null[T] {
+	if p == nil {
+		return Null[T]{}
+	}
+	return NewNull(*p)
+}
+
+// Ptr returns a pointer to the value, or nil when it is NULL
+func (n Null[T]) Ptr() *T {
+	if !n.Valid {
+		return nil
+	}
+	v := n.V
+	return &v
+}
+
+// ValueOr returns the value, or def when it is NULL
+func (n Null[T]) ValueOr(def T) T {
+	if !n.Valid {
+		return def
+	}
+	return n.V
+}
+
+func (n Null[T]) MarshalJSON() ([]byte, error) {
+	if !n.Valid {
+		return []byte("null"), nil
+	}
+	return json.Marshal(n.V)
+}
+
+func (n *Null[T]) UnmarshalJSON(b []byte) error {
+	if bytes.Equal(bytes.TrimSpace(b), []byte("null")) {
+		*n = Null[T]{}
+		return nil
+	}
+	if err := json.Unmarshal(b, &n.V); err != nil {
+		return err
+	}
+	n.Valid = true
+	return nil
+}
@@ -0,0 +1,51 @@
+package dbx
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestNull(t *testing.T) {
+	ctx := context.Background()
+	db := setupTestDB(t)
+	if _, err := db.ExecContext(ctx, "CREATE TABLE people (id INTEGER PRIMARY KEY, nickname TEXT, born TIMESTAMP)"); err != nil {
+		t.Fatalf("create failed: %v", err)
+	}
+
+	born := time.Date(1990, 5, 1, 0, 0, 0, 0, time.UTC)
+	if _, err := db.ExecContext(ctx, "INSERT INTO people VALUES (1, ?, ?), (2, ?, ?)",
+		NewNull("ace"), NewNull(born), Null[string]{}, NullFromPtr[time.Time](nil)); err != nil {
+		t.Fatalf("insert failed: %v", err)
+	}
+
+	type person struct {
+		ID       int64           `bun:"id" json:"id"`
+		Nickname Null[string]    `bun:"nickname" json:"nickname"`
+		Born     Null[time.Time] `bun:"born" json:"born"`
+	}
+	var people []person
+	if err := db.NewRaw("SELECT id, nickname, born FROM people ORDER BY id").Scan(ctx, &people); err != nil {
+		t.Fatalf("scan failed: %v", err)
+	}
+	if !people[0].Nickname.Valid || people[0].Nickname.V != "ace" || !people[0].Born.V.Equal(born) {
+		t.Fatalf("unexpected first row %+v", people[0])
+	}
+	if people[1].Nickname.Valid || people[1].Born.Ptr() != nil || people[1].Nickname.ValueOr("anon") != "anon" {
+		t.Fatalf("expected NULLs in second row, got %+v", people[1])
+	}
+
+	b, err := json.Marshal(people[1])
+	if err != nil || string(b) != `{"id":2,"nickname":null,"born":null}` {
+		t.Fatalf("unexpected JSON %s (err %v)", b, err)
+	}
+
+	var decoded person
+	if err := json.Unmarshal([]byte(`{"id":3,"nickname":"bo","born":null}`), &decoded); err != nil {
+		t.Fatalf("unmarshal failed: %v", err)
+	}
+	if !decoded.Nickname.Valid || decoded.Nickname.V != "bo" || decoded.Born.Valid {
+		t.Fatalf("unexpected decoded value %+v", decoded)
+	}
+}
@@ -1,14 +1,22 @@
 package dbx
 
 import (
-	"database/sql"
+	"context"
 	"fmt"
+	"log/slog"
+	"math/rand/v2"
+	"os"
 	"path/filepath"
+	"slices"
+	"sync/atomic"
 	"time"
 
 	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/dialect/mysqldialect"
+	"github.com/uptrace/bun/dialect/pgdialect"
 	"github.com/uptrace/bun/dialect/sqlitedialect"
 	"github.com/uptrace/bun/extra/bundebug"
+	"github.com/uptrace/bun/schema"
 )
 
 type Options struct {
@@ -18,7 +26,38 @@ type Options struct {
 	maxIdleConns    int
 	connMaxIdleTime time.Duration
 	connMaxLifetime time.Duration
-	logQueries      bool
+	queryLogging    QueryLogging
+	queryHooks      []bun.QueryHook
+	extensions      []string
+	sqlFuncs        []sqlFunc
+	models          []any
+	validateModels  bool
+	queryStats      bool
+	explainLogger   *slog.Logger
+	prePing         bool
+	connectAttempts int
+	connectBackoff  time.Duration
+	createIfMissing bool
+	pprofDB         string
+	chaos           *chaos
+	recorder        *Recorder
+	randSource      rand.Source
+	strictColumns   bool
+	bunOptions      []bun.DBOption
+	tableNamer      func(string) string
+	columnNamer     func(string) string
+	tablePrefix     string
+	tableSuffix     string
+	collations      []collation
+	nfc             *nfcTargets
+	readOnly        bool
+	immutable       bool
+	pragmas         []pragma
+	policies        []QueryPolicy
+	tenantGuard     bool
+	statementPolicy *statementPolicy
+	dialect         schema.Dialect
+	onClose         []func() error
 }
 type OpenOptFn func(options *Options)
 
@@ -28,9 +67,62 @@ func WithDriverName(dn DriverName) OpenOptFn {
 	}
 }
 
+// WithDialect sets the bun dialect instead of the one of the driver, e.g. mssqldialect.New() for DriverMSSQL,
+// whose dialect is not bundled
+func WithDialect(d schema.Dialect) OpenOptFn {
+	return func(opt *Options) {
+		opt.dialect = d
+	}
+}
+
+// QueryLogging is what the bundebug query hook of OpenDB writes to stderr
+type QueryLogging uint8
+
+const (
+	// QueryLogOff adds no bundebug hook (default)
+	QueryLogOff QueryLogging = iota
+	// QueryLogErrors logs the failed queries
+	QueryLogErrors
+	// QueryLogAll logs every query
+	QueryLogAll
+	// QueryLogEnv reads the mode from the BUNDEBUG environment variable: 0 or unset off, 1 errors, 2 all
+	QueryLogEnv
+)
+
+// WithLog logs every query to stderr, like WithQueryLogging(QueryLogAll), or none
 func WithLog(log bool) OpenOptFn {
+	if log {
+		return WithQueryLogging(QueryLogAll)
+	}
+	return WithQueryLogging(QueryLogOff)
+}
+
+// WithQueryLogging sets the queries logged to stderr by bundebug (default: QueryLogOff)
+func WithQueryLogging(mode QueryLogging) OpenOptFn {
 	return func(opt *Options) {
-		opt.logQueries = log
+		opt.queryLogging = mode
+	}
+}
+
+// WithQueryHook adds hooks to the db, after the ones of dbx, e.g. to log queries to a logger of your own
+func WithQueryHook(hooks ...bun.QueryHook) OpenOptFn {
+	return func(opt *Options) {
+		opt.queryHooks = append(opt.queryHooks, hooks...)
+	}
+}
+
+// WithDiscardUnknownColumns sets whether scanning ignores the columns a model has no field for (default: true).
+// Pass false to fail on them instead, catching drift between the schema and the models.
+func WithDiscardUnknownColumns(discard bool) OpenOptFn {
+	return func(opt *Options) {
+		opt.strictColumns = !discard
+	}
+}
+
+// WithBunOptions passes opts to bun.NewDB, after the ones of dbx
+func WithBunOptions(opts ...bun.DBOption) OpenOptFn {
+	return func(opt *Options) {
+		opt.bunOptions = append(opt.bunOptions, opts...)
 	}
 }
 
@@ -52,6 +144,15 @@ func WithMaxIdleConns(n int) OpenOptFn {
 	}
 }
 
+// WithCreateIfMissing makes OpenDB create an empty SQLite database file (and its folder) when it does not exist,
+// instead of failing with ErrDBFileNotFound. Migrations still need CreateDB or MigrateDB.
+func WithCreateIfMissing() OpenOptFn {
+	return func(opt *Options) {
+		opt.createIfMissing = true
+	}
+}
+
+// WithConnMaxIdleTime closes connections idle for longer than d (default on SQLite: 15 minutes)
 func WithConnMaxIdleTime(n time.Duration) OpenOptFn {
 	return func(opt *Options) {
 		opt.connMaxIdleTime = n
@@ -67,16 +168,54 @@ func WithConnMaxLifetime(d time.Duration) OpenOptFn {
 // OpenDB opens a new database connection.
 // for sqlite, dsn should be a file name (without extension)
 func OpenDB(dsn string, opts ...OpenOptFn) (*bun.DB, error) {
+	return OpenDBContext(context.Background(), dsn, opts...)
+}
+
+// OpenDBContext is OpenDB with a context that is honored by the ping, its retries (see WithConnectRetry) and the
+// pragma setup.
+// Errors are returned as an *Error of op "open".
+func OpenDBContext(ctx context.Context, dsn string, opts ...OpenOptFn) (*bun.DB, error) {
 	var opt Options
 	setOptions(&opt, opts...)
+	db, err := openDB(ctx, dsn, &opt)
+	if err != nil {
+		return nil, wrapErr("open", openName(dsn, &opt), "", err)
+	}
+	return db, nil
+}
+
+// openName returns the name of the db for events and errors: the SQLite name, never a DSN that may hold credentials
+func openName(dsn string, opt *Options) string {
+	if IsSQLite(DriverName(opt.driverName)) {
+		return dsn
+	}
+	return ""
+}
+
+func openDB(ctx context.Context, dsn string, opt *Options) (*bun.DB, error) {
+	if err := validateOptions(opt); err != nil {
+		return nil, err
+	}
+	warnPoolOptions(opt)
 	driver := DriverName(opt.driverName)
+	name := openName(dsn, opt)
 	if IsSQLite(driver) {
+		if opt.createIfMissing {
+			if err := os.MkdirAll(opt.dbFolder, 0o755); err != nil {
+				return nil, fmt.Errorf("failed to create db folder(%s): %w", opt.dbFolder, err)
+			}
+			if _, err := createSQLiteDBFile(ctx, dsn, opt.dbFolder); err != nil {
+				return nil, err
+			}
+		}
 		dbFile, err := DbFilePath(dsn, opt.dbFolder)
 		if err != nil {
 			return nil, err
 		}
 
-		if driver == DriverSQLite {
+		if opt.readOnly {
+			dsn = readOnlySQLiteDSN(driver, dbFile, opt.immutable)
+		} else if driver == DriverSQLite {
 			dsn = "file:" + dbFile +
 				"?_journal_mode=WAL" +
 				"&_synchronous=NORMAL" +
@@ -95,7 +234,50 @@ func OpenDB(dsn string, opts ...OpenOptFn) (*bun.DB, error) {
 		}
 	}
 
-	db, err := sql.Open(opt.driverName, dsn)
+	var hooks []connHook
+	if len(opt.extensions) > 0 {
+		hooks = append(hooks, extensionHook(opt.extensions))
+	}
+	if len(opt.sqlFuncs) > 0 {
+		hooks = append(hooks, sqlFuncHook(opt.sqlFuncs))
+	}
+	if len(opt.collations) > 0 {
+		hooks = append(hooks, collationHook(opt.collations))
+	}
+	if len(opt.pragmas) > 0 {
+		hooks = append(hooks, pragmaHook(opt.pragmas))
+	}
+	if opt.readOnly && !IsSQLite(driver) {
+		hooks = append(hooks, readOnlyHook(driver))
+	}
+
+	// The recorder wraps the chaos connection, so it records the injected failures too
+	var wraps []connWrapper
+	if opt.chaos != nil {
+		if opt.randSource != nil {
+			opt.chaos.rng = rand.New(opt.randSource)
+		}
+		wraps = append(wraps, opt.chaos.wrap)
+	}
+	if opt.recorder != nil {
+		wraps = append(wraps, opt.recorder.wrap)
+	}
+	if opt.tenantGuard {
+		wraps = append(wraps, checkWrapper(checkTenantGuard))
+	}
+	if opt.statementPolicy != nil {
+		opt.statementPolicy.backslash = driver == DriverMySQL
+		wraps = append(wraps, checkWrapper(opt.statementPolicy.check))
+	}
+	// Pools failing to open are closed without an event
+	var opened atomic.Bool
+	onClose := append(opt.onClose, func() error {
+		if opened.Load() {
+			publish(Event{Kind: EventDBClosed, DB: name})
+		}
+		return nil
+	})
+	db, err := openSQLDB(opt.driverName, dsn, hooks, wraps, onClose)
 	if err != nil {
 		return nil, err
 	}
@@ -105,29 +287,126 @@ func OpenDB(dsn string, opts ...OpenOptFn) (*bun.DB, error) {
 	db.SetConnMaxLifetime(opt.connMaxLifetime)
 	db.SetConnMaxIdleTime(opt.connMaxIdleTime)
 
-	if err := db.Ping(); err != nil {
+	if err := pingRetry(ctx, db, opt); err != nil {
 		db.Close()
 		return nil, err
 	}
 
-	if IsSQLite(driver) && driver == DriverSQLite {
-		if _, err = db.Exec(`PRAGMA temp_store = MEMORY;`); err != nil {
+	// A temp_store of WithPragma wins
+	if driver == DriverSQLite && !slices.ContainsFunc(opt.pragmas, func(p pragma) bool { return p.name == "temp_store" }) {
+		if _, err = db.ExecContext(ctx, `PRAGMA temp_store = MEMORY;`); err != nil {
 			db.Close()
 			return nil, fmt.Errorf("failed to enable WAL mode: %w", err)
 		}
 	}
 
-	bunDB := bun.NewDB(db, sqlitedialect.New(), bun.WithDiscardUnknownColumns())
-	if opt.logQueries {
-		bunDB.AddQueryHook(bundebug.NewQueryHook(
-			bundebug.WithVerbose(true),
-			// bundebug.FromEnv("BUN_DEBUG")
-		))
+	var bunOpts []bun.DBOption
+	if !opt.strictColumns {
+		bunOpts = append(bunOpts, bun.WithDiscardUnknownColumns())
+	}
+	d, err := openDialect(opt)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	bunDB := bun.NewDB(db, withModelDialect(d, opt), append(bunOpts, opt.bunOptions...)...)
+	if IsSQLite(driver) {
+		recordSettings(db, opt)
+	}
+	if opt.prePing {
+		if err := WarmPool(ctx, bunDB, opt.maxIdleConns); err != nil {
+			bunDB.Close()
+			return nil, fmt.Errorf("failed to warm pool: %w", err)
+		}
+	}
+	if len(opt.models) > 0 {
+		bunDB.RegisterModel(opt.models...)
+	}
+	if opt.validateModels {
+		if err := validateModelTables(ctx, bunDB, opt.models); err != nil {
+			bunDB.Close()
+			return nil, err
+		}
+	}
+	if d, ok := bunDB.Dialect().(*modelDialect); ok && d.guard != nil {
+		bunDB.AddQueryHook(d.guard)
+	}
+	bunDB.AddQueryHook(TraceHook{})
+	bunDB.AddQueryHook(metricsHook{})
+	if opt.explainLogger != nil {
+		bunDB.AddQueryHook(explainHook{logger: opt.explainLogger})
+	}
+	if opt.queryStats {
+		bunDB.AddQueryHook(DefaultQueryStats)
+	}
+	if opt.chaos != nil {
+		// Faults start once the db is open, so they never fail OpenDB itself
+		opt.chaos.enabled.Store(true)
+	}
+	if opt.statementPolicy != nil {
+		// Nor blocked
+		opt.statementPolicy.enabled.Store(true)
+	}
+	if opt.recorder != nil {
+		// Neither is the setup of OpenDB recorded, since OpenReplayDB does not run it
+		opt.recorder.enabled.Store(true)
+	}
+	if opt.pprofDB != "" {
+		bunDB.AddQueryHook(pprofHook{db: opt.pprofDB})
+	}
+	if hook := debugQueryHook(opt.queryLogging); hook != nil {
+		bunDB.AddQueryHook(hook)
+	}
+	for _, hook := range opt.queryHooks {
+		bunDB.AddQueryHook(hook)
 	}
 
+	opened.Store(true)
+	publish(Event{Kind: EventDBOpened, DB: name})
 	return bunDB, nil
 }
 
+// debugQueryHook returns the bundebug hook of mode, nil when it logs nothing
+func debugQueryHook(mode QueryLogging) bun.QueryHook {
+	switch mode {
+	case QueryLogErrors:
+		return bundebug.NewQueryHook()
+	case QueryLogAll:
+		return bundebug.NewQueryHook(bundebug.WithVerbose(true))
+	case QueryLogEnv:
+		// Without the variable, FromEnv keeps the hook enabled
+		if v, ok := os.LookupEnv("BUNDEBUG"); ok && v != "" && v != "0" {
+			return bundebug.NewQueryHook(bundebug.FromEnv("BUNDEBUG"))
+		}
+	}
+	return nil
+}
+
+// openDialect returns the dialect set by WithDialect, else the one of the driver
+func openDialect(opt *Options) (schema.Dialect, error) {
+	if opt.dialect != nil {
+		return opt.dialect, nil
+	}
+	return driverDialect(DriverName(opt.driverName))
+}
+
+// driverDialect returns a new bun dialect for driver
+func driverDialect(driver DriverName) (schema.Dialect, error) {
+	switch driver {
+	case DriverSQLite, DriverSQLiteMc:
+		return sqlitedialect.New(), nil
+	case DriverPostgres, DriverPgx:
+		return pgdialect.New(), nil
+	case DriverMySQL:
+		return mysqldialect.New(), nil
+	case DriverMSSQL:
+		return nil, fmt.Errorf("%w: no bundled dialect for driver %s, set one with WithDialect",
+			ErrInvalidOptions, driver)
+	default:
+		return nil, fmt.Errorf("%w: no dialect for driver %q, set one with WithDialect", ErrInvalidOptions, driver)
+	}
+}
+
 func setOptions(opt *Options, opts ...OpenOptFn) {
 
 	// Apply all options
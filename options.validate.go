@@ -0,0 +1,129 @@
+package dbx
+
+import (
+	"database/sql"
+	"fmt"
+	"io/fs"
+	"slices"
+	"strings"
+)
+
+// OptionsError lists every configuration problem found in the options of OpenDB, CreateDB or MigrateDB,
+// each wrapping ErrInvalidOptions
+type OptionsError struct {
+	Problems []error
+}
+
+func (e *OptionsError) Error() string {
+	if len(e.Problems) == 1 {
+		return e.Problems[0].Error()
+	}
+	msgs := make([]string, len(e.Problems))
+	for i, p := range e.Problems {
+		msgs[i] = p.Error()
+	}
+	return fmt.Sprintf("%d configuration problems: %s", len(e.Problems), strings.Join(msgs, "; "))
+}
+
+func (e *OptionsError) Unwrap() []error {
+	return e.Problems
+}
+
+// optionsError returns an *OptionsError of problems, nil without any
+func optionsError(problems []error) error {
+	if len(problems) == 0 {
+		return nil
+	}
+	return &OptionsError{Problems: problems}
+}
+
+// ValidateOptions checks opts as OpenDB would, without opening anything, and returns an *OptionsError listing all
+// the problems found, nil when there are none. OpenDB runs the same checks first.
+func ValidateOptions(opts ...OpenOptFn) error {
+	var opt Options
+	setOptions(&opt, opts...)
+	return validateOptions(&opt)
+}
+
+func validateOptions(opt *Options) error {
+	problems := driverProblems(DriverName(opt.driverName))
+	if len(problems) == 0 {
+		// A registered driver still needs a dialect
+		if _, err := openDialect(opt); err != nil {
+			problems = append(problems, err)
+		}
+	}
+	problems = append(problems, poolProblems(opt)...)
+	problems = append(problems, pragmaProblems(opt)...)
+	problems = append(problems, tenantGuardProblems(opt)...)
+	problems = append(problems, readOnlyProblems(opt)...)
+	problems = append(problems, statementPolicyProblems(opt)...)
+	if opt.queryLogging > QueryLogEnv {
+		problems = append(problems, fmt.Errorf("%w: unknown query logging mode %d", ErrInvalidOptions, opt.queryLogging))
+	}
+	for _, c := range opt.collations {
+		if c.cmp == nil {
+			problems = append(problems, fmt.Errorf("%w: unknown collation %s", ErrInvalidOptions, c.name))
+		}
+	}
+	if DriverName(opt.driverName) == DriverSQLiteMc && len(opt.sqlFuncs) > 0 {
+		// modernc.org/sqlite only registers functions process-wide, through its own API, before opening
+		problems = append(problems, fmt.Errorf("%w: WithSQLFunc needs driver %s; with %s, register %s with "+
+			"sqlite.RegisterFunction before opening", ErrInvalidOptions, DriverSQLite, DriverSQLiteMc, opt.sqlFuncs[0].name))
+	} else if DriverName(opt.driverName) != DriverSQLite &&
+		(len(opt.extensions) > 0 || len(opt.sqlFuncs) > 0 || len(opt.collations) > 0) {
+		problems = append(problems, fmt.Errorf("%w: extensions, SQL functions and collations need driver %s, not %s",
+			ErrInvalidOptions, DriverSQLite, opt.driverName))
+	}
+	if opt.createIfMissing && !IsSQLite(DriverName(opt.driverName)) {
+		problems = append(problems, fmt.Errorf("%w: WithCreateIfMissing needs sqlite, not %s",
+			ErrInvalidOptions, opt.driverName))
+	}
+	return optionsError(problems)
+}
+
+// ValidateCreateOptions checks opts as CreateDB and MigrateDB would, without opening anything, and returns an
+// *OptionsError listing all the problems found, nil when there are none. CreateDB and MigrateDB run the same checks
+// first.
+func ValidateCreateOptions(opts ...CreateOptFn) error {
+	option := CreateOptions{}
+	setCreateOptions(&option, opts...)
+	return validateCreateOptions(&option)
+}
+
+func validateCreateOptions(opt *CreateOptions) error {
+	problems := driverProblems(opt.driverName)
+	switch {
+	case opt.source != nil:
+		folder := opt.srcFolder
+		if folder == "" {
+			folder = "."
+		}
+		if info, err := fs.Stat(opt.source, folder); err != nil || !info.IsDir() {
+			problems = append(problems, fmt.Errorf("%w: migration folder %q not found in source",
+				ErrInvalidOptions, opt.srcFolder))
+		}
+	case opt.srcFolder != "":
+		problems = append(problems, fmt.Errorf("%w: migration folder %q without a source",
+			ErrInvalidOptions, opt.srcFolder))
+	}
+	if len(opt.attachments) > 0 && !IsSQLite(opt.driverName) {
+		problems = append(problems, fmt.Errorf("%w: attached databases need sqlite, not %s",
+			ErrInvalidOptions, opt.driverName))
+	}
+	for _, a := range opt.attachments {
+		if !moduleNameRe.MatchString(a.schema) {
+			problems = append(problems, fmt.Errorf("%w: invalid attach schema name %q", ErrInvalidOptions, a.schema))
+		}
+	}
+	return optionsError(problems)
+}
+
+// driverProblems reports a driver not registered with database/sql, usually a missing import
+func driverProblems(driver DriverName) []error {
+	if slices.Contains(sql.Drivers(), string(driver)) {
+		return nil
+	}
+	return []error{fmt.Errorf("%w: unknown driver %q, not registered with database/sql (missing import?)",
+		ErrInvalidOptions, driver)}
+}
@@ -0,0 +1,60 @@
+package dbx
+
+import (
+	"errors"
+	"testing"
+	"testing/fstest"
+)
+
+func TestValidateOptions(t *testing.T) {
+	if err := ValidateOptions(WithDbFolder(t.TempDir())); err != nil {
+		t.Fatalf("expected valid defaults, got %v", err)
+	}
+
+	opts := []OpenOptFn{WithDriverName("nope"), WithMaxOpenConns(-1), WithCollation("KLINGON"), WithCreateIfMissing()}
+	err := ValidateOptions(opts...)
+	var oe *OptionsError
+	if !errors.As(err, &oe) {
+		t.Fatalf("expected an *OptionsError, got %v", err)
+	}
+	// The unknown driver, the negative pool size, the unknown collation, the collation without mattn/go-sqlite3
+	// and WithCreateIfMissing without sqlite
+	if len(oe.Problems) != 5 {
+		t.Errorf("expected 5 problems, got %d: %v", len(oe.Problems), err)
+	}
+	if !errors.Is(err, ErrInvalidOptions) || KindOf(err) != KindInvalid {
+		t.Errorf("expected ErrInvalidOptions of KindInvalid, got %v", err)
+	}
+
+	_, err = OpenDB("invalid", opts...)
+	if !errors.As(err, &oe) || len(oe.Problems) != 5 {
+		t.Errorf("expected OpenDB to list the 5 problems, got %v", err)
+	}
+}
+
+func TestValidateCreateOptions(t *testing.T) {
+	source := fstest.MapFS{"migrations/00001_init.sql": {Data: []byte("-- +goose Up\n")}}
+	if err := ValidateCreateOptions(CreateWithSource(source), CreateWithSrcFolder("migrations")); err != nil {
+		t.Fatalf("expected valid options, got %v", err)
+	}
+
+	opts := []CreateOptFn{
+		CreateWithDbFolder(t.TempDir()),
+		CreateWithSource(source),
+		CreateWithSrcFolder("migration"),
+		CreateWithAttach("bad name", "archive"),
+	}
+	err := ValidateCreateOptions(opts...)
+	var oe *OptionsError
+	if !errors.As(err, &oe) || len(oe.Problems) != 2 {
+		t.Fatalf("expected the missing folder and the bad schema name, got %v", err)
+	}
+	if err := CreateDB("invalid", opts...); !errors.As(err, &oe) || len(oe.Problems) != 2 {
+		t.Errorf("expected CreateDB to list the 2 problems, got %v", err)
+	}
+
+	err = ValidateCreateOptions(CreateWithDriverName("nope"), CreateWithSrcFolder("migrations"))
+	if !errors.As(err, &oe) || len(oe.Problems) != 2 {
+		t.Errorf("expected the unknown driver and the folder without a source, got %v", err)
+	}
+}
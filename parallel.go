@@ -0,0 +1,38 @@
+package dbx
+
+import (
+	"context"
+	"runtime"
+
+	"github.com/uptrace/bun"
+	"golang.org/x/sync/errgroup"
+)
+
+// QueryParallel runs independent read queries concurrently, each on its own pooled connection, and returns the
+// first error; the ctx passed to the other queries is cancelled on error. Parallelism is bounded by the pool's
+// max open connections (GOMAXPROCS when unlimited), so a single-connection SQLite pool runs the queries one
+// after the other. Queries must not share a transaction: use separate destinations and no Tx.
+func QueryParallel(ctx context.Context, db *bun.DB, queries ...func(ctx context.Context, idb bun.IDB) error) error {
+	limit := db.Stats().MaxOpenConnections
+	if limit <= 0 {
+		limit = runtime.GOMAXPROCS(0)
+	}
+
+	if limit == 1 || len(queries) < 2 {
+		for _, query := range queries {
+			if err := query(ctx, db); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	g, gctx := errgroup.WithContext(ctx)
+	g.SetLimit(limit)
+	for _, query := range queries {
+		g.Go(func() error {
+			return query(gctx, db)
+		})
+	}
+	return g.Wait()
+}
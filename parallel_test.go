@@ -0,0 +1,65 @@
+package dbx
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/uptrace/bun"
+)
+
+func TestQueryParallel(t *testing.T) {
+	ctx := context.Background()
+	db := setupTestDB(t)
+	insertItem(t, db, "a")
+	insertItem(t, db, "b")
+
+	// Single connection: sequential
+	var count int
+	var names []string
+	err := QueryParallel(ctx, db,
+		func(ctx context.Context, idb bun.IDB) error {
+			return idb.NewRaw("SELECT COUNT(*) FROM items").Scan(ctx, &count)
+		},
+		func(ctx context.Context, idb bun.IDB) error {
+			return idb.NewRaw("SELECT name FROM items ORDER BY id").Scan(ctx, &names)
+		},
+	)
+	if err != nil {
+		t.Fatalf("QueryParallel failed: %v", err)
+	}
+	if count != 2 || len(names) != 2 {
+		t.Fatalf("unexpected results count=%d names=%v", count, names)
+	}
+
+	// Larger pool: queries overlap
+	db.SetMaxOpenConns(4)
+	var running, peak atomic.Int32
+	slow := func(ctx context.Context, idb bun.IDB) error {
+		n := running.Add(1)
+		defer running.Add(-1)
+		for {
+			p := peak.Load()
+			if n <= p || peak.CompareAndSwap(p, n) {
+				break
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+		var one int
+		return idb.NewRaw("SELECT 1").Scan(ctx, &one)
+	}
+	if err := QueryParallel(ctx, db, slow, slow, slow); err != nil {
+		t.Fatalf("QueryParallel failed: %v", err)
+	}
+	if peak.Load() < 2 {
+		t.Fatalf("expected queries to run concurrently, peak %d", peak.Load())
+	}
+
+	boom := errors.New("boom")
+	err = QueryParallel(ctx, db, slow, func(context.Context, bun.IDB) error { return boom })
+	if !errors.Is(err, boom) {
+		t.Fatalf("expected boom, got %v", err)
+	}
+}
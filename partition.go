@@ -0,0 +1,204 @@
+package dbx
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/dialect"
+)
+
+var (
+	partitionTableDef = regexp.MustCompile(`(?is)^\s*CREATE\s+TABLE\s+(?:IF\s+NOT\s+EXISTS\s+)?(?:"[^"]+"|\S+?)\s*(\(.*)$`)
+	partitionIndexDef = regexp.MustCompile(`(?is)^\s*CREATE\s+(UNIQUE\s+)?INDEX\s+(?:IF\s+NOT\s+EXISTS\s+)?(?:"[^"]+"|\S+)\s+ON\s+(?:"[^"]+"|\S+?)\s*(\(.*)$`)
+)
+
+// MonthPartitions splits a table into one table per calendar month (UTC) of a time column.
+//
+// On SQLite the base table is a template: each partition is created with its definition and indexes as
+// <base>_YYYY_MM, and the <base>_all view reads all partitions with UNION ALL.
+// On Postgres the base table must be declared with `PARTITION BY RANGE (<timeColumn>)`; partitions are created as
+// declarative partitions of it, so inserts and queries on the base table are routed by Postgres itself.
+type MonthPartitions struct {
+	db     bun.IDB
+	base   string
+	column string
+
+	mu    sync.Mutex
+	known map[string]bool
+}
+
+// PartitionByMonth returns the partition manager of baseTable, partitioned on timeColumn.
+// The existing partitions are looked up once, so the returned value should be kept for the life of the db.
+func PartitionByMonth(ctx context.Context, idb bun.IDB, baseTable, timeColumn string) (*MonthPartitions, error) {
+	switch dName := idb.Dialect().Name(); dName {
+	case dialect.SQLite, dialect.PG:
+	default:
+		return nil, fmt.Errorf("unsupported dialect: %s", dName)
+	}
+
+	p := &MonthPartitions{db: idb, base: baseTable, column: timeColumn, known: make(map[string]bool)}
+	names, err := p.Partitions(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range names {
+		p.known[name] = true
+	}
+	return p, nil
+}
+
+// PartitionName returns the name of the partition holding rows at t
+func (p *MonthPartitions) PartitionName(t time.Time) string {
+	return fmt.Sprintf("%s_%s", p.base, t.UTC().Format("2006_01"))
+}
+
+// View returns the table or view to query across all partitions
+func (p *MonthPartitions) View() string {
+	if p.db.Dialect().Name() == dialect.PG {
+		return p.base
+	}
+	return p.base + "_all"
+}
+
+// Partitions returns the names of the existing partitions, oldest first
+func (p *MonthPartitions) Partitions(ctx context.Context) ([]string, error) {
+	var query string
+	switch p.db.Dialect().Name() {
+	case dialect.SQLite:
+		query = `SELECT name FROM sqlite_master WHERE type = 'table' AND name GLOB ? || '_[0-9][0-9][0-9][0-9]_[0-9][0-9]' ORDER BY name`
+	case dialect.PG:
+		query = `SELECT c.relname FROM pg_inherits i
+			JOIN pg_class c ON c.oid = i.inhrelid
+			JOIN pg_class b ON b.oid = i.inhparent
+			WHERE b.relname = ? ORDER BY c.relname`
+	}
+
+	var names []string
+	if err := p.db.NewRaw(query, p.base).Scan(ctx, &names); err != nil {
+		return nil, fmt.Errorf("failed to list partitions of %s: %w", p.base, err)
+	}
+	return names, nil
+}
+
+// Ensure creates the partition holding rows at t if it does not exist and returns its name.
+// On SQLite the view is recreated to include the new partition.
+func (p *MonthPartitions) Ensure(ctx context.Context, t time.Time) (string, error) {
+	name := p.PartitionName(t)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.known[name] {
+		return name, nil
+	}
+
+	var err error
+	switch p.db.Dialect().Name() {
+	case dialect.SQLite:
+		err = p.createSQLitePartition(ctx, name)
+	case dialect.PG:
+		from := time.Date(t.UTC().Year(), t.UTC().Month(), 1, 0, 0, 0, 0, time.UTC)
+		_, err = p.db.ExecContext(ctx, "CREATE TABLE IF NOT EXISTS ? PARTITION OF ? FOR VALUES FROM (?) TO (?)",
+			bun.Ident(name), bun.Ident(p.base), from, from.AddDate(0, 1, 0))
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to create partition %s: %w", name, err)
+	}
+
+	p.known[name] = true
+	return name, nil
+}
+
+// Insert stores model in the partition matching t, creating it when needed
+func (p *MonthPartitions) Insert(ctx context.Context, t time.Time, model any) error {
+	name, err := p.Ensure(ctx, t)
+	if err != nil {
+		return err
+	}
+	if p.db.Dialect().Name() == dialect.PG {
+		name = p.base
+	}
+	_, err = p.db.NewInsert().Model(model).ModelTableExpr("?", bun.Ident(name)).Exec(ctx)
+	return err
+}
+
+// Select returns a query over the rows with timeColumn in [from, to). On SQLite only the partitions covering the
+// range are read, as a subquery aliased to the base table name.
+func (p *MonthPartitions) Select(from, to time.Time) *bun.SelectQuery {
+	q := p.db.NewSelect()
+	if p.db.Dialect().Name() == dialect.PG {
+		q = q.TableExpr("?", bun.Ident(p.base))
+	} else {
+		first, last := p.PartitionName(from), p.PartitionName(to.Add(-time.Nanosecond))
+		selects := []string{fmt.Sprintf("SELECT * FROM %q WHERE 0", p.base)}
+		p.mu.Lock()
+		for _, part := range slices.Sorted(maps.Keys(p.known)) {
+			if part >= first && part <= last {
+				selects = append(selects, fmt.Sprintf("SELECT * FROM %q", part))
+			}
+		}
+		p.mu.Unlock()
+		q = q.TableExpr("("+strings.Join(selects, " UNION ALL ")+") AS ?", bun.Ident(p.base))
+	}
+	return q.Where("? >= ?", bun.Ident(p.column), from.UTC()).Where("? < ?", bun.Ident(p.column), to.UTC())
+}
+
+// createSQLitePartition copies the definition and indexes of the base table and rebuilds the view
+func (p *MonthPartitions) createSQLitePartition(ctx context.Context, name string) error {
+	var defs []struct {
+		Type string `bun:"type"`
+		Name string `bun:"name"`
+		SQL  string `bun:"sql"`
+	}
+	err := p.db.NewRaw("SELECT type, name, sql FROM sqlite_master WHERE tbl_name = ? AND sql IS NOT NULL ORDER BY type = 'index'", p.base).
+		Scan(ctx, &defs)
+	if err != nil {
+		return err
+	}
+	if len(defs) == 0 {
+		return fmt.Errorf("base table %s not found", p.base)
+	}
+
+	suffix := strings.TrimPrefix(name, p.base+"_")
+	for _, def := range defs {
+		var stmt string
+		switch def.Type {
+		case "table":
+			m := partitionTableDef.FindStringSubmatch(def.SQL)
+			if m == nil {
+				return fmt.Errorf("cannot parse definition of %s", p.base)
+			}
+			stmt = fmt.Sprintf("CREATE TABLE IF NOT EXISTS %q %s", name, m[1])
+		case "index":
+			m := partitionIndexDef.FindStringSubmatch(def.SQL)
+			if m == nil {
+				return fmt.Errorf("cannot parse definition of index %s", def.Name)
+			}
+			stmt = fmt.Sprintf("CREATE %sINDEX IF NOT EXISTS %q ON %q %s", m[1], def.Name+"_"+suffix, name, m[2])
+		default:
+			continue
+		}
+		if _, err := p.db.ExecContext(ctx, stmt); err != nil {
+			return err
+		}
+	}
+
+	parts := append(slices.Collect(maps.Keys(p.known)), name)
+	slices.Sort(parts)
+	selects := make([]string, len(parts))
+	for i, part := range parts {
+		selects[i] = fmt.Sprintf(`SELECT * FROM %q`, part)
+	}
+	view := bun.Ident(p.View())
+	if _, err := p.db.ExecContext(ctx, "DROP VIEW IF EXISTS ?", view); err != nil {
+		return err
+	}
+	_, err = p.db.ExecContext(ctx, "CREATE VIEW ? AS "+strings.Join(selects, " UNION ALL "), view)
+	return err
+}
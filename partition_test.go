@@ -0,0 +1,78 @@
+package dbx
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/uptrace/bun"
+)
+
+type partitionedEvent struct {
+	ID        int64     `bun:"id,pk"`
+	Kind      string    `bun:"kind"`
+	CreatedAt time.Time `bun:"created_at"`
+}
+
+func TestPartitionByMonth(t *testing.T) {
+	ctx := context.Background()
+	db := setupTestDB(t)
+	if _, err := db.ExecContext(ctx, `CREATE TABLE events (id INTEGER PRIMARY KEY, kind TEXT, created_at TIMESTAMP)`); err != nil {
+		t.Fatalf("create failed: %v", err)
+	}
+	if _, err := db.ExecContext(ctx, `CREATE INDEX events_kind_idx ON events (kind)`); err != nil {
+		t.Fatalf("create index failed: %v", err)
+	}
+
+	parts, err := PartitionByMonth(ctx, db, "events", "created_at")
+	if err != nil {
+		t.Fatalf("PartitionByMonth failed: %v", err)
+	}
+
+	jan := time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)
+	feb := time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)
+	for i, at := range []time.Time{jan, jan.Add(time.Hour), feb} {
+		ev := &partitionedEvent{ID: int64(i + 1), Kind: "click", CreatedAt: at}
+		if err := parts.Insert(ctx, at, ev); err != nil {
+			t.Fatalf("Insert failed: %v", err)
+		}
+	}
+
+	names, err := parts.Partitions(ctx)
+	if err != nil {
+		t.Fatalf("Partitions failed: %v", err)
+	}
+	if len(names) != 2 || names[0] != "events_2024_01" || names[1] != "events_2024_02" {
+		t.Fatalf("unexpected partitions: %v", names)
+	}
+	var idx int
+	if err := db.NewRaw("SELECT COUNT(*) FROM sqlite_master WHERE type = 'index' AND name = 'events_kind_idx_2024_01'").Scan(ctx, &idx); err != nil || idx != 1 {
+		t.Fatalf("expected index copied to partition, got %d (err %v)", idx, err)
+	}
+
+	var total int
+	if err := db.NewRaw("SELECT COUNT(*) FROM ?", bun.Ident(parts.View())).Scan(ctx, &total); err != nil {
+		t.Fatalf("count view failed: %v", err)
+	}
+	if total != 3 {
+		t.Fatalf("expected 3 rows in view, got %d", total)
+	}
+
+	var janEvents []partitionedEvent
+	if err := parts.Select(jan.AddDate(0, 0, -14), feb).Scan(ctx, &janEvents); err != nil {
+		t.Fatalf("Select failed: %v", err)
+	}
+	if len(janEvents) != 2 {
+		t.Fatalf("expected 2 events in January, got %d", len(janEvents))
+	}
+
+	// A new manager picks up the existing partitions
+	reopened, err := PartitionByMonth(ctx, db, "events", "created_at")
+	if err != nil {
+		t.Fatalf("PartitionByMonth failed: %v", err)
+	}
+	var all []partitionedEvent
+	if err := reopened.Select(jan.AddDate(-1, 0, 0), feb.AddDate(1, 0, 0)).Scan(ctx, &all); err != nil || len(all) != 3 {
+		t.Fatalf("expected 3 events after reopening, got %d (err %v)", len(all), err)
+	}
+}
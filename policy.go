@@ -0,0 +1,115 @@
+package dbx
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/schema"
+)
+
+// ErrPolicyDenied is wrapped by the errors of the policies refusing a query, e.g. TenantPolicy without a tenant
+var ErrPolicyDenied = errors.New("query denied by policy")
+
+// QueryPolicy inspects a select, update or delete query built by a repository before it runs, and may restrict it,
+// e.g. with q.Where, or refuse it with an error. It emulates row-level security on the databases without it.
+type QueryPolicy interface {
+	ApplyPolicy(ctx context.Context, q bun.QueryBuilder) error
+}
+
+// QueryPolicyFunc is a function used as a QueryPolicy
+type QueryPolicyFunc func(ctx context.Context, q bun.QueryBuilder) error
+
+func (f QueryPolicyFunc) ApplyPolicy(ctx context.Context, q bun.QueryBuilder) error {
+	return f(ctx, q)
+}
+
+// WithQueryPolicy registers policies applied by ApplyQueryPolicies to the queries of the db, in order.
+// Repeat it to add more.
+func WithQueryPolicy(policies ...QueryPolicy) OpenOptFn {
+	return func(opt *Options) {
+		opt.policies = append(opt.policies, policies...)
+	}
+}
+
+// ApplyQueryPolicies applies the policies of WithQueryPolicy of the db of q to q, e.g.
+//
+//	q := db.NewSelect().Model(&orders)
+//	if err := dbx.ApplyQueryPolicies(ctx, q.QueryBuilder()); err != nil { ... }
+//
+// The repositories of dbx, such as TreeRepo, apply them to the queries they build; repositories of the application
+// call it on theirs. Raw SQL and queries never passed to it are not checked.
+func ApplyQueryPolicies(ctx context.Context, q bun.QueryBuilder) error {
+	for _, p := range queryPolicies(q) {
+		if err := p.ApplyPolicy(ctx, q); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// queryPolicies returns the policies of the db of q
+func queryPolicies(q bun.QueryBuilder) []QueryPolicy {
+	if d := policyDialect(q); d != nil {
+		return d.policies
+	}
+	return nil
+}
+
+// policyDialect returns the modelDialect of the db of q, nil without one
+func policyDialect(q bun.QueryBuilder) *modelDialect {
+	if dq, ok := q.Unwrap().(interface{ Dialect() schema.Dialect }); ok {
+		if d, ok := dq.Dialect().(*modelDialect); ok {
+			return d
+		}
+	}
+	return nil
+}
+
+// TenantPolicy restricts the queries of tables to the rows of the tenant of their context: it adds
+// "column = <tenant>" to their WHERE, and refuses them with ErrPolicyDenied when tenant finds none in the context.
+// tables are names in the database, see TableName; without any, every query is restricted.
+// WithTenantGuard makes the db fail the queries of the tables that did not go through it.
+func TenantPolicy(column string, tenant func(ctx context.Context) (any, bool), tables ...string) QueryPolicy {
+	return &tenantPolicy{column: column, tenant: tenant, tables: tables}
+}
+
+type tenantPolicy struct {
+	column string
+	tenant func(ctx context.Context) (any, bool)
+	tables []string
+}
+
+// covers reports whether the queries of table are restricted by p
+func (p *tenantPolicy) covers(table string) bool {
+	return len(p.tables) == 0 || slices.Contains(p.tables, table)
+}
+
+func (p *tenantPolicy) ApplyPolicy(ctx context.Context, q bun.QueryBuilder) error {
+	table := queryTable(q)
+	if !p.covers(table) {
+		return nil
+	}
+	id, ok := p.tenant(ctx)
+	if !ok {
+		return fmt.Errorf("%w: no tenant in the context for %s of %s", ErrPolicyDenied, q.Operation(), table)
+	}
+	// The column of the model table, not of a joined one
+	if _, ok := q.GetModel().(bun.TableModel); ok {
+		q.Where("?TableAlias.? = ?", bun.Ident(p.column), id)
+	} else {
+		q.Where("? = ?", bun.Ident(p.column), id)
+	}
+	if d := policyDialect(q); d != nil && d.guard != nil {
+		d.guard.markScoped(q.Unwrap())
+	}
+	return nil
+}
+
+// queryTable returns the name of the table of q, unquoted
+func queryTable(q schema.Query) string {
+	return strings.Trim(q.GetTableName(), "\"`[]")
+}
@@ -0,0 +1,83 @@
+package dbx
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+type policyDoc struct {
+	ID       int64 `bun:",pk,autoincrement"`
+	TenantID int64 `bun:",notnull"`
+	Title    string
+}
+
+type tenantKey struct{}
+
+func tenantFromCtx(ctx context.Context) (any, bool) {
+	id, ok := ctx.Value(tenantKey{}).(int64)
+	return id, ok
+}
+
+func TestTenantPolicy(t *testing.T) {
+	ctx := context.Background()
+	db, err := OpenDB("policy", WithDbFolder(t.TempDir()), WithCreateIfMissing(),
+		WithQueryPolicy(TenantPolicy("tenant_id", tenantFromCtx, "policy_docs")))
+	if err != nil {
+		t.Fatalf("OpenDB failed: %v", err)
+	}
+	defer db.Close()
+	if _, err := db.NewCreateTable().Model((*policyDoc)(nil)).Exec(ctx); err != nil {
+		t.Fatalf("create table failed: %v", err)
+	}
+	docs := []policyDoc{{TenantID: 1, Title: "a"}, {TenantID: 1, Title: "b"}, {TenantID: 2, Title: "c"}}
+	if _, err := db.NewInsert().Model(&docs).Exec(ctx); err != nil {
+		t.Fatalf("insert failed: %v", err)
+	}
+
+	var got []policyDoc
+	q := db.NewSelect().Model(&got)
+	if err := ApplyQueryPolicies(ctx, q.QueryBuilder()); !errors.Is(err, ErrPolicyDenied) || KindOf(err) != KindMisuse {
+		t.Fatalf("expected ErrPolicyDenied without a tenant, got %v", err)
+	}
+
+	tenant1 := context.WithValue(ctx, tenantKey{}, int64(1))
+	q = db.NewSelect().Model(&got).Order("id")
+	if err := ApplyQueryPolicies(tenant1, q.QueryBuilder()); err != nil {
+		t.Fatalf("ApplyQueryPolicies failed: %v", err)
+	}
+	if err := q.Scan(tenant1); err != nil {
+		t.Fatalf("select failed: %v", err)
+	}
+	if len(got) != 2 || got[0].Title != "a" || got[1].Title != "b" {
+		t.Fatalf("expected the docs of tenant 1, got %+v", got)
+	}
+
+	upd := db.NewUpdate().Model((*policyDoc)(nil)).Set("title = ?", "x").Where("1 = 1")
+	if err := ApplyQueryPolicies(tenant1, upd.QueryBuilder()); err != nil {
+		t.Fatalf("ApplyQueryPolicies failed: %v", err)
+	}
+	if _, err := upd.Exec(tenant1); err != nil {
+		t.Fatalf("update failed: %v", err)
+	}
+	del := db.NewDelete().Model((*policyDoc)(nil)).Where("title = ?", "x")
+	if err := ApplyQueryPolicies(tenant1, del.QueryBuilder()); err != nil {
+		t.Fatalf("ApplyQueryPolicies failed: %v", err)
+	}
+	if _, err := del.Exec(tenant1); err != nil {
+		t.Fatalf("delete failed: %v", err)
+	}
+	var left []policyDoc
+	if err := db.NewSelect().Model(&left).Scan(ctx); err != nil {
+		t.Fatalf("select failed: %v", err)
+	}
+	if len(left) != 1 || left[0].Title != "c" {
+		t.Fatalf("expected only the doc of tenant 2 left, got %+v", left)
+	}
+
+	// Tables outside the policy are not restricted
+	other := db.NewSelect().Table("sqlite_master")
+	if err := ApplyQueryPolicies(ctx, other.QueryBuilder()); err != nil {
+		t.Fatalf("expected no policy on another table, got %v", err)
+	}
+}
@@ -0,0 +1,117 @@
+package dbx
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/uptrace/bun"
+)
+
+var ErrInvalidOptions = errors.New("invalid options")
+
+// poolProblems lists the pool settings database/sql would silently adjust
+func poolProblems(opt *Options) []error {
+	var problems []error
+	if opt.maxOpenConns < 0 || opt.maxIdleConns < 0 {
+		problems = append(problems, fmt.Errorf("%w: negative max open connections %d or max idle connections %d",
+			ErrInvalidOptions, opt.maxOpenConns, opt.maxIdleConns))
+	}
+	if opt.maxOpenConns > 0 && opt.maxIdleConns > opt.maxOpenConns {
+		problems = append(problems, fmt.Errorf("%w: max idle connections %d exceed max open connections %d",
+			ErrInvalidOptions, opt.maxIdleConns, opt.maxOpenConns))
+	}
+	if opt.connMaxIdleTime < 0 || opt.connMaxLifetime < 0 {
+		problems = append(problems, fmt.Errorf("%w: negative connection max idle time %s or lifetime %s",
+			ErrInvalidOptions, opt.connMaxIdleTime, opt.connMaxLifetime))
+	}
+	if opt.connectAttempts < 0 || opt.connectBackoff < 0 {
+		problems = append(problems, fmt.Errorf("%w: negative connect attempts %d or backoff %s",
+			ErrInvalidOptions, opt.connectAttempts, opt.connectBackoff))
+	}
+	return problems
+}
+
+// warnPoolOptions warns about a writable SQLite pool with several connections, whose writers contend for the single
+// database lock. Read-only pools (WithReadOnly, WithImmutable, OpenEmbeddedDB) take no write lock.
+func warnPoolOptions(opt *Options) {
+	if IsSQLite(DriverName(opt.driverName)) && !opt.readOnly && opt.maxOpenConns != 1 {
+		slog.Warn("sqlite pool with several connections: concurrent writers will wait on busy_timeout",
+			"max_open_conns", opt.maxOpenConns)
+	}
+}
+
+// WithPrePing makes OpenDB establish and ping as many connections as the pool keeps idle (see WarmPool),
+// so the first requests do not pay for connecting and a bad DSN or server fails at open instead of under traffic
+func WithPrePing(prePing bool) OpenOptFn {
+	return func(opt *Options) {
+		opt.prePing = prePing
+	}
+}
+
+// WithConnectRetry makes OpenDB try up to attempts times to connect and ping, waiting backoff before the second try
+// and twice as long before each further one, e.g. while the container of a Postgres server starts. The waits end
+// with the context of OpenDBContext.
+func WithConnectRetry(attempts int, backoff time.Duration) OpenOptFn {
+	return func(opt *Options) {
+		opt.connectAttempts = attempts
+		opt.connectBackoff = backoff
+	}
+}
+
+// pingRetry pings db with the retries of WithConnectRetry
+func pingRetry(ctx context.Context, db *sql.DB, opt *Options) error {
+	backoff := opt.connectBackoff
+	for attempt := 1; ; attempt++ {
+		err := db.PingContext(ctx)
+		if err == nil || ctx.Err() != nil {
+			return err
+		}
+		if attempt >= opt.connectAttempts {
+			if attempt > 1 {
+				return fmt.Errorf("failed to connect after %d attempts: %w", attempt, err)
+			}
+			return err
+		}
+
+		timer := time.NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return errors.Join(err, context.Cause(ctx))
+		case <-timer.C:
+		}
+		backoff *= 2
+	}
+}
+
+// WarmPool opens n connections at once, pings each and returns them to the pool, where up to the max idle
+// connections stay open. n is capped at the max open connections of the pool.
+func WarmPool(ctx context.Context, db *bun.DB, n int) error {
+	if maxOpen := db.Stats().MaxOpenConnections; maxOpen > 0 {
+		n = min(n, maxOpen)
+	}
+
+	conns := make([]*sql.Conn, 0, n)
+	defer func() {
+		for _, conn := range conns {
+			_ = conn.Close()
+		}
+	}()
+
+	// Hold every connection until all are open, otherwise the pool would hand out the same one again
+	for i := range n {
+		conn, err := db.DB.Conn(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to open connection %d of %d: %w", i+1, n, err)
+		}
+		conns = append(conns, conn)
+		if err := conn.PingContext(ctx); err != nil {
+			return fmt.Errorf("failed to ping connection %d of %d: %w", i+1, n, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,148 @@
+package dbx
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"log/slog"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+	"github.com/uptrace/bun/dialect/sqlitedialect"
+)
+
+func TestWarmPool(t *testing.T) {
+	ctx := context.Background()
+	tmp := t.TempDir()
+	dsn := filepath.Join(tmp, "pool.sqlite")
+	if _, err := createSQLiteDBFile(ctx, dsn, tmp); err != nil {
+		t.Fatalf("createSQLiteDBFile failed: %v", err)
+	}
+
+	db, err := OpenDB(dsn, WithDbFolder(tmp), WithMaxOpenConns(4), WithMaxIdleConns(3), WithPrePing(true))
+	if err != nil {
+		t.Fatalf("OpenDB failed: %v", err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+	if stats := db.Stats(); stats.OpenConnections != 3 || stats.Idle != 3 {
+		t.Fatalf("expected 3 idle connections after open, got %+v", stats)
+	}
+
+	// Capped at the max open connections
+	if err := WarmPool(ctx, db, 10); err != nil {
+		t.Fatalf("WarmPool failed: %v", err)
+	}
+	if stats := db.Stats(); stats.InUse != 0 || stats.Idle != 3 {
+		t.Fatalf("expected the connections back in the pool, got %+v", stats)
+	}
+
+	if err := db.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	if err := WarmPool(ctx, db, 1); err == nil {
+		t.Fatalf("expected WarmPool on a closed db to fail")
+	}
+}
+
+func TestValidatePoolOptions(t *testing.T) {
+	tmp := t.TempDir()
+	for _, opts := range [][]OpenOptFn{
+		{WithMaxOpenConns(2), WithMaxIdleConns(3)},
+		{WithConnMaxIdleTime(-time.Second)},
+		{WithConnMaxLifetime(-time.Second)},
+	} {
+		opts = append(opts, WithDbFolder(tmp))
+		if _, err := OpenDB("invalid", opts...); !errors.Is(err, ErrInvalidOptions) {
+			t.Fatalf("expected ErrInvalidOptions, got %v", err)
+		}
+	}
+
+	opt := Options{}
+	setOptions(&opt, WithMaxOpenConns(4), WithMaxIdleConns(4), WithConnMaxIdleTime(time.Minute))
+	if err := validateOptions(&opt); err != nil {
+		t.Fatalf("expected valid options, got %v", err)
+	}
+}
+
+// flakyDriver fails the first connections, like a server still starting, then connects with go-sqlite3
+type flakyDriver struct {
+	failures atomic.Int32
+}
+
+func (d *flakyDriver) Open(dsn string) (driver.Conn, error) {
+	if d.failures.Add(-1) >= 0 {
+		return nil, errors.New("connection refused")
+	}
+	return (&sqlite3.SQLiteDriver{}).Open(dsn)
+}
+
+var flaky = &flakyDriver{}
+
+func init() {
+	sql.Register("dbx-flaky", flaky)
+}
+
+func TestWithConnectRetry(t *testing.T) {
+	dsn := filepath.Join(t.TempDir(), "flaky.sqlite")
+	opts := []OpenOptFn{WithDriverName("dbx-flaky"), WithDialect(sqlitedialect.New())}
+
+	flaky.failures.Store(2)
+	if _, err := OpenDB(dsn, opts...); err == nil || !strings.Contains(err.Error(), "connection refused") {
+		t.Fatalf("expected the connection to fail without retries, got %v", err)
+	}
+
+	flaky.failures.Store(2)
+	db, err := OpenDB(dsn, append(opts, WithConnectRetry(3, time.Millisecond))...)
+	if err != nil {
+		t.Fatalf("expected OpenDB to connect on the third attempt, got %v", err)
+	}
+	_ = db.Close()
+
+	flaky.failures.Store(5)
+	_, err = OpenDB(dsn, append(opts, WithConnectRetry(2, time.Millisecond))...)
+	if err == nil || !strings.Contains(err.Error(), "after 2 attempts") {
+		t.Fatalf("expected OpenDB to give up after 2 attempts, got %v", err)
+	}
+
+	flaky.failures.Store(100)
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, err = OpenDBContext(ctx, dsn, append(opts, WithConnectRetry(10, time.Second))...)
+	if !errors.Is(err, context.DeadlineExceeded) || time.Since(start) > 5*time.Second {
+		t.Fatalf("expected the retries to end with the context, got %v after %s", err, time.Since(start))
+	}
+
+	if err := ValidateOptions(WithConnectRetry(-1, 0)); !errors.Is(err, ErrInvalidOptions) {
+		t.Fatalf("expected negative attempts to be invalid, got %v", err)
+	}
+}
+
+func TestWarnPoolOptions(t *testing.T) {
+	var buf strings.Builder
+	defer slog.SetDefault(slog.Default())
+	slog.SetDefault(slog.New(slog.NewTextHandler(&buf, nil)))
+
+	for _, tc := range []struct {
+		opts []OpenOptFn
+		warn bool
+	}{
+		{[]OpenOptFn{WithMaxOpenConns(4)}, true},
+		{[]OpenOptFn{WithMaxOpenConns(1)}, false},
+		{[]OpenOptFn{WithMaxOpenConns(4), WithReadOnly()}, false},
+		{[]OpenOptFn{WithMaxOpenConns(4), WithImmutable()}, false},
+	} {
+		buf.Reset()
+		var opt Options
+		setOptions(&opt, tc.opts...)
+		warnPoolOptions(&opt)
+		if warned := strings.Contains(buf.String(), "sqlite pool with several connections"); warned != tc.warn {
+			t.Errorf("read-only %v, max open conns %d: expected warning %v, got %q", opt.readOnly, opt.maxOpenConns, tc.warn, buf.String())
+		}
+	}
+}
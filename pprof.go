@@ -0,0 +1,76 @@
+package dbx
+
+import (
+	"context"
+	"runtime"
+	"runtime/pprof"
+	"strings"
+
+	"github.com/uptrace/bun"
+)
+
+// Profile label keys set by WithPprofLabels
+const (
+	PprofLabelDB    = "dbx.db"
+	PprofLabelQuery = "dbx.query"
+)
+
+type queryTagKey struct{}
+
+type pprofRestoreKey struct{}
+
+// WithPprofLabels sets runtime/pprof labels while each query of the db runs, so CPU profiles attribute the time
+// spent in the driver to a database and a query: dbx.db is set to db, a name chosen by the caller (not the DSN,
+// which may hold credentials), and dbx.query to the tag of TagQueries or else to the function running the query.
+func WithPprofLabels(db string) OpenOptFn {
+	return func(opt *Options) {
+		opt.pprofDB = db
+	}
+}
+
+// TagQueries returns a context whose queries are labeled with tag instead of their call site (see WithPprofLabels)
+func TagQueries(ctx context.Context, tag string) context.Context {
+	return context.WithValue(ctx, queryTagKey{}, tag)
+}
+
+// pprofHook labels the goroutine running a query, then restores the labels of its context
+type pprofHook struct {
+	db string
+}
+
+var _ bun.QueryHook = pprofHook{}
+
+func (h pprofHook) BeforeQuery(ctx context.Context, _ *bun.QueryEvent) context.Context {
+	tag, _ := ctx.Value(queryTagKey{}).(string)
+	if tag == "" {
+		tag = querySite()
+	}
+	labeled := pprof.WithLabels(ctx, pprof.Labels(PprofLabelDB, h.db, PprofLabelQuery, tag))
+	pprof.SetGoroutineLabels(labeled)
+	return context.WithValue(labeled, pprofRestoreKey{}, ctx)
+}
+
+func (h pprofHook) AfterQuery(ctx context.Context, _ *bun.QueryEvent) {
+	if orig, ok := ctx.Value(pprofRestoreKey{}).(context.Context); ok {
+		pprof.SetGoroutineLabels(orig)
+	}
+}
+
+// querySite returns the function that ran the query: the first caller outside of bun and of dbx itself
+func querySite() string {
+	var pcs [32]uintptr
+	frames := runtime.CallersFrames(pcs[:runtime.Callers(3, pcs[:])])
+	for {
+		frame, more := frames.Next()
+		fn := frame.Function
+		internal := strings.HasPrefix(fn, "github.com/uptrace/bun") ||
+			strings.HasPrefix(fn, "database/sql.") ||
+			strings.HasPrefix(fn, "github.com/actanonv/dbx.") && !strings.HasSuffix(frame.File, "_test.go")
+		if fn != "" && !internal {
+			return fn[strings.LastIndexByte(fn, '/')+1:]
+		}
+		if !more {
+			return "unknown"
+		}
+	}
+}
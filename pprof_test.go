@@ -0,0 +1,57 @@
+package dbx
+
+import (
+	"context"
+	"path/filepath"
+	"runtime/pprof"
+	"testing"
+
+	"github.com/uptrace/bun"
+)
+
+// labelRecorder records the pprof labels of the context of each query
+type labelRecorder struct {
+	labels []map[string]string
+}
+
+func (r *labelRecorder) BeforeQuery(ctx context.Context, _ *bun.QueryEvent) context.Context {
+	labels := make(map[string]string)
+	pprof.ForLabels(ctx, func(key, value string) bool {
+		labels[key] = value
+		return true
+	})
+	r.labels = append(r.labels, labels)
+	return ctx
+}
+
+func (r *labelRecorder) AfterQuery(context.Context, *bun.QueryEvent) {}
+
+func TestWithPprofLabels(t *testing.T) {
+	tmp := t.TempDir()
+	db, err := OpenDB(filepath.Join(tmp, "pprof.sqlite"), WithDbFolder(tmp), WithCreateIfMissing(), WithPprofLabels("tenant_1"))
+	if err != nil {
+		t.Fatalf("OpenDB failed: %v", err)
+	}
+	defer db.Close()
+	rec := &labelRecorder{}
+	db.AddQueryHook(rec)
+
+	ctx := pprof.WithLabels(context.Background(), pprof.Labels("request", "r1"))
+	if _, err := db.ExecContext(ctx, "SELECT 1"); err != nil {
+		t.Fatalf("query failed: %v", err)
+	}
+	if _, err := db.ExecContext(TagQueries(ctx, "load_invoices"), "SELECT 1"); err != nil {
+		t.Fatalf("tagged query failed: %v", err)
+	}
+
+	if len(rec.labels) != 2 {
+		t.Fatalf("expected 2 queries, got %d", len(rec.labels))
+	}
+	site := rec.labels[0]
+	if site[PprofLabelDB] != "tenant_1" || site[PprofLabelQuery] != "dbx.TestWithPprofLabels" || site["request"] != "r1" {
+		t.Fatalf("unexpected labels of untagged query: %v", site)
+	}
+	if tag := rec.labels[1][PprofLabelQuery]; tag != "load_invoices" {
+		t.Fatalf("expected the tag as query label, got %q", tag)
+	}
+}
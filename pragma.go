@@ -0,0 +1,67 @@
+package dbx
+
+import (
+	"context"
+	"database/sql/driver"
+	"fmt"
+	"regexp"
+)
+
+type pragma struct {
+	name  string
+	value string
+}
+
+var (
+	pragmaNameRe  = regexp.MustCompile(`^([A-Za-z_][A-Za-z0-9_]*\.)?[A-Za-z_][A-Za-z0-9_]*$`)
+	pragmaValueRe = regexp.MustCompile(`^([+-]?[0-9]+|[A-Za-z_][A-Za-z0-9_]*|'[^']*')$`)
+)
+
+// WithPragma runs PRAGMA name = value on every SQLite connection, after the pragmas of OpenDB, which it overrides,
+// e.g. WithPragma("mmap_size", "268435456"). Repeat it for several pragmas; they run in order.
+// value is a number, a keyword or a single-quoted string.
+func WithPragma(name, value string) OpenOptFn {
+	return func(opt *Options) {
+		opt.pragmas = append(opt.pragmas, pragma{name: name, value: value})
+	}
+}
+
+func pragmaHook(pragmas []pragma) connHook {
+	return func(conn driver.Conn) error {
+		for _, p := range pragmas {
+			if err := execConn(conn, "PRAGMA "+p.name+" = "+p.value); err != nil {
+				return fmt.Errorf("failed to set pragma %s: %w", p.name, err)
+			}
+		}
+		return nil
+	}
+}
+
+// pragmaProblems checks the pragmas, which are not quoted but inlined in their statements
+func pragmaProblems(opt *Options) []error {
+	var problems []error
+	if len(opt.pragmas) > 0 && !IsSQLite(DriverName(opt.driverName)) {
+		problems = append(problems, fmt.Errorf("%w: WithPragma needs sqlite, not %s", ErrInvalidOptions, opt.driverName))
+	}
+	for _, p := range opt.pragmas {
+		if !pragmaNameRe.MatchString(p.name) || !pragmaValueRe.MatchString(p.value) {
+			problems = append(problems, fmt.Errorf("%w: invalid pragma %q = %q", ErrInvalidOptions, p.name, p.value))
+		}
+	}
+	return problems
+}
+
+// execConn runs query without arguments on a driver connection
+func execConn(conn driver.Conn, query string) error {
+	if execer, ok := conn.(driver.ExecerContext); ok {
+		_, err := execer.ExecContext(context.Background(), query, nil)
+		return err
+	}
+	stmt, err := conn.Prepare(query)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+	_, err = stmt.Exec(nil)
+	return err
+}
@@ -0,0 +1,54 @@
+package dbx
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestWithPragma(t *testing.T) {
+	ctx := context.Background()
+	db, err := OpenDB("pragmas", WithDbFolder(t.TempDir()), WithCreateIfMissing(), WithMaxOpenConns(2),
+		WithMaxIdleConns(2), WithPragma("cache_size", "-8192"), WithPragma("temp_store", "FILE"),
+		WithPragma("main.synchronous", "FULL"))
+	if err != nil {
+		t.Fatalf("OpenDB failed: %v", err)
+	}
+	defer db.Close()
+
+	// Hold both connections of the pool, so each of them is checked
+	for range 2 {
+		conn, err := db.Conn(ctx)
+		if err != nil {
+			t.Fatalf("Conn failed: %v", err)
+		}
+		defer conn.Close()
+		var cacheSize, tempStore, synchronous int
+		if err := conn.QueryRowContext(ctx, "PRAGMA cache_size").Scan(&cacheSize); err != nil {
+			t.Fatalf("read cache_size failed: %v", err)
+		}
+		if err := conn.QueryRowContext(ctx, "PRAGMA temp_store").Scan(&tempStore); err != nil {
+			t.Fatalf("read temp_store failed: %v", err)
+		}
+		if err := conn.QueryRowContext(ctx, "PRAGMA synchronous").Scan(&synchronous); err != nil {
+			t.Fatalf("read synchronous failed: %v", err)
+		}
+		if cacheSize != -8192 || tempStore != 1 || synchronous != 2 {
+			t.Fatalf("expected the pragmas on every connection, got cache_size %d, temp_store %d, synchronous %d",
+				cacheSize, tempStore, synchronous)
+		}
+	}
+}
+
+func TestWithPragma_Invalid(t *testing.T) {
+	for _, p := range [][2]string{{"cache_size; DROP TABLE x", "1"}, {"cache_size", "1; DROP TABLE x"}, {"", "1"}} {
+		if err := ValidateOptions(WithPragma(p[0], p[1])); !errors.Is(err, ErrInvalidOptions) {
+			t.Errorf("WithPragma(%q, %q): expected ErrInvalidOptions, got %v", p[0], p[1], err)
+		}
+	}
+	err := ValidateOptions(WithDriverName(DriverPostgres), WithPragma("cache_size", "1"))
+	if !errors.Is(err, ErrInvalidOptions) || !strings.Contains(err.Error(), "WithPragma needs sqlite") {
+		t.Errorf("expected WithPragma to need sqlite, got %v", err)
+	}
+}
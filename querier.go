@@ -0,0 +1,109 @@
+package dbx
+
+import (
+	"context"
+	"database/sql"
+	"strconv"
+	"strings"
+
+	"github.com/uptrace/bun/dialect"
+)
+
+// Querier is the database handle sqlc-generated code expects (its DBTX interface), so that
+// New(dbx.NewQuerier(t)) plugs a Transact into the generated Queries
+type Querier interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	PrepareContext(ctx context.Context, query string) (*sql.Stmt, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+type transactQuerier struct {
+	t *Transact
+}
+
+// NewQuerier returns a Querier running each statement on whatever t.Db() is at the time: inside the current
+// transaction or savepoint when one is active, on the pool otherwise. Statements go through bun, so query hooks
+// (tracing, stats) see them; the driver placeholders (?, ?NNN or $N) are translated to bun's. Prepared statements
+// are created on the raw handles and bypass the hooks.
+func NewQuerier(t *Transact) Querier {
+	return transactQuerier{t: t}
+}
+
+func (q transactQuerier) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	idb := q.t.Db()
+	return idb.ExecContext(ctx, bunPlaceholders(idb.Dialect().Name(), query), args...)
+}
+
+func (q transactQuerier) QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	idb := q.t.Db()
+	return idb.QueryContext(ctx, bunPlaceholders(idb.Dialect().Name(), query), args...)
+}
+
+func (q transactQuerier) QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row {
+	idb := q.t.Db()
+	return idb.QueryRowContext(ctx, bunPlaceholders(idb.Dialect().Name(), query), args...)
+}
+
+func (q transactQuerier) PrepareContext(ctx context.Context, query string) (*sql.Stmt, error) {
+	if tx := q.t.SQLTx(); tx != nil {
+		return tx.PrepareContext(ctx, query)
+	}
+	return q.t.SQLDB().PrepareContext(ctx, query)
+}
+
+// bunPlaceholders rewrites the driver placeholders of query into bun's positional ?N (zero-based), and escapes the
+// question marks bun must leave alone (in literals, identifiers and comments, and Postgres operators)
+func bunPlaceholders(d dialect.Name, query string) string {
+	var sb strings.Builder
+	sb.Grow(len(query) + 8)
+	next := 0 // index of the next anonymous ? placeholder
+
+	escaped := func(s string) {
+		sb.WriteString(strings.ReplaceAll(s, "?", `\?`))
+	}
+	for i := 0; i < len(query); i++ {
+		c := query[i]
+		switch {
+		case c == '\'' || c == '"' || c == '`':
+			end := skipQuoted(query, i, d == dialect.MySQL && c == '\'')
+			escaped(query[i:end])
+			i = end - 1
+		case c == '-' && strings.HasPrefix(query[i:], "--"):
+			end := strings.IndexByte(query[i:], '\n')
+			if end < 0 {
+				end = len(query) - i
+			}
+			escaped(query[i : i+end])
+			i += end - 1
+		case c == '/' && strings.HasPrefix(query[i:], "/*"):
+			end := strings.Index(query[i+2:], "*/")
+			if end < 0 {
+				end = len(query) - i - 2
+			} else {
+				end += 2
+			}
+			escaped(query[i : i+2+end])
+			i += 1 + end
+		case c == '$' && d == dialect.PG && i+1 < len(query) && isDigit(query[i+1]),
+			c == '?' && d != dialect.PG && i+1 < len(query) && isDigit(query[i+1]):
+			// Numbered placeholder, one-based
+			j := i + 1
+			for j < len(query) && isDigit(query[j]) {
+				j++
+			}
+			n, _ := strconv.Atoi(query[i+1 : j])
+			sb.WriteString("?" + strconv.Itoa(n-1))
+			i = j - 1
+		case c == '?' && d != dialect.PG:
+			sb.WriteString("?" + strconv.Itoa(next))
+			next++
+		case c == '?':
+			// A Postgres operator such as the jsonb ?|
+			sb.WriteString(`\?`)
+		default:
+			sb.WriteByte(c)
+		}
+	}
+	return sb.String()
+}
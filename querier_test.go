@@ -0,0 +1,96 @@
+package dbx
+
+import (
+	"context"
+	"testing"
+
+	"github.com/uptrace/bun/dialect"
+)
+
+func TestBunPlaceholders(t *testing.T) {
+	cases := []struct {
+		d     dialect.Name
+		query string
+		want  string
+	}{
+		{dialect.SQLite, "SELECT id FROM items WHERE name = ? AND id > ?", "SELECT id FROM items WHERE name = ?0 AND id > ?1"},
+		{dialect.SQLite, "SELECT '?', ?2, ?1 -- why?", `SELECT '\?', ?1, ?0 -- why\?`},
+		{dialect.PG, "SELECT data ?| $2 FROM t WHERE id = $1", `SELECT data \?| ?1 FROM t WHERE id = ?0`},
+		{dialect.MySQL, "SELECT `a?` FROM t WHERE b = ?", "SELECT `a\\?` FROM t WHERE b = ?0"},
+	}
+	for _, c := range cases {
+		if got := bunPlaceholders(c.d, c.query); got != c.want {
+			t.Errorf("bunPlaceholders(%q)\n got %q\nwant %q", c.query, got, c.want)
+		}
+	}
+}
+
+// queries stands for sqlc-generated code
+type queries struct {
+	db Querier
+}
+
+func (q *queries) createItem(ctx context.Context, name string) error {
+	_, err := q.db.ExecContext(ctx, "INSERT INTO items (name) VALUES (?)", name)
+	return err
+}
+
+func (q *queries) itemName(ctx context.Context, id int64) (string, error) {
+	var name string
+	err := q.db.QueryRowContext(ctx, "SELECT name FROM items WHERE id = ? AND name <> '?'", id).Scan(&name)
+	return name, err
+}
+
+func TestNewQuerier(t *testing.T) {
+	ctx := context.Background()
+	db := setupTestDB(t)
+	hook := NewQueryStatsHook()
+	db.AddQueryHook(hook)
+	tx := mustNewTx(t, db)
+	q := &queries{db: NewQuerier(tx)}
+
+	if err := q.createItem(ctx, "a"); err != nil {
+		t.Fatalf("createItem failed: %v", err)
+	}
+	// The same Queries joins the transactions started later
+	err := tx.Transaction(nil, func(ctx context.Context) error {
+		if err := q.createItem(ctx, "b"); err != nil {
+			return err
+		}
+		name, err := q.itemName(ctx, 2)
+		if err != nil {
+			return err
+		}
+		if name != "b" {
+			t.Errorf("expected b, got %q", name)
+		}
+		return errUndo
+	})
+	if err != errUndo {
+		t.Fatalf("expected errUndo, got %v", err)
+	}
+	if got := countItems(t, db); got != 1 {
+		t.Fatalf("expected the insert in the tx to be rolled back, got %d items", got)
+	}
+
+	// Statements went through the hooks
+	var inserts int64
+	for _, st := range hook.Stats() {
+		if st.Fingerprint == "INSERT INTO items (name) VALUES (?)" {
+			inserts = st.Count
+		}
+	}
+	if inserts != 2 {
+		t.Fatalf("expected the hook to see 2 inserts, got %d", inserts)
+	}
+
+	stmt, err := NewQuerier(tx).PrepareContext(ctx, "SELECT COUNT(*) FROM items")
+	if err != nil {
+		t.Fatalf("PrepareContext failed: %v", err)
+	}
+	defer stmt.Close()
+	var n int
+	if err := stmt.QueryRowContext(ctx).Scan(&n); err != nil || n != 1 {
+		t.Fatalf("expected 1 item through the prepared statement, got %d (err %v)", n, err)
+	}
+}
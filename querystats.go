@@ -0,0 +1,171 @@
+package dbx
+
+import (
+	"cmp"
+	"context"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/uptrace/bun"
+)
+
+// QueryStat aggregates the executions of the queries sharing a fingerprint
+type QueryStat struct {
+	Fingerprint string        `json:"fingerprint"`
+	Count       int64         `json:"count"`
+	Errors      int64         `json:"errors"`
+	Total       time.Duration `json:"total_ns"`
+	Avg         time.Duration `json:"avg_ns"`
+	Max         time.Duration `json:"max_ns"`
+}
+
+// QueryStatsHook is a bun query hook aggregating count and durations per query fingerprint in memory,
+// a lightweight pg_stat_statements that works on every dialect
+type QueryStatsHook struct {
+	mu    sync.Mutex
+	stats map[string]*QueryStat
+}
+
+var _ bun.QueryHook = (*QueryStatsHook)(nil)
+
+// DefaultQueryStats is the hook installed by WithQueryStats and read by QueryStats
+var DefaultQueryStats = NewQueryStatsHook()
+
+// NewQueryStatsHook returns an empty hook, to be added with bun.DB.AddQueryHook
+func NewQueryStatsHook() *QueryStatsHook {
+	return &QueryStatsHook{stats: make(map[string]*QueryStat)}
+}
+
+// WithQueryStats aggregates the statistics of the queries of the db into DefaultQueryStats, read with QueryStats
+func WithQueryStats(enabled bool) OpenOptFn {
+	return func(opt *Options) {
+		opt.queryStats = enabled
+	}
+}
+
+// QueryStats returns the statistics of DefaultQueryStats, slowest total first
+func QueryStats() []QueryStat {
+	return DefaultQueryStats.Stats()
+}
+
+func (h *QueryStatsHook) BeforeQuery(ctx context.Context, _ *bun.QueryEvent) context.Context {
+	return ctx
+}
+
+func (h *QueryStatsHook) AfterQuery(_ context.Context, event *bun.QueryEvent) {
+	elapsed := time.Since(event.StartTime)
+	fp := Fingerprint(event.Query)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	st, found := h.stats[fp]
+	if !found {
+		st = &QueryStat{Fingerprint: fp}
+		h.stats[fp] = st
+	}
+	st.Count++
+	st.Total += elapsed
+	st.Max = max(st.Max, elapsed)
+	if event.Err != nil {
+		st.Errors++
+	}
+}
+
+// Stats returns a snapshot of the statistics, slowest total first
+func (h *QueryStatsHook) Stats() []QueryStat {
+	h.mu.Lock()
+	stats := make([]QueryStat, 0, len(h.stats))
+	for _, st := range h.stats {
+		stats = append(stats, *st)
+	}
+	h.mu.Unlock()
+
+	for i := range stats {
+		stats[i].Avg = stats[i].Total / time.Duration(stats[i].Count)
+	}
+	slices.SortFunc(stats, func(a, b QueryStat) int {
+		return cmp.Or(cmp.Compare(b.Total, a.Total), strings.Compare(a.Fingerprint, b.Fingerprint))
+	})
+	return stats
+}
+
+// Reset drops all statistics
+func (h *QueryStatsHook) Reset() {
+	h.mu.Lock()
+	clear(h.stats)
+	h.mu.Unlock()
+}
+
+var (
+	fingerprintList = regexp.MustCompile(`\(\s*\?(\s*,\s*\?)+\s*\)`)
+	fingerprintRows = regexp.MustCompile(`\(\?\)(\s*,\s*\(\?\))+`)
+)
+
+// Fingerprint normalizes query so that executions differing only in literals share it: string and numeric
+// literals and placeholders become ?, lists of them collapse to (?), and whitespace is collapsed.
+func Fingerprint(query string) string {
+	var sb strings.Builder
+	sb.Grow(len(query))
+	space := false
+	for i := 0; i < len(query); i++ {
+		c := query[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			space = true
+			continue
+		case c == '\'':
+			// String literal, '' being an escaped quote
+			for i++; i < len(query); i++ {
+				if query[i] == '\'' {
+					if i+1 < len(query) && query[i+1] == '\'' {
+						i++
+						continue
+					}
+					break
+				}
+			}
+			c = '?'
+		case c == '"' || c == '`':
+			// Quoted identifier, kept as is
+			ident := query[i:]
+			if end := strings.IndexByte(query[i+1:], c); end >= 0 {
+				ident = query[i : i+end+2]
+			}
+			if space && sb.Len() > 0 {
+				sb.WriteByte(' ')
+			}
+			space = false
+			sb.WriteString(ident)
+			i += len(ident) - 1
+			continue
+		case isDigit(c) && !precededByIdent(query, i), c == '$' && i+1 < len(query) && isDigit(query[i+1]):
+			for i+1 < len(query) && (isDigit(query[i+1]) || query[i+1] == '.' || query[i+1] == '$') {
+				i++
+			}
+			c = '?'
+		}
+		if space && sb.Len() > 0 {
+			sb.WriteByte(' ')
+		}
+		space = false
+		sb.WriteByte(c)
+	}
+
+	fp := fingerprintList.ReplaceAllString(sb.String(), "(?)")
+	return fingerprintRows.ReplaceAllString(fp, "(?)")
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func precededByIdent(s string, i int) bool {
+	if i == 0 {
+		return false
+	}
+	c := s[i-1]
+	return c == '_' || isDigit(c) || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
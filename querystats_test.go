@@ -0,0 +1,63 @@
+package dbx
+
+import (
+	"context"
+	"testing"
+)
+
+func TestFingerprint(t *testing.T) {
+	cases := map[string]string{
+		"SELECT * FROM items WHERE id = 42":                        "SELECT * FROM items WHERE id = ?",
+		"SELECT *\n  FROM items\tWHERE name = 'it''s' AND x = 1.5": "SELECT * FROM items WHERE name = ? AND x = ?",
+		`SELECT "t1"."col2" FROM t1 WHERE id IN (1, 2, 3)`:         `SELECT "t1"."col2" FROM t1 WHERE id IN (?)`,
+		"INSERT INTO items (name) VALUES ('a'), ('b'), ('c')":      "INSERT INTO items (name) VALUES (?)",
+		"SELECT * FROM items WHERE id = $1 AND name = $2":          "SELECT * FROM items WHERE id = ? AND name = ?",
+		"INSERT INTO t (a, b) VALUES (1, 'x'), (2, 'y')":           "INSERT INTO t (a, b) VALUES (?)",
+		"SELECT `name` FROM `items` WHERE `id` = 7 LIMIT 10":       "SELECT `name` FROM `items` WHERE `id` = ? LIMIT ?",
+		"SELECT '2024-01-01'::date, 'unterminated":                 "SELECT ?::date, ?",
+	}
+	for query, want := range cases {
+		if got := Fingerprint(query); got != want {
+			t.Errorf("Fingerprint(%q)\n got %q\nwant %q", query, got, want)
+		}
+	}
+}
+
+func TestQueryStatsHook(t *testing.T) {
+	ctx := context.Background()
+	db := setupTestDB(t)
+	hook := NewQueryStatsHook()
+	db.AddQueryHook(hook)
+
+	for _, name := range []string{"a", "b", "c"} {
+		if _, err := db.NewRaw("INSERT INTO items(name) VALUES (?)", name).Exec(ctx); err != nil {
+			t.Fatalf("insert failed: %v", err)
+		}
+	}
+	_, _ = db.ExecContext(ctx, "SELECT * FROM missing WHERE id = 1")
+
+	stats := hook.Stats()
+	if len(stats) != 2 {
+		t.Fatalf("expected 2 fingerprints, got %+v", stats)
+	}
+	var insert, failed QueryStat
+	for _, st := range stats {
+		switch st.Fingerprint {
+		case "INSERT INTO items(name) VALUES (?)":
+			insert = st
+		case "SELECT * FROM missing WHERE id = ?":
+			failed = st
+		}
+	}
+	if insert.Count != 3 || insert.Errors != 0 || insert.Total <= 0 || insert.Max < insert.Avg {
+		t.Fatalf("unexpected insert stats %+v", insert)
+	}
+	if failed.Count != 1 || failed.Errors != 1 {
+		t.Fatalf("unexpected failed query stats %+v", failed)
+	}
+
+	hook.Reset()
+	if len(hook.Stats()) != 0 {
+		t.Fatalf("expected no stats after Reset")
+	}
+}
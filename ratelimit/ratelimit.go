@@ -0,0 +1,89 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/actanonv/dbx"
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/dialect"
+)
+
+var ErrInvalidLimit = errors.New("invalid rate limit")
+
+// Counter is a single row of the dbx_ratelimits table.
+// It keeps the hit count of the current fixed window together with the count of the window before it,
+// which is enough to approximate a sliding window.
+type Counter struct {
+	bun.BaseModel `bun:"table:dbx_ratelimits"`
+
+	Key         string `bun:"key,pk"`
+	WindowStart int64  `bun:"window_start,notnull"`
+	Count       int64  `bun:"count,notnull"`
+	PrevCount   int64  `bun:"prev_count,notnull"`
+}
+
+// CreateTable creates the dbx_ratelimits table if it does not exist
+func CreateTable(ctx context.Context, idb bun.IDB) error {
+	if _, err := idb.NewCreateTable().Model((*Counter)(nil)).IfNotExists().Exec(ctx); err != nil {
+		return fmt.Errorf("failed to create ratelimit table: %w", err)
+	}
+	return nil
+}
+
+// Allow records a hit for key and reports whether it is within limit hits per window.
+//
+// The check uses a sliding window approximation: the count of the previous window is weighted by how much
+// of it still overlaps the sliding window and added to the count of the current window.
+// The counter update is a single upsert statement, so concurrent callers never lose hits.
+// Denied hits are counted too, so a client hammering a key stays limited.
+//
+// Supported dialects are SQLite (3.35+ for RETURNING) and Postgres. The table must exist (see CreateTable).
+func Allow(ctx context.Context, idb bun.IDB, key string, limit int, window time.Duration) (bool, error) {
+	if limit <= 0 || window <= 0 {
+		return false, fmt.Errorf("%w: limit=%d window=%s", ErrInvalidLimit, limit, window)
+	}
+
+	switch name := idb.Dialect().Name(); name {
+	case dialect.SQLite, dialect.PG:
+	default:
+		return false, fmt.Errorf("ratelimit: unsupported dialect: %s", name)
+	}
+
+	now := time.Now().UnixNano()
+	windowStart := now - now%int64(window)
+
+	var counter Counter
+	err := idb.NewRaw(`
+		INSERT INTO ?0 (key, window_start, count, prev_count) VALUES (?1, ?2, 1, 0)
+		ON CONFLICT (key) DO UPDATE SET
+			prev_count = CASE
+				WHEN ?0.window_start = excluded.window_start THEN ?0.prev_count
+				WHEN ?0.window_start = excluded.window_start - ?3 THEN ?0.count
+				ELSE 0
+			END,
+			count = CASE
+				WHEN ?0.window_start = excluded.window_start THEN ?0.count + 1
+				ELSE 1
+			END,
+			window_start = excluded.window_start
+		RETURNING count, prev_count`,
+		bun.Ident(dbx.TableName(idb, "dbx_ratelimits")), key, windowStart, int64(window),
+	).Scan(ctx, &counter.Count, &counter.PrevCount)
+	if err != nil {
+		return false, fmt.Errorf("failed to record hit for %s: %w", key, err)
+	}
+
+	overlap := 1 - float64(now-windowStart)/float64(window)
+	estimate := float64(counter.PrevCount)*overlap + float64(counter.Count)
+
+	return estimate <= float64(limit), nil
+}
+
+// Reset removes the counter for key
+func Reset(ctx context.Context, idb bun.IDB, key string) error {
+	_, err := idb.NewDelete().Model((*Counter)(nil)).Where("key = ?", key).Exec(ctx)
+	return err
+}
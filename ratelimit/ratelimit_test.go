@@ -0,0 +1,53 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/actanonv/dbx"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+func TestAllow(t *testing.T) {
+	ctx := context.Background()
+	tmp := t.TempDir()
+	if err := dbx.CreateDB("ratelimit", dbx.CreateWithDbFolder(tmp)); err != nil {
+		t.Fatalf("CreateDB failed: %v", err)
+	}
+	db, err := dbx.OpenDB("ratelimit", dbx.WithDbFolder(tmp))
+	if err != nil {
+		t.Fatalf("OpenDB failed: %v", err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+
+	if err := CreateTable(ctx, db); err != nil {
+		t.Fatalf("CreateTable failed: %v", err)
+	}
+
+	for i := 1; i <= 4; i++ {
+		ok, err := Allow(ctx, db, "client-a", 3, time.Hour)
+		if err != nil {
+			t.Fatalf("Allow failed: %v", err)
+		}
+		if want := i <= 3; ok != want {
+			t.Fatalf("hit %d: expected allowed=%v, got %v", i, want, ok)
+		}
+	}
+
+	// Other keys are counted separately
+	if ok, err := Allow(ctx, db, "client-b", 3, time.Hour); err != nil || !ok {
+		t.Fatalf("expected client-b to be allowed, got %v (err %v)", ok, err)
+	}
+
+	if err := Reset(ctx, db, "client-a"); err != nil {
+		t.Fatalf("Reset failed: %v", err)
+	}
+	if ok, err := Allow(ctx, db, "client-a", 3, time.Hour); err != nil || !ok {
+		t.Fatalf("expected client-a to be allowed after Reset, got %v (err %v)", ok, err)
+	}
+
+	if _, err := Allow(ctx, db, "client-a", 0, time.Hour); err == nil {
+		t.Fatal("expected error for zero limit")
+	}
+}
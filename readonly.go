@@ -0,0 +1,85 @@
+package dbx
+
+import (
+	"database/sql/driver"
+	"fmt"
+)
+
+// WithReadOnly opens the database read-only, for code that must not write, e.g. analytics:
+//   - SQLite is opened with mode=ro, so writes fail with SQLITE_READONLY;
+//   - Postgres sessions run SET default_transaction_read_only = on, and MySQL ones SET SESSION TRANSACTION READ ONLY,
+//     so writes fail in every transaction, implicit ones included.
+//
+// A session may still turn the setting off on Postgres and MySQL: connect as a role without write privileges where
+// that matters.
+func WithReadOnly() OpenOptFn {
+	return func(opt *Options) {
+		opt.readOnly = true
+	}
+}
+
+// WithImmutable opens the SQLite database read-only like WithReadOnly, and immutable (immutable=1): SQLite takes no
+// locks and skips change detection, so the file must not change while the db is open, not even by another process.
+// For databases of reference data and snapshots.
+func WithImmutable() OpenOptFn {
+	return func(opt *Options) {
+		opt.readOnly = true
+		opt.immutable = true
+	}
+}
+
+// readOnlySQLiteDSN returns the DSN opening dbFile read-only with driver, immutable if asked
+func readOnlySQLiteDSN(driver DriverName, dbFile string, immutable bool) string {
+	mode := "?mode=ro"
+	if immutable {
+		mode += "&immutable=1"
+	}
+	if driver == DriverSQLite {
+		return "file:" + dbFile + mode +
+			"&_busy_timeout=5000" +
+			"&_foreign_keys=on" +
+			"&_cache_size=-4096" +
+			"&cache=private"
+	}
+	return "file:" + dbFile + mode +
+		"&_pragma=busy_timeout(5000)" +
+		"&_pragma=foreign_keys(ON)" +
+		"&_pragma=cache_size(-4096)" +
+		"&_pragma=temp_store(MEMORY)"
+}
+
+// readOnlyHook makes the sessions of the Postgres and MySQL connections read-only
+func readOnlyHook(dn DriverName) connHook {
+	query := "SET default_transaction_read_only = on"
+	if dn == DriverMySQL {
+		query = "SET SESSION TRANSACTION READ ONLY"
+	}
+	return func(conn driver.Conn) error {
+		if err := execConn(conn, query); err != nil {
+			return fmt.Errorf("failed to make the session read-only: %w", err)
+		}
+		return nil
+	}
+}
+
+// readOnlyProblems checks the read-only mode is supported by the driver and not mixed with creating the database
+func readOnlyProblems(opt *Options) []error {
+	if !opt.readOnly {
+		return nil
+	}
+	var problems []error
+	switch dn := DriverName(opt.driverName); {
+	case IsSQLite(dn):
+		if opt.createIfMissing {
+			problems = append(problems, fmt.Errorf("%w: WithReadOnly cannot create the database", ErrInvalidOptions))
+		}
+	case dn == DriverPostgres, dn == DriverPgx, dn == DriverMySQL:
+		if opt.immutable {
+			problems = append(problems, fmt.Errorf("%w: WithImmutable needs sqlite, not %s", ErrInvalidOptions, dn))
+		}
+	default:
+		problems = append(problems, fmt.Errorf("%w: WithReadOnly needs sqlite, postgres or mysql, not %s",
+			ErrInvalidOptions, dn))
+	}
+	return problems
+}
@@ -0,0 +1,55 @@
+package dbx
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestWithReadOnly(t *testing.T) {
+	ctx := context.Background()
+	tmp := t.TempDir()
+	rw, err := OpenDB("ro", WithDbFolder(tmp), WithCreateIfMissing())
+	if err != nil {
+		t.Fatalf("OpenDB failed: %v", err)
+	}
+	defer rw.Close()
+	if _, err := rw.ExecContext(ctx, "CREATE TABLE items (id INTEGER PRIMARY KEY); INSERT INTO items VALUES (1)"); err != nil {
+		t.Fatalf("create table failed: %v", err)
+	}
+
+	ro, err := OpenDB("ro", WithDbFolder(tmp), WithReadOnly())
+	if err != nil {
+		t.Fatalf("OpenDB read-only failed: %v", err)
+	}
+	defer ro.Close()
+	if _, err := ro.ExecContext(ctx, "INSERT INTO items VALUES (2)"); err == nil {
+		t.Fatalf("expected the insert of a read-only db to fail")
+	}
+	// Not immutable: the writes of others are seen
+	if _, err := rw.ExecContext(ctx, "INSERT INTO items VALUES (3)"); err != nil {
+		t.Fatalf("insert failed: %v", err)
+	}
+	if n, err := ro.NewSelect().Table("items").Count(ctx); err != nil || n != 2 {
+		t.Fatalf("expected the read-only db to see 2 items, got %d, %v", n, err)
+	}
+
+	im, err := OpenDB("ro", WithDbFolder(tmp), WithImmutable())
+	if err != nil {
+		t.Fatalf("OpenDB immutable failed: %v", err)
+	}
+	defer im.Close()
+	if _, err := im.ExecContext(ctx, "DELETE FROM items"); err == nil {
+		t.Fatalf("expected the delete of an immutable db to fail")
+	}
+
+	if err := ValidateOptions(WithReadOnly(), WithCreateIfMissing()); !errors.Is(err, ErrInvalidOptions) {
+		t.Fatalf("expected WithReadOnly with WithCreateIfMissing to be invalid, got %v", err)
+	}
+	if problems := readOnlyProblems(&Options{driverName: string(DriverPgx), readOnly: true, immutable: true}); len(problems) != 1 {
+		t.Fatalf("expected WithImmutable on postgres to be invalid, got %v", problems)
+	}
+	if problems := readOnlyProblems(&Options{driverName: string(DriverPgx), readOnly: true}); len(problems) != 0 {
+		t.Fatalf("expected WithReadOnly on postgres to be valid, got %v", problems)
+	}
+}
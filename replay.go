@@ -0,0 +1,408 @@
+package dbx
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/dialect/sqlitedialect"
+)
+
+// ErrReplayMiss is returned by a replay db for a statement the golden file has no record of
+var ErrReplayMiss = errors.New("statement not recorded")
+
+// ReplayEntry is a recorded statement with its outcome
+type ReplayEntry struct {
+	Query        string          `json:"query"`
+	Args         []ReplayValue   `json:"args,omitempty"`
+	Columns      []string        `json:"columns,omitempty"`
+	Rows         [][]ReplayValue `json:"rows,omitempty"`
+	RowsAffected int64           `json:"rows_affected,omitempty"`
+	LastInsertID int64           `json:"last_insert_id,omitempty"`
+	Error        string          `json:"error,omitempty"`
+}
+
+// ReplayValue is a driver value keeping its Go type through JSON:
+// {"int":1}, {"float":1.5}, {"bool":true}, {"text":"a"}, {"bytes":"<base64>"}, {"time":"<RFC 3339>"} or null
+type ReplayValue struct {
+	V driver.Value
+}
+
+func (v ReplayValue) MarshalJSON() ([]byte, error) {
+	var tagged map[string]any
+	switch x := v.V.(type) {
+	case nil:
+		return []byte("null"), nil
+	case int64:
+		tagged = map[string]any{"int": x}
+	case float64:
+		tagged = map[string]any{"float": x}
+	case bool:
+		tagged = map[string]any{"bool": x}
+	case string:
+		tagged = map[string]any{"text": x}
+	case []byte:
+		tagged = map[string]any{"bytes": base64.StdEncoding.EncodeToString(x)}
+	case time.Time:
+		tagged = map[string]any{"time": x.Format(time.RFC3339Nano)}
+	default:
+		return nil, fmt.Errorf("cannot record driver value of type %T", x)
+	}
+	return json.Marshal(tagged)
+}
+
+func (v *ReplayValue) UnmarshalJSON(data []byte) error {
+	var tagged struct {
+		Int   *int64   `json:"int"`
+		Float *float64 `json:"float"`
+		Bool  *bool    `json:"bool"`
+		Text  *string  `json:"text"`
+		Bytes *string  `json:"bytes"`
+		Time  *string  `json:"time"`
+	}
+	if err := json.Unmarshal(data, &tagged); err != nil {
+		return err
+	}
+	var err error
+	switch {
+	case tagged.Int != nil:
+		v.V = *tagged.Int
+	case tagged.Float != nil:
+		v.V = *tagged.Float
+	case tagged.Bool != nil:
+		v.V = *tagged.Bool
+	case tagged.Text != nil:
+		v.V = *tagged.Text
+	case tagged.Bytes != nil:
+		v.V, err = base64.StdEncoding.DecodeString(*tagged.Bytes)
+	case tagged.Time != nil:
+		v.V, err = time.Parse(time.RFC3339Nano, *tagged.Time)
+	default:
+		v.V = nil
+	}
+	return err
+}
+
+// Recorder records the statements run on the dbs opened with WithRecorder and their results, to be saved as a
+// golden file served back by OpenReplayDB. Statements are recorded at the driver level, after bun formatted them.
+type Recorder struct {
+	mu      sync.Mutex
+	entries []ReplayEntry
+	enabled atomic.Bool
+}
+
+// NewRecorder returns an empty recorder
+func NewRecorder() *Recorder {
+	return &Recorder{}
+}
+
+// WithRecorder records the statements of the db into rec, from the end of OpenDB on
+func WithRecorder(rec *Recorder) OpenOptFn {
+	return func(opt *Options) {
+		opt.recorder = rec
+	}
+}
+
+// Entries returns the statements recorded so far, in execution order
+func (r *Recorder) Entries() []ReplayEntry {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]ReplayEntry(nil), r.entries...)
+}
+
+// Save writes the recorded statements to the golden file path
+func (r *Recorder) Save(path string) error {
+	data, err := json.MarshalIndent(r.Entries(), "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode recording: %w", err)
+	}
+	return os.WriteFile(path, append(data, '\n'), 0o644)
+}
+
+func (r *Recorder) add(entry ReplayEntry) {
+	if !r.enabled.Load() {
+		return
+	}
+	r.mu.Lock()
+	r.entries = append(r.entries, entry)
+	r.mu.Unlock()
+}
+
+func (r *Recorder) wrap(conn driver.Conn) driver.Conn {
+	return &recordConn{Conn: conn, rec: r}
+}
+
+// recordConn records the statements run through the context interfaces of the driver connection; statements the
+// driver prepares instead are not recorded
+type recordConn struct {
+	driver.Conn
+	rec *Recorder
+}
+
+func (c *recordConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	ex, ok := c.Conn.(driver.ExecerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+	res, err := ex.ExecContext(ctx, query, args)
+	if errors.Is(err, driver.ErrSkip) {
+		return res, err
+	}
+
+	entry := ReplayEntry{Query: query, Args: replayArgs(args)}
+	if err != nil {
+		entry.Error = err.Error()
+	} else {
+		entry.RowsAffected, _ = res.RowsAffected()
+		entry.LastInsertID, _ = res.LastInsertId()
+	}
+	c.rec.add(entry)
+	return res, err
+}
+
+func (c *recordConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	q, ok := c.Conn.(driver.QueryerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+	rows, err := q.QueryContext(ctx, query, args)
+	if errors.Is(err, driver.ErrSkip) {
+		return rows, err
+	}
+
+	entry := ReplayEntry{Query: query, Args: replayArgs(args)}
+	if err != nil {
+		entry.Error = err.Error()
+		c.rec.add(entry)
+		return nil, err
+	}
+
+	// Read all the rows now, to record them, and serve them from memory
+	entry.Columns = rows.Columns()
+	for {
+		dest := make([]driver.Value, len(entry.Columns))
+		err := rows.Next(dest)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			_ = rows.Close()
+			entry.Error = err.Error()
+			c.rec.add(entry)
+			return nil, err
+		}
+		row := make([]ReplayValue, len(dest))
+		for i, v := range dest {
+			// Drivers may reuse the buffers of []byte values
+			if b, ok := v.([]byte); ok {
+				v = append([]byte(nil), b...)
+			}
+			row[i] = ReplayValue{V: v}
+		}
+		entry.Rows = append(entry.Rows, row)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	c.rec.add(entry)
+	return &replayRows{columns: entry.Columns, rows: entry.Rows}, nil
+}
+
+func (c *recordConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	if b, ok := c.Conn.(driver.ConnBeginTx); ok {
+		return b.BeginTx(ctx, opts)
+	}
+	return c.Conn.Begin()
+}
+
+func (c *recordConn) CheckNamedValue(nv *driver.NamedValue) error {
+	if ch, ok := c.Conn.(driver.NamedValueChecker); ok {
+		return ch.CheckNamedValue(nv)
+	}
+	return driver.ErrSkip
+}
+
+func (c *recordConn) Ping(ctx context.Context) error {
+	if p, ok := c.Conn.(driver.Pinger); ok {
+		return p.Ping(ctx)
+	}
+	return nil
+}
+
+func (c *recordConn) IsValid() bool {
+	if v, ok := c.Conn.(driver.Validator); ok {
+		return v.IsValid()
+	}
+	return true
+}
+
+func (c *recordConn) ResetSession(ctx context.Context) error {
+	if r, ok := c.Conn.(driver.SessionResetter); ok {
+		return r.ResetSession(ctx)
+	}
+	return nil
+}
+
+func replayArgs(args []driver.NamedValue) []ReplayValue {
+	if len(args) == 0 {
+		return nil
+	}
+	values := make([]ReplayValue, len(args))
+	for i, arg := range args {
+		values[i] = ReplayValue{V: arg.Value}
+	}
+	return values
+}
+
+// OpenReplayDB returns a db serving the statements of the golden file path, saved by a Recorder, without a
+// database: each statement gets the results recorded for the same query and args, in recording order, the last
+// one again once they are used up. Other statements fail with ErrReplayMiss. Transactions are accepted and do
+// nothing. The db has the dialect of the dbs of OpenDB.
+func OpenReplayDB(path string) (*bun.DB, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var entries []ReplayEntry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, fmt.Errorf("failed to decode recording %s: %w", path, err)
+	}
+
+	store := &replayStore{entries: make(map[string][]ReplayEntry)}
+	for _, entry := range entries {
+		key := replayKey(entry.Query, entry.Args)
+		store.entries[key] = append(store.entries[key], entry)
+	}
+	return bun.NewDB(sql.OpenDB(replayConnector{store: store}), sqlitedialect.New(), bun.WithDiscardUnknownColumns()), nil
+}
+
+type replayStore struct {
+	mu      sync.Mutex
+	entries map[string][]ReplayEntry
+}
+
+func replayKey(query string, args []ReplayValue) string {
+	key, _ := json.Marshal(args)
+	return query + "\x00" + string(key)
+}
+
+func (s *replayStore) next(query string, args []driver.NamedValue) (ReplayEntry, error) {
+	key := replayKey(query, replayArgs(args))
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	queue := s.entries[key]
+	if len(queue) == 0 {
+		return ReplayEntry{}, fmt.Errorf("%w: %s", ErrReplayMiss, query)
+	}
+	entry := queue[0]
+	if len(queue) > 1 {
+		s.entries[key] = queue[1:]
+	}
+	if entry.Error != "" {
+		return entry, errors.New(entry.Error)
+	}
+	return entry, nil
+}
+
+type replayConnector struct {
+	store *replayStore
+}
+
+func (c replayConnector) Connect(context.Context) (driver.Conn, error) {
+	return &replayConn{store: c.store}, nil
+}
+
+func (c replayConnector) Driver() driver.Driver {
+	return replayDriver{}
+}
+
+type replayDriver struct{}
+
+func (replayDriver) Open(string) (driver.Conn, error) {
+	return nil, errors.New("replay driver: use OpenReplayDB")
+}
+
+type replayConn struct {
+	store *replayStore
+}
+
+var (
+	_ driver.ExecerContext  = (*replayConn)(nil)
+	_ driver.QueryerContext = (*replayConn)(nil)
+)
+
+func (c *replayConn) Prepare(query string) (driver.Stmt, error) {
+	return nil, fmt.Errorf("%w: prepared statement %s", ErrReplayMiss, query)
+}
+
+func (c *replayConn) Close() error {
+	return nil
+}
+
+func (c *replayConn) Begin() (driver.Tx, error) {
+	return replayTx{}, nil
+}
+
+func (c *replayConn) ExecContext(_ context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	entry, err := c.store.next(query, args)
+	if err != nil {
+		return nil, err
+	}
+	return replayResult{lastInsertID: entry.LastInsertID, rowsAffected: entry.RowsAffected}, nil
+}
+
+func (c *replayConn) QueryContext(_ context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	entry, err := c.store.next(query, args)
+	if err != nil {
+		return nil, err
+	}
+	return &replayRows{columns: entry.Columns, rows: entry.Rows}, nil
+}
+
+type replayTx struct{}
+
+func (replayTx) Commit() error   { return nil }
+func (replayTx) Rollback() error { return nil }
+
+type replayResult struct {
+	lastInsertID int64
+	rowsAffected int64
+}
+
+func (r replayResult) LastInsertId() (int64, error) { return r.lastInsertID, nil }
+func (r replayResult) RowsAffected() (int64, error) { return r.rowsAffected, nil }
+
+// replayRows serves rows from memory
+type replayRows struct {
+	columns []string
+	rows    [][]ReplayValue
+}
+
+func (r *replayRows) Columns() []string {
+	return r.columns
+}
+
+func (r *replayRows) Close() error {
+	return nil
+}
+
+func (r *replayRows) Next(dest []driver.Value) error {
+	if len(r.rows) == 0 {
+		return io.EOF
+	}
+	for i, v := range r.rows[0] {
+		dest[i] = v.V
+	}
+	r.rows = r.rows[1:]
+	return nil
+}
@@ -0,0 +1,107 @@
+package dbx
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/uptrace/bun"
+)
+
+type replayItem struct {
+	bun.BaseModel `bun:"table:items"`
+
+	ID        int64     `bun:"id,pk,autoincrement"`
+	Name      string    `bun:"name"`
+	Data      []byte    `bun:"data"`
+	Price     float64   `bun:"price"`
+	CreatedAt time.Time `bun:"created_at"`
+}
+
+// replayScenario is code layered on dbx, run once against a real db and once against its recording
+func replayScenario(ctx context.Context, db *bun.DB) ([]replayItem, error) {
+	t, err := NewTransact(ctx, db)
+	if err != nil {
+		return nil, err
+	}
+	err = t.Transaction(nil, func(ctx context.Context) error {
+		item := &replayItem{Name: "a", Data: []byte{0, 1, 2}, Price: 1.5, CreatedAt: time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)}
+		_, err := t.Db().NewInsert().Model(item).Exec(ctx)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	var items []replayItem
+	err = db.NewSelect().Model(&items).Order("id").Scan(ctx)
+	return items, err
+}
+
+func TestRecordReplay(t *testing.T) {
+	ctx := context.Background()
+	tmp := t.TempDir()
+	golden := filepath.Join(tmp, "scenario.golden.json")
+
+	rec := NewRecorder()
+	db, err := OpenDB(filepath.Join(tmp, "rec.sqlite"), WithDbFolder(tmp), WithCreateIfMissing(), WithRecorder(rec))
+	if err != nil {
+		t.Fatalf("OpenDB failed: %v", err)
+	}
+	if _, err := db.NewCreateTable().Model((*replayItem)(nil)).Exec(ctx); err != nil {
+		t.Fatalf("create table failed: %v", err)
+	}
+	want, err := replayScenario(ctx, db)
+	if err != nil {
+		t.Fatalf("recorded scenario failed: %v", err)
+	}
+	_ = db.Close()
+	if err := rec.Save(golden); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+
+	replay, err := OpenReplayDB(golden)
+	if err != nil {
+		t.Fatalf("OpenReplayDB failed: %v", err)
+	}
+	defer replay.Close()
+	got, err := replayScenario(ctx, replay)
+	if err != nil {
+		t.Fatalf("replayed scenario failed: %v", err)
+	}
+	if len(got) != 1 || len(want) != 1 {
+		t.Fatalf("expected one item, got %+v and %+v", got, want)
+	}
+	if got[0].ID != want[0].ID || got[0].Name != want[0].Name || string(got[0].Data) != string(want[0].Data) ||
+		got[0].Price != want[0].Price || !got[0].CreatedAt.Equal(want[0].CreatedAt) {
+		t.Fatalf("replayed %+v, recorded %+v", got[0], want[0])
+	}
+
+	var n int
+	if err := replay.NewRaw("SELECT COUNT(*) FROM items").Scan(ctx, &n); !errors.Is(err, ErrReplayMiss) {
+		t.Fatalf("expected ErrReplayMiss for an unrecorded statement, got %v", err)
+	}
+}
+
+func TestReplayValue_JSON(t *testing.T) {
+	for _, v := range []any{nil, int64(-3), 2.5, true, "x", []byte("raw"), time.Date(2024, 1, 2, 3, 4, 5, 6, time.UTC)} {
+		data, err := ReplayValue{V: v}.MarshalJSON()
+		if err != nil {
+			t.Fatalf("marshal %v failed: %v", v, err)
+		}
+		var got ReplayValue
+		if err := got.UnmarshalJSON(data); err != nil {
+			t.Fatalf("unmarshal %s failed: %v", data, err)
+		}
+		if b, ok := v.([]byte); ok {
+			if string(got.V.([]byte)) != string(b) {
+				t.Fatalf("expected %q, got %v", b, got.V)
+			}
+			continue
+		}
+		if got.V != v {
+			t.Fatalf("expected %#v, got %#v (%s)", v, got.V, data)
+		}
+	}
+}
@@ -0,0 +1,138 @@
+package dbx
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"maps"
+	"slices"
+
+	"github.com/pressly/goose/v3"
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/dialect"
+)
+
+// OpenReport describes the state of a database right after it was opened, for startup logs
+type OpenReport struct {
+	Driver  string
+	Dialect string
+	// ServerVersion is the SQLite library version, or the version reported by the server
+	ServerVersion string
+	// Warnings lists the features of dbx the server version is too old for, see ServerVersion
+	Warnings []string
+	// JournalMode is the journal mode in effect on SQLite
+	JournalMode string
+	// Pragmas holds the values in effect of the pragmas OpenDB configures on SQLite
+	Pragmas map[string]string
+	// MigrationVersion is the last applied goose migration, 0 without a version table
+	MigrationVersion int64
+	MaxOpenConns     int
+}
+
+// LogValue renders the report as a slog group, e.g. slog.Info("db opened", "db", report)
+func (r *OpenReport) LogValue() slog.Value {
+	attrs := []slog.Attr{
+		slog.String("driver", r.Driver),
+		slog.String("dialect", r.Dialect),
+		slog.String("server_version", r.ServerVersion),
+		slog.Int64("migration_version", r.MigrationVersion),
+		slog.Int("max_open_conns", r.MaxOpenConns),
+	}
+	if len(r.Warnings) > 0 {
+		attrs = append(attrs, slog.Any("warnings", r.Warnings))
+	}
+	if r.JournalMode != "" {
+		attrs = append(attrs, slog.String("journal_mode", r.JournalMode))
+	}
+	for _, name := range slices.Sorted(maps.Keys(r.Pragmas)) {
+		attrs = append(attrs, slog.String("pragma."+name, r.Pragmas[name]))
+	}
+	return slog.GroupValue(attrs...)
+}
+
+// OpenDBWithReport is OpenDBContext also returning an OpenReport read from the opened db.
+// The migration version is read from the default goose version table.
+func OpenDBWithReport(ctx context.Context, dsn string, opts ...OpenOptFn) (*bun.DB, *OpenReport, error) {
+	db, err := OpenDBContext(ctx, dsn, opts...)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var opt Options
+	setOptions(&opt, opts...)
+	report, err := newOpenReport(ctx, db, opt.driverName)
+	if err != nil {
+		db.Close()
+		return nil, nil, fmt.Errorf("failed to read open report: %w", err)
+	}
+	return db, report, nil
+}
+
+func newOpenReport(ctx context.Context, db *bun.DB, driver string) (*OpenReport, error) {
+	report := &OpenReport{
+		Driver:       driver,
+		Dialect:      db.Dialect().Name().String(),
+		MaxOpenConns: db.Stats().MaxOpenConnections,
+	}
+
+	if err := readServerSettings(ctx, db, report); err != nil {
+		return nil, err
+	}
+
+	server := &ServerInfo{Dialect: report.Dialect, Version: report.ServerVersion}
+	server.parse()
+	report.Warnings = compatWarnings(db.Dialect().Name(), server)
+
+	var err error
+	if report.MigrationVersion, err = migrationVersion(ctx, db); err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+// migrationVersion returns the last migration applied according to the default goose version table, 0 without one
+func migrationVersion(ctx context.Context, db *bun.DB) (int64, error) {
+	exists, err := TableExists(ctx, db, goose.DefaultTablename)
+	if err != nil || !exists {
+		return 0, err
+	}
+	var version int64
+	err = db.NewRaw("SELECT COALESCE(MAX(version_id), 0) FROM ? WHERE is_applied", bun.Ident(goose.DefaultTablename)).
+		Scan(ctx, &version)
+	return version, err
+}
+
+func readServerSettings(ctx context.Context, db *bun.DB, report *OpenReport) error {
+	// A single connection, so every setting is read from the same one
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	switch dName := db.Dialect().Name(); dName {
+	case dialect.SQLite:
+		if err := conn.NewRaw("SELECT sqlite_version()").Scan(ctx, &report.ServerVersion); err != nil {
+			return err
+		}
+		settings := expectedSettings(db.DB)
+		report.Pragmas = make(map[string]string, len(settings))
+		for _, s := range settings {
+			var value string
+			if err := conn.NewRaw("PRAGMA "+s.name).Scan(ctx, &value); err != nil {
+				return fmt.Errorf("failed to read pragma %s: %w", s.name, err)
+			}
+			report.Pragmas[s.name] = value
+		}
+		if err := conn.NewRaw("PRAGMA journal_mode").Scan(ctx, &report.JournalMode); err != nil {
+			return fmt.Errorf("failed to read pragma journal_mode: %w", err)
+		}
+	case dialect.PG, dialect.MySQL:
+		if err := conn.NewRaw("SELECT version()").Scan(ctx, &report.ServerVersion); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("unsupported dialect: %s", dName)
+	}
+	return nil
+}
@@ -0,0 +1,51 @@
+package dbx
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestOpenDBWithReport(t *testing.T) {
+	ctx := context.Background()
+	tmp := t.TempDir()
+	err := CreateDB("report", CreateWithDbFolder(tmp), CreateWithSource(testMigrations),
+		CreateWithSrcFolder("testmigrations"), CreateWithLogger(nil))
+	if err != nil {
+		t.Fatalf("CreateDB failed: %v", err)
+	}
+
+	db, report, err := OpenDBWithReport(ctx, "report", WithDbFolder(tmp))
+	if err != nil {
+		t.Fatalf("OpenDBWithReport failed: %v", err)
+	}
+	defer db.Close()
+
+	if report.Driver != string(DriverSQLite) || report.Dialect != "sqlite" || report.MaxOpenConns != 1 {
+		t.Fatalf("unexpected report %+v", report)
+	}
+	if report.ServerVersion == "" || report.JournalMode != "wal" || report.Pragmas["foreign_keys"] != "1" {
+		t.Fatalf("unexpected settings in report %+v", report)
+	}
+	if report.MigrationVersion != 1 {
+		t.Fatalf("expected migration version 1, got %d", report.MigrationVersion)
+	}
+
+	var buf bytes.Buffer
+	slog.New(slog.NewTextHandler(&buf, nil)).Info("db opened", "db", report)
+	if out := buf.String(); !strings.Contains(out, "db.journal_mode=wal") || !strings.Contains(out, "db.migration_version=1") {
+		t.Fatalf("unexpected log line %q", out)
+	}
+
+	// Without migrations
+	db2, report, err := OpenDBWithReport(ctx, "plain", WithDbFolder(tmp), WithCreateIfMissing())
+	if err != nil {
+		t.Fatalf("OpenDBWithReport failed: %v", err)
+	}
+	defer db2.Close()
+	if report.MigrationVersion != 0 {
+		t.Fatalf("expected migration version 0, got %d", report.MigrationVersion)
+	}
+}
@@ -0,0 +1,142 @@
+package dbx
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/uptrace/bun"
+)
+
+// SeedFunc loads fixtures into a freshly reset database
+type SeedFunc func(ctx context.Context, db *bun.DB) error
+
+// CreateWithSeed sets the function ResetDB runs after the migrations to load fixtures
+func CreateWithSeed(fn SeedFunc) CreateOptFn {
+	return func(opt *CreateOptions) {
+		opt.seed = fn
+	}
+}
+
+// ResetDB drops the database specified by the dsn, recreates it with the migrations and loads the fixtures of
+// CreateWithSeed, for development and test workflows. It takes the same options as CreateDB.
+//
+// For SQLite the db file and its WAL files are deleted, so no connection to it may be open.
+// On Postgres the public schema is dropped and recreated, on MySQL every table of the current database is dropped.
+func ResetDB(dsn string, opts ...CreateOptFn) error {
+	return ResetDBContext(context.Background(), dsn, opts...)
+}
+
+// ResetDBContext is ResetDB with a context
+func ResetDBContext(ctx context.Context, dsn string, opts ...CreateOptFn) error {
+	option := CreateOptions{}
+	setCreateOptions(&option, opts...)
+
+	if IsSQLite(option.driverName) {
+		if err := removeSQLiteDBFile(dsn, option.dbFolder); err != nil {
+			return err
+		}
+	} else if err := dropAllTables(ctx, option.driverName, dsn); err != nil {
+		return err
+	}
+
+	if err := CreateDBContext(ctx, dsn, opts...); err != nil {
+		return err
+	}
+	if option.seed == nil {
+		return nil
+	}
+
+	openOpts := []OpenOptFn{WithDriverName(option.driverName)}
+	if IsSQLite(option.driverName) {
+		openOpts = append(openOpts, WithDbFolder(option.dbFolder))
+	}
+	db, err := OpenDBContext(ctx, dsn, openOpts...)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	if err := option.seed(ctx, db); err != nil {
+		return fmt.Errorf("failed to seed db: %w", err)
+	}
+	return nil
+}
+
+func removeSQLiteDBFile(name, dbFolder string) error {
+	dbFile, err := DbFilePath(name, dbFolder)
+	if errors.Is(err, ErrDBFileNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	for _, f := range []string{dbFile, dbFile + "-wal", dbFile + "-shm"} {
+		if err := os.Remove(f); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove db file(%s): %w", f, err)
+		}
+	}
+	return nil
+}
+
+func dropAllTables(ctx context.Context, driverName DriverName, dsn string) error {
+	db, err := sql.Open(string(driverName), dsn)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	switch driverName {
+	case DriverPostgres, DriverPgx:
+		_, err = db.ExecContext(ctx, "DROP SCHEMA public CASCADE; CREATE SCHEMA public")
+	case DriverMySQL:
+		err = dropMySQLTables(ctx, db)
+	default:
+		return fmt.Errorf("unsupported driver: %s", driverName)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to drop tables: %w", err)
+	}
+	return nil
+}
+
+func dropMySQLTables(ctx context.Context, db *sql.DB) error {
+	// FOREIGN_KEY_CHECKS is per session, so everything runs on one connection
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	rows, err := conn.QueryContext(ctx, "SELECT table_name FROM information_schema.tables WHERE table_schema = DATABASE()")
+	if err != nil {
+		return err
+	}
+	var tables []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			rows.Close()
+			return err
+		}
+		tables = append(tables, name)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	if _, err := conn.ExecContext(ctx, "SET FOREIGN_KEY_CHECKS = 0"); err != nil {
+		return err
+	}
+	defer conn.ExecContext(context.WithoutCancel(ctx), "SET FOREIGN_KEY_CHECKS = 1")
+	for _, table := range tables {
+		if _, err := conn.ExecContext(ctx, fmt.Sprintf("DROP TABLE IF EXISTS `%s`", table)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
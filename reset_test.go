@@ -0,0 +1,50 @@
+package dbx
+
+import (
+	"context"
+	"testing"
+
+	"github.com/uptrace/bun"
+)
+
+func TestResetDB(t *testing.T) {
+	ctx := context.Background()
+	tmp := t.TempDir()
+	seeded := 0
+	opts := []CreateOptFn{
+		CreateWithDbFolder(tmp), CreateWithSource(testMigrations), CreateWithSrcFolder("testmigrations"),
+		CreateWithLogger(nil),
+		CreateWithSeed(func(ctx context.Context, db *bun.DB) error {
+			seeded++
+			insertItem(t, db, "fixture")
+			return nil
+		}),
+	}
+
+	if err := CreateDB("reset", opts...); err != nil {
+		t.Fatalf("CreateDB failed: %v", err)
+	}
+	db, err := OpenDB("reset", WithDbFolder(tmp))
+	if err != nil {
+		t.Fatalf("OpenDB failed: %v", err)
+	}
+	insertItem(t, db, "dev data")
+	insertItem(t, db, "more dev data")
+	_ = db.Close()
+
+	if err := ResetDBContext(ctx, "reset", opts...); err != nil {
+		t.Fatalf("ResetDB failed: %v", err)
+	}
+	if seeded != 1 {
+		t.Fatalf("expected seed to run once on reset, ran %d times", seeded)
+	}
+
+	db, err = OpenDB("reset", WithDbFolder(tmp))
+	if err != nil {
+		t.Fatalf("OpenDB failed: %v", err)
+	}
+	defer db.Close()
+	if n := countItems(t, db); n != 1 {
+		t.Fatalf("expected only the fixture row after reset, got %d rows", n)
+	}
+}
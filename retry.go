@@ -0,0 +1,147 @@
+package dbx
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+)
+
+// RetryClassifier decides which errors are transient, so that the transaction failing with them can safely run again
+type RetryClassifier interface {
+	Retryable(err error) bool
+}
+
+// RetryClassifierFunc adapts a function to a RetryClassifier
+type RetryClassifierFunc func(err error) bool
+
+func (f RetryClassifierFunc) Retryable(err error) bool {
+	return f(err)
+}
+
+// AnyRetryClassifier reports an error as retryable when one of classifiers does,
+// e.g. to extend DefaultRetryClassifier with the errors of a proxy
+func AnyRetryClassifier(classifiers ...RetryClassifier) RetryClassifier {
+	return RetryClassifierFunc(func(err error) bool {
+		for _, c := range classifiers {
+			if c.Retryable(err) {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+// DefaultRetryClassifier retries SQLite busy and locked errors, and Postgres serialization failures (40001)
+// and deadlocks (40P01). Drivers are recognized by their SQLState method or error text, so none is imported.
+var DefaultRetryClassifier RetryClassifier = RetryClassifierFunc(defaultRetryable)
+
+var retryableSQLStates = []string{"40001", "40P01"}
+
+func defaultRetryable(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+
+	var stater interface{ SQLState() string }
+	if errors.As(err, &stater) {
+		for _, state := range retryableSQLStates {
+			if stater.SQLState() == state {
+				return true
+			}
+		}
+	}
+
+	msg := err.Error()
+	for _, state := range retryableSQLStates {
+		if strings.Contains(msg, "SQLSTATE "+state) {
+			return true
+		}
+	}
+	return strings.Contains(msg, "database is locked") ||
+		strings.Contains(msg, "database table is locked") ||
+		strings.Contains(msg, "SQLITE_BUSY") ||
+		strings.Contains(msg, "SQLITE_LOCKED")
+}
+
+type RetryOptions struct {
+	attempts   int
+	backoff    time.Duration
+	classifier RetryClassifier
+	txOpts     []TransactionOptFn
+}
+
+type RetryOptFn func(options *RetryOptions)
+
+// RetryWithAttempts sets the maximum number of runs, the first one included (default: 3)
+func RetryWithAttempts(n int) RetryOptFn {
+	return func(opt *RetryOptions) {
+		opt.attempts = n
+	}
+}
+
+// RetryWithBackoff sets the wait before the second run, doubled before each further one (default: 10ms)
+func RetryWithBackoff(d time.Duration) RetryOptFn {
+	return func(opt *RetryOptions) {
+		opt.backoff = d
+	}
+}
+
+// RetryWithClassifier sets the classifier of retryable errors (default: DefaultRetryClassifier)
+func RetryWithClassifier(c RetryClassifier) RetryOptFn {
+	return func(opt *RetryOptions) {
+		opt.classifier = c
+	}
+}
+
+// RetryWithTransactionOpts sets the options of each Transaction run, e.g. TransactionWithoutTx
+func RetryWithTransactionOpts(opts ...TransactionOptFn) RetryOptFn {
+	return func(opt *RetryOptions) {
+		opt.txOpts = append(opt.txOpts, opts...)
+	}
+}
+
+func setRetryOptions(opt *RetryOptions, opts ...RetryOptFn) {
+	for _, optFn := range opts {
+		optFn(opt)
+	}
+
+	if opt.attempts <= 0 {
+		RetryWithAttempts(3)(opt)
+	}
+	if opt.backoff <= 0 {
+		RetryWithBackoff(10 * time.Millisecond)(opt)
+	}
+	if opt.classifier == nil {
+		RetryWithClassifier(DefaultRetryClassifier)(opt)
+	}
+}
+
+// TransactionRetry runs fn in a transaction like Transaction, running it again in a new transaction when it fails
+// with an error the classifier reports as retryable. Only a whole transaction can be retried, so it fails with
+// ErrTxActive inside another one. fn must not have side effects outside of the transaction (see OnCommit).
+func (t *Transact) TransactionRetry(opt *sql.TxOptions, fn TransactFunc, opts ...RetryOptFn) error {
+	var ro RetryOptions
+	setRetryOptions(&ro, opts...)
+	if t.active() {
+		return ErrTxActive
+	}
+
+	backoff := ro.backoff
+	for attempt := 1; ; attempt++ {
+		err := t.Transaction(opt, fn, ro.txOpts...)
+		if err == nil || attempt >= ro.attempts || !ro.classifier.Retryable(err) {
+			return err
+		}
+
+		timer := time.NewTimer(backoff)
+		select {
+		case <-t.ctx.Done():
+			timer.Stop()
+			return errors.Join(err, context.Cause(t.ctx))
+		case <-timer.C:
+		}
+		backoff *= 2
+	}
+}
@@ -0,0 +1,101 @@
+package dbx
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+type sqlStateErr string
+
+func (e sqlStateErr) Error() string    { return "pq: " + string(e) }
+func (e sqlStateErr) SQLState() string { return string(e) }
+
+func TestDefaultRetryClassifier(t *testing.T) {
+	cases := []struct {
+		err  error
+		want bool
+	}{
+		{errors.New("database is locked"), true},
+		{fmt.Errorf("failed to commit: %w", errors.New("database is locked (5) (SQLITE_BUSY)")), true},
+		{sqlStateErr("40001"), true},
+		{fmt.Errorf("wrapped: %w", sqlStateErr("40P01")), true},
+		{errors.New("ERROR: could not serialize access (SQLSTATE 40001)"), true},
+		{sqlStateErr("23505"), false},
+		{errors.New("no such table: items"), false},
+		{context.Canceled, false},
+		{nil, false},
+	}
+	for _, c := range cases {
+		if got := DefaultRetryClassifier.Retryable(c.err); got != c.want {
+			t.Errorf("Retryable(%v) = %v, want %v", c.err, got, c.want)
+		}
+	}
+}
+
+func TestTransactionRetry(t *testing.T) {
+	db := setupTestDB(t)
+	tx := mustNewTx(t, db)
+	errFlaky := errors.New("flaky proxy")
+	classifier := AnyRetryClassifier(DefaultRetryClassifier, RetryClassifierFunc(func(err error) bool {
+		return errors.Is(err, errFlaky)
+	}))
+
+	runs := 0
+	err := tx.TransactionRetry(nil, func(ctx context.Context) error {
+		runs++
+		insertItem(t, tx.Db(), "a")
+		if runs < 3 {
+			return errFlaky
+		}
+		return nil
+	}, RetryWithClassifier(classifier), RetryWithBackoff(time.Millisecond))
+	if err != nil {
+		t.Fatalf("TransactionRetry failed: %v", err)
+	}
+	if runs != 3 || countItems(t, db) != 1 {
+		t.Fatalf("expected 3 runs and 1 item, got %d runs and %d items", runs, countItems(t, db))
+	}
+
+	// Not retryable with the default classifier, and attempts are bounded
+	runs = 0
+	err = tx.TransactionRetry(nil, func(ctx context.Context) error {
+		runs++
+		return errFlaky
+	}, RetryWithBackoff(time.Millisecond))
+	if !errors.Is(err, errFlaky) || runs != 1 {
+		t.Fatalf("expected a single run, got %d (err %v)", runs, err)
+	}
+	runs = 0
+	err = tx.TransactionRetry(nil, func(ctx context.Context) error {
+		runs++
+		return errFlaky
+	}, RetryWithClassifier(classifier), RetryWithAttempts(2), RetryWithBackoff(time.Millisecond))
+	if !errors.Is(err, errFlaky) || runs != 2 {
+		t.Fatalf("expected 2 runs, got %d (err %v)", runs, err)
+	}
+
+	// Without tx, the writes of the failed runs are kept
+	runs = 0
+	err = tx.TransactionRetry(nil, func(ctx context.Context) error {
+		runs++
+		insertItem(t, tx.Db(), "b")
+		if runs < 2 {
+			return errFlaky
+		}
+		return nil
+	}, RetryWithClassifier(classifier), RetryWithBackoff(time.Millisecond), RetryWithTransactionOpts(TransactionWithoutTx()))
+	if err != nil || runs != 2 || countItems(t, db) != 3 {
+		t.Fatalf("expected 2 runs and 3 items, got %d runs and %d items (err %v)", runs, countItems(t, db), err)
+	}
+
+	// Nested transactions cannot be retried on their own
+	err = tx.Transaction(nil, func(ctx context.Context) error {
+		return tx.TransactionRetry(nil, func(ctx context.Context) error { return nil })
+	})
+	if !errors.Is(err, ErrTxActive) {
+		t.Fatalf("expected ErrTxActive, got %v", err)
+	}
+}
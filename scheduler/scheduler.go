@@ -0,0 +1,302 @@
+package scheduler
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"runtime/debug"
+	"sync"
+	"time"
+
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/dialect"
+)
+
+var ErrJobExists = errors.New("job already registered")
+
+// JobFunc is the work executed when a job is due
+type JobFunc func(ctx context.Context) error
+
+// Job is a single row of the dbx_jobs table
+type Job struct {
+	bun.BaseModel `bun:"table:dbx_jobs"`
+
+	Name        string     `bun:"name,pk"`
+	CronExpr    string     `bun:"cron_expr,notnull"`
+	NextRunAt   time.Time  `bun:"next_run_at,notnull"`
+	LastRunAt   *time.Time `bun:"last_run_at"`
+	LockedBy    string     `bun:"locked_by,nullzero"`
+	LockedUntil *time.Time `bun:"locked_until"`
+}
+
+// JobRun is a single row of the dbx_job_runs table, recording one execution of a job
+type JobRun struct {
+	bun.BaseModel `bun:"table:dbx_job_runs"`
+
+	ID         int64     `bun:"id,pk,autoincrement"`
+	Name       string    `bun:"name,notnull"`
+	Owner      string    `bun:"owner,notnull"`
+	StartedAt  time.Time `bun:"started_at,notnull"`
+	FinishedAt time.Time `bun:"finished_at,notnull"`
+	Error      string    `bun:"error,nullzero"`
+}
+
+type Options struct {
+	pollInterval time.Duration
+	lease        time.Duration
+	owner        string
+}
+
+type OptFn func(options *Options)
+
+// WithPollInterval sets how often Run checks for due jobs (default: 10s)
+func WithPollInterval(d time.Duration) OptFn {
+	return func(opt *Options) {
+		opt.pollInterval = d
+	}
+}
+
+// WithLease sets how long a claimed job stays locked to this scheduler before another one may take over (default: 5m).
+// It should be longer than the slowest job.
+func WithLease(d time.Duration) OptFn {
+	return func(opt *Options) {
+		opt.lease = d
+	}
+}
+
+// WithOwner sets the name this scheduler records as lock holder and in the run history (default: hostname-pid-random)
+func WithOwner(owner string) OptFn {
+	return func(opt *Options) {
+		opt.owner = owner
+	}
+}
+
+type job struct {
+	name     string
+	schedule *Schedule
+	fn       JobFunc
+}
+
+// Scheduler runs registered jobs on their cron schedule.
+// Next-run timestamps and the run history are kept in the database, and a job is claimed with a lease
+// on its dbx_jobs row before it runs, so several processes sharing the database run each occurrence once.
+// All schedules are evaluated in UTC.
+type Scheduler struct {
+	db   bun.IDB
+	ctx  context.Context
+	opt  Options
+	mu   sync.Mutex
+	jobs map[string]*job
+}
+
+// New creates the scheduler tables if they do not exist and returns a Scheduler using them.
+// ctx is used for the table setup and for Register.
+func New(ctx context.Context, db bun.IDB, opts ...OptFn) (*Scheduler, error) {
+	if db == nil {
+		return nil, errors.New("scheduler: New with nil db")
+	}
+
+	var opt Options
+	for _, optFn := range opts {
+		optFn(&opt)
+	}
+	if opt.pollInterval == 0 {
+		WithPollInterval(10 * time.Second)(&opt)
+	}
+	if opt.lease == 0 {
+		WithLease(5 * time.Minute)(&opt)
+	}
+	if opt.owner == "" {
+		WithOwner(defaultOwner())(&opt)
+	}
+
+	for _, model := range []any{(*Job)(nil), (*JobRun)(nil)} {
+		if _, err := db.NewCreateTable().Model(model).IfNotExists().Exec(ctx); err != nil {
+			return nil, fmt.Errorf("failed to create scheduler tables: %w", err)
+		}
+	}
+
+	return &Scheduler{db: db, ctx: ctx, opt: opt, jobs: make(map[string]*job)}, nil
+}
+
+// Register adds a job. The job row is created on first registration; an existing row keeps its next run time
+// unless the cron expression changed.
+func (s *Scheduler) Register(name, cronExpr string, fn JobFunc) error {
+	ctx := s.ctx
+	schedule, err := ParseCron(cronExpr)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, found := s.jobs[name]; found {
+		return fmt.Errorf("%w: %s", ErrJobExists, name)
+	}
+
+	next := schedule.Next(time.Now().UTC())
+	if next.IsZero() {
+		return fmt.Errorf("%w: %q: never matches", ErrInvalidCronExpr, cronExpr)
+	}
+	row := &Job{
+		Name:      name,
+		CronExpr:  cronExpr,
+		NextRunAt: next,
+	}
+	if _, err = s.registerQuery(row).Exec(ctx); err != nil {
+		return fmt.Errorf("failed to register job %s: %w", name, err)
+	}
+
+	s.jobs[name] = &job{name: name, schedule: schedule, fn: fn}
+	return nil
+}
+
+// registerQuery upserts the row of a job, keeping the next run time of an existing row with the same cron expression
+func (s *Scheduler) registerQuery(row *Job) *bun.InsertQuery {
+	q := s.db.NewInsert().Model(row)
+	if s.db.Dialect().Name() == dialect.MySQL {
+		// The assignments run in order on the existing row, so next_run_at compares the old cron_expr
+		return q.On("DUPLICATE KEY UPDATE").
+			Set("next_run_at = IF(cron_expr = VALUES(cron_expr), next_run_at, VALUES(next_run_at))").
+			Set("cron_expr = VALUES(cron_expr)")
+	}
+	return q.On("CONFLICT (name) DO UPDATE").
+		Set("next_run_at = CASE WHEN ?TableAlias.cron_expr = EXCLUDED.cron_expr THEN ?TableAlias.next_run_at ELSE EXCLUDED.next_run_at END").
+		Set("cron_expr = EXCLUDED.cron_expr")
+}
+
+// Run checks for due jobs every poll interval until ctx is cancelled
+func (s *Scheduler) Run(ctx context.Context) error {
+	ticker := time.NewTicker(s.opt.pollInterval)
+	defer ticker.Stop()
+
+	for {
+		if err := s.RunPending(ctx); err != nil {
+			slog.Error("scheduler run", "err", err.Error())
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// RunPending runs every registered job that is due now, one after the other
+func (s *Scheduler) RunPending(ctx context.Context) error {
+	return s.runPending(ctx, time.Now().UTC())
+}
+
+func (s *Scheduler) runPending(ctx context.Context, now time.Time) error {
+	s.mu.Lock()
+	jobs := make([]*job, 0, len(s.jobs))
+	for _, j := range s.jobs {
+		jobs = append(jobs, j)
+	}
+	s.mu.Unlock()
+
+	var errs []error
+	for _, j := range jobs {
+		if err := s.runJob(ctx, j, now); err != nil {
+			errs = append(errs, fmt.Errorf("job %s: %w", j.name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (s *Scheduler) runJob(ctx context.Context, j *job, now time.Time) error {
+	claimed, err := s.claim(ctx, j.name, now)
+	if err != nil || !claimed {
+		return err
+	}
+
+	started := time.Now().UTC()
+	jobErr := callJob(ctx, j.fn)
+	finished := time.Now().UTC()
+
+	run := &JobRun{
+		Name:       j.name,
+		Owner:      s.opt.owner,
+		StartedAt:  started,
+		FinishedAt: finished,
+	}
+	if jobErr != nil {
+		run.Error = jobErr.Error()
+	}
+	if _, err = s.db.NewInsert().Model(run).Exec(ctx); err != nil {
+		return fmt.Errorf("failed to record run: %w", err)
+	}
+
+	// Release the lease and move on to the next occurrence after now, skipping the ones missed while down.
+	next := j.schedule.Next(now)
+	if next.IsZero() {
+		// Keep the lease rather than a zero next run, which would be due on every poll
+		return errors.Join(jobErr, fmt.Errorf("%w: no run after %s", ErrInvalidCronExpr, now))
+	}
+	_, err = s.db.NewUpdate().Model((*Job)(nil)).
+		Set("next_run_at = ?", next).
+		Set("last_run_at = ?", started).
+		Set("locked_by = NULL").
+		Set("locked_until = NULL").
+		Where("name = ?", j.name).
+		Where("locked_by = ?", s.opt.owner).
+		Exec(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to release job: %w", err)
+	}
+
+	return jobErr
+}
+
+// claim takes the lease on a due job. It reports false when the job is not due or another owner holds the lease.
+func (s *Scheduler) claim(ctx context.Context, name string, now time.Time) (bool, error) {
+	res, err := s.db.NewUpdate().Model((*Job)(nil)).
+		Set("locked_by = ?", s.opt.owner).
+		Set("locked_until = ?", now.Add(s.opt.lease)).
+		Where("name = ?", name).
+		Where("next_run_at <= ?", now).
+		WhereGroup(" AND ", func(q *bun.UpdateQuery) *bun.UpdateQuery {
+			return q.Where("locked_until IS NULL").WhereOr("locked_until < ?", now)
+		}).
+		Exec(ctx)
+	if err != nil {
+		return false, fmt.Errorf("failed to claim job: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return n == 1, nil
+}
+
+// History returns the most recent runs of a job, newest first
+func (s *Scheduler) History(ctx context.Context, name string, limit int) ([]JobRun, error) {
+	var runs []JobRun
+	err := s.db.NewSelect().Model(&runs).
+		Where("name = ?", name).
+		Order("id DESC").
+		Limit(limit).
+		Scan(ctx)
+	return runs, err
+}
+
+func callJob(ctx context.Context, fn JobFunc) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic recovered in job: %v\nStack trace:\n%s", r, debug.Stack())
+		}
+	}()
+	return fn(ctx)
+}
+
+func defaultOwner() string {
+	host, _ := os.Hostname()
+	b := make([]byte, 4)
+	_, _ = rand.Read(b)
+	return fmt.Sprintf("%s-%d-%s", host, os.Getpid(), hex.EncodeToString(b))
+}
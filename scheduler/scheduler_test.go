@@ -0,0 +1,105 @@
+package scheduler
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/actanonv/dbx"
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/dialect/mysqldialect"
+)
+
+func setupTestDB(t *testing.T) *bun.DB {
+	t.Helper()
+
+	tmp := t.TempDir()
+	if err := dbx.CreateDB("scheduler", dbx.CreateWithDbFolder(tmp)); err != nil {
+		t.Fatalf("CreateDB failed: %v", err)
+	}
+	db, err := dbx.OpenDB("scheduler", dbx.WithDbFolder(tmp))
+	if err != nil {
+		t.Fatalf("OpenDB failed: %v", err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+
+	return db
+}
+
+func TestScheduler_RunsDueJobsOnce(t *testing.T) {
+	ctx := context.Background()
+	db := setupTestDB(t)
+
+	a, err := New(ctx, db, WithOwner("a"))
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	b, err := New(ctx, db, WithOwner("b"))
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+
+	runs := 0
+	fn := func(ctx context.Context) error {
+		runs++
+		return errors.New("boom")
+	}
+	if err := a.Register("report", "* * * * *", fn); err != nil {
+		t.Fatalf("Register failed: %v", err)
+	}
+	if err := b.Register("report", "* * * * *", fn); err != nil {
+		t.Fatalf("Register failed: %v", err)
+	}
+	if err := a.Register("leap", "0 0 31 2 *", fn); !errors.Is(err, ErrInvalidCronExpr) {
+		t.Fatalf("expected ErrInvalidCronExpr for a schedule that never matches, got %v", err)
+	}
+	if err := a.Register("report", "* * * * *", fn); !errors.Is(err, ErrJobExists) {
+		t.Fatalf("expected ErrJobExists, got %v", err)
+	}
+
+	// Not due yet
+	if err := a.RunPending(ctx); err != nil {
+		t.Fatalf("RunPending failed: %v", err)
+	}
+	if runs != 0 {
+		t.Fatalf("expected no runs before the job is due, got %d", runs)
+	}
+
+	later := time.Now().UTC().Add(2 * time.Minute)
+	if err := a.runPending(ctx, later); err == nil {
+		t.Fatal("expected the job error to be returned")
+	}
+	if err := b.runPending(ctx, later); err != nil {
+		t.Fatalf("runPending failed: %v", err)
+	}
+	if runs != 1 {
+		t.Fatalf("expected exactly one run, got %d", runs)
+	}
+
+	history, err := a.History(ctx, "report", 10)
+	if err != nil {
+		t.Fatalf("History failed: %v", err)
+	}
+	if len(history) != 1 || history[0].Owner != "a" || history[0].Error != "boom" {
+		t.Fatalf("unexpected history: %+v", history)
+	}
+}
+
+func TestScheduler_RegisterMySQL(t *testing.T) {
+	sqldb, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("sql.Open failed: %v", err)
+	}
+	defer sqldb.Close()
+	s := &Scheduler{db: bun.NewDB(sqldb, mysqldialect.New())}
+	query := s.registerQuery(&Job{Name: "nightly", CronExpr: "0 3 * * *", NextRunAt: time.Now()}).String()
+	want := "ON DUPLICATE KEY UPDATE next_run_at = IF(cron_expr = VALUES(cron_expr), next_run_at, VALUES(next_run_at)), " +
+		"cron_expr = VALUES(cron_expr)"
+	if !strings.HasSuffix(query, want) {
+		t.Fatalf("expected the MySQL upsert, got %s", query)
+	}
+}
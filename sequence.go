@@ -0,0 +1,68 @@
+package dbx
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/dialect"
+)
+
+// Sequence is a single row of the dbx_sequences table
+type Sequence struct {
+	bun.BaseModel `bun:"table:dbx_sequences"`
+
+	Name  string `bun:"name,pk"`
+	Value int64  `bun:"value,notnull"`
+}
+
+// CreateSequenceTable creates the dbx_sequences table used by NextSequence if it does not exist
+func CreateSequenceTable(ctx context.Context, idb bun.IDB) error {
+	if _, err := idb.NewCreateTable().Model((*Sequence)(nil)).IfNotExists().Exec(ctx); err != nil {
+		return fmt.Errorf("failed to create sequences table: %w", err)
+	}
+	return nil
+}
+
+// NextSequence atomically increments the named counter and returns its new value, starting at 1.
+// The increment is a single statement, so concurrent callers always get distinct values. When idb is a
+// transaction that rolls back, the value is handed out again, which keeps sequences gapless in the common case.
+//
+// Supported dialects are SQLite (3.35+), Postgres and MySQL. The table must exist (see CreateSequenceTable).
+func NextSequence(ctx context.Context, idb bun.IDB, name string) (int64, error) {
+	var value int64
+	switch dName := idb.Dialect().Name(); dName {
+	case dialect.SQLite, dialect.PG:
+		err := idb.NewRaw(`
+			INSERT INTO ?0 (name, value) VALUES (?1, 1)
+			ON CONFLICT (name) DO UPDATE SET value = ?0.value + 1
+			RETURNING value`, bun.Ident(TableName(idb, "dbx_sequences")), name).Scan(ctx, &value)
+		if err != nil {
+			return 0, fmt.Errorf("failed to increment sequence %s: %w", name, err)
+		}
+	case dialect.MySQL:
+		// LAST_INSERT_ID(expr) makes the new value available as the statement's insert id.
+		res, err := idb.ExecContext(ctx, `
+			INSERT INTO ? (name, value) VALUES (?, LAST_INSERT_ID(1))
+			ON DUPLICATE KEY UPDATE value = LAST_INSERT_ID(value + 1)`, bun.Ident(TableName(idb, "dbx_sequences")), name)
+		if err != nil {
+			return 0, fmt.Errorf("failed to increment sequence %s: %w", name, err)
+		}
+		if value, err = res.LastInsertId(); err != nil {
+			return 0, err
+		}
+	default:
+		return 0, fmt.Errorf("unsupported dialect: %s", dName)
+	}
+
+	return value, nil
+}
+
+// upsert makes q, the insert of a model whose primary key is pk, update the other columns of the row it conflicts
+// with: ON DUPLICATE KEY UPDATE on MySQL, ON CONFLICT DO UPDATE elsewhere
+func upsert(q *bun.InsertQuery, pk string) *bun.InsertQuery {
+	if q.Dialect().Name() == dialect.MySQL {
+		return q.On("DUPLICATE KEY UPDATE")
+	}
+	return q.On("CONFLICT (?) DO UPDATE", bun.Ident(pk))
+}
@@ -0,0 +1,99 @@
+package dbx
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/dialect/mysqldialect"
+	"github.com/uptrace/bun/dialect/pgdialect"
+	"github.com/uptrace/bun/dialect/sqlitedialect"
+	"github.com/uptrace/bun/schema"
+)
+
+func TestNextSequence(t *testing.T) {
+	db := setupTestDB(t)
+	ctx := context.Background()
+
+	if err := CreateSequenceTable(ctx, db); err != nil {
+		t.Fatalf("CreateSequenceTable failed: %v", err)
+	}
+
+	for want := int64(1); want <= 3; want++ {
+		got, err := NextSequence(ctx, db, "invoice")
+		if err != nil {
+			t.Fatalf("NextSequence failed: %v", err)
+		}
+		if got != want {
+			t.Fatalf("expected %d, got %d", want, got)
+		}
+	}
+
+	if got, err := NextSequence(ctx, db, "order"); err != nil || got != 1 {
+		t.Fatalf("expected a new sequence to start at 1, got %d (err %v)", got, err)
+	}
+
+	// Rolled back values are handed out again
+	tx := mustNewTx(t, db)
+	if _, err := tx.Start(nil); err != nil {
+		t.Fatalf("Start error: %v", err)
+	}
+	if got, err := NextSequence(ctx, tx.Db(), "invoice"); err != nil || got != 4 {
+		t.Fatalf("expected 4 inside tx, got %d (err %v)", got, err)
+	}
+	if err := tx.Rollback(); err != nil {
+		t.Fatalf("Rollback error: %v", err)
+	}
+
+	var wg sync.WaitGroup
+	seen := make(chan int64, 10)
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			v, err := NextSequence(ctx, db, "invoice")
+			if err != nil {
+				t.Errorf("NextSequence failed: %v", err)
+				return
+			}
+			seen <- v
+		}()
+	}
+	wg.Wait()
+	close(seen)
+
+	unique := make(map[int64]bool)
+	for v := range seen {
+		unique[v] = true
+	}
+	if len(unique) != 10 {
+		t.Fatalf("expected 10 distinct values, got %d", len(unique))
+	}
+	if !unique[4] || !unique[13] {
+		t.Fatalf("expected values 4..13, got %v", unique)
+	}
+}
+
+func TestUpsert(t *testing.T) {
+	sqldb, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("sql.Open failed: %v", err)
+	}
+	defer sqldb.Close()
+	for _, tc := range []struct {
+		dialect schema.Dialect
+		want    string
+	}{
+		{sqlitedialect.New(), `ON CONFLICT ("name") DO UPDATE SET "value" = EXCLUDED."value"`},
+		{pgdialect.New(), `ON CONFLICT ("name") DO UPDATE SET "value" = EXCLUDED."value"`},
+		{mysqldialect.New(), "ON DUPLICATE KEY UPDATE `value` = VALUES(`value`)"},
+	} {
+		q := upsert(bun.NewDB(sqldb, tc.dialect).NewInsert().Model(&Sequence{Name: "a", Value: 1}), "name")
+		if got := q.String(); !strings.HasSuffix(got, tc.want) {
+			t.Errorf("%s: expected the upsert to end with %s, got %s", tc.dialect.Name(), tc.want, got)
+		}
+	}
+}
@@ -0,0 +1,183 @@
+package sessions
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/uptrace/bun"
+)
+
+var ErrSessionNotFound = errors.New("session not found")
+
+// Session is a single row of the dbx_sessions table
+type Session struct {
+	bun.BaseModel `bun:"table:dbx_sessions"`
+
+	ID        string    `bun:"id,pk"`
+	Data      []byte    `bun:"data"`
+	CreatedAt time.Time `bun:"created_at,notnull"`
+	ExpiresAt time.Time `bun:"expires_at,notnull"`
+}
+
+type Options struct {
+	lifetime time.Duration
+	rand     io.Reader
+}
+
+type OptFn func(options *Options)
+
+// WithLifetime sets how long a session stays valid after it was created or last touched (default: 24h)
+func WithLifetime(d time.Duration) OptFn {
+	return func(opt *Options) {
+		opt.lifetime = d
+	}
+}
+
+// WithRandReader sets the source of the session ids (default: crypto/rand.Reader).
+// Only tests should replace it, for stable ids; session ids must be unguessable.
+func WithRandReader(r io.Reader) OptFn {
+	return func(opt *Options) {
+		opt.rand = r
+	}
+}
+
+// Store is an HTTP session store backed by the dbx_sessions table
+type Store struct {
+	db       bun.IDB
+	lifetime time.Duration
+	rand     io.Reader
+}
+
+// NewStore creates the sessions table if it does not exist and returns a Store using it.
+func NewStore(ctx context.Context, db bun.IDB, opts ...OptFn) (*Store, error) {
+	if db == nil {
+		return nil, errors.New("sessions: NewStore with nil db")
+	}
+
+	var opt Options
+	for _, optFn := range opts {
+		optFn(&opt)
+	}
+	if opt.lifetime == 0 {
+		WithLifetime(24 * time.Hour)(&opt)
+	}
+	if opt.rand == nil {
+		WithRandReader(rand.Reader)(&opt)
+	}
+
+	if _, err := db.NewCreateTable().Model((*Session)(nil)).IfNotExists().Exec(ctx); err != nil {
+		return nil, fmt.Errorf("failed to create sessions table: %w", err)
+	}
+	if _, err := db.NewCreateIndex().Model((*Session)(nil)).Index("dbx_sessions_expires_at_idx").
+		Column("expires_at").IfNotExists().Exec(ctx); err != nil {
+		return nil, fmt.Errorf("failed to create sessions index: %w", err)
+	}
+
+	return &Store{db: db, lifetime: opt.lifetime, rand: opt.rand}, nil
+}
+
+// Create stores a new session holding data and returns it with a freshly generated ID
+func (s *Store) Create(ctx context.Context, data []byte) (*Session, error) {
+	id, err := newID(s.rand)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	sess := &Session{
+		ID:        id,
+		Data:      data,
+		CreatedAt: now,
+		ExpiresAt: now.Add(s.lifetime),
+	}
+	if _, err = s.db.NewInsert().Model(sess).Exec(ctx); err != nil {
+		return nil, err
+	}
+
+	return sess, nil
+}
+
+// Load returns the session with the given id. Expired sessions are reported as ErrSessionNotFound.
+func (s *Store) Load(ctx context.Context, id string) (*Session, error) {
+	sess := new(Session)
+	err := s.db.NewSelect().Model(sess).
+		Where("id = ?", id).
+		Where("expires_at > ?", time.Now().UTC()).
+		Scan(ctx)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("%w: %s", ErrSessionNotFound, id)
+		}
+		return nil, err
+	}
+
+	return sess, nil
+}
+
+// Save replaces the data of an existing session and extends its expiry
+func (s *Store) Save(ctx context.Context, id string, data []byte) error {
+	now := time.Now().UTC()
+	res, err := s.db.NewUpdate().Model((*Session)(nil)).
+		Set("data = ?", data).
+		Set("expires_at = ?", now.Add(s.lifetime)).
+		Where("id = ?", id).
+		Where("expires_at > ?", now).
+		Exec(ctx)
+	return checkAffected(res, err, id)
+}
+
+// Touch extends the expiry of an existing session without changing its data
+func (s *Store) Touch(ctx context.Context, id string) error {
+	now := time.Now().UTC()
+	res, err := s.db.NewUpdate().Model((*Session)(nil)).
+		Set("expires_at = ?", now.Add(s.lifetime)).
+		Where("id = ?", id).
+		Where("expires_at > ?", now).
+		Exec(ctx)
+	return checkAffected(res, err, id)
+}
+
+// Destroy deletes the session. Destroying an unknown session is not an error.
+func (s *Store) Destroy(ctx context.Context, id string) error {
+	_, err := s.db.NewDelete().Model((*Session)(nil)).Where("id = ?", id).Exec(ctx)
+	return err
+}
+
+// GC deletes all expired sessions and returns how many were removed
+func (s *Store) GC(ctx context.Context) (int64, error) {
+	res, err := s.db.NewDelete().Model((*Session)(nil)).
+		Where("expires_at <= ?", time.Now().UTC()).
+		Exec(ctx)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+func checkAffected(res sql.Result, err error, id string) error {
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return fmt.Errorf("%w: %s", ErrSessionNotFound, id)
+	}
+	return nil
+}
+
+func newID(r io.Reader) (string, error) {
+	b := make([]byte, 32)
+	if _, err := io.ReadFull(r, b); err != nil {
+		return "", fmt.Errorf("failed to generate session id: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
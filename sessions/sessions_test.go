@@ -0,0 +1,118 @@
+package sessions
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/actanonv/dbx"
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/uptrace/bun"
+)
+
+func setupTestDB(t *testing.T) *bun.DB {
+	t.Helper()
+
+	tmp := t.TempDir()
+	if err := dbx.CreateDB("sessions", dbx.CreateWithDbFolder(tmp)); err != nil {
+		t.Fatalf("CreateDB failed: %v", err)
+	}
+	db, err := dbx.OpenDB("sessions", dbx.WithDbFolder(tmp))
+	if err != nil {
+		t.Fatalf("OpenDB failed: %v", err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+
+	return db
+}
+
+func TestStore_Lifecycle(t *testing.T) {
+	ctx := context.Background()
+	store, err := NewStore(ctx, setupTestDB(t))
+	if err != nil {
+		t.Fatalf("NewStore failed: %v", err)
+	}
+
+	sess, err := store.Create(ctx, []byte("hello"))
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+
+	got, err := store.Load(ctx, sess.ID)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if string(got.Data) != "hello" {
+		t.Fatalf("expected data %q, got %q", "hello", got.Data)
+	}
+
+	if err := store.Save(ctx, sess.ID, []byte("world")); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	if err := store.Touch(ctx, sess.ID); err != nil {
+		t.Fatalf("Touch failed: %v", err)
+	}
+	if got, err = store.Load(ctx, sess.ID); err != nil || string(got.Data) != "world" {
+		t.Fatalf("expected data %q after Save, got %v (err %v)", "world", got, err)
+	}
+
+	if err := store.Destroy(ctx, sess.ID); err != nil {
+		t.Fatalf("Destroy failed: %v", err)
+	}
+	if _, err := store.Load(ctx, sess.ID); !errors.Is(err, ErrSessionNotFound) {
+		t.Fatalf("expected ErrSessionNotFound after Destroy, got %v", err)
+	}
+	if err := store.Touch(ctx, sess.ID); !errors.Is(err, ErrSessionNotFound) {
+		t.Fatalf("expected ErrSessionNotFound on Touch after Destroy, got %v", err)
+	}
+}
+
+func TestStore_GCRemovesExpired(t *testing.T) {
+	ctx := context.Background()
+	store, err := NewStore(ctx, setupTestDB(t), WithLifetime(50*time.Millisecond))
+	if err != nil {
+		t.Fatalf("NewStore failed: %v", err)
+	}
+
+	sess, err := store.Create(ctx, nil)
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+
+	time.Sleep(100 * time.Millisecond)
+
+	if _, err := store.Load(ctx, sess.ID); !errors.Is(err, ErrSessionNotFound) {
+		t.Fatalf("expected expired session to be not found, got %v", err)
+	}
+
+	n, err := store.GC(ctx)
+	if err != nil {
+		t.Fatalf("GC failed: %v", err)
+	}
+	if n != 1 {
+		t.Fatalf("expected GC to remove 1 session, got %d", n)
+	}
+}
+
+func TestStore_WithRandReader(t *testing.T) {
+	ctx := context.Background()
+	store, err := NewStore(ctx, setupTestDB(t), WithRandReader(strings.NewReader(strings.Repeat("a", 32)+strings.Repeat("b", 32))))
+	if err != nil {
+		t.Fatalf("NewStore failed: %v", err)
+	}
+
+	for _, want := range []string{strings.Repeat("61", 32), strings.Repeat("62", 32)} {
+		sess, err := store.Create(ctx, nil)
+		if err != nil {
+			t.Fatalf("Create failed: %v", err)
+		}
+		if sess.ID != want {
+			t.Fatalf("expected id %s, got %s", want, sess.ID)
+		}
+	}
+	if _, err := store.Create(ctx, nil); err == nil {
+		t.Fatal("expected an error once the reader is exhausted")
+	}
+}
@@ -0,0 +1,179 @@
+package dbx
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"runtime"
+	"slices"
+	"strings"
+	"sync"
+	"weak"
+
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/dialect"
+)
+
+type setting struct {
+	name string
+	want string
+}
+
+// openedSettings holds the settings expected on the connections of the SQLite dbs opened by OpenDB, keyed by weak
+// pointers to their *sql.DB and deleted once it is collected
+var openedSettings sync.Map
+
+// actionPragmas run a command instead of setting a value that can be read back
+var actionPragmas = map[string]bool{"optimize": true, "shrink_memory": true, "wal_checkpoint": true,
+	"incremental_vacuum": true}
+
+// pragmaKeywords are the values reported for the keywords of the pragmas read back as numbers
+var pragmaKeywords = map[string]map[string]string{
+	"synchronous": {"off": "0", "normal": "1", "full": "2", "extra": "3"},
+	"temp_store":  {"default": "0", "file": "1", "memory": "2"},
+	"auto_vacuum": {"none": "0", "full": "1", "incremental": "2"},
+}
+
+// sqliteSettings returns the pragma values OpenDB configures on the SQLite connections opened with opt
+func sqliteSettings(opt *Options) []setting {
+	var settings []setting
+	// A read-only db keeps the journal mode of its file
+	if !opt.readOnly {
+		settings = append(settings, setting{"journal_mode", "wal"}, setting{"synchronous", "1"})
+	}
+	settings = append(settings,
+		setting{"foreign_keys", "1"},
+		setting{"busy_timeout", "5000"},
+		setting{"cache_size", "-4096"},
+		setting{"temp_store", "2"},
+	)
+
+	// WithPragma runs last and overrides them
+	for _, p := range opt.pragmas {
+		_, name, found := strings.Cut(p.name, ".")
+		if !found {
+			name = p.name
+		}
+		name = strings.ToLower(name)
+		if actionPragmas[name] {
+			continue
+		}
+		want := strings.Trim(p.value, "'")
+		lower := strings.ToLower(want)
+		if v, ok := pragmaKeywords[name][lower]; ok {
+			want = v
+		} else if name != "journal_mode" && name != "locking_mode" && name != "encoding" {
+			switch lower {
+			case "on", "true", "yes":
+				want = "1"
+			case "off", "false", "no":
+				want = "0"
+			}
+		}
+		if i := slices.IndexFunc(settings, func(s setting) bool { return s.name == p.name }); i >= 0 {
+			settings[i].want = want
+		} else {
+			settings = append(settings, setting{name: p.name, want: want})
+		}
+	}
+	return settings
+}
+
+// recordSettings remembers the settings expected on the connections of db, see VerifyConnectionSettings
+func recordSettings(db *sql.DB, opt *Options) {
+	key := weak.Make(db)
+	openedSettings.Store(key, sqliteSettings(opt))
+	runtime.AddCleanup(db, func(key weak.Pointer[sql.DB]) { openedSettings.Delete(key) }, key)
+}
+
+// expectedSettings returns the settings recorded for db by OpenDB, else those of a writable db opened by OpenDB
+func expectedSettings(db *sql.DB) []setting {
+	if settings, ok := openedSettings.Load(weak.Make(db)); ok {
+		return settings.([]setting)
+	}
+	return sqliteSettings(&Options{})
+}
+
+// SettingMismatch is a setting that does not have the expected value on one connection
+type SettingMismatch struct {
+	Conn int
+	Name string
+	Want string
+	Got  string
+}
+
+// SettingsReport is the result of VerifyConnectionSettings
+type SettingsReport struct {
+	// Connections is the number of distinct pooled connections that were checked
+	Connections int
+	Mismatches  []SettingMismatch
+}
+
+// OK reports whether every checked connection had the expected settings
+func (r SettingsReport) OK() bool {
+	return len(r.Mismatches) == 0
+}
+
+func (r SettingsReport) String() string {
+	if r.OK() {
+		return fmt.Sprintf("%d connections ok", r.Connections)
+	}
+	parts := make([]string, 0, len(r.Mismatches))
+	for _, m := range r.Mismatches {
+		parts = append(parts, fmt.Sprintf("conn %d: %s = %s, want %s", m.Conn, m.Name, m.Got, m.Want))
+	}
+	return fmt.Sprintf("%d connections, %d mismatches: %s", r.Connections, len(r.Mismatches), strings.Join(parts, "; "))
+}
+
+// VerifyConnectionSettings checks the pragmas configured by OpenDB on every pooled connection: its defaults, as
+// changed by WithPragma and WithReadOnly (WithImmutable, OpenEmbeddedDB).
+// Connection-level settings applied with a plain Exec only reach the one connection that ran it, so this
+// holds as many connections as the pool allows at once, forcing each one to be distinct, and checks them all.
+// Only SQLite is supported.
+func VerifyConnectionSettings(ctx context.Context, db *bun.DB) (SettingsReport, error) {
+	var report SettingsReport
+	if dName := db.Dialect().Name(); dName != dialect.SQLite {
+		return report, fmt.Errorf("unsupported dialect: %s", dName)
+	}
+
+	stats := db.Stats()
+	n := stats.MaxOpenConnections
+	if n <= 0 {
+		n = max(stats.OpenConnections, 1)
+	}
+
+	conns := make([]bun.Conn, 0, n)
+	defer func() {
+		for _, conn := range conns {
+			_ = conn.Close()
+		}
+	}()
+	for i := 0; i < n; i++ {
+		conn, err := db.Conn(ctx)
+		if err != nil {
+			return report, fmt.Errorf("failed to get connection %d: %w", i, err)
+		}
+		conns = append(conns, conn)
+	}
+
+	settings := expectedSettings(db.DB)
+	for i, conn := range conns {
+		for _, setting := range settings {
+			var got string
+			if err := conn.QueryRowContext(ctx, "PRAGMA "+setting.name).Scan(&got); err != nil {
+				return report, fmt.Errorf("failed to read %s on connection %d: %w", setting.name, i, err)
+			}
+			if !strings.EqualFold(got, setting.want) {
+				report.Mismatches = append(report.Mismatches, SettingMismatch{
+					Conn: i,
+					Name: setting.name,
+					Want: setting.want,
+					Got:  got,
+				})
+			}
+		}
+	}
+	report.Connections = len(conns)
+
+	return report, nil
+}
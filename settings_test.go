@@ -0,0 +1,56 @@
+package dbx
+
+import (
+	"context"
+	"testing"
+)
+
+func TestVerifyConnectionSettings(t *testing.T) {
+	db := setupTestDB(t)
+	ctx := context.Background()
+
+	report, err := VerifyConnectionSettings(ctx, db)
+	if err != nil {
+		t.Fatalf("VerifyConnectionSettings failed: %v", err)
+	}
+	if !report.OK() || report.Connections != 1 {
+		t.Fatalf("expected 1 connection without mismatches, got %s", report)
+	}
+
+	if _, err := db.ExecContext(ctx, "PRAGMA foreign_keys = OFF"); err != nil {
+		t.Fatalf("PRAGMA failed: %v", err)
+	}
+	if report, err = VerifyConnectionSettings(ctx, db); err != nil {
+		t.Fatalf("VerifyConnectionSettings failed: %v", err)
+	}
+	if len(report.Mismatches) != 1 || report.Mismatches[0].Name != "foreign_keys" || report.Mismatches[0].Got != "0" {
+		t.Fatalf("expected foreign_keys mismatch, got %s", report)
+	}
+}
+
+func TestVerifyConnectionSettings_Options(t *testing.T) {
+	tmp := t.TempDir()
+	ctx := context.Background()
+	if err := CreateDB("settingstest", CreateWithDbFolder(tmp)); err != nil {
+		t.Fatalf("CreateDB failed: %v", err)
+	}
+
+	for name, opts := range map[string][]OpenOptFn{
+		"pragmas":   {WithPragma("synchronous", "FULL"), WithPragma("cache_size", "-8192"), WithPragma("recursive_triggers", "ON")},
+		"read-only": {WithReadOnly()},
+		"immutable": {WithImmutable()},
+	} {
+		db, err := OpenDB("settingstest", append(opts, WithDbFolder(tmp))...)
+		if err != nil {
+			t.Fatalf("%s: OpenDB failed: %v", name, err)
+		}
+		report, err := VerifyConnectionSettings(ctx, db)
+		_ = db.Close()
+		if err != nil {
+			t.Fatalf("%s: VerifyConnectionSettings failed: %v", name, err)
+		}
+		if !report.OK() {
+			t.Errorf("%s: expected no mismatches, got %s", name, report)
+		}
+	}
+}
@@ -0,0 +1,141 @@
+package dbx
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"sync"
+
+	"github.com/uptrace/bun"
+)
+
+var ErrShardSchemaMismatch = errors.New("shards are on different schema versions")
+
+// ShardRouter spreads keys over a fixed list of databases. A key is hashed (FNV-1a) to one shard, so the shard list
+// must keep its order and length for the life of the data: adding a shard remaps most keys.
+// Shards are opened through the cache, so idle shards are closed and reopened on demand.
+type ShardRouter struct {
+	cache    *Cache
+	shards   []string
+	openOpts []OpenOptFn
+}
+
+// NewShardRouter returns a router over the databases named shards, opened from cache with openOpts
+func NewShardRouter(cache *Cache, shards []string, openOpts ...OpenOptFn) (*ShardRouter, error) {
+	if cache == nil {
+		return nil, errors.New("dbx: NewShardRouter with nil cache")
+	}
+	if len(shards) == 0 {
+		return nil, errors.New("dbx: NewShardRouter without shards")
+	}
+	return &ShardRouter{cache: cache, shards: shards, openOpts: openOpts}, nil
+}
+
+// Shards returns the names of the shard databases
+func (r *ShardRouter) Shards() []string {
+	return r.shards
+}
+
+// ShardFor returns the index of the shard holding key
+func (r *ShardRouter) ShardFor(key string) int {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(key))
+	return int(h.Sum64() % uint64(len(r.shards)))
+}
+
+// ForKey returns the database of the shard holding key
+func (r *ShardRouter) ForKey(ctx context.Context, key string) (bun.IDB, error) {
+	return r.shard(ctx, r.ShardFor(key))
+}
+
+func (r *ShardRouter) shard(ctx context.Context, i int) (*bun.DB, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	db, err := r.cache.GetOrOpen(r.shards[i], r.openOpts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open shard %s: %w", r.shards[i], err)
+	}
+	return db, nil
+}
+
+// FanOut runs fn on every shard concurrently and returns the errors of all shards joined
+func (r *ShardRouter) FanOut(ctx context.Context, fn func(ctx context.Context, shard int, db bun.IDB) error) error {
+	errs := make([]error, len(r.shards))
+	var wg sync.WaitGroup
+	for i := range r.shards {
+		wg.Go(func() {
+			db, err := r.shard(ctx, i)
+			if err == nil {
+				err = fn(ctx, i, db)
+			}
+			if err != nil {
+				errs[i] = fmt.Errorf("shard %s: %w", r.shards[i], err)
+			}
+		})
+	}
+	wg.Wait()
+	return errors.Join(errs...)
+}
+
+// FanOutQuery runs query on every shard concurrently and returns the rows of all shards, in shard order
+func FanOutQuery[T any](ctx context.Context, r *ShardRouter, query func(ctx context.Context, db bun.IDB) ([]T, error)) ([]T, error) {
+	results := make([][]T, len(r.shards))
+	err := r.FanOut(ctx, func(ctx context.Context, shard int, db bun.IDB) error {
+		rows, err := query(ctx, db)
+		results[shard] = rows
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var merged []T
+	for _, rows := range results {
+		merged = append(merged, rows...)
+	}
+	return merged, nil
+}
+
+// Migrate creates and migrates every shard with opts (see CreateDBContext), one after the other, then checks that
+// all shards ended on the same schema version
+func (r *ShardRouter) Migrate(ctx context.Context, opts ...CreateOptFn) error {
+	for _, name := range r.shards {
+		if err := CreateDBContext(ctx, name, opts...); err != nil {
+			return fmt.Errorf("failed to migrate shard %s: %w", name, err)
+		}
+	}
+
+	option := CreateOptions{}
+	setCreateOptions(&option, opts...)
+	if option.source == nil {
+		return nil
+	}
+	_, err := r.SchemaVersions(ctx, option.versionTable)
+	return err
+}
+
+// SchemaVersions returns the migration version of every shard, read from versionTable (goose_db_version when empty).
+// It fails with ErrShardSchemaMismatch, along with the versions, when they differ.
+func (r *ShardRouter) SchemaVersions(ctx context.Context, versionTable string) ([]int64, error) {
+	if versionTable == "" {
+		versionTable = "goose_db_version"
+	}
+
+	versions := make([]int64, len(r.shards))
+	err := r.FanOut(ctx, func(ctx context.Context, shard int, db bun.IDB) error {
+		return db.NewRaw("SELECT COALESCE(MAX(version_id), 0) FROM ? WHERE is_applied", bun.Ident(versionTable)).
+			Scan(ctx, &versions[shard])
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for i, v := range versions {
+		if v != versions[0] {
+			return versions, fmt.Errorf("%w: %s is at %d, %s at %d", ErrShardSchemaMismatch, r.shards[0], versions[0], r.shards[i], v)
+		}
+	}
+	return versions, nil
+}
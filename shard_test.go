@@ -0,0 +1,76 @@
+package dbx
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/uptrace/bun"
+)
+
+func TestShardRouter(t *testing.T) {
+	ctx := context.Background()
+	tmp := t.TempDir()
+	cache := NewCache(time.Minute)
+	t.Cleanup(func() { _ = cache.Close() })
+
+	router, err := NewShardRouter(cache, []string{"shard0", "shard1", "shard2"}, WithDbFolder(tmp))
+	if err != nil {
+		t.Fatalf("NewShardRouter failed: %v", err)
+	}
+	if err := router.Migrate(ctx, CreateWithDbFolder(tmp), CreateWithSource(testMigrations),
+		CreateWithSrcFolder("testmigrations"), CreateWithLogger(nil)); err != nil {
+		t.Fatalf("Migrate failed: %v", err)
+	}
+
+	keys := make([]string, 30)
+	for i := range keys {
+		keys[i] = fmt.Sprintf("user-%d", i)
+		if router.ShardFor(keys[i]) != router.ShardFor(keys[i]) {
+			t.Fatalf("expected stable shard for %s", keys[i])
+		}
+		db, err := router.ForKey(ctx, keys[i])
+		if err != nil {
+			t.Fatalf("ForKey failed: %v", err)
+		}
+		insertItem(t, db, keys[i])
+	}
+
+	names, err := FanOutQuery(ctx, router, func(ctx context.Context, db bun.IDB) ([]string, error) {
+		var names []string
+		err := db.NewRaw("SELECT name FROM items").Scan(ctx, &names)
+		return names, err
+	})
+	if err != nil {
+		t.Fatalf("FanOutQuery failed: %v", err)
+	}
+	if len(names) != len(keys) {
+		t.Fatalf("expected %d rows across shards, got %d", len(keys), len(names))
+	}
+
+	// Keys are spread, not all on one shard
+	counts := make([]int, len(router.Shards()))
+	for _, key := range keys {
+		counts[router.ShardFor(key)]++
+	}
+	for i, n := range counts {
+		if n == 0 {
+			t.Fatalf("expected keys on shard %d", i)
+		}
+	}
+
+	// A shard with an extra migration is reported
+	extra := fstest.MapFS{
+		"m/00001_create_items.sql": {Data: []byte("-- +goose Up\nCREATE TABLE IF NOT EXISTS items (id INTEGER PRIMARY KEY, name TEXT);\n")},
+		"m/00002_create_tags.sql":  {Data: []byte("-- +goose Up\nCREATE TABLE tags (id INTEGER PRIMARY KEY);\n")},
+	}
+	if err := MigrateDB("shard1", CreateWithDbFolder(tmp), CreateWithSource(extra), CreateWithSrcFolder("m"), CreateWithLogger(nil)); err != nil {
+		t.Fatalf("MigrateDB failed: %v", err)
+	}
+	if _, err := router.SchemaVersions(ctx, ""); !errors.Is(err, ErrShardSchemaMismatch) {
+		t.Fatalf("expected ErrShardSchemaMismatch, got %v", err)
+	}
+}
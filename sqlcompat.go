@@ -0,0 +1,65 @@
+package dbx
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/uptrace/bun"
+)
+
+// SQLExecutor is the part of *sql.DB and *sql.Tx that code written against database/sql usually depends on
+type SQLExecutor interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+var (
+	_ SQLExecutor = (*sql.DB)(nil)
+	_ SQLExecutor = (*sql.Tx)(nil)
+)
+
+// AsSQLDB adapts idb for legacy database/sql code, e.g. AsSQLDB(t.Db()) runs it inside the current transaction or
+// savepoint of a Transact. Statements go straight to the driver: they use its placeholders ($1 on Postgres) and
+// bypass bun's formatting and query hooks.
+func AsSQLDB(idb bun.IDB) SQLExecutor {
+	switch db := idb.(type) {
+	case *bun.DB:
+		return db.DB
+	case bun.Tx:
+		return db.Tx
+	case *bun.Tx:
+		return db.Tx
+	case bun.Conn:
+		return db.Conn
+	case *bun.Conn:
+		return db.Conn
+	}
+	// Other implementations go through bun, which formats ? placeholders itself
+	return idb
+}
+
+// SQLTx returns the *sql.Tx underlying idb, and false when idb is not a transaction.
+// A savepoint shares the *sql.Tx of its outermost transaction; statements on it run in the current savepoint.
+func SQLTx(idb bun.IDB) (*sql.Tx, bool) {
+	switch tx := idb.(type) {
+	case bun.Tx:
+		return tx.Tx, tx.Tx != nil
+	case *bun.Tx:
+		return tx.Tx, tx.Tx != nil
+	}
+	return nil, false
+}
+
+// SQLDB returns the *sql.DB of the Transact, for code such as sqlc-generated queries that needs the raw pool
+func (t *Transact) SQLDB() *sql.DB {
+	return t.db.DB
+}
+
+// SQLTx returns the *sql.Tx of the active transaction, or nil outside of one, so that code written against
+// database/sql (sqlc, GORM with an existing connection) shares the transaction of the Transact.
+// Never commit or roll it back directly: end it through the Transact, which also tracks savepoints and hooks.
+func (t *Transact) SQLTx() *sql.Tx {
+	tx, _ := SQLTx(t.Db())
+	return tx
+}
@@ -0,0 +1,82 @@
+package dbx
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+// legacyInsert stands for code written against database/sql
+func legacyInsert(ctx context.Context, db SQLExecutor, name string) error {
+	_, err := db.ExecContext(ctx, "INSERT INTO items(name) VALUES (?)", name)
+	return err
+}
+
+var errUndo = errors.New("undo")
+
+func TestAsSQLDB(t *testing.T) {
+	ctx := context.Background()
+	db := setupTestDB(t)
+	tx := mustNewTx(t, db)
+
+	if err := legacyInsert(ctx, AsSQLDB(tx.Db()), "a"); err != nil {
+		t.Fatalf("insert without tx failed: %v", err)
+	}
+
+	// Inside the transaction, rolled back with it
+	err := tx.Transaction(nil, func(ctx context.Context) error {
+		if err := legacyInsert(ctx, AsSQLDB(tx.Db()), "b"); err != nil {
+			return err
+		}
+		var n int
+		if err := AsSQLDB(tx.Db()).QueryRowContext(ctx, "SELECT COUNT(*) FROM items").Scan(&n); err != nil {
+			return err
+		}
+		if n != 2 {
+			t.Errorf("expected the legacy insert to be visible in the tx, got %d items", n)
+		}
+		return errUndo
+	})
+	if !errors.Is(err, errUndo) {
+		t.Fatalf("expected errUndo, got %v", err)
+	}
+	if got := countItems(t, db); got != 1 {
+		t.Fatalf("expected the insert in the tx to be rolled back, got %d items", got)
+	}
+}
+
+func TestSQLTx(t *testing.T) {
+	db := setupTestDB(t)
+	tx := mustNewTx(t, db)
+
+	if tx.SQLDB() != db.DB {
+		t.Fatalf("expected the pool of the db")
+	}
+	if tx.SQLTx() != nil {
+		t.Fatalf("expected no *sql.Tx outside of a transaction")
+	}
+	if _, ok := SQLTx(db); ok {
+		t.Fatalf("expected SQLTx of a *bun.DB to report false")
+	}
+
+	err := tx.Transaction(nil, func(ctx context.Context) error {
+		outer := tx.SQLTx()
+		if outer == nil {
+			t.Fatalf("expected the *sql.Tx of the transaction")
+		}
+		return tx.Transaction(nil, func(ctx context.Context) error {
+			// sqlc-style code sharing the savepoint
+			if tx.SQLTx() != outer {
+				t.Errorf("expected the savepoint to share the *sql.Tx")
+			}
+			_, err := tx.SQLTx().ExecContext(ctx, "INSERT INTO items(name) VALUES (?)", "a")
+			return err
+		})
+	})
+	if err != nil {
+		t.Fatalf("Transaction failed: %v", err)
+	}
+	if got := countItems(t, db); got != 1 {
+		t.Fatalf("expected 1 item, got %d", got)
+	}
+}
@@ -0,0 +1,314 @@
+package dbx
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync/atomic"
+)
+
+// StatementClass is a set of classes of statements, blocked by WithStatementPolicy
+type StatementClass uint8
+
+const (
+	// StmtDDL: CREATE, ALTER, DROP, TRUNCATE, RENAME, GRANT, REVOKE, COMMENT, and SELECT ... INTO
+	StmtDDL StatementClass = 1 << iota
+	// StmtWrite: INSERT, UPDATE, DELETE, REPLACE, MERGE, COPY and LOAD, data-modifying CTEs included
+	StmtWrite
+	// StmtUnboundedWrite: UPDATE and DELETE without WHERE, and TRUNCATE
+	StmtUnboundedWrite
+	// StmtAttach: ATTACH and DETACH of SQLite databases
+	StmtAttach
+	// StmtPragma: SQLite PRAGMA, reading or setting
+	StmtPragma
+	// StmtOther: the statements of no other class but transaction control, e.g. SET, VACUUM, CALL, and the
+	// statements calling a function known for its side effects, e.g. set_config, setval or pg_terminate_backend
+	StmtOther
+
+	// StmtAllWrites is every class: blocked, it leaves a handle running selects and transaction control. The functions
+	// with side effects a select may call are only blocked when known (see StmtOther): the list is not exhaustive,
+	// so pair it with WithReadOnly, or a role without privileges, to rule out every write.
+	StmtAllWrites = StmtDDL | StmtWrite | StmtUnboundedWrite | StmtAttach | StmtPragma | StmtOther
+)
+
+var statementClassNames = [...]string{"ddl", "write", "unbounded_write", "attach", "pragma", "other"}
+
+func (c StatementClass) String() string {
+	var names []string
+	for i, name := range statementClassNames {
+		if c&(1<<i) != 0 {
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 {
+		return "none"
+	}
+	return strings.Join(names, "|")
+}
+
+// ErrStatementBlocked fails the statements blocked by WithStatementPolicy. It wraps ErrPolicyDenied.
+var ErrStatementBlocked = fmt.Errorf("%w: statement blocked", ErrPolicyDenied)
+
+// WithStatementPolicy makes the db fail with ErrStatementBlocked the statements of the blocked classes, e.g.
+// StmtDDL|StmtUnboundedWrite|StmtAttach, for the handle given to semi-trusted code such as plugins; open the other
+// handles of the database without it. Every statement of the db is checked, raw SQL and each statement of
+// a multi-statement string included, by its keywords outside of literals and comments. The setup of OpenDB is not.
+func WithStatementPolicy(blocked StatementClass) OpenOptFn {
+	return func(opt *Options) {
+		opt.statementPolicy = &statementPolicy{blocked: blocked}
+	}
+}
+
+type statementPolicy struct {
+	blocked StatementClass
+	// backslash is set for MySQL, whose strings escape quotes with backslashes
+	backslash bool
+	enabled   atomic.Bool
+}
+
+// statementPolicyProblems checks the classes of WithStatementPolicy
+func statementPolicyProblems(opt *Options) []error {
+	if p := opt.statementPolicy; p != nil && (p.blocked == 0 || p.blocked&^StmtAllWrites != 0) {
+		return []error{fmt.Errorf("%w: invalid blocked statement classes %#x", ErrInvalidOptions, uint8(p.blocked))}
+	}
+	return nil
+}
+
+// check is the check of the connections of WithStatementPolicy
+func (p *statementPolicy) check(_ context.Context, query string) error {
+	if !p.enabled.Load() {
+		return nil
+	}
+	for _, stmt := range sqlStatements(query, p.backslash) {
+		if class := classifyStatement(stmt) & p.blocked; class != 0 {
+			return fmt.Errorf("%w: %s statement %s", ErrStatementBlocked, class, statementText(stmt))
+		}
+	}
+	return nil
+}
+
+// sqlWord is a keyword or identifier of a statement, upper-cased
+type sqlWord struct {
+	word string
+	// group numbers the innermost parentheses around the word, 0 outside of any
+	group int
+	// open is set for the first token after an opening parenthesis
+	open bool
+	// call is set for a word followed by an opening parenthesis, e.g. a function name
+	call bool
+}
+
+// sqlStatements splits query into its statements, as their words outside of literals and comments
+func sqlStatements(query string, backslash bool) [][]sqlWord {
+	var (
+		stmts  [][]sqlWord
+		stmt   []sqlWord
+		groups []int
+		next   int
+		open   bool
+	)
+	for i := 0; i < len(query); {
+		c := query[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f':
+			i++
+			continue
+		case c == '-' && strings.HasPrefix(query[i:], "--"), c == '#' && backslash:
+			i = skipPast(query, i, "\n")
+		case c == '/' && backslash && strings.HasPrefix(query[i:], "/*!"):
+			// MySQL runs the content of /*! ... */ comments
+			i += 3
+		case c == '/' && strings.HasPrefix(query[i:], "/*"):
+			i = skipPast(query, i+1, "*/")
+		case c == '\'':
+			i = skipQuoted(query, i, backslash)
+		case c == '"':
+			// A string on MySQL, an identifier elsewhere
+			i = skipQuoted(query, i, backslash)
+		case c == '`':
+			i = skipQuoted(query, i, false)
+		case c == '[':
+			i = skipPast(query, i, "]")
+		case c == '$':
+			i = skipDollar(query, i)
+		case c == '(':
+			next++
+			groups = append(groups, next)
+			i++
+			open = true
+			continue
+		case c == ')':
+			if len(groups) > 0 {
+				groups = groups[:len(groups)-1]
+			}
+			i++
+		case c == ';':
+			// Even in unbalanced parentheses, for the driver may run what follows
+			if len(stmt) > 0 {
+				stmts = append(stmts, stmt)
+			}
+			stmt, groups = nil, nil
+			i++
+		case isWordByte(c):
+			j := i
+			for j < len(query) && (isWordByte(query[j]) || isDigit(query[j])) {
+				j++
+			}
+			word := strings.ToUpper(query[i:j])
+			// E'...' strings of Postgres escape with backslashes
+			if word == "E" && j < len(query) && query[j] == '\'' {
+				i = skipQuoted(query, j, true)
+				break
+			}
+			k := j
+			for k < len(query) && (query[k] == ' ' || query[k] == '\t' || query[k] == '\n' || query[k] == '\r') {
+				k++
+			}
+			w := sqlWord{word: word, open: open, call: k < len(query) && query[k] == '('}
+			if len(groups) > 0 {
+				w.group = groups[len(groups)-1]
+			}
+			stmt = append(stmt, w)
+			i = j
+		default:
+			i++
+		}
+		open = false
+	}
+	if len(stmt) > 0 {
+		stmts = append(stmts, stmt)
+	}
+	return stmts
+}
+
+func isWordByte(c byte) bool {
+	return c >= 'A' && c <= 'Z' || c >= 'a' && c <= 'z' || c == '_' || c >= 0x80
+}
+
+// skipPast returns the index after the end of the first end in query from i, len(query) without any
+func skipPast(query string, i int, end string) int {
+	if n := strings.Index(query[i+1:], end); n >= 0 {
+		return i + 1 + n + len(end)
+	}
+	return len(query)
+}
+
+// skipDollar returns the index after the Postgres dollar-quoted literal at i, or after the $ of a parameter
+func skipDollar(query string, i int) int {
+	j := i + 1
+	for j < len(query) && (isWordByte(query[j]) || j > i+1 && isDigit(query[j])) {
+		j++
+	}
+	if j >= len(query) || query[j] != '$' {
+		return i + 1
+	}
+	return skipPast(query, j, query[i:j+1])
+}
+
+// classifyStatement returns the classes of stmt, 0 for reads and transaction control
+func classifyStatement(stmt []sqlWord) StatementClass {
+	verb, at := stmt[0].word, 0
+	if verb == "EXPLAIN" || verb == "WITH" {
+		// The statement explained, which EXPLAIN ANALYZE runs, or the main one of the CTEs
+		verb = ""
+		for i, w := range stmt[1:] {
+			if w.group == 0 && isVerb(w.word) {
+				verb, at = w.word, i+1
+				break
+			}
+		}
+	}
+
+	var class StatementClass
+	switch verb {
+	case "", "SELECT", "VALUES", "TABLE", "SHOW", "DESCRIBE", "DESC", "BEGIN", "START", "COMMIT", "END", "ROLLBACK",
+		"SAVEPOINT", "RELEASE":
+		if verb == "SELECT" && hasWord(stmt[at:], 0, "INTO") {
+			class = StmtDDL
+		}
+	case "INSERT", "REPLACE", "UPSERT", "MERGE", "COPY", "LOAD":
+		class = StmtWrite
+	case "UPDATE", "DELETE":
+		class = StmtWrite
+		if !hasWord(stmt[at:], 0, "WHERE") {
+			class |= StmtUnboundedWrite
+		}
+	case "TRUNCATE":
+		class = StmtDDL | StmtUnboundedWrite
+	case "CREATE", "ALTER", "DROP", "RENAME", "GRANT", "REVOKE", "COMMENT":
+		class = StmtDDL
+	case "ATTACH", "DETACH":
+		class = StmtAttach
+	case "PRAGMA":
+		class = StmtPragma
+	default:
+		class = StmtOther
+	}
+	// Data-modifying subqueries, e.g. WITH d AS (DELETE ... RETURNING id) SELECT ...
+	for i, w := range stmt {
+		if w.call && sideEffectFuncs[w.word] {
+			class |= StmtOther
+		}
+		if !w.open {
+			continue
+		}
+		switch w.word {
+		case "INSERT", "MERGE":
+			class |= StmtWrite
+		case "UPDATE", "DELETE":
+			class |= StmtWrite
+			if !hasWord(stmt[i:], w.group, "WHERE") {
+				class |= StmtUnboundedWrite
+			}
+		}
+	}
+	return class
+}
+
+// sideEffectFuncs are the functions changing the state of the server, a session or files, which a select can call
+var sideEffectFuncs = map[string]bool{
+	// Postgres
+	"SET_CONFIG": true, "SETVAL": true, "NEXTVAL": true, "PG_TERMINATE_BACKEND": true, "PG_CANCEL_BACKEND": true,
+	"PG_RELOAD_CONF": true, "PG_ROTATE_LOGFILE": true, "PG_ADVISORY_LOCK": true, "PG_ADVISORY_XACT_LOCK": true,
+	"PG_TRY_ADVISORY_LOCK": true, "PG_ADVISORY_UNLOCK_ALL": true, "PG_NOTIFY": true, "LO_IMPORT": true,
+	"LO_EXPORT": true, "LO_UNLINK": true, "LO_CREATE": true, "LO_FROM_BYTEA": true, "LO_PUT": true,
+	"PG_WRITE_FILE": true, "PG_SWITCH_WAL": true,
+	"PG_CREATE_RESTORE_POINT": true, "PG_DROP_REPLICATION_SLOT": true, "PG_CREATE_LOGICAL_REPLICATION_SLOT": true,
+	"PG_CREATE_PHYSICAL_REPLICATION_SLOT": true, "DBLINK_EXEC": true, "DBLINK": true,
+	// MySQL
+	"GET_LOCK": true, "RELEASE_LOCK": true, "RELEASE_ALL_LOCKS": true,
+	// SQLite
+	"LOAD_EXTENSION": true, "WRITEFILE": true, "EDIT": true, "FTS3_TOKENIZER": true,
+}
+
+func isVerb(word string) bool {
+	switch word {
+	case "SELECT", "VALUES", "TABLE", "INSERT", "REPLACE", "UPDATE", "DELETE", "MERGE":
+		return true
+	}
+	return false
+}
+
+// hasWord reports whether word is in stmt, in the parentheses of group
+func hasWord(stmt []sqlWord, group int, word string) bool {
+	for _, w := range stmt {
+		if w.group == group && w.word == word {
+			return true
+		}
+	}
+	return false
+}
+
+// statementText renders the first words of stmt for errors, without its literals
+func statementText(stmt []sqlWord) string {
+	const maxWords = 6
+	words := make([]string, 0, maxWords+1)
+	for i, w := range stmt {
+		if i == maxWords {
+			words = append(words, "...")
+			break
+		}
+		words = append(words, w.word)
+	}
+	return strings.Join(words, " ")
+}
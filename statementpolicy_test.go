@@ -0,0 +1,107 @@
+package dbx
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestClassifyStatements(t *testing.T) {
+	for _, tc := range []struct {
+		query     string
+		backslash bool
+		want      StatementClass
+	}{
+		{"SELECT * FROM items WHERE name = 'DROP TABLE items'", false, 0},
+		{"select replace(name, 'a', 'b') from items", false, 0},
+		{"BEGIN; SAVEPOINT sp1; RELEASE sp1; COMMIT", false, 0},
+		{"EXPLAIN QUERY PLAN SELECT 1", false, 0},
+		{"INSERT INTO items (name) VALUES ('x')", false, StmtWrite},
+		{"UPDATE items SET name = 'x' WHERE id = 1", false, StmtWrite},
+		{"DELETE FROM items", false, StmtWrite | StmtUnboundedWrite},
+		{"DELETE FROM items WHERE id IN (SELECT id FROM items)", false, StmtWrite},
+		{"UPDATE items SET n = (SELECT 1 WHERE 1)", false, StmtWrite | StmtUnboundedWrite},
+		{"WITH old AS (SELECT id FROM items) DELETE FROM items WHERE id IN (SELECT id FROM old)", false, StmtWrite},
+		{"WITH gone AS (DELETE FROM items RETURNING id) SELECT count(*) FROM gone", false, StmtWrite | StmtUnboundedWrite},
+		{"WITH gone AS (DELETE FROM items WHERE id = 1 RETURNING id) SELECT * FROM gone", false, StmtWrite},
+		{"SELECT (DELETE FROM a), (SELECT 1 WHERE 1)", false, StmtWrite | StmtUnboundedWrite},
+		{"EXPLAIN ANALYZE DELETE FROM items", false, StmtWrite | StmtUnboundedWrite},
+		{"TRUNCATE items", false, StmtDDL | StmtUnboundedWrite},
+		{"SELECT * INTO copy FROM items", false, StmtDDL},
+		{"create table t (id int)", false, StmtDDL},
+		{"ATTACH DATABASE 'other.db' AS other", false, StmtAttach},
+		{"PRAGMA journal_mode", false, StmtPragma},
+		{"VACUUM INTO '/tmp/copy.db'", false, StmtOther},
+		{"SELECT 1; DROP TABLE items", false, StmtDDL},
+		{"SELECT (1; DROP TABLE items", false, StmtDDL},
+		{"SELECT 1 -- ; DROP TABLE items", false, 0},
+		{"SELECT 1 /* ; DROP TABLE items */", false, 0},
+		{`SELECT "a;b", [c;d] FROM items`, false, 0},
+		{"SELECT $$ ; DROP TABLE items $$, $1", false, 0},
+		{`SELECT 'a\'; DROP TABLE items; --'`, false, StmtDDL},
+		{`SELECT 'a\'; DROP TABLE items; --'`, true, 0},
+		{`SELECT 'a\''; DROP TABLE items; -- '`, true, StmtDDL},
+		{"SELECT 1 /*!50000 ; DROP TABLE items */", true, StmtDDL},
+		{`UPDATE t SET a = "\" WHERE "`, true, StmtWrite | StmtUnboundedWrite},
+		{`SELECT "\"" ; DROP TABLE t; -- "`, true, StmtDDL},
+		{`SELECT "a\" FROM t WHERE 1; DROP TABLE t`, false, StmtDDL},
+		{"SELECT set_config('default_transaction_read_only', 'off', false)", false, StmtOther},
+		{"SELECT setval ('seq', 1), pg_terminate_backend(42)", false, StmtOther},
+		{"SELECT load_extension('evil')", false, StmtOther},
+		{"SELECT sleep FROM naps", false, 0},
+	} {
+		var got StatementClass
+		for _, stmt := range sqlStatements(tc.query, tc.backslash) {
+			got |= classifyStatement(stmt)
+		}
+		if got != tc.want {
+			t.Errorf("%q (backslash %v): expected %s, got %s", tc.query, tc.backslash, tc.want, got)
+		}
+	}
+}
+
+func TestWithStatementPolicy(t *testing.T) {
+	ctx := context.Background()
+	tmp := t.TempDir()
+	rw, err := OpenDB("plugins", WithDbFolder(tmp), WithCreateIfMissing())
+	if err != nil {
+		t.Fatalf("OpenDB failed: %v", err)
+	}
+	defer rw.Close()
+	if _, err := rw.ExecContext(ctx, "CREATE TABLE items (id INTEGER PRIMARY KEY, name TEXT)"); err != nil {
+		t.Fatalf("create table failed: %v", err)
+	}
+
+	// The pragmas of the setup of OpenDB are not blocked
+	db, err := OpenDB("plugins", WithDbFolder(tmp),
+		WithStatementPolicy(StmtDDL|StmtUnboundedWrite|StmtAttach|StmtPragma))
+	if err != nil {
+		t.Fatalf("OpenDB failed: %v", err)
+	}
+	defer db.Close()
+
+	if _, err := db.ExecContext(ctx, "INSERT INTO items (name) VALUES ('a'), ('b')"); err != nil {
+		t.Fatalf("insert failed: %v", err)
+	}
+	if _, err := db.NewDelete().Table("items").Where("name = ?", "a").Exec(ctx); err != nil {
+		t.Fatalf("delete with where failed: %v", err)
+	}
+	for _, query := range []string{
+		"DELETE FROM items",
+		"DROP TABLE items",
+		"SELECT 1; DROP TABLE items",
+		"ATTACH DATABASE ':memory:' AS other",
+		"PRAGMA foreign_keys = off",
+	} {
+		if _, err := db.ExecContext(ctx, query); !errors.Is(err, ErrStatementBlocked) || !errors.Is(err, ErrPolicyDenied) {
+			t.Errorf("%s: expected ErrStatementBlocked, got %v", query, err)
+		}
+	}
+	if n, err := db.NewSelect().Table("items").Count(ctx); err != nil || n != 1 {
+		t.Fatalf("expected 1 item left, got %d, %v", n, err)
+	}
+
+	if err := ValidateOptions(WithStatementPolicy(0)); !errors.Is(err, ErrInvalidOptions) {
+		t.Fatalf("expected no blocked classes to be invalid, got %v", err)
+	}
+}
@@ -0,0 +1,368 @@
+// Package sync keeps tables of two or more SQLite databases in sync.
+//
+// Track installs triggers recording every change of a tracked table in the dbx_sync_rows shadow table: a full JSON
+// copy of the row (or a tombstone for deletes), a Lamport version and the id of the site that made the change.
+// Changes produces a Changeset of the rows changed after a local sequence number, and Apply merges a Changeset into
+// another database, resolving concurrent edits of the same row with a Resolver (last write wins by default).
+// Sync does both directions between two databases and remembers how far each side has seen the other.
+//
+// Tracked tables need an `id` primary key that never changes, and no BLOB columns.
+package sync
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/dialect"
+)
+
+// RandReader is the source of the site ids generated by Track. Tests may replace it for stable ids.
+var RandReader io.Reader = rand.Reader
+
+// Row is a single row of the dbx_sync_rows shadow table
+type Row struct {
+	bun.BaseModel `bun:"table:dbx_sync_rows"`
+
+	Table   string          `bun:"table_name,pk" json:"table"`
+	RowID   string          `bun:"row_id,pk" json:"row_id"`
+	Seq     int64           `bun:"seq,notnull" json:"-"`
+	Version int64           `bun:"version,notnull" json:"version"`
+	Site    string          `bun:"site,notnull" json:"site"`
+	Deleted bool            `bun:"deleted,notnull" json:"deleted"`
+	Data    json.RawMessage `bun:"data,type:text" json:"data,omitempty"`
+}
+
+// newer reports whether r was written after other, ordering by version then site
+func (r Row) newer(other Row) bool {
+	if r.Version != other.Version {
+		return r.Version > other.Version
+	}
+	return r.Site > other.Site
+}
+
+// Changeset is a batch of row changes produced by Changes and consumed by Apply
+type Changeset struct {
+	Site string `json:"site"`
+	// Seq is the highest local sequence number included, to pass as since to the next Changes call
+	Seq  int64 `json:"seq"`
+	Rows []Row `json:"rows"`
+}
+
+// Resolver decides whether a remote row replaces the local one when both exist with different versions
+type Resolver func(local, remote Row) (useRemote bool)
+
+// LastWriteWins keeps the row with the higher version, breaking ties by site id
+func LastWriteWins(local, remote Row) bool {
+	return remote.newer(local)
+}
+
+type state struct {
+	bun.BaseModel `bun:"table:dbx_sync_state"`
+
+	Key   string `bun:"key,pk"`
+	Value string `bun:"value,notnull"`
+}
+
+type peer struct {
+	bun.BaseModel `bun:"table:dbx_sync_peers"`
+
+	Site string `bun:"site,pk"`
+	Seq  int64  `bun:"seq,notnull"`
+}
+
+// Track creates the sync tables, assigns the database a site id on first use and installs change triggers on tables.
+// Existing rows are recorded as changes, so they are part of the first changeset.
+// Call it again after altering a tracked table, so the triggers pick up the new columns.
+func Track(ctx context.Context, db bun.IDB, tables ...string) error {
+	if dName := db.Dialect().Name(); dName != dialect.SQLite {
+		return fmt.Errorf("unsupported dialect: %s", dName)
+	}
+
+	for _, model := range []any{(*Row)(nil), (*state)(nil), (*peer)(nil)} {
+		if _, err := db.NewCreateTable().Model(model).IfNotExists().Exec(ctx); err != nil {
+			return fmt.Errorf("failed to create sync tables: %w", err)
+		}
+	}
+	if _, err := db.NewCreateIndex().Model((*Row)(nil)).Index("dbx_sync_rows_seq_idx").
+		Column("seq").IfNotExists().Exec(ctx); err != nil {
+		return fmt.Errorf("failed to create sync index: %w", err)
+	}
+
+	site, err := newSiteID()
+	if err != nil {
+		return err
+	}
+	initial := []state{{Key: "site", Value: site}, {Key: "clock", Value: "0"}, {Key: "seq", Value: "0"}, {Key: "applying", Value: "0"}}
+	if _, err := db.NewInsert().Model(&initial).Ignore().Exec(ctx); err != nil {
+		return fmt.Errorf("failed to initialize sync state: %w", err)
+	}
+
+	for _, table := range tables {
+		if err := trackTable(ctx, db, table); err != nil {
+			return fmt.Errorf("failed to track %s: %w", table, err)
+		}
+	}
+	return nil
+}
+
+func trackTable(ctx context.Context, db bun.IDB, table string) error {
+	columns, err := tableColumns(ctx, db, table)
+	if err != nil {
+		return err
+	}
+	if len(columns) == 0 {
+		return fmt.Errorf("table %s not found", table)
+	}
+
+	jsonObject := func(row string) string {
+		parts := make([]string, 0, len(columns))
+		for _, col := range columns {
+			parts = append(parts, fmt.Sprintf("'%s', %s.%q", strings.ReplaceAll(col, "'", "''"), row, col))
+		}
+		return "json_object(" + strings.Join(parts, ", ") + ")"
+	}
+
+	// Every write bumps the Lamport clock and the local sequence, and records the row in the shadow table.
+	// Writes made by Apply are skipped: Apply records the remote version itself.
+	record := func(row, deleted, data string) string {
+		return fmt.Sprintf(`
+			UPDATE dbx_sync_state SET value = value + 1 WHERE key IN ('clock', 'seq');
+			INSERT OR REPLACE INTO dbx_sync_rows (table_name, row_id, seq, version, site, deleted, data) VALUES (
+				?2, %[1]s.id,
+				(SELECT value FROM dbx_sync_state WHERE key = 'seq'),
+				(SELECT value FROM dbx_sync_state WHERE key = 'clock'),
+				(SELECT value FROM dbx_sync_state WHERE key = 'site'),
+				%[2]s, %[3]s
+			);`, row, deleted, data)
+	}
+	guard := "WHEN (SELECT value FROM dbx_sync_state WHERE key = 'applying') = '0'"
+
+	statements := []string{
+		`DROP TRIGGER IF EXISTS ?0`,
+		`DROP TRIGGER IF EXISTS ?3`,
+		`DROP TRIGGER IF EXISTS ?4`,
+		`CREATE TRIGGER ?0 AFTER INSERT ON ?1 ` + guard + ` BEGIN` + record("NEW", "0", jsonObject("NEW")) + ` END`,
+		`CREATE TRIGGER ?3 AFTER UPDATE ON ?1 ` + guard + ` BEGIN` + record("NEW", "0", jsonObject("NEW")) + ` END`,
+		`CREATE TRIGGER ?4 AFTER DELETE ON ?1 ` + guard + ` BEGIN` + record("OLD", "1", "NULL") + ` END`,
+	}
+	args := []any{bun.Ident("dbx_sync_" + table + "_ins"), bun.Ident(table), table,
+		bun.Ident("dbx_sync_" + table + "_upd"), bun.Ident("dbx_sync_" + table + "_del")}
+	for _, stmt := range statements {
+		if _, err := db.ExecContext(ctx, stmt, args...); err != nil {
+			return err
+		}
+	}
+
+	// Record the rows that exist before tracking started
+	_, err = db.ExecContext(ctx, `UPDATE ?1 SET id = id WHERE id NOT IN (SELECT row_id FROM dbx_sync_rows WHERE table_name = ?2)`, args...)
+	return err
+}
+
+func tableColumns(ctx context.Context, db bun.IDB, table string) ([]string, error) {
+	rows, err := db.QueryContext(ctx, "SELECT name FROM pragma_table_info(?)", table)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var columns []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		columns = append(columns, name)
+	}
+	return columns, rows.Err()
+}
+
+// SiteID returns the id identifying this database in changesets
+func SiteID(ctx context.Context, db bun.IDB) (string, error) {
+	return stateValue(ctx, db, "site")
+}
+
+func stateValue(ctx context.Context, db bun.IDB, key string) (string, error) {
+	var value string
+	err := db.NewSelect().Model((*state)(nil)).Column("value").Where("key = ?", key).Scan(ctx, &value)
+	if err != nil {
+		return "", fmt.Errorf("failed to read sync state %s: %w", key, err)
+	}
+	return value, nil
+}
+
+// Changes returns the rows changed locally (or applied from other sites) after the local sequence number since
+func Changes(ctx context.Context, db bun.IDB, since int64) (*Changeset, error) {
+	site, err := SiteID(ctx, db)
+	if err != nil {
+		return nil, err
+	}
+
+	cs := &Changeset{Site: site, Seq: since}
+	if err := db.NewSelect().Model(&cs.Rows).Where("seq > ?", since).Order("seq ASC").Scan(ctx); err != nil {
+		return nil, err
+	}
+	for _, row := range cs.Rows {
+		cs.Seq = max(cs.Seq, row.Seq)
+	}
+	return cs, nil
+}
+
+// Apply merges cs into db in a single transaction. Rows unknown locally are applied as they are; when the local copy
+// has a different version, resolve decides which one is kept (LastWriteWins when nil).
+// It returns the number of rows that changed locally.
+func Apply(ctx context.Context, db *bun.DB, cs *Changeset, resolve Resolver) (applied int, err error) {
+	if resolve == nil {
+		resolve = LastWriteWins
+	}
+
+	err = db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		if _, err := tx.NewUpdate().Model((*state)(nil)).Set("value = '1'").Where("key = 'applying'").Exec(ctx); err != nil {
+			return err
+		}
+
+		for _, remote := range cs.Rows {
+			var local Row
+			err := tx.NewSelect().Model(&local).Where("table_name = ? AND row_id = ?", remote.Table, remote.RowID).Scan(ctx)
+			switch {
+			case err == nil:
+				if local.Version == remote.Version && local.Site == remote.Site {
+					continue
+				}
+				if !resolve(local, remote) {
+					continue
+				}
+			case !errors.Is(err, sql.ErrNoRows):
+				return err
+			}
+
+			if err := applyRow(ctx, tx, remote); err != nil {
+				return fmt.Errorf("failed to apply %s/%s: %w", remote.Table, remote.RowID, err)
+			}
+			applied++
+		}
+
+		_, err := tx.NewUpdate().Model((*state)(nil)).Set("value = '0'").Where("key = 'applying'").Exec(ctx)
+		return err
+	})
+	return applied, err
+}
+
+func applyRow(ctx context.Context, tx bun.Tx, remote Row) error {
+	if remote.Deleted {
+		if _, err := tx.ExecContext(ctx, "DELETE FROM ? WHERE id = ?", bun.Ident(remote.Table), remote.RowID); err != nil {
+			return err
+		}
+	} else {
+		dec := json.NewDecoder(strings.NewReader(string(remote.Data)))
+		dec.UseNumber()
+		var values map[string]any
+		if err := dec.Decode(&values); err != nil {
+			return err
+		}
+
+		names := make([]string, 0, len(values))
+		placeholders := make([]string, 0, len(values))
+		updates := make([]string, 0, len(values))
+		args := []any{bun.Ident(remote.Table)}
+		for col, value := range values {
+			names = append(names, fmt.Sprintf("%q", col))
+			placeholders = append(placeholders, "?")
+			updates = append(updates, fmt.Sprintf("%q = excluded.%q", col, col))
+			args = append(args, jsonValue(value))
+		}
+		query := fmt.Sprintf("INSERT INTO ? (%s) VALUES (%s) ON CONFLICT (id) DO UPDATE SET %s",
+			strings.Join(names, ", "), strings.Join(placeholders, ", "), strings.Join(updates, ", "))
+		_, err := tx.ExecContext(ctx, query, args...)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Keep the remote version, advance the Lamport clock past it and give the row a local sequence number
+	if _, err := tx.ExecContext(ctx, `
+		UPDATE dbx_sync_state SET value = CASE
+			WHEN key = 'clock' THEN max(CAST(value AS INTEGER), ?)
+			ELSE value + 1
+		END WHERE key IN ('clock', 'seq')`, remote.Version); err != nil {
+		return err
+	}
+	_, err := tx.ExecContext(ctx, `
+		INSERT OR REPLACE INTO dbx_sync_rows (table_name, row_id, seq, version, site, deleted, data)
+		VALUES (?, ?, (SELECT value FROM dbx_sync_state WHERE key = 'seq'), ?, ?, ?, ?)`,
+		remote.Table, remote.RowID, remote.Version, remote.Site, remote.Deleted, string(remote.Data))
+	return err
+}
+
+// jsonValue converts a decoded JSON value into an argument SQLite stores with the column's affinity
+func jsonValue(v any) any {
+	switch v := v.(type) {
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return i
+		}
+		f, _ := v.Float64()
+		return f
+	case nil, string, bool:
+		return v
+	default:
+		b, _ := json.Marshal(v)
+		return string(b)
+	}
+}
+
+// Sync exchanges changes between two tracked databases in both directions, remembering in each database how far it
+// has seen the other, and returns how many rows changed on each side.
+func Sync(ctx context.Context, a, b *bun.DB, resolve Resolver) (appliedToA, appliedToB int, err error) {
+	if appliedToB, err = push(ctx, a, b, resolve); err != nil {
+		return 0, 0, err
+	}
+	if appliedToA, err = push(ctx, b, a, resolve); err != nil {
+		return 0, appliedToB, err
+	}
+	return appliedToA, appliedToB, nil
+}
+
+// push applies the changes of src that dst has not seen yet
+func push(ctx context.Context, src, dst *bun.DB, resolve Resolver) (int, error) {
+	srcSite, err := SiteID(ctx, src)
+	if err != nil {
+		return 0, err
+	}
+
+	var seen peer
+	err = dst.NewSelect().Model(&seen).Where("site = ?", srcSite).Scan(ctx)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return 0, err
+	}
+
+	cs, err := Changes(ctx, src, seen.Seq)
+	if err != nil {
+		return 0, err
+	}
+	applied, err := Apply(ctx, dst, cs, resolve)
+	if err != nil {
+		return 0, err
+	}
+
+	seen = peer{Site: srcSite, Seq: cs.Seq}
+	if _, err := dst.NewInsert().Model(&seen).On("CONFLICT (site) DO UPDATE").Set("seq = EXCLUDED.seq").Exec(ctx); err != nil {
+		return applied, fmt.Errorf("failed to record sync position: %w", err)
+	}
+	return applied, nil
+}
+
+func newSiteID() (string, error) {
+	b := make([]byte, 8)
+	if _, err := io.ReadFull(RandReader, b); err != nil {
+		return "", fmt.Errorf("failed to generate site id: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,172 @@
+package sync
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"testing"
+
+	"github.com/actanonv/dbx"
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/uptrace/bun"
+)
+
+func setupTestDB(t *testing.T, name string) *bun.DB {
+	t.Helper()
+
+	tmp := t.TempDir()
+	if err := dbx.CreateDB(name, dbx.CreateWithDbFolder(tmp)); err != nil {
+		t.Fatalf("CreateDB failed: %v", err)
+	}
+	db, err := dbx.OpenDB(name, dbx.WithDbFolder(tmp))
+	if err != nil {
+		t.Fatalf("OpenDB failed: %v", err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+
+	ctx := context.Background()
+	if _, err := db.ExecContext(ctx, "CREATE TABLE notes (id INTEGER PRIMARY KEY, body TEXT, stars INTEGER)"); err != nil {
+		t.Fatalf("create table failed: %v", err)
+	}
+	return db
+}
+
+func noteBody(t *testing.T, db *bun.DB, id int) (string, bool) {
+	t.Helper()
+	var body []string
+	if err := db.NewSelect().Table("notes").Column("body").Where("id = ?", id).Scan(context.Background(), &body); err != nil {
+		t.Fatalf("select failed: %v", err)
+	}
+	if len(body) == 0 {
+		return "", false
+	}
+	return body[0], true
+}
+
+func TestSync_Bidirectional(t *testing.T) {
+	ctx := context.Background()
+	a, b := setupTestDB(t, "a"), setupTestDB(t, "b")
+
+	if _, err := a.ExecContext(ctx, "INSERT INTO notes (id, body, stars) VALUES (1, 'existing', 3)"); err != nil {
+		t.Fatalf("insert failed: %v", err)
+	}
+	for _, db := range []*bun.DB{a, b} {
+		if err := Track(ctx, db, "notes"); err != nil {
+			t.Fatalf("Track failed: %v", err)
+		}
+	}
+
+	if _, err := b.ExecContext(ctx, "INSERT INTO notes (id, body, stars) VALUES (2, 'from b', 1)"); err != nil {
+		t.Fatalf("insert failed: %v", err)
+	}
+
+	toA, toB, err := Sync(ctx, a, b, nil)
+	if err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+	if toA != 1 || toB != 1 {
+		t.Fatalf("expected 1 row applied each way, got toA=%d toB=%d", toA, toB)
+	}
+	if body, _ := noteBody(t, b, 1); body != "existing" {
+		t.Fatalf("expected pre-existing row on b, got %q", body)
+	}
+	if body, _ := noteBody(t, a, 2); body != "from b" {
+		t.Fatalf("expected row from b on a, got %q", body)
+	}
+
+	// A second sync only echoes rows the other side already has
+	if toA, toB, err = Sync(ctx, a, b, nil); err != nil || toA != 0 || toB != 0 {
+		t.Fatalf("expected nothing to apply, got toA=%d toB=%d err=%v", toA, toB, err)
+	}
+
+	if _, err := a.ExecContext(ctx, "DELETE FROM notes WHERE id = 2"); err != nil {
+		t.Fatalf("delete failed: %v", err)
+	}
+	if _, _, err = Sync(ctx, a, b, nil); err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+	if _, found := noteBody(t, b, 2); found {
+		t.Fatalf("expected delete to reach b")
+	}
+}
+
+func TestApply_Conflicts(t *testing.T) {
+	ctx := context.Background()
+	a, b := setupTestDB(t, "a"), setupTestDB(t, "b")
+	for _, db := range []*bun.DB{a, b} {
+		if err := Track(ctx, db, "notes"); err != nil {
+			t.Fatalf("Track failed: %v", err)
+		}
+	}
+
+	if _, err := a.ExecContext(ctx, "INSERT INTO notes (id, body) VALUES (1, 'v1')"); err != nil {
+		t.Fatalf("insert failed: %v", err)
+	}
+	if _, _, err := Sync(ctx, a, b, nil); err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+
+	// Concurrent edits: b edits twice, so its version is higher and wins under LastWriteWins
+	if _, err := a.ExecContext(ctx, "UPDATE notes SET body = 'a edit' WHERE id = 1"); err != nil {
+		t.Fatalf("update failed: %v", err)
+	}
+	for _, body := range []string{"b edit", "b edit 2"} {
+		if _, err := b.ExecContext(ctx, "UPDATE notes SET body = ? WHERE id = 1", body); err != nil {
+			t.Fatalf("update failed: %v", err)
+		}
+	}
+
+	var conflicts int
+	preferLocal := func(local, remote Row) bool {
+		conflicts++
+		return false
+	}
+	cs, err := Changes(ctx, b, 0)
+	if err != nil {
+		t.Fatalf("Changes failed: %v", err)
+	}
+	if _, err := Apply(ctx, a, cs, preferLocal); err != nil {
+		t.Fatalf("Apply failed: %v", err)
+	}
+	if body, _ := noteBody(t, a, 1); body != "a edit" || conflicts != 1 {
+		t.Fatalf("expected custom resolver to keep local edit, got %q after %d conflicts", body, conflicts)
+	}
+
+	if _, _, err := Sync(ctx, a, b, LastWriteWins); err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+	for name, db := range map[string]*bun.DB{"a": a, "b": b} {
+		if body, _ := noteBody(t, db, 1); body != "b edit 2" {
+			t.Fatalf("expected last write to win on %s, got %q", name, body)
+		}
+	}
+
+	// Local edits after Apply get a version above the remote one
+	if _, err := a.ExecContext(ctx, "UPDATE notes SET body = 'a wins' WHERE id = 1"); err != nil {
+		t.Fatalf("update failed: %v", err)
+	}
+	if _, _, err := Sync(ctx, a, b, nil); err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+	if body, _ := noteBody(t, b, 1); body != "a wins" {
+		t.Fatalf("expected newer edit from a on b, got %q", body)
+	}
+}
+
+func TestTrack_RandReader(t *testing.T) {
+	ctx := context.Background()
+	defer func(r io.Reader) { RandReader = r }(RandReader)
+	RandReader = bytes.NewReader([]byte{1, 2, 3, 4, 5, 6, 7, 8})
+
+	db := setupTestDB(t, "a")
+	if err := Track(ctx, db, "notes"); err != nil {
+		t.Fatalf("Track failed: %v", err)
+	}
+	site, err := SiteID(ctx, db)
+	if err != nil {
+		t.Fatalf("SiteID failed: %v", err)
+	}
+	if site != "0102030405060708" {
+		t.Fatalf("expected the site id of the injected reader, got %s", site)
+	}
+}
@@ -0,0 +1,154 @@
+package dbx
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"runtime"
+	"sync"
+	"weak"
+
+	"github.com/uptrace/bun"
+)
+
+// ErrTenantUnscoped fails the queries WithTenantGuard rejects. It wraps ErrPolicyDenied.
+var ErrTenantUnscoped = fmt.Errorf("%w: query not scoped to a tenant", ErrPolicyDenied)
+
+// WithTenantGuard makes the db fail with ErrTenantUnscoped the select, update and delete queries on the tables of its
+// TenantPolicy (see WithQueryPolicy) that were not scoped by it through ApplyQueryPolicies, so a repository forgetting
+// the call cannot read or change the rows of other tenants. Relations loaded by queries of their own (has-many,
+// m2m) need the call too, in their apply function. Raw SQL, inserts and the queries of a context from Unscoped are
+// not checked.
+func WithTenantGuard() OpenOptFn {
+	return func(opt *Options) {
+		opt.tenantGuard = true
+	}
+}
+
+type unscopedKey struct{}
+
+// Unscoped returns a context whose queries WithTenantGuard lets through, for the jobs working across tenants
+// (migrations, reports, test assertions)
+func Unscoped(ctx context.Context) context.Context {
+	return context.WithValue(ctx, unscopedKey{}, true)
+}
+
+// tenantGuard is the query hook of WithTenantGuard. It marks the queries rejected in their context, and checkConn
+// fails their statements, since a query hook cannot.
+type tenantGuard struct {
+	policies []*tenantPolicy
+	// scoped holds weak pointers to the queries scoped by a policy, deleted once they are collected
+	scoped sync.Map
+}
+
+var _ bun.QueryHook = (*tenantGuard)(nil)
+
+type guardErrKey struct{}
+
+func newTenantGuard(policies []QueryPolicy) *tenantGuard {
+	g := &tenantGuard{}
+	for _, p := range policies {
+		if tp, ok := p.(*tenantPolicy); ok {
+			g.policies = append(g.policies, tp)
+		}
+	}
+	return g
+}
+
+// tenantGuardProblems checks WithTenantGuard has a TenantPolicy to enforce
+func tenantGuardProblems(opt *Options) []error {
+	if opt.tenantGuard && len(newTenantGuard(opt.policies).policies) == 0 {
+		return []error{fmt.Errorf("%w: WithTenantGuard needs a TenantPolicy", ErrInvalidOptions)}
+	}
+	return nil
+}
+
+// markScoped records q, a *bun.SelectQuery, *bun.UpdateQuery or *bun.DeleteQuery, as scoped
+func (g *tenantGuard) markScoped(q any) {
+	switch q := q.(type) {
+	case *bun.SelectQuery:
+		markWeak(&g.scoped, q)
+	case *bun.UpdateQuery:
+		markWeak(&g.scoped, q)
+	case *bun.DeleteQuery:
+		markWeak(&g.scoped, q)
+	}
+}
+
+func markWeak[T any](m *sync.Map, p *T) {
+	key := weak.Make(p)
+	if _, loaded := m.LoadOrStore(key, struct{}{}); !loaded {
+		runtime.AddCleanup(p, func(key weak.Pointer[T]) { m.Delete(key) }, key)
+	}
+}
+
+// isScoped reports whether q was marked, and whether it is a query the guard checks at all. Selects, updates and
+// deletes of an unknown type are checked but never marked, so a new wrapper in bun fails them instead of letting them
+// through.
+func (g *tenantGuard) isScoped(q bun.Query) (scoped, checked bool) {
+	var key any
+	switch uq := unwrapSelect(q).(type) {
+	case *bun.SelectQuery:
+		key = weak.Make(uq)
+	case *bun.UpdateQuery:
+		key = weak.Make(uq)
+	case *bun.DeleteQuery:
+		key = weak.Make(uq)
+	case *bun.RawQuery:
+		return false, false
+	default:
+		switch q.Operation() {
+		case "SELECT", "UPDATE", "DELETE":
+			return false, true
+		}
+		return false, false
+	}
+	_, scoped = g.scoped.Load(key)
+	return scoped, true
+}
+
+// unwrapSelect returns the *bun.SelectQuery of the unexported wrappers bun runs for Count and Exists, structs of a
+// single field holding it, and q otherwise
+func unwrapSelect(q any) any {
+	if v := reflect.ValueOf(q); v.Kind() == reflect.Struct && v.NumField() == 1 &&
+		v.Field(0).Type() == reflect.TypeFor[*bun.SelectQuery]() {
+		return v.Field(0).Interface()
+	}
+	return q
+}
+
+// covers reports whether a policy of the guard restricts the queries of table
+func (g *tenantGuard) covers(table string) bool {
+	for _, p := range g.policies {
+		if p.covers(table) {
+			return true
+		}
+	}
+	return false
+}
+
+func (g *tenantGuard) BeforeQuery(ctx context.Context, event *bun.QueryEvent) context.Context {
+	if event.IQuery == nil || ctx.Value(unscopedKey{}) != nil {
+		return ctx
+	}
+	scoped, checked := g.isScoped(event.IQuery)
+	if !checked || scoped {
+		return ctx
+	}
+	table := queryTable(event.IQuery)
+	if !g.covers(table) {
+		return ctx
+	}
+	return context.WithValue(ctx, guardErrKey{},
+		fmt.Errorf("%w: %s of %s", ErrTenantUnscoped, event.IQuery.Operation(), table))
+}
+
+func (g *tenantGuard) AfterQuery(context.Context, *bun.QueryEvent) {}
+
+// checkTenantGuard is the check of the connections of WithTenantGuard, failing the statements of rejected queries
+func checkTenantGuard(ctx context.Context, _ string) error {
+	if err, ok := ctx.Value(guardErrKey{}).(error); ok {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,110 @@
+package dbx
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/uptrace/bun"
+)
+
+func TestWithTenantGuard(t *testing.T) {
+	ctx := context.Background()
+	db, err := OpenDB("guard", WithDbFolder(t.TempDir()), WithCreateIfMissing(),
+		WithQueryPolicy(TenantPolicy("tenant_id", tenantFromCtx, "policy_docs")), WithTenantGuard())
+	if err != nil {
+		t.Fatalf("OpenDB failed: %v", err)
+	}
+	defer db.Close()
+	if _, err := db.NewCreateTable().Model((*policyDoc)(nil)).Exec(ctx); err != nil {
+		t.Fatalf("create table failed: %v", err)
+	}
+	docs := []policyDoc{{TenantID: 1, Title: "a"}, {TenantID: 2, Title: "b"}}
+	if _, err := db.NewInsert().Model(&docs).Exec(ctx); err != nil {
+		t.Fatalf("insert failed: %v", err)
+	}
+
+	tenant1 := context.WithValue(ctx, tenantKey{}, int64(1))
+	var got []policyDoc
+	if err := db.NewSelect().Model(&got).Scan(tenant1); !errors.Is(err, ErrTenantUnscoped) ||
+		!errors.Is(err, ErrPolicyDenied) || KindOf(err) != KindMisuse {
+		t.Fatalf("expected ErrTenantUnscoped, got %v", err)
+	}
+	if _, err := db.NewSelect().Model((*policyDoc)(nil)).Count(tenant1); !errors.Is(err, ErrTenantUnscoped) {
+		t.Fatalf("expected ErrTenantUnscoped for the count, got %v", err)
+	}
+	if _, err := db.NewDelete().Model((*policyDoc)(nil)).Where("1 = 1").Exec(tenant1); !errors.Is(err, ErrTenantUnscoped) {
+		t.Fatalf("expected ErrTenantUnscoped for the delete, got %v", err)
+	}
+
+	q := db.NewSelect().Model(&got)
+	if err := ApplyQueryPolicies(tenant1, q.QueryBuilder()); err != nil {
+		t.Fatalf("ApplyQueryPolicies failed: %v", err)
+	}
+	if err := q.Scan(tenant1); err != nil || len(got) != 1 || got[0].Title != "a" {
+		t.Fatalf("expected the doc of tenant 1, got %+v, %v", got, err)
+	}
+	if n, err := q.Count(tenant1); err != nil || n != 1 {
+		t.Fatalf("expected a count of 1, got %d, %v", n, err)
+	}
+
+	if n, err := db.NewSelect().Model((*policyDoc)(nil)).Count(Unscoped(ctx)); err != nil || n != 2 {
+		t.Fatalf("expected the unscoped count of all the docs, got %d, %v", n, err)
+	}
+	if _, err := db.NewSelect().Table("sqlite_master").Exists(ctx); err != nil {
+		t.Fatalf("expected no guard on another table, got %v", err)
+	}
+	if err := ValidateOptions(WithTenantGuard()); !errors.Is(err, ErrInvalidOptions) {
+		t.Fatalf("expected WithTenantGuard without TenantPolicy to be invalid, got %v", err)
+	}
+}
+
+// queryCapture records the queries run on a db
+type queryCapture struct{ queries []bun.Query }
+
+func (c *queryCapture) BeforeQuery(ctx context.Context, event *bun.QueryEvent) context.Context {
+	c.queries = append(c.queries, event.IQuery)
+	return ctx
+}
+
+func (c *queryCapture) AfterQuery(context.Context, *bun.QueryEvent) {}
+
+// TestTenantGuardWrappers fails when bun changes the wrappers of the select it runs for Count and Exists, which the
+// guard unwraps by reflection: until unwrapSelect is updated, their queries would all fail with ErrTenantUnscoped.
+func TestTenantGuardWrappers(t *testing.T) {
+	ctx := context.Background()
+	db := setupTestDB(t)
+	capture := &queryCapture{}
+	db.AddQueryHook(capture)
+
+	q := db.NewSelect().Table("items")
+	if _, err := q.Count(ctx); err != nil {
+		t.Fatalf("Count failed: %v", err)
+	}
+	if _, err := q.Exists(ctx); err != nil {
+		t.Fatalf("Exists failed: %v", err)
+	}
+	if len(capture.queries) != 2 {
+		t.Fatalf("expected 2 queries, got %d", len(capture.queries))
+	}
+	for i, name := range []string{"Count", "Exists"} {
+		if got := unwrapSelect(capture.queries[i]); got != q {
+			t.Errorf("bun runs %s with a %T that unwrapSelect does not unwrap to its select: update unwrapSelect",
+				name, capture.queries[i])
+		}
+	}
+
+	// An unknown wrapper is checked, and fails as unscoped
+	var g tenantGuard
+	g.markScoped(q)
+	wrapper := struct {
+		*bun.SelectQuery
+		extra int
+	}{q, 0}
+	if scoped, checked := g.isScoped(wrapper); scoped || !checked {
+		t.Fatalf("expected an unknown wrapper to be checked and unscoped, got %v, %v", scoped, checked)
+	}
+	if scoped, checked := g.isScoped(capture.queries[0]); !scoped || !checked {
+		t.Fatalf("expected the count of a scoped select to be scoped, got %v, %v", scoped, checked)
+	}
+}
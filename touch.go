@@ -0,0 +1,97 @@
+package dbx
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/dialect"
+	"github.com/uptrace/bun/schema"
+)
+
+// TouchParent installs triggers setting the updated_at column of the parent row to the current time whenever a
+// child row referencing it through parentFK is inserted, updated or deleted, in the same transaction as the change.
+// A child moved to another parent touches both. Parents that are children themselves touch their own parents in turn.
+//
+// childModel must declare the parent as a belongs-to relation joined on parentFK, e.g.
+// Order *Order `bun:"rel:belongs-to,join:order_id=id"`, from which the parent table and key are read.
+//
+// Supported dialects are SQLite, Postgres and MySQL. Run it inside a migration, like MaintainCounter.
+func TouchParent(ctx context.Context, idb bun.IDB, childModel any, parentFK string) error {
+	child := idb.Dialect().Tables().Get(reflect.TypeOf(childModel))
+	rel := belongsToRelation(child, parentFK)
+	if rel == nil {
+		return fmt.Errorf("%w: %s has no belongs-to relation joined on %s", ErrInvalidOptions, child.TypeName, parentFK)
+	}
+
+	name := fmt.Sprintf("dbx_touch_%s_%s", child.Name, parentFK)
+	var statements []string
+	switch dName := idb.Dialect().Name(); dName {
+	case dialect.SQLite:
+		now := `strftime('%Y-%m-%d %H:%M:%f', 'now')`
+		statements = []string{
+			`CREATE TRIGGER IF NOT EXISTS ?0 AFTER INSERT ON ?3 BEGIN
+				UPDATE ?1 SET updated_at = ` + now + ` WHERE ?2 = NEW.?4;
+			END`,
+			`CREATE TRIGGER IF NOT EXISTS ?5 AFTER DELETE ON ?3 BEGIN
+				UPDATE ?1 SET updated_at = ` + now + ` WHERE ?2 = OLD.?4;
+			END`,
+			`CREATE TRIGGER IF NOT EXISTS ?6 AFTER UPDATE ON ?3 BEGIN
+				UPDATE ?1 SET updated_at = ` + now + ` WHERE ?2 IN (OLD.?4, NEW.?4);
+			END`,
+		}
+	case dialect.PG:
+		statements = []string{
+			`CREATE OR REPLACE FUNCTION ?7() RETURNS trigger AS $$
+			BEGIN
+				IF TG_OP IN ('DELETE', 'UPDATE') THEN
+					UPDATE ?1 SET updated_at = now() WHERE ?2 = OLD.?4;
+				END IF;
+				IF TG_OP IN ('INSERT', 'UPDATE') THEN
+					UPDATE ?1 SET updated_at = now() WHERE ?2 = NEW.?4;
+				END IF;
+				RETURN NULL;
+			END;
+			$$ LANGUAGE plpgsql`,
+			`DROP TRIGGER IF EXISTS ?8 ON ?3`,
+			`CREATE TRIGGER ?8 AFTER INSERT OR DELETE OR UPDATE ON ?3 FOR EACH ROW EXECUTE FUNCTION ?7()`,
+		}
+	case dialect.MySQL:
+		statements = []string{
+			`DROP TRIGGER IF EXISTS ?0`,
+			`CREATE TRIGGER ?0 AFTER INSERT ON ?3 FOR EACH ROW
+				UPDATE ?1 SET updated_at = CURRENT_TIMESTAMP(6) WHERE ?2 = NEW.?4`,
+			`DROP TRIGGER IF EXISTS ?5`,
+			`CREATE TRIGGER ?5 AFTER DELETE ON ?3 FOR EACH ROW
+				UPDATE ?1 SET updated_at = CURRENT_TIMESTAMP(6) WHERE ?2 = OLD.?4`,
+			`DROP TRIGGER IF EXISTS ?6`,
+			`CREATE TRIGGER ?6 AFTER UPDATE ON ?3 FOR EACH ROW
+				UPDATE ?1 SET updated_at = CURRENT_TIMESTAMP(6) WHERE ?2 IN (OLD.?4, NEW.?4)`,
+		}
+	default:
+		return fmt.Errorf("unsupported dialect: %s", dName)
+	}
+
+	args := []any{
+		bun.Ident(name + "_ins"), bun.Ident(rel.JoinTable.Name), bun.Ident(rel.JoinPKs[0].Name),
+		bun.Ident(child.Name), bun.Ident(parentFK),
+		bun.Ident(name + "_del"), bun.Ident(name + "_upd"), bun.Ident(name + "_fn"), bun.Ident(name),
+	}
+	for _, stmt := range statements {
+		if _, err := idb.ExecContext(ctx, stmt, args...); err != nil {
+			return fmt.Errorf("failed to touch %s from %s: %w", rel.JoinTable.Name, child.Name, err)
+		}
+	}
+	return nil
+}
+
+// belongsToRelation returns the belongs-to relation of table joined on the single column fk, nil without one
+func belongsToRelation(table *schema.Table, fk string) *schema.Relation {
+	for _, rel := range table.Relations {
+		if rel.Type == schema.BelongsToRelation && len(rel.BasePKs) == 1 && rel.BasePKs[0].Name == fk {
+			return rel
+		}
+	}
+	return nil
+}
@@ -0,0 +1,77 @@
+package dbx
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+type touchOrder struct {
+	ID        int64     `bun:",pk,autoincrement"`
+	UpdatedAt time.Time `bun:",notnull"`
+}
+
+type touchLine struct {
+	ID      int64       `bun:",pk,autoincrement"`
+	OrderID int64       `bun:",notnull"`
+	Order   *touchOrder `bun:"rel:belongs-to,join:order_id=id"`
+}
+
+func TestTouchParent(t *testing.T) {
+	ctx := context.Background()
+	db := setupTestDB(t)
+	for _, model := range []any{(*touchOrder)(nil), (*touchLine)(nil)} {
+		if _, err := db.NewCreateTable().Model(model).Exec(ctx); err != nil {
+			t.Fatalf("create table failed: %v", err)
+		}
+	}
+	if err := TouchParent(ctx, db, (*touchLine)(nil), "order_id"); err != nil {
+		t.Fatalf("TouchParent failed: %v", err)
+	}
+
+	past := time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+	orders := []touchOrder{{UpdatedAt: past}, {UpdatedAt: past}}
+	if _, err := db.NewInsert().Model(&orders).Exec(ctx); err != nil {
+		t.Fatalf("insert orders failed: %v", err)
+	}
+	touched := func() (first, second bool) {
+		t.Helper()
+		var got []touchOrder
+		if err := db.NewSelect().Model(&got).Order("id").Scan(ctx); err != nil {
+			t.Fatalf("select orders failed: %v", err)
+		}
+		// Reset, so each step is checked on its own
+		if _, err := db.NewUpdate().Model((*touchOrder)(nil)).Set("updated_at = ?", past).Where("1 = 1").Exec(ctx); err != nil {
+			t.Fatalf("reset orders failed: %v", err)
+		}
+		return got[0].UpdatedAt.After(past), got[1].UpdatedAt.After(past)
+	}
+
+	line := &touchLine{OrderID: orders[0].ID}
+	if _, err := db.NewInsert().Model(line).Exec(ctx); err != nil {
+		t.Fatalf("insert line failed: %v", err)
+	}
+	if first, second := touched(); !first || second {
+		t.Fatalf("insert: expected only the first order touched, got %v %v", first, second)
+	}
+
+	line.OrderID = orders[1].ID
+	if _, err := db.NewUpdate().Model(line).WherePK().Exec(ctx); err != nil {
+		t.Fatalf("update line failed: %v", err)
+	}
+	if first, second := touched(); !first || !second {
+		t.Fatalf("move: expected both orders touched, got %v %v", first, second)
+	}
+
+	if _, err := db.NewDelete().Model(line).WherePK().Exec(ctx); err != nil {
+		t.Fatalf("delete line failed: %v", err)
+	}
+	if first, second := touched(); first || !second {
+		t.Fatalf("delete: expected only the second order touched, got %v %v", first, second)
+	}
+
+	if err := TouchParent(ctx, db, (*touchLine)(nil), "id"); !errors.Is(err, ErrInvalidOptions) {
+		t.Fatalf("expected ErrInvalidOptions without a relation, got %v", err)
+	}
+}
@@ -0,0 +1,151 @@
+package dbx
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/uptrace/bun"
+)
+
+// TraceEvent is a single line of a trace file
+type TraceEvent struct {
+	Time     time.Time `json:"time"`
+	Kind     string    `json:"kind"` // query, begin, commit, rollback or savepoint
+	Query    string    `json:"query"`
+	Args     []any     `json:"args,omitempty"`
+	Duration float64   `json:"duration_ms"`
+	Error    string    `json:"error,omitempty"`
+}
+
+// Trace writes the statements executed under a context to a file, one JSON TraceEvent per line
+type Trace struct {
+	mu   sync.Mutex
+	file *os.File
+	enc  *json.Encoder
+	err  error
+}
+
+type traceCtxKey struct{}
+
+// TraceToFile returns a context under which every statement, with its args, duration, error and the transaction
+// boundaries, is appended to the file at path as JSON lines. Close the Trace once the traced work is done.
+// Tracing needs the trace hook, which OpenDB installs; add TraceHook to dbs opened otherwise.
+func TraceToFile(ctx context.Context, path string) (context.Context, *Trace, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return ctx, nil, fmt.Errorf("failed to open trace file: %w", err)
+	}
+	tr := &Trace{file: file, enc: json.NewEncoder(file)}
+	return context.WithValue(ctx, traceCtxKey{}, tr), tr, nil
+}
+
+// Close closes the trace file and returns the first write error, if any
+func (tr *Trace) Close() error {
+	tr.mu.Lock()
+	defer tr.mu.Unlock()
+	if tr.file == nil {
+		return tr.err
+	}
+	if err := tr.file.Close(); err != nil && tr.err == nil {
+		tr.err = err
+	}
+	tr.file = nil
+	return tr.err
+}
+
+func (tr *Trace) write(ev TraceEvent) {
+	tr.mu.Lock()
+	defer tr.mu.Unlock()
+	if tr.file == nil || tr.err != nil {
+		return
+	}
+	tr.err = tr.enc.Encode(ev)
+}
+
+// TraceHook is the bun query hook recording statements into the Trace of their context, if any
+type TraceHook struct{}
+
+var _ bun.QueryHook = TraceHook{}
+
+func (TraceHook) BeforeQuery(ctx context.Context, _ *bun.QueryEvent) context.Context {
+	return ctx
+}
+
+func (TraceHook) AfterQuery(ctx context.Context, event *bun.QueryEvent) {
+	tr, ok := ctx.Value(traceCtxKey{}).(*Trace)
+	if !ok {
+		return
+	}
+
+	ev := TraceEvent{
+		Time:     event.StartTime,
+		Kind:     traceKind(event.Query),
+		Query:    event.Query,
+		Duration: float64(time.Since(event.StartTime).Microseconds()) / 1000,
+	}
+	if event.IQuery == nil && len(event.QueryArgs) > 0 {
+		// Raw statements; bun queries have their args formatted into the query already
+		ev.Query = event.QueryTemplate
+		ev.Args = event.QueryArgs
+	}
+	if event.Err != nil {
+		ev.Error = event.Err.Error()
+	}
+	tr.write(ev)
+}
+
+func traceKind(query string) string {
+	verb, _, _ := strings.Cut(strings.TrimSpace(query), " ")
+	switch strings.ToUpper(verb) {
+	case "BEGIN":
+		return "begin"
+	case "COMMIT":
+		return "commit"
+	case "ROLLBACK":
+		if strings.Contains(strings.ToUpper(query), " TO ") {
+			return "savepoint"
+		}
+		return "rollback"
+	case "SAVEPOINT", "RELEASE":
+		return "savepoint"
+	}
+	return "query"
+}
+
+var traceSeq atomic.Int64
+
+// TraceMiddleware traces the statements of the requests whose header holds secret into a new file in dir, named
+// after the time and a sequence number, for debugging a single request in production. Traces hold the query
+// arguments, so secret keeps clients from writing them to disk: an empty one traces nothing.
+// The handlers must run their queries with the request context.
+func TraceMiddleware(dir, header, secret string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			value := r.Header.Get(header)
+			if secret == "" || subtle.ConstantTimeCompare([]byte(value), []byte(secret)) != 1 {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			name := fmt.Sprintf("trace-%s-%d.jsonl", time.Now().UTC().Format("20060102T150405"), traceSeq.Add(1))
+			ctx, tr, err := TraceToFile(r.Context(), filepath.Join(dir, name))
+			if err != nil {
+				slog.Error("sql trace", "err", err.Error())
+				next.ServeHTTP(w, r)
+				return
+			}
+			defer tr.Close()
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
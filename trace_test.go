@@ -0,0 +1,124 @@
+package dbx
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func readTrace(t *testing.T, path string) []TraceEvent {
+	t.Helper()
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("failed to open trace: %v", err)
+	}
+	defer f.Close()
+
+	var events []TraceEvent
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		var ev TraceEvent
+		if err := json.Unmarshal(sc.Bytes(), &ev); err != nil {
+			t.Fatalf("invalid trace line %q: %v", sc.Text(), err)
+		}
+		events = append(events, ev)
+	}
+	return events
+}
+
+func TestTraceToFile(t *testing.T) {
+	db := setupTestDB(t)
+	path := filepath.Join(t.TempDir(), "trace.jsonl")
+
+	ctx, tr, err := TraceToFile(context.Background(), path)
+	if err != nil {
+		t.Fatalf("TraceToFile failed: %v", err)
+	}
+	tx, err := NewTransact(ctx, db)
+	if err != nil {
+		t.Fatalf("NewTransact failed: %v", err)
+	}
+	err = tx.Transaction(nil, func(ctx context.Context) error {
+		_, err := tx.Db().ExecContext(ctx, "INSERT INTO items(name) VALUES (?)", "a")
+		return err
+	})
+	if err != nil {
+		t.Fatalf("Transaction failed: %v", err)
+	}
+	// Statements under other contexts are not traced
+	insertItem(t, db, "b")
+	if _, err := db.ExecContext(ctx, "SELECT * FROM missing"); err == nil {
+		t.Fatalf("expected an error")
+	}
+	if err := tr.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	events := readTrace(t, path)
+	kinds := make([]string, len(events))
+	for i, ev := range events {
+		kinds[i] = ev.Kind
+	}
+	want := []string{"begin", "query", "commit", "query"}
+	if len(kinds) != len(want) {
+		t.Fatalf("expected events %v, got %v", want, kinds)
+	}
+	for i := range want {
+		if kinds[i] != want[i] {
+			t.Fatalf("expected events %v, got %v", want, kinds)
+		}
+	}
+	if ev := events[1]; ev.Query != "INSERT INTO items(name) VALUES (?)" || len(ev.Args) != 1 || ev.Args[0] != "a" {
+		t.Fatalf("unexpected insert event %+v", ev)
+	}
+	if events[3].Error == "" {
+		t.Fatalf("expected the failed query to record its error")
+	}
+}
+
+func TestTraceMiddleware(t *testing.T) {
+	db := setupTestDB(t)
+	dir := t.TempDir()
+
+	handler := TraceMiddleware(dir, "X-Dbx-Trace", "s3cret")(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var n int
+		_ = db.NewRaw("SELECT COUNT(*) FROM items").Scan(r.Context(), &n)
+	}))
+
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	if files, _ := os.ReadDir(dir); len(files) != 0 {
+		t.Fatalf("expected no trace without the header, got %d files", len(files))
+	}
+
+	// Any other value of the header is ignored
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("X-Dbx-Trace", "1")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+	if files, _ := os.ReadDir(dir); len(files) != 0 {
+		t.Fatalf("expected no trace without the secret, got %d files", len(files))
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("X-Dbx-Trace", "s3cret")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+	files, _ := os.ReadDir(dir)
+	if len(files) != 1 {
+		t.Fatalf("expected one trace file, got %d", len(files))
+	}
+	if events := readTrace(t, filepath.Join(dir, files[0].Name())); len(events) != 1 || events[0].Kind != "query" {
+		t.Fatalf("unexpected trace %+v", events)
+	}
+
+	// Without a secret, nothing is traced
+	req = httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("X-Dbx-Trace", "")
+	TraceMiddleware(dir, "X-Dbx-Trace", "")(http.NotFoundHandler()).ServeHTTP(httptest.NewRecorder(), req)
+	if files, _ := os.ReadDir(dir); len(files) != 1 {
+		t.Fatalf("expected no trace with an empty secret, got %d files", len(files))
+	}
+}
@@ -1,181 +1,504 @@
 package dbx
 
 import (
+	"bytes"
 	"context"
 	"database/sql"
 	"errors"
+	"runtime"
 	"runtime/debug"
+	"slices"
+	"strconv"
+	"strings"
 
 	"fmt"
 	"github.com/uptrace/bun"
 	"sync"
+	"sync/atomic"
+	"time"
 )
 
-type ListOptions struct {
-	Where string
-	Args  []any
-	Limit int
-}
-
 type IDB interface {
 	Db() (db bun.IDB)
-	Start(opt *sql.TxOptions) error
+	Start(opt *sql.TxOptions) (*TxHandle, error)
 	Commit() error
 	Rollback() error
-	Transaction(opt *sql.TxOptions, fn TransactFunc) (err error)
+	Transaction(opt *sql.TxOptions, fn TransactFunc, opts ...TransactionOptFn) (err error)
 	Ctx() context.Context
 }
 
+var ErrTxActive = errors.New("cannot run without tx: tx active")
+
+// ErrTxWrongGoroutine is returned by Start, Commit and Rollback when called from a goroutine other than the one that
+// started the active transaction. Interleaving them from several goroutines would corrupt the savepoint nesting.
+var ErrTxWrongGoroutine = errors.New("tx used from another goroutine")
+
+// ErrUnbalancedTx is returned by TxHandle.Commit and Rollback when the level of the handle is not the current one
+var ErrUnbalancedTx = errors.New("unbalanced tx")
+
+// ErrTxAborted is returned by Commit when the context of the Transact was cancelled while the transaction was open,
+// which rolled it back
+var ErrTxAborted = errors.New("tx aborted")
+
+// ErrTxTooDeep is returned by Start when a savepoint would exceed the maximum nesting depth of the Transact
+var ErrTxTooDeep = errors.New("tx nesting too deep")
+
+// DefaultMaxTxDepth is the maximum nesting depth of a Transact, see TransactWithMaxDepth
+const DefaultMaxTxDepth = 100
+
 var _ IDB = (*Transact)(nil)
 
+// Transact runs work in a transaction, nesting further transactions as savepoints.
+//
+// Concurrency contract: Db, Ctx and the checks of whether a transaction is active read an immutable snapshot of
+// the state through an atomic pointer and never block. Start, Commit, Rollback and OnCommit are serialized by a
+// mutex and publish a new snapshot when they change the state. A transaction belongs to the goroutine that started
+// it: Start, Commit and Rollback from any other goroutine fail with ErrTxWrongGoroutine until it ends. Transaction
+// ends its level on the goroutine that started it, so it skips the check there.
+// Other goroutines may call Db concurrently, but they see whichever transaction is current at that moment.
+//
+// When the context of the Transact is cancelled while a transaction is open, a watcher rolls it back right away,
+// with all its savepoints, instead of leaving it to the next call; Commit then fails with ErrTxAborted.
 type Transact struct {
-	db     *bun.DB
+	db    *bun.DB
+	ctx   context.Context
+	state atomic.Pointer[txState]
+	// mu serializes state changes and guards hooks and aborted; readers only load state.
+	mu sync.Mutex
+	// hooks run after the outermost commit; level is the nesting level they were registered at.
+	hooks []commitHook
+	// aborted is the outermost level of the last transaction rolled back by context cancellation
+	aborted *txState
+	// maxDepth is the maximum nesting level, savepoints included
+	maxDepth int
+}
+
+type TransactOptions struct {
+	maxDepth int
+}
+
+type TransactOptFn func(options *TransactOptions)
+
+// TransactWithMaxDepth sets the maximum nesting depth, the outermost transaction included (default: DefaultMaxTxDepth).
+// Start fails with ErrTxTooDeep beyond it, listing where the open levels were started, which stops runaway
+// recursion from piling up savepoints.
+func TransactWithMaxDepth(n int) TransactOptFn {
+	return func(opt *TransactOptions) {
+		opt.maxDepth = n
+	}
+}
+
+type TransactionOptions struct {
+	noTx bool
+}
+
+type TransactionOptFn func(options *TransactionOptions)
+
+// TransactionWithoutTx runs fn of Transaction without a transaction (see RunWithoutTx), for statements or drivers
+// that do not allow transactions, while keeping a single code path.
+func TransactionWithoutTx() TransactionOptFn {
+	return func(opt *TransactionOptions) {
+		opt.noTx = true
+	}
+}
+
+// txState is the current transaction, a savepoint when nested > 1, with its parents linked through parent.
+// It is never modified once published; a nil state means no transaction is active.
+type txState struct {
 	tx     bun.Tx
-	ctx    context.Context
-	active bool
-	// stack holds parent transactions when using savepoints for nesting.
-	stack  []bun.Tx
-	mu     sync.RWMutex
+	parent *txState
 	nested int
+	owner  uint64 // id of the goroutine that started the outermost transaction
+	// stop unregisters the cancellation watcher; only set on the outermost level
+	stop func() bool
+	// site is the pc of the caller of Start, symbolized only for ErrTxTooDeep and DebugHandler
+	site uintptr
+	// started is when the outermost level began
+	started time.Time
+}
+
+// openTxs holds the Transacts with an open transaction, listed by DebugHandler
+var openTxs sync.Map // *Transact -> struct{}
+
+func (st *txState) root() *txState {
+	for st.parent != nil {
+		st = st.parent
+	}
+	return st
 }
 
-func NewTransact(ctx context.Context, db *bun.DB) (tsx *Transact, err error) {
+type commitHook struct {
+	level int
+	fn    func(ctx context.Context)
+}
+
+func NewTransact(ctx context.Context, db *bun.DB, opts ...TransactOptFn) (tsx *Transact, err error) {
 	if db == nil {
 		return nil, errors.New("dbx: NewTransact with nil db")
 	}
+	option := TransactOptions{}
+	for _, optFn := range opts {
+		optFn(&option)
+	}
+	if option.maxDepth <= 0 {
+		TransactWithMaxDepth(DefaultMaxTxDepth)(&option)
+	}
+
 	tsx = new(Transact)
 	tsx.db = db
 	tsx.ctx = ctx
+	tsx.maxDepth = option.maxDepth
 
 	return tsx, nil
 }
 
 func (t *Transact) Db() (db bun.IDB) {
-	t.mu.RLock()
-	defer t.mu.RUnlock()
-	if !t.active {
-		return t.db
+	if st := t.state.Load(); st != nil {
+		return st.tx
 	}
-	return t.tx
+	return t.db
 }
 
 func (t *Transact) Ctx() context.Context {
 	return t.ctx
 }
 
-func (t *Transact) Start(opt *sql.TxOptions) error {
+// active reports whether a transaction is open
+func (t *Transact) active() bool {
+	return t.state.Load() != nil
+}
+
+// depth returns the nesting level of the current transaction, 0 when none is open
+func (t *Transact) depth() int {
+	if st := t.state.Load(); st != nil {
+		return st.nested
+	}
+	return 0
+}
+
+// Start begins a transaction, or a savepoint when one is already active. The returned handle ends exactly that
+// level; prefer it over Transact.Commit and Rollback, which end whatever level is current.
+func (t *Transact) Start(opt *sql.TxOptions) (*TxHandle, error) {
+	h, err := t.start(opt, 3)
+	if err != nil {
+		return nil, wrapErr("tx.start", "", "", err)
+	}
+	return h, nil
+}
+
+// start is Start recording the caller skip frames up as the call site
+func (t *Transact) start(opt *sql.TxOptions, skip int) (*TxHandle, error) {
+	var site [1]uintptr
+	runtime.Callers(skip, site[:])
+
 	ctx := t.ctx
 	t.mu.Lock()
 	defer t.mu.Unlock()
 
 	// If a transaction is already active, create a savepoint and switch to it.
-	if t.active {
+	if st := t.state.Load(); st != nil {
+		if err := st.checkOwner("start"); err != nil {
+			return nil, err
+		}
+		if st.nested >= t.maxDepth {
+			return nil, fmt.Errorf("cannot start: %w: limit %d, levels started at:\n%s", ErrTxTooDeep, t.maxDepth, st.sites())
+		}
 		// Create a savepoint (bun.Tx.BeginTx on a Tx creates a savepoint-backed Tx).
-		sp, err := t.tx.BeginTx(ctx, opt)
+		sp, err := st.tx.BeginTx(ctx, opt)
 		if err != nil {
-			return err
+			return nil, err
 		}
-		// Push current tx to stack and switch active tx to the savepoint.
-		t.stack = append(t.stack, t.tx)
-		t.tx = sp
-		t.nested++
-		return nil
+		next := &txState{tx: sp, parent: st, nested: st.nested + 1, owner: st.owner, site: site[0]}
+		t.state.Store(next)
+		return &TxHandle{t: t, st: next}, nil
 	}
 
 	// No active transaction: start a new DB transaction.
 	tx, err := t.db.BeginTx(ctx, opt)
 	if err != nil {
-		return err
+		return nil, err
+	}
+	next := &txState{tx: tx, nested: 1, owner: goroutineID(), site: site[0], started: time.Now()}
+	if ctx.Done() != nil {
+		next.stop = context.AfterFunc(ctx, func() { t.abort(next) })
 	}
+	t.aborted = nil
+	t.state.Store(next)
+	openTxs.Store(t, struct{}{})
+	return &TxHandle{t: t, st: next}, nil
+}
+
+// Commit commits the current transaction level, whichever Start created it
+func (t *Transact) Commit() error {
+	return t.commitLevel(nil, false)
+}
 
-	t.tx = tx
-	t.active = true
-	t.nested = 1
-	t.stack = nil
+// commitLevel commits the current level, which must be want unless want is nil, then runs the hooks when it was the
+// outermost one. owned skips the goroutine check, for the levels Transaction ends.
+func (t *Transact) commitLevel(want *txState, owned bool) error {
+	hooks, err := t.commit(want, owned)
+	if err != nil {
+		err = wrapErr("tx.commit", "", "", err)
+		publish(Event{Kind: EventTxFailed, Err: err})
+		return err
+	}
 
+	// Run the hooks without holding the lock, so they can use the Transact themselves
+	for _, hook := range hooks {
+		hook.fn(t.ctx)
+	}
 	return nil
 }
 
-func (t *Transact) Commit() error {
+func (t *Transact) commit(want *txState, owned bool) ([]commitHook, error) {
 	t.mu.Lock()
 	defer t.mu.Unlock()
-	if !t.active {
-		return errors.New("cannot commit: no tx active")
+	st := t.state.Load()
+	if st == nil && t.abortedLevel(want) {
+		return nil, fmt.Errorf("cannot commit: %w: %w", ErrTxAborted, context.Cause(t.ctx))
+	}
+	if st == nil && want == nil {
+		return nil, errors.New("cannot commit: no tx active")
+	}
+	if err := checkLevel("commit", st, want); err != nil {
+		return nil, err
+	}
+	if !owned {
+		if err := st.checkOwner("commit"); err != nil {
+			return nil, err
+		}
 	}
 
-	if t.nested > 1 {
+	if st.nested > 1 {
 		// Commit current savepoint and revert to parent tx.
-		if err := t.tx.Commit(); err != nil {
-			return err
+		if err := st.tx.Commit(); err != nil {
+			return nil, err
 		}
-		t.popTx()
-		return nil
+		// Hooks of the savepoint now belong to its parent
+		for i := range t.hooks {
+			if t.hooks[i].level == st.nested {
+				t.hooks[i].level--
+			}
+		}
+		t.state.Store(st.parent)
+		return nil, nil
 	}
 
 	// Outermost transaction commit.
-	if err := t.tx.Commit(); err != nil {
-		return err
+	if err := st.tx.Commit(); err != nil {
+		return nil, err
 	}
+	st.stopWatch()
 
-	t.tx = bun.Tx{}
-	t.active = false
-	t.stack = nil
-	t.nested = 0
-	return nil
+	hooks := t.hooks
+	t.state.Store(nil)
+	t.hooks = nil
+	openTxs.Delete(t)
+	return hooks, nil
 }
 
+// Rollback rolls back the current transaction level, whichever Start created it
 func (t *Transact) Rollback() error {
+	return t.rollbackLevel(nil, false)
+}
+
+func (t *Transact) rollbackLevel(want *txState, owned bool) error {
+	return wrapErr("tx.rollback", "", "", t.rollback(want, owned))
+}
+
+func (t *Transact) rollback(want *txState, owned bool) error {
 	t.mu.Lock()
 	defer t.mu.Unlock()
-	if !t.active {
+	st := t.state.Load()
+	if st == nil && t.abortedLevel(want) {
+		// Already rolled back when the context was cancelled
+		return nil
+	}
+	if st == nil && want == nil {
 		return errors.New("cannot rollback: no tx active")
 	}
+	if err := checkLevel("rollback", st, want); err != nil {
+		return err
+	}
+	if !owned {
+		if err := st.checkOwner("rollback"); err != nil {
+			return err
+		}
+	}
 
-	if t.nested > 1 {
+	if st.nested > 1 {
 		// Rollback to the current savepoint and revert to parent tx.
-		if err := t.tx.Rollback(); err != nil {
+		if err := st.tx.Rollback(); err != nil {
 			return err
 		}
-		t.popTx()
+		t.hooks = slices.DeleteFunc(t.hooks, func(hook commitHook) bool { return hook.level >= st.nested })
+		t.state.Store(st.parent)
 		return nil
 	}
 
 	// Outermost transaction rollback.
-	err := t.tx.Rollback()
-	t.tx = bun.Tx{}
-	t.active = false
-	t.stack = nil
-	t.nested = 0
+	st.stopWatch()
+	err := st.tx.Rollback()
+	t.state.Store(nil)
+	t.hooks = nil
+	openTxs.Delete(t)
 	return err
 }
 
-func (t *Transact) popTx() {
-	// Pop parent from the stack.
-	parentIdx := len(t.stack) - 1
-	if parentIdx >= 0 {
-		t.tx = t.stack[parentIdx]
-		t.stack = t.stack[:parentIdx]
-	} else {
-		// Should not happen, but safeguard.
-		t.tx = bun.Tx{}
-		t.active = false
+// abort rolls back the transaction started at root when the context is cancelled, unless it already ended
+func (t *Transact) abort(root *txState) {
+	t.mu.Lock()
+	st := t.state.Load()
+	if st == nil || st.root() != root {
+		t.mu.Unlock()
+		return
+	}
+	_ = root.tx.Rollback()
+	t.state.Store(nil)
+	t.hooks = nil
+	t.aborted = root
+	openTxs.Delete(t)
+	t.mu.Unlock()
+
+	publish(Event{Kind: EventTxFailed, Err: fmt.Errorf("%w: %w", ErrTxAborted, context.Cause(t.ctx))})
+}
+
+// abortedLevel reports whether the last transaction was aborted and want, when set, was one of its levels
+func (t *Transact) abortedLevel(want *txState) bool {
+	return t.aborted != nil && (want == nil || want.root() == t.aborted)
+}
+
+func (st *txState) stopWatch() {
+	if st.stop != nil {
+		st.stop()
+	}
+}
+
+// OnCommit registers fn to run once the outermost transaction has committed, for side effects that must only
+// happen when the data is durable. Hooks registered inside a savepoint that is rolled back are dropped, and so are
+// all hooks when the transaction rolls back. Without an active transaction fn runs right away.
+func (t *Transact) OnCommit(fn func(ctx context.Context)) {
+	t.mu.Lock()
+	st := t.state.Load()
+	if st == nil {
+		t.mu.Unlock()
+		fn(t.ctx)
+		return
 	}
-	t.nested--
+	t.hooks = append(t.hooks, commitHook{level: st.nested, fn: fn})
+	t.mu.Unlock()
+}
+
+// TxHandle ends the transaction level it was returned for by Start. Its Commit and Rollback fail with
+// ErrUnbalancedTx when another level is current, e.g. when an inner function left a savepoint open, or when the
+// level already ended, instead of ending the wrong one.
+type TxHandle struct {
+	t  *Transact
+	st *txState
+	// owned is set on the handles of Transaction, which end their level on the goroutine that started it
+	owned bool
+}
+
+// Level returns the nesting level of the handle, 1 for the outermost transaction
+func (h *TxHandle) Level() int {
+	return h.st.nested
+}
+
+func (h *TxHandle) Commit() error {
+	return h.t.commitLevel(h.st, h.owned)
+}
+
+func (h *TxHandle) Rollback() error {
+	return h.t.rollbackLevel(h.st, h.owned)
+}
+
+// rollbackNested rolls back the levels fn left open above the handle, then the level of the handle itself
+func (h *TxHandle) rollbackNested() error {
+	for {
+		st := h.t.state.Load()
+		if st == nil || st == h.st || st.nested <= h.st.nested {
+			return h.Rollback()
+		}
+		if err := h.t.Rollback(); err != nil {
+			return err
+		}
+	}
+}
+
+func checkLevel(op string, current, want *txState) error {
+	if want == nil || current == want {
+		return nil
+	}
+	if current == nil {
+		return fmt.Errorf("cannot %s level %d: %w: no tx active", op, want.nested, ErrUnbalancedTx)
+	}
+	return fmt.Errorf("cannot %s level %d: %w: level %d is current", op, want.nested, ErrUnbalancedTx, current.nested)
+}
+
+// sites lists the call sites of Start of st and its parents, outermost first, eliding the middle of deep stacks
+func (st *txState) sites() string {
+	var pcs []uintptr
+	for ; st != nil; st = st.parent {
+		pcs = append(pcs, st.site)
+	}
+	slices.Reverse(pcs)
+
+	const keep = 10
+	var sb strings.Builder
+	for i, pc := range pcs {
+		if len(pcs) > 2*keep && i == keep {
+			fmt.Fprintf(&sb, "\t... %d more\n", len(pcs)-2*keep)
+		}
+		if len(pcs) > 2*keep && i >= keep && i < len(pcs)-keep {
+			continue
+		}
+		frame, _ := runtime.CallersFrames([]uintptr{pc}).Next()
+		fmt.Fprintf(&sb, "\t%d: %s (%s:%d)\n", i+1, frame.Function, frame.File, frame.Line)
+	}
+	return sb.String()
+}
+
+func (st *txState) checkOwner(op string) error {
+	if id := goroutineID(); id != st.owner {
+		return fmt.Errorf("cannot %s: %w: started on goroutine %d, called on %d", op, ErrTxWrongGoroutine, st.owner, id)
+	}
+	return nil
+}
+
+// goroutineID returns the id of the calling goroutine, parsed from the header of its stack trace
+func goroutineID() uint64 {
+	var buf [64]byte
+	b := buf[:runtime.Stack(buf[:], false)]
+	b, _ = bytes.CutPrefix(b, []byte("goroutine "))
+	b, _, _ = bytes.Cut(b, []byte(" "))
+	id, _ := strconv.ParseUint(string(b), 10, 64)
+	return id
 }
 
 type TransactFunc func(ctx context.Context) error
 
-func (t *Transact) Transaction(opt *sql.TxOptions, fn TransactFunc) (err error) {
+func (t *Transact) Transaction(opt *sql.TxOptions, fn TransactFunc, opts ...TransactionOptFn) (err error) {
+	var to TransactionOptions
+	for _, optFn := range opts {
+		optFn(&to)
+	}
+
 	ctx := t.ctx
-	if err = t.Start(opt); err != nil {
-		return err
+	if to.noTx {
+		return t.RunWithoutTx(ctx, fn)
+	}
+
+	h, err := t.start(opt, 3)
+	if err != nil {
+		return wrapErr("tx.start", "", "", err)
 	}
+	h.owned = true
 
 	committed := false
 
 	defer func() {
 		if r := recover(); r != nil {
-			_ = t.Rollback()
+			_ = h.rollbackNested()
 
 			stack := debug.Stack()
 			err = fmt.Errorf("panic recovered in Transaction: %v\nStack trace:\n%s", r, stack)
@@ -184,7 +507,7 @@ func (t *Transact) Transaction(opt *sql.TxOptions, fn TransactFunc) (err error)
 
 		// Handle normal rollback if committed is false (due to fn() or Commit() error)
 		if !committed {
-			rbErr := t.Rollback()
+			rbErr := h.rollbackNested()
 			if rbErr != nil {
 				if err != nil {
 					err = errors.Join(err, fmt.Errorf("rollback failed: %w", rbErr))
@@ -200,7 +523,7 @@ func (t *Transact) Transaction(opt *sql.TxOptions, fn TransactFunc) (err error)
 		return err
 	}
 
-	if cErr := t.Commit(); cErr != nil {
+	if cErr := h.Commit(); cErr != nil {
 		err = fmt.Errorf("failed to commit: %w", cErr)
 		return err
 	}
@@ -208,3 +531,21 @@ func (t *Transact) Transaction(opt *sql.TxOptions, fn TransactFunc) (err error)
 	committed = true
 	return nil
 }
+
+// RunWithoutTx runs fn directly against the db, with the same panic recovery as Transaction.
+// Db() returns the *bun.DB while fn runs. It fails with ErrTxActive when called inside a transaction,
+// since there is no way to step outside of it.
+func (t *Transact) RunWithoutTx(ctx context.Context, fn TransactFunc) (err error) {
+	if t.active() {
+		return ErrTxActive
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			stack := debug.Stack()
+			err = fmt.Errorf("panic recovered in RunWithoutTx: %v\nStack trace:\n%s", r, stack)
+		}
+	}()
+
+	return fn(ctx)
+}
@@ -8,6 +8,7 @@ import (
 	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/uptrace/bun"
 )
@@ -17,7 +18,7 @@ var (
 )
 
 // Test setup utilities
-func setupTestDB(t *testing.T) *bun.DB {
+func setupTestDB(t testing.TB) *bun.DB {
 	t.Helper()
 
 	// Isolate DB files under a temp dir and configure package-level dbFolder
@@ -28,7 +29,7 @@ func setupTestDB(t *testing.T) *bun.DB {
 	dsn := filepath.Join(tmp, "testdb.sqlite")
 
 	// Ensure the file exists because OpenDB expects an existing SQLite file path
-	if _, err := createSQLiteDBFile(dsn, dbFolder); err != nil {
+	if _, err := createSQLiteDBFile(context.Background(), dsn, dbFolder); err != nil {
 		t.Fatalf("createSQLiteDBFile failed: %v", err)
 	}
 
@@ -92,7 +93,7 @@ func TestDbMethodSwitchesBetweenDBAndTx(t *testing.T) {
 	}
 
 	// Start transaction -> Db() should be bun.Tx
-	if err := tx.Start(nil); err != nil {
+	if _, err := tx.Start(nil); err != nil {
 		t.Fatalf("Start error: %v", err)
 	}
 	switch tx.Db().(type) {
@@ -120,7 +121,7 @@ func TestOuterCommitAndRollback(t *testing.T) {
 	tx := mustNewTx(t, db)
 
 	// Start outer tx, insert 1 row, commit
-	if err := tx.Start(nil); err != nil {
+	if _, err := tx.Start(nil); err != nil {
 		t.Fatalf("Start error: %v", err)
 	}
 	insertItem(t, tx.Db(), "a")
@@ -132,7 +133,7 @@ func TestOuterCommitAndRollback(t *testing.T) {
 	}
 
 	// Start outer tx, insert 1 row, rollback
-	if err := tx.Start(nil); err != nil {
+	if _, err := tx.Start(nil); err != nil {
 		t.Fatalf("Start error: %v", err)
 	}
 	insertItem(t, tx.Db(), "b")
@@ -149,13 +150,13 @@ func TestNestedTransactionsCommitInnerThenOuter(t *testing.T) {
 	tx := mustNewTx(t, db)
 
 	// Outer
-	if err := tx.Start(nil); err != nil {
+	if _, err := tx.Start(nil); err != nil {
 		t.Fatalf("Start outer error: %v", err)
 	}
 	insertItem(t, tx.Db(), "outer-a")
 
 	// Inner (savepoint)
-	if err := tx.Start(nil); err != nil {
+	if _, err := tx.Start(nil); err != nil {
 		t.Fatalf("Start inner error: %v", err)
 	}
 	insertItem(t, tx.Db(), "inner-b")
@@ -180,13 +181,13 @@ func TestNestedTransactionsRollbackInnerCommitOuter(t *testing.T) {
 	tx := mustNewTx(t, db)
 
 	// Outer
-	if err := tx.Start(nil); err != nil {
+	if _, err := tx.Start(nil); err != nil {
 		t.Fatalf("Start outer error: %v", err)
 	}
 	insertItem(t, tx.Db(), "outer-a")
 
 	// Inner
-	if err := tx.Start(nil); err != nil {
+	if _, err := tx.Start(nil); err != nil {
 		t.Fatalf("Start inner error: %v", err)
 	}
 	insertItem(t, tx.Db(), "inner-b")
@@ -282,8 +283,42 @@ func TestTransactionDoubleRollbackSafety(t *testing.T) {
 		})
 	})
 
-	if tx.nested != 0 {
-		t.Fatalf("expected nested to be 0, got %d", tx.nested)
+	if tx.depth() != 0 {
+		t.Fatalf("expected nested to be 0, got %d", tx.depth())
+	}
+}
+
+func TestRunWithoutTx(t *testing.T) {
+	db := setupTestDB(t)
+	tx := mustNewTx(t, db)
+
+	// TransactionWithoutTx runs fn against the db, so a failing fn does not undo its writes
+	wantErr := errors.New("boom")
+	err := tx.Transaction(nil, func(ctx context.Context) error {
+		if _, ok := tx.Db().(*bun.DB); !ok {
+			t.Errorf("expected Db() to be *bun.DB without tx, got %T", tx.Db())
+		}
+		insertItem(t, tx.Db(), "kept")
+		return wantErr
+	}, TransactionWithoutTx())
+	if !errors.Is(err, wantErr) {
+		t.Fatalf("expected function error, got %v", err)
+	}
+	if got := countItems(t, db); got != 1 {
+		t.Fatalf("want 1 after TransactionWithoutTx, got %d", got)
+	}
+
+	// Cannot step outside of an active transaction
+	err = tx.Transaction(nil, func(ctx context.Context) error {
+		return tx.RunWithoutTx(ctx, func(ctx context.Context) error { return nil })
+	})
+	if !errors.Is(err, ErrTxActive) {
+		t.Fatalf("expected ErrTxActive, got %v", err)
+	}
+
+	err = tx.RunWithoutTx(context.Background(), func(ctx context.Context) error { panic("kaboom") })
+	if err == nil || !contains(err.Error(), "panic recovered in RunWithoutTx: kaboom") {
+		t.Fatalf("expected recovered panic, got %v", err)
 	}
 }
 
@@ -307,6 +342,233 @@ func TestCommitRollbackWithoutActiveTx(t *testing.T) {
 	}
 }
 
+func TestTxGoroutineAffinity(t *testing.T) {
+	db := setupTestDB(t)
+	tx := mustNewTx(t, db)
+
+	if _, err := tx.Start(nil); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+
+	errs := make(chan error, 3)
+	go func() {
+		_, err := tx.Start(nil)
+		errs <- err
+		errs <- tx.Commit()
+		errs <- tx.Rollback()
+	}()
+	for range 3 {
+		if err := <-errs; !errors.Is(err, ErrTxWrongGoroutine) {
+			t.Fatalf("expected ErrTxWrongGoroutine, got %v", err)
+		}
+	}
+
+	// The owner is unaffected
+	if tx.depth() != 1 {
+		t.Fatalf("expected depth 1, got %d", tx.depth())
+	}
+	insertItem(t, tx.Db(), "a")
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("Commit failed: %v", err)
+	}
+
+	// Once the transaction ended, any goroutine can start the next one
+	go func() {
+		if _, err := tx.Start(nil); err != nil {
+			errs <- err
+			return
+		}
+		errs <- tx.Rollback()
+	}()
+	if err := <-errs; err != nil {
+		t.Fatalf("expected a new tx on another goroutine, got %v", err)
+	}
+}
+
+func TestTxHandleUnbalanced(t *testing.T) {
+	db := setupTestDB(t)
+	tx := mustNewTx(t, db)
+
+	outer, err := tx.Start(nil)
+	if err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	inner, err := tx.Start(nil)
+	if err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	if outer.Level() != 1 || inner.Level() != 2 {
+		t.Fatalf("unexpected levels %d and %d", outer.Level(), inner.Level())
+	}
+
+	// The outer handle cannot end the transaction while the savepoint is open
+	if err := outer.Commit(); !errors.Is(err, ErrUnbalancedTx) {
+		t.Fatalf("expected ErrUnbalancedTx, got %v", err)
+	}
+	if err := outer.Rollback(); !errors.Is(err, ErrUnbalancedTx) {
+		t.Fatalf("expected ErrUnbalancedTx, got %v", err)
+	}
+	insertItem(t, tx.Db(), "a")
+	if err := inner.Commit(); err != nil {
+		t.Fatalf("inner Commit failed: %v", err)
+	}
+	// An ended level cannot be ended again
+	if err := inner.Commit(); !errors.Is(err, ErrUnbalancedTx) {
+		t.Fatalf("expected ErrUnbalancedTx, got %v", err)
+	}
+	if err := outer.Commit(); err != nil {
+		t.Fatalf("outer Commit failed: %v", err)
+	}
+	if err := outer.Rollback(); !errors.Is(err, ErrUnbalancedTx) {
+		t.Fatalf("expected ErrUnbalancedTx, got %v", err)
+	}
+	if got := countItems(t, db); got != 1 {
+		t.Fatalf("expected 1 item, got %d", got)
+	}
+
+	// Transaction rolls back savepoints fn left open along with its own level
+	err = tx.Transaction(nil, func(ctx context.Context) error {
+		if _, err := tx.Start(nil); err != nil {
+			return err
+		}
+		insertItem(t, tx.Db(), "b")
+		return nil
+	})
+	if !errors.Is(err, ErrUnbalancedTx) {
+		t.Fatalf("expected ErrUnbalancedTx, got %v", err)
+	}
+	if tx.depth() != 0 || countItems(t, db) != 1 {
+		t.Fatalf("expected the tx to be rolled back, depth %d", tx.depth())
+	}
+}
+
+func TestTxAbortedOnContextCancel(t *testing.T) {
+	db := setupTestDB(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	tx, err := NewTransact(ctx, db)
+	if err != nil {
+		t.Fatalf("NewTransact failed: %v", err)
+	}
+
+	waitAborted := func() {
+		t.Helper()
+		deadline := time.Now().Add(5 * time.Second)
+		for tx.depth() != 0 {
+			if time.Now().After(deadline) {
+				t.Fatalf("tx was not rolled back after cancel")
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}
+
+	h, err := tx.Start(nil)
+	if err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	if _, err := tx.Start(nil); err != nil {
+		t.Fatalf("nested Start failed: %v", err)
+	}
+	insertItem(t, tx.Db(), "a")
+	cancel()
+	waitAborted()
+
+	if err := h.Commit(); !errors.Is(err, ErrTxAborted) || !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected ErrTxAborted caused by context.Canceled, got %v", err)
+	}
+	if err := h.Rollback(); err != nil {
+		t.Fatalf("expected Rollback of an aborted tx to succeed, got %v", err)
+	}
+	if got := countItems(t, db); got != 0 {
+		t.Fatalf("expected the insert to be rolled back, got %d items", got)
+	}
+
+	// Transaction reports the abort when fn does not notice the cancellation
+	ctx, cancel = context.WithCancel(context.Background())
+	if tx, err = NewTransact(ctx, db); err != nil {
+		t.Fatalf("NewTransact failed: %v", err)
+	}
+	err = tx.Transaction(nil, func(ctx context.Context) error {
+		insertItem(t, tx.Db(), "b")
+		cancel()
+		waitAborted()
+		return nil
+	})
+	if !errors.Is(err, ErrTxAborted) {
+		t.Fatalf("expected ErrTxAborted, got %v", err)
+	}
+	if got := countItems(t, db); got != 0 {
+		t.Fatalf("expected the insert to be rolled back, got %d items", got)
+	}
+}
+
+func TestTxMaxDepth(t *testing.T) {
+	db := setupTestDB(t)
+	tx, err := NewTransact(context.Background(), db, TransactWithMaxDepth(3))
+	if err != nil {
+		t.Fatalf("NewTransact failed: %v", err)
+	}
+
+	var recurse func(ctx context.Context) error
+	levels := 0
+	recurse = func(ctx context.Context) error {
+		levels++
+		return tx.Transaction(nil, recurse)
+	}
+	err = tx.Transaction(nil, recurse)
+	if !errors.Is(err, ErrTxTooDeep) {
+		t.Fatalf("expected ErrTxTooDeep, got %v", err)
+	}
+	if levels != 3 {
+		t.Fatalf("expected 3 levels to run, got %d", levels)
+	}
+	if msg := err.Error(); strings.Count(msg, "transact_test.go") != 3 || !strings.Contains(msg, "limit 3") {
+		t.Fatalf("expected the start sites of the 3 levels, got %v", err)
+	}
+	if tx.depth() != 0 {
+		t.Fatalf("expected every level to be rolled back, depth %d", tx.depth())
+	}
+
+	// The default limit is far above normal nesting
+	tx = mustNewTx(t, db)
+	if tx.maxDepth != DefaultMaxTxDepth {
+		t.Fatalf("expected the default max depth, got %d", tx.maxDepth)
+	}
+}
+
 // Silence staticcheck warning about unused import in tests when running in certain modes
 var _ = fmt.Sprintf
 var _ = os.Stat
+
+func BenchmarkTransactDb(b *testing.B) {
+	db := setupTestDB(b)
+	tx, err := NewTransact(context.Background(), db)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			_ = tx.Db()
+		}
+	})
+}
+
+func BenchmarkTransactNested(b *testing.B) {
+	db := setupTestDB(b)
+	tx, err := NewTransact(context.Background(), db)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	for b.Loop() {
+		err := tx.Transaction(nil, func(ctx context.Context) error {
+			return tx.Transaction(nil, func(ctx context.Context) error {
+				_ = tx.Db()
+				return nil
+			})
+		})
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,195 @@
+package dbx
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/dialect"
+)
+
+// MaxTreeDepth bounds the recursion of ListDescendants and ListAncestors, so a cycle in the data ends the query
+const MaxTreeDepth = 1000
+
+// TreeNode is a row of a tree stored as an adjacency list, at Depth levels from the node a query started from
+type TreeNode[ID any] struct {
+	ID       ID           `bun:"id"`
+	ParentID sql.Null[ID] `bun:"parent_id"`
+	Depth    int          `bun:"depth"`
+}
+
+// ListDescendants returns the rows below rootID in the tree of table, where parentColumn references idColumn of
+// the parent row, ordered by depth (1 for the children of rootID) then id. rootID itself is not returned.
+//
+// Supported dialects are SQLite (3.8.3+), Postgres, MySQL (8.0+) and MSSQL.
+func ListDescendants[ID any](ctx context.Context, idb bun.IDB, table, idColumn, parentColumn string, rootID ID) ([]TreeNode[ID], error) {
+	with, err := recursiveWith(idb)
+	if err != nil {
+		return nil, err
+	}
+	var nodes []TreeNode[ID]
+	err = idb.NewRaw(with+` tree (id, parent_id, depth) AS (
+			SELECT ?1, ?2, 1 FROM ?0 WHERE ?2 = ?3
+			UNION ALL
+			SELECT c.?1, c.?2, tree.depth + 1 FROM ?0 AS c JOIN tree ON c.?2 = tree.id WHERE tree.depth < ?4
+		)
+		SELECT id, parent_id, depth FROM tree ORDER BY depth, id`,
+		bun.Ident(table), bun.Ident(idColumn), bun.Ident(parentColumn), rootID, MaxTreeDepth,
+	).Scan(ctx, &nodes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list descendants in %s: %w", table, err)
+	}
+	return nodes, nil
+}
+
+// ListAncestors returns the rows above id in the tree of table, from its parent (depth 1) up to the root.
+// id itself is not returned. Supported dialects are those of ListDescendants.
+func ListAncestors[ID any](ctx context.Context, idb bun.IDB, table, idColumn, parentColumn string, id ID) ([]TreeNode[ID], error) {
+	with, err := recursiveWith(idb)
+	if err != nil {
+		return nil, err
+	}
+	var nodes []TreeNode[ID]
+	err = idb.NewRaw(with+` tree (id, parent_id, depth) AS (
+			SELECT p.?1, p.?2, 1 FROM ?0 AS p JOIN ?0 AS n ON p.?1 = n.?2 WHERE n.?1 = ?3
+			UNION ALL
+			SELECT p.?1, p.?2, tree.depth + 1 FROM ?0 AS p JOIN tree ON p.?1 = tree.parent_id WHERE tree.depth < ?4
+		)
+		SELECT id, parent_id, depth FROM tree ORDER BY depth`,
+		bun.Ident(table), bun.Ident(idColumn), bun.Ident(parentColumn), id, MaxTreeDepth,
+	).Scan(ctx, &nodes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list ancestors in %s: %w", table, err)
+	}
+	return nodes, nil
+}
+
+// recursiveWith returns the keyword opening a recursive CTE: MSSQL has no RECURSIVE
+func recursiveWith(idb bun.IDB) (string, error) {
+	switch dName := idb.Dialect().Name(); dName {
+	case dialect.SQLite, dialect.PG, dialect.MySQL:
+		return "WITH RECURSIVE", nil
+	case dialect.MSSQL:
+		return "WITH", nil
+	default:
+		return "", fmt.Errorf("unsupported dialect: %s", dName)
+	}
+}
+
+// ClosureTable returns the name of the closure table of table: table_closure
+func ClosureTable(table string) string {
+	return table + "_closure"
+}
+
+// RebuildClosure creates the closure table of table if it does not exist and refills it from the adjacency list:
+// a row (ancestor, descendant, depth) for every node and each of its ancestors, and (id, id, 0) for the node itself.
+// Queries on the closure table find whole subtrees with an index lookup instead of a recursion.
+// Ids must be integers. Supported dialects are those of ListDescendants.
+func RebuildClosure(ctx context.Context, idb bun.IDB, table, idColumn, parentColumn string) error {
+	with, err := recursiveWith(idb)
+	if err != nil {
+		return err
+	}
+	closure := bun.Ident(ClosureTable(table))
+	create := `CREATE TABLE IF NOT EXISTS ?0 (`
+	if idb.Dialect().Name() == dialect.MSSQL {
+		create = `IF OBJECT_ID(?5, 'U') IS NULL CREATE TABLE ?0 (`
+	}
+	create += `ancestor BIGINT NOT NULL, descendant BIGINT NOT NULL, depth INTEGER NOT NULL,
+		PRIMARY KEY (ancestor, descendant))`
+
+	paths := with + ` paths (ancestor, descendant, depth) AS (
+			SELECT ?2, ?2, 0 FROM ?1
+			UNION ALL
+			SELECT paths.ancestor, c.?2, paths.depth + 1 FROM ?1 AS c JOIN paths ON c.?3 = paths.descendant
+			WHERE paths.depth < ?4
+		)`
+	fill := `INSERT INTO ?0 (ancestor, descendant, depth) ` + paths + ` SELECT ancestor, descendant, depth FROM paths`
+	if idb.Dialect().Name() == dialect.MSSQL {
+		// MSSQL takes the CTE before the INSERT only
+		fill = paths + ` INSERT INTO ?0 (ancestor, descendant, depth) SELECT ancestor, descendant, depth FROM paths`
+	}
+	statements := []string{create, `DELETE FROM ?0`, fill}
+	for _, stmt := range statements {
+		if _, err := idb.ExecContext(ctx, stmt, closure, bun.Ident(table), bun.Ident(idColumn), bun.Ident(parentColumn),
+			MaxTreeDepth, ClosureTable(table)); err != nil {
+			return fmt.Errorf("failed to rebuild closure table of %s: %w", table, err)
+		}
+	}
+	return nil
+}
+
+// MaintainClosure rebuilds the closure table of table (see RebuildClosure) and installs triggers keeping it in
+// sync with inserts, deletes and moves of subtrees, in the same transaction as the change.
+// Deleting a node removes its own paths; delete or move its children first.
+//
+// Supported dialects are SQLite and Postgres. Run it inside a migration, like MaintainCounter.
+func MaintainClosure(ctx context.Context, idb bun.IDB, table, idColumn, parentColumn string) error {
+	name := "dbx_closure_" + table
+	var statements []string
+	switch dName := idb.Dialect().Name(); dName {
+	case dialect.SQLite:
+		statements = []string{
+			`CREATE TRIGGER IF NOT EXISTS ?0 AFTER INSERT ON ?3 BEGIN
+				INSERT INTO ?1 (ancestor, descendant, depth) VALUES (NEW.?4, NEW.?4, 0);
+				INSERT INTO ?1 (ancestor, descendant, depth)
+					SELECT ancestor, NEW.?4, depth + 1 FROM ?1 WHERE descendant = NEW.?5;
+			END`,
+			`CREATE TRIGGER IF NOT EXISTS ?2 AFTER DELETE ON ?3 BEGIN
+				DELETE FROM ?1 WHERE descendant = OLD.?4 OR ancestor = OLD.?4;
+			END`,
+			`CREATE TRIGGER IF NOT EXISTS ?6 AFTER UPDATE OF ?5 ON ?3 WHEN OLD.?5 IS NOT NEW.?5 BEGIN
+				DELETE FROM ?1
+					WHERE descendant IN (SELECT descendant FROM ?1 WHERE ancestor = NEW.?4)
+					AND ancestor NOT IN (SELECT descendant FROM ?1 WHERE ancestor = NEW.?4);
+				INSERT INTO ?1 (ancestor, descendant, depth)
+					SELECT super.ancestor, sub.descendant, super.depth + sub.depth + 1
+					FROM ?1 AS super, ?1 AS sub
+					WHERE super.descendant = NEW.?5 AND sub.ancestor = NEW.?4;
+			END`,
+		}
+	case dialect.PG:
+		statements = []string{
+			`CREATE OR REPLACE FUNCTION ?7() RETURNS trigger AS $$
+			BEGIN
+				IF TG_OP = 'DELETE' THEN
+					DELETE FROM ?1 WHERE descendant = OLD.?4 OR ancestor = OLD.?4;
+				ELSIF TG_OP = 'INSERT' THEN
+					INSERT INTO ?1 (ancestor, descendant, depth) VALUES (NEW.?4, NEW.?4, 0);
+					INSERT INTO ?1 (ancestor, descendant, depth)
+						SELECT ancestor, NEW.?4, depth + 1 FROM ?1 WHERE descendant = NEW.?5;
+				ELSIF OLD.?5 IS DISTINCT FROM NEW.?5 THEN
+					DELETE FROM ?1
+						WHERE descendant IN (SELECT descendant FROM ?1 WHERE ancestor = NEW.?4)
+						AND ancestor NOT IN (SELECT descendant FROM ?1 WHERE ancestor = NEW.?4);
+					INSERT INTO ?1 (ancestor, descendant, depth)
+						SELECT super.ancestor, sub.descendant, super.depth + sub.depth + 1
+						FROM ?1 AS super, ?1 AS sub
+						WHERE super.descendant = NEW.?5 AND sub.ancestor = NEW.?4;
+				END IF;
+				RETURN NULL;
+			END;
+			$$ LANGUAGE plpgsql`,
+			`DROP TRIGGER IF EXISTS ?8 ON ?3`,
+			`CREATE TRIGGER ?8 AFTER INSERT OR DELETE OR UPDATE OF ?5 ON ?3 FOR EACH ROW EXECUTE FUNCTION ?7()`,
+		}
+	default:
+		return fmt.Errorf("unsupported dialect: %s", dName)
+	}
+
+	if err := RebuildClosure(ctx, idb, table, idColumn, parentColumn); err != nil {
+		return err
+	}
+	args := []any{
+		bun.Ident(name + "_ins"), bun.Ident(ClosureTable(table)), bun.Ident(name + "_del"), bun.Ident(table),
+		bun.Ident(idColumn), bun.Ident(parentColumn), bun.Ident(name + "_upd"), bun.Ident(name + "_fn"),
+		bun.Ident(name),
+	}
+	for _, stmt := range statements {
+		if _, err := idb.ExecContext(ctx, stmt, args...); err != nil {
+			return fmt.Errorf("failed to maintain closure table of %s: %w", table, err)
+		}
+	}
+	return nil
+}
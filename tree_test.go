@@ -0,0 +1,111 @@
+package dbx
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"testing"
+
+	"github.com/uptrace/bun"
+)
+
+func setupTree(t *testing.T) *bun.DB {
+	t.Helper()
+	db := setupTestDB(t)
+	// 1 ─┬─ 2 ── 4 ── 5
+	//    └─ 3
+	if _, err := db.ExecContext(context.Background(), `
+		CREATE TABLE categories (id INTEGER PRIMARY KEY, parent_id INTEGER REFERENCES categories(id));
+		INSERT INTO categories (id, parent_id) VALUES (1, NULL), (2, 1), (3, 1), (4, 2), (5, 4);
+	`); err != nil {
+		t.Fatalf("create tree failed: %v", err)
+	}
+	return db
+}
+
+func formatNodes(nodes []TreeNode[int64]) string {
+	var s string
+	for _, n := range nodes {
+		s += fmt.Sprintf("%d<%d@%d ", n.ID, n.ParentID.V, n.Depth)
+	}
+	return s
+}
+
+func TestListDescendantsAndAncestors(t *testing.T) {
+	ctx := context.Background()
+	db := setupTree(t)
+
+	nodes, err := ListDescendants(ctx, db, "categories", "id", "parent_id", int64(1))
+	if err != nil {
+		t.Fatalf("ListDescendants failed: %v", err)
+	}
+	if got, want := formatNodes(nodes), "2<1@1 3<1@1 4<2@2 5<4@3 "; got != want {
+		t.Fatalf("expected descendants %q, got %q", want, got)
+	}
+
+	nodes, err = ListAncestors(ctx, db, "categories", "id", "parent_id", int64(5))
+	if err != nil {
+		t.Fatalf("ListAncestors failed: %v", err)
+	}
+	if got, want := formatNodes(nodes), "4<2@1 2<1@2 1<0@3 "; got != want {
+		t.Fatalf("expected ancestors %q, got %q", want, got)
+	}
+	if nodes[2].ParentID.Valid {
+		t.Fatalf("expected the root to have no parent")
+	}
+
+	if nodes, err = ListDescendants(ctx, db, "categories", "id", "parent_id", int64(5)); err != nil || len(nodes) != 0 {
+		t.Fatalf("expected no descendants of a leaf, got %v, %v", nodes, err)
+	}
+}
+
+func TestMaintainClosure(t *testing.T) {
+	ctx := context.Background()
+	db := setupTree(t)
+	if err := MaintainClosure(ctx, db, "categories", "id", "parent_id"); err != nil {
+		t.Fatalf("MaintainClosure failed: %v", err)
+	}
+
+	closure := func() []string {
+		t.Helper()
+		var rows []struct {
+			Ancestor, Descendant int64
+			Depth                int
+		}
+		if err := db.NewSelect().Table(ClosureTable("categories")).Column("ancestor", "descendant", "depth").
+			Order("ancestor", "descendant").Scan(ctx, &rows); err != nil {
+			t.Fatalf("read closure failed: %v", err)
+		}
+		var paths []string
+		for _, r := range rows {
+			paths = append(paths, fmt.Sprintf("%d>%d@%d", r.Ancestor, r.Descendant, r.Depth))
+		}
+		return paths
+	}
+	if paths := closure(); !slices.Contains(paths, "1>5@3") || len(paths) != 12 {
+		t.Fatalf("unexpected initial closure %v", paths)
+	}
+
+	for _, stmt := range []string{
+		"INSERT INTO categories (id, parent_id) VALUES (6, 3)",
+		"UPDATE categories SET parent_id = 3 WHERE id = 4",
+		"DELETE FROM categories WHERE id = 5",
+		"INSERT INTO categories (id, parent_id) VALUES (7, NULL)",
+		"UPDATE categories SET parent_id = 7 WHERE id = 3",
+	} {
+		if _, err := db.ExecContext(ctx, stmt); err != nil {
+			t.Fatalf("%s failed: %v", stmt, err)
+		}
+	}
+
+	maintained := closure()
+	if err := RebuildClosure(ctx, db, "categories", "id", "parent_id"); err != nil {
+		t.Fatalf("RebuildClosure failed: %v", err)
+	}
+	if rebuilt := closure(); !slices.Equal(maintained, rebuilt) {
+		t.Fatalf("maintained closure %v differs from the rebuilt one %v", maintained, rebuilt)
+	}
+	if !slices.Contains(maintained, "7>4@2") || slices.Contains(maintained, "1>4@2") {
+		t.Fatalf("expected 4 moved under 7 through 3, got %v", maintained)
+	}
+}
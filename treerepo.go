@@ -0,0 +1,280 @@
+package dbx
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/schema"
+)
+
+// ErrTreeCycle is returned by TreeRepo.Move when the new parent is the node itself or one of its descendants
+var ErrTreeCycle = errors.New("tree move would create a cycle")
+
+// ErrTreeMismatch is returned by TreeRepo.Reorder when the ids are not exactly the children of the parent
+var ErrTreeMismatch = errors.New("ids are not the children of the parent")
+
+// TreeRepo reads and rearranges a tree of bun models of type T stored as an adjacency list: each row references its
+// parent through parentColumn (NULL for the roots) and holds its rank among its siblings in positionColumn, from 0.
+// T needs a single primary key. Moves and reorders run in a transaction of the Transact they are given, so the
+// positions of the siblings are never left half shifted. The queries of the repo go through ApplyQueryPolicies.
+type TreeRepo[T any] struct {
+	parentColumn   string
+	positionColumn string
+}
+
+// NewTreeRepo returns a repository of the tree of T, in the columns named parentColumn and positionColumn
+func NewTreeRepo[T any](parentColumn, positionColumn string) *TreeRepo[T] {
+	return &TreeRepo[T]{parentColumn: parentColumn, positionColumn: positionColumn}
+}
+
+// treeTable returns the table of T and its primary key, checking the columns of the repo exist
+func (r *TreeRepo[T]) treeTable(idb bun.IDB) (*schema.Table, *schema.Field, error) {
+	table := idb.Dialect().Tables().Get(reflect.TypeFor[T]())
+	if len(table.PKs) != 1 {
+		return nil, nil, fmt.Errorf("%w: tree repo needs a single primary key, %s has %d",
+			ErrInvalidOptions, table.TypeName, len(table.PKs))
+	}
+	for _, column := range []string{r.parentColumn, r.positionColumn} {
+		if !table.HasField(column) {
+			return nil, nil, fmt.Errorf("%w: %s has no column %s", ErrInvalidOptions, table.TypeName, column)
+		}
+	}
+	return table, table.PKs[0], nil
+}
+
+// whereParent filters q on the children of parentID, the roots for a nil parentID
+func (r *TreeRepo[T]) whereParent(q bun.QueryBuilder, parentID any) bun.QueryBuilder {
+	if parentID == nil {
+		return q.Where("? IS NULL", bun.Ident(r.parentColumn))
+	}
+	return q.Where("? = ?", bun.Ident(r.parentColumn), parentID)
+}
+
+// Children returns the children of parentID by position, the roots for a nil parentID
+func (r *TreeRepo[T]) Children(ctx context.Context, idb bun.IDB, parentID any) ([]T, error) {
+	if _, _, err := r.treeTable(idb); err != nil {
+		return nil, err
+	}
+	var children []T
+	q := idb.NewSelect().Model(&children).Order(r.positionColumn)
+	r.whereParent(q.QueryBuilder(), parentID)
+	if err := ApplyQueryPolicies(ctx, q.QueryBuilder()); err != nil {
+		return nil, err
+	}
+	if err := q.Scan(ctx); err != nil {
+		return nil, err
+	}
+	return children, nil
+}
+
+// Subtree returns rootID and all the nodes below it, ordered by depth then position
+func (r *TreeRepo[T]) Subtree(ctx context.Context, idb bun.IDB, rootID any) ([]T, error) {
+	table, pk, err := r.treeTable(idb)
+	if err != nil {
+		return nil, err
+	}
+	nodes, err := ListDescendants(ctx, idb, table.Name, pk.Name, r.parentColumn, rootID)
+	if err != nil {
+		return nil, err
+	}
+	ids := []any{rootID}
+	depths := map[string]int{fmt.Sprint(rootID): 0}
+	for _, n := range nodes {
+		ids = append(ids, n.ID)
+		depths[fmt.Sprint(n.ID)] = n.Depth
+	}
+	rows, err := r.byIDs(ctx, idb, pk, ids)
+	if err != nil {
+		return nil, err
+	}
+	position := table.FieldMap[r.positionColumn]
+	slices.SortStableFunc(rows, func(a, b T) int {
+		if d := depths[fmt.Sprint(fieldValue(pk, &a))] - depths[fmt.Sprint(fieldValue(pk, &b))]; d != 0 {
+			return d
+		}
+		return int(reflect.ValueOf(fieldValue(position, &a)).Int() - reflect.ValueOf(fieldValue(position, &b)).Int())
+	})
+	return rows, nil
+}
+
+// Ancestors returns the nodes above id, from its parent up to the root
+func (r *TreeRepo[T]) Ancestors(ctx context.Context, idb bun.IDB, id any) ([]T, error) {
+	table, pk, err := r.treeTable(idb)
+	if err != nil {
+		return nil, err
+	}
+	nodes, err := ListAncestors(ctx, idb, table.Name, pk.Name, r.parentColumn, id)
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]any, len(nodes))
+	for i, n := range nodes {
+		ids[i] = n.ID
+	}
+	return r.byIDs(ctx, idb, pk, ids)
+}
+
+// byIDs loads the rows of ids, in the order of ids
+func (r *TreeRepo[T]) byIDs(ctx context.Context, idb bun.IDB, pk *schema.Field, ids []any) ([]T, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	var rows []T
+	q := idb.NewSelect().Model(&rows).Where("?TableAlias.? IN (?)", bun.Ident(pk.Name), bun.In(ids))
+	if err := ApplyQueryPolicies(ctx, q.QueryBuilder()); err != nil {
+		return nil, err
+	}
+	if err := q.Scan(ctx); err != nil {
+		return nil, err
+	}
+	rank := make(map[string]int, len(ids))
+	for i, id := range ids {
+		rank[fmt.Sprint(id)] = i
+	}
+	slices.SortFunc(rows, func(a, b T) int {
+		return rank[fmt.Sprint(fieldValue(pk, &a))] - rank[fmt.Sprint(fieldValue(pk, &b))]
+	})
+	return rows, nil
+}
+
+// fieldValue returns the value of field in row
+func fieldValue[T any](field *schema.Field, row *T) any {
+	return reflect.ValueOf(row).Elem().FieldByIndex(field.Index).Interface()
+}
+
+// Move makes id the child of newParentID (a root for nil) at position, shifting the siblings it leaves and joins.
+// position is clamped to the number of siblings, so -1 or a large value appends. Moving a node under itself or
+// one of its descendants fails with ErrTreeCycle.
+func (r *TreeRepo[T]) Move(t *Transact, id, newParentID any, position int) error {
+	table, pk, err := r.treeTable(t.Db())
+	if err != nil {
+		return err
+	}
+	return t.Transaction(nil, func(ctx context.Context) error {
+		db := t.Db()
+		if newParentID != nil {
+			if fmt.Sprint(newParentID) == fmt.Sprint(id) {
+				return fmt.Errorf("%w: %v under itself", ErrTreeCycle, id)
+			}
+			ancestors, err := ListAncestors(ctx, db, table.Name, pk.Name, r.parentColumn, newParentID)
+			if err != nil {
+				return err
+			}
+			for _, a := range ancestors {
+				if fmt.Sprint(a.ID) == fmt.Sprint(id) {
+					return fmt.Errorf("%w: %v under its descendant %v", ErrTreeCycle, id, newParentID)
+				}
+			}
+		}
+
+		var old struct {
+			Parent   any `bun:"parent"`
+			Position int `bun:"position"`
+		}
+		sel := db.NewSelect().Model((*T)(nil)).
+			ColumnExpr("? AS parent, ? AS position", bun.Ident(r.parentColumn), bun.Ident(r.positionColumn)).
+			Where("? = ?", bun.Ident(pk.Name), id)
+		if err := ApplyQueryPolicies(ctx, sel.QueryBuilder()); err != nil {
+			return err
+		}
+		if err := sel.Scan(ctx, &old); err != nil {
+			return err
+		}
+
+		// Close the gap among the old siblings, then count the new ones without the node itself
+		q := db.NewUpdate().Model((*T)(nil)).
+			Set("? = ? - 1", bun.Ident(r.positionColumn), bun.Ident(r.positionColumn)).
+			Where("? > ?", bun.Ident(r.positionColumn), old.Position)
+		r.whereParent(q.QueryBuilder(), old.Parent)
+		if err := ApplyQueryPolicies(ctx, q.QueryBuilder()); err != nil {
+			return err
+		}
+		if _, err := q.Exec(ctx); err != nil {
+			return err
+		}
+		count := db.NewSelect().Model((*T)(nil)).Where("? <> ?", bun.Ident(pk.Name), id)
+		r.whereParent(count.QueryBuilder(), newParentID)
+		if err := ApplyQueryPolicies(ctx, count.QueryBuilder()); err != nil {
+			return err
+		}
+		n, err := count.Count(ctx)
+		if err != nil {
+			return err
+		}
+		if position < 0 || position > n {
+			position = n
+		}
+
+		q = db.NewUpdate().Model((*T)(nil)).
+			Set("? = ? + 1", bun.Ident(r.positionColumn), bun.Ident(r.positionColumn)).
+			Where("? >= ?", bun.Ident(r.positionColumn), position).
+			Where("? <> ?", bun.Ident(pk.Name), id)
+		r.whereParent(q.QueryBuilder(), newParentID)
+		if err := ApplyQueryPolicies(ctx, q.QueryBuilder()); err != nil {
+			return err
+		}
+		if _, err := q.Exec(ctx); err != nil {
+			return err
+		}
+		q = db.NewUpdate().Model((*T)(nil)).
+			Set("? = ?", bun.Ident(r.parentColumn), newParentID).
+			Set("? = ?", bun.Ident(r.positionColumn), position).
+			Where("? = ?", bun.Ident(pk.Name), id)
+		if err := ApplyQueryPolicies(ctx, q.QueryBuilder()); err != nil {
+			return err
+		}
+		_, err = q.Exec(ctx)
+		return err
+	})
+}
+
+// Reorder sets the positions of the children of parentID (the roots for nil) to their order in ids, which must
+// hold each child once; otherwise it fails with ErrTreeMismatch.
+func (r *TreeRepo[T]) Reorder(t *Transact, parentID any, ids []any) error {
+	_, pk, err := r.treeTable(t.Db())
+	if err != nil {
+		return err
+	}
+	return t.Transaction(nil, func(ctx context.Context) error {
+		db := t.Db()
+		var current []any
+		q := db.NewSelect().Model((*T)(nil)).Column(pk.Name)
+		r.whereParent(q.QueryBuilder(), parentID)
+		if err := ApplyQueryPolicies(ctx, q.QueryBuilder()); err != nil {
+			return err
+		}
+		if err := q.Scan(ctx, &current); err != nil {
+			return err
+		}
+		want := make([]string, len(ids))
+		for i, id := range ids {
+			want[i] = fmt.Sprint(id)
+		}
+		got := make([]string, len(current))
+		for i, id := range current {
+			got[i] = fmt.Sprint(id)
+		}
+		slices.Sort(want)
+		slices.Sort(got)
+		if !slices.Equal(want, got) {
+			return fmt.Errorf("%w: %v", ErrTreeMismatch, ids)
+		}
+
+		for i, id := range ids {
+			q := db.NewUpdate().Model((*T)(nil)).
+				Set("? = ?", bun.Ident(r.positionColumn), i).
+				Where("? = ?", bun.Ident(pk.Name), id)
+			if err := ApplyQueryPolicies(ctx, q.QueryBuilder()); err != nil {
+				return err
+			}
+			if _, err := q.Exec(ctx); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
@@ -0,0 +1,103 @@
+package dbx
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+type treeCategory struct {
+	ID       int64  `bun:",pk,autoincrement"`
+	Name     string `bun:",notnull"`
+	ParentID *int64
+	Position int `bun:",notnull"`
+}
+
+func treeNames(cats []treeCategory) string {
+	names := make([]string, len(cats))
+	for i, c := range cats {
+		names[i] = c.Name
+	}
+	return strings.Join(names, ",")
+}
+
+func TestTreeRepo(t *testing.T) {
+	ctx := context.Background()
+	db := setupTestDB(t)
+	if _, err := db.NewCreateTable().Model((*treeCategory)(nil)).Exec(ctx); err != nil {
+		t.Fatalf("create table failed: %v", err)
+	}
+	id := func(n int64) *int64 { return &n }
+	// root ─┬─ a ─┬─ a1
+	//       │     └─ a2
+	//       └─ b
+	cats := []treeCategory{
+		{Name: "root", Position: 0},
+		{Name: "a", ParentID: id(1), Position: 0},
+		{Name: "b", ParentID: id(1), Position: 1},
+		{Name: "a1", ParentID: id(2), Position: 0},
+		{Name: "a2", ParentID: id(2), Position: 1},
+	}
+	if _, err := db.NewInsert().Model(&cats).Exec(ctx); err != nil {
+		t.Fatalf("insert failed: %v", err)
+	}
+
+	repo := NewTreeRepo[treeCategory]("parent_id", "position")
+	tx, err := NewTransact(ctx, db)
+	if err != nil {
+		t.Fatalf("NewTransact failed: %v", err)
+	}
+	expect := func(what string, got []treeCategory, err error, want string) {
+		t.Helper()
+		if err != nil {
+			t.Fatalf("%s failed: %v", what, err)
+		}
+		if names := treeNames(got); names != want {
+			t.Fatalf("%s: expected %s, got %s", what, want, names)
+		}
+	}
+
+	got, err := repo.Subtree(ctx, db, int64(1))
+	expect("Subtree", got, err, "root,a,b,a1,a2")
+	got, err = repo.Ancestors(ctx, db, int64(5))
+	expect("Ancestors", got, err, "a,root")
+
+	// Move a2 under b, then a1 first among the roots
+	if err := repo.Move(tx, int64(5), int64(3), 0); err != nil {
+		t.Fatalf("Move failed: %v", err)
+	}
+	got, err = repo.Children(ctx, db, int64(3))
+	expect("Children of b", got, err, "a2")
+	if err := repo.Move(tx, int64(4), nil, 0); err != nil {
+		t.Fatalf("Move to the roots failed: %v", err)
+	}
+	got, err = repo.Children(ctx, db, nil)
+	expect("roots", got, err, "a1,root")
+	got, err = repo.Children(ctx, db, int64(2))
+	expect("Children of a", got, err, "")
+
+	// Back under root, at the end
+	if err := repo.Move(tx, int64(4), int64(1), -1); err != nil {
+		t.Fatalf("Move to the end failed: %v", err)
+	}
+	got, err = repo.Children(ctx, db, int64(1))
+	expect("Children of root", got, err, "a,b,a1")
+	got, err = repo.Children(ctx, db, nil)
+	expect("roots after move back", got, err, "root")
+
+	if err := repo.Reorder(tx, int64(1), []any{int64(4), int64(2), int64(3)}); err != nil {
+		t.Fatalf("Reorder failed: %v", err)
+	}
+	got, err = repo.Children(ctx, db, int64(1))
+	expect("Children after reorder", got, err, "a1,a,b")
+
+	if err := repo.Move(tx, int64(1), int64(5), 0); !errors.Is(err, ErrTreeCycle) {
+		t.Fatalf("expected ErrTreeCycle moving root under a2, got %v", err)
+	}
+	if err := repo.Reorder(tx, int64(1), []any{int64(4), int64(2)}); !errors.Is(err, ErrTreeMismatch) {
+		t.Fatalf("expected ErrTreeMismatch, got %v", err)
+	}
+	got, err = repo.Subtree(ctx, db, int64(1))
+	expect("Subtree after failed ops", got, err, "root,a1,a,b,a2")
+}
@@ -0,0 +1,104 @@
+package dbx
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/dialect"
+)
+
+// TruncateAll deletes the rows of every table except the ones named in except and the goose version tables,
+// and resets their autoincrement counters, for integration tests sharing one database.
+// Foreign keys are handled per dialect, so tables are cleared in any order: SQLite defers the checks to the commit,
+// Postgres truncates all tables in one statement and MySQL disables the checks on the connection while truncating.
+func TruncateAll(ctx context.Context, db *bun.DB, except ...string) error {
+	tables, err := truncateTables(ctx, db)
+	if err != nil {
+		return fmt.Errorf("failed to list tables: %w", err)
+	}
+	tables = slices.DeleteFunc(tables, func(table string) bool {
+		return slices.Contains(except, table) || strings.HasPrefix(table, "goose_db_version")
+	})
+	if len(tables) == 0 {
+		return nil
+	}
+
+	switch dName := db.Dialect().Name(); dName {
+	case dialect.SQLite:
+		err = db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+			if _, err := tx.ExecContext(ctx, "PRAGMA defer_foreign_keys = ON"); err != nil {
+				return err
+			}
+			for _, table := range tables {
+				if _, err := tx.ExecContext(ctx, "DELETE FROM ?", bun.Ident(table)); err != nil {
+					return err
+				}
+			}
+			// sqlite_sequence only exists once a table with AUTOINCREMENT was created
+			var hasSeq bool
+			if err := tx.NewRaw("SELECT COUNT(*) > 0 FROM sqlite_master WHERE name = 'sqlite_sequence'").Scan(ctx, &hasSeq); err != nil {
+				return err
+			}
+			if !hasSeq {
+				return nil
+			}
+			_, err := tx.ExecContext(ctx, "DELETE FROM sqlite_sequence WHERE name IN (?)", bun.In(tables))
+			return err
+		})
+	case dialect.PG:
+		idents := make([]bun.Ident, len(tables))
+		for i, table := range tables {
+			idents[i] = bun.Ident(table)
+		}
+		_, err = db.ExecContext(ctx, "TRUNCATE TABLE ? RESTART IDENTITY", bun.In(idents))
+	case dialect.MySQL:
+		err = truncateMySQL(ctx, db, tables)
+	default:
+		return fmt.Errorf("unsupported dialect: %s", dName)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to truncate tables: %w", err)
+	}
+	return nil
+}
+
+func truncateTables(ctx context.Context, db *bun.DB) ([]string, error) {
+	var query string
+	switch dName := db.Dialect().Name(); dName {
+	case dialect.SQLite:
+		query = `SELECT name FROM sqlite_master WHERE type = 'table' AND name NOT LIKE 'sqlite\_%' ESCAPE '\' ORDER BY name`
+	case dialect.PG:
+		query = `SELECT tablename FROM pg_tables WHERE schemaname = current_schema() ORDER BY tablename`
+	case dialect.MySQL:
+		query = `SELECT table_name FROM information_schema.tables WHERE table_schema = DATABASE() AND table_type = 'BASE TABLE' ORDER BY table_name`
+	default:
+		return nil, fmt.Errorf("unsupported dialect: %s", dName)
+	}
+
+	var tables []string
+	err := db.NewRaw(query).Scan(ctx, &tables)
+	return tables, err
+}
+
+func truncateMySQL(ctx context.Context, db *bun.DB, tables []string) error {
+	// FOREIGN_KEY_CHECKS is per session, so everything runs on one connection
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(ctx, "SET FOREIGN_KEY_CHECKS = 0"); err != nil {
+		return err
+	}
+	defer conn.ExecContext(context.WithoutCancel(ctx), "SET FOREIGN_KEY_CHECKS = 1")
+	for _, table := range tables {
+		if _, err := conn.ExecContext(ctx, "TRUNCATE TABLE ?", bun.Ident(table)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
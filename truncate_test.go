@@ -0,0 +1,46 @@
+package dbx
+
+import (
+	"context"
+	"testing"
+)
+
+func TestTruncateAll(t *testing.T) {
+	ctx := context.Background()
+	db := setupTestDB(t)
+	_, err := db.ExecContext(ctx, `
+		CREATE TABLE tags (id INTEGER PRIMARY KEY AUTOINCREMENT, item_id INTEGER NOT NULL REFERENCES items (id), name TEXT);
+		CREATE TABLE settings (key TEXT PRIMARY KEY, value TEXT);
+		INSERT INTO settings VALUES ('theme', 'dark');`)
+	if err != nil {
+		t.Fatalf("schema failed: %v", err)
+	}
+
+	insertItem(t, db, "a")
+	insertItem(t, db, "b")
+	if _, err := db.ExecContext(ctx, "INSERT INTO tags (item_id, name) VALUES (1, 'x'), (2, 'y')"); err != nil {
+		t.Fatalf("insert failed: %v", err)
+	}
+
+	// items is cleared before tags, which only works with the FK checks deferred
+	if err := TruncateAll(ctx, db, "settings"); err != nil {
+		t.Fatalf("TruncateAll failed: %v", err)
+	}
+
+	for table, want := range map[string]int{"items": 0, "tags": 0, "settings": 1} {
+		var n int
+		if err := db.NewRaw("SELECT COUNT(*) FROM "+table).Scan(ctx, &n); err != nil {
+			t.Fatalf("count %s failed: %v", table, err)
+		}
+		if n != want {
+			t.Fatalf("expected %d rows in %s, got %d", want, table, n)
+		}
+	}
+
+	// Autoincrement starts over
+	insertItem(t, db, "c")
+	var id int
+	if err := db.NewRaw("SELECT id FROM items").Scan(ctx, &id); err != nil || id != 1 {
+		t.Fatalf("expected id 1 after truncate, got %d (err %v)", id, err)
+	}
+}
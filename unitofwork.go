@@ -0,0 +1,107 @@
+package dbx
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/uptrace/bun"
+)
+
+// EventHandler receives the domain events of a UnitOfWork
+type EventHandler func(ctx context.Context, event any) error
+
+// OutboxEvent is a single row of the dbx_outbox table, holding an event raised by an outbox UnitOfWork
+type OutboxEvent struct {
+	bun.BaseModel `bun:"table:dbx_outbox"`
+
+	ID           int64           `bun:"id,pk,autoincrement"`
+	Type         string          `bun:"type,notnull"`
+	Payload      json.RawMessage `bun:"payload,type:text,notnull"`
+	CreatedAt    time.Time       `bun:"created_at,notnull"`
+	DispatchedAt *time.Time      `bun:"dispatched_at"`
+}
+
+// UnitOfWork collects the domain events raised while a Transact runs and publishes them only when the outermost
+// transaction commits, so no event announces a change that was rolled back.
+//
+// With a handler, events are dispatched in-process after the commit (see Transact.OnCommit); handler errors can no
+// longer undo the commit and are logged. With the outbox, events are written to the dbx_outbox table inside the
+// transaction itself, to be relayed later with PendingOutbox and MarkDispatched.
+type UnitOfWork struct {
+	t       *Transact
+	handler EventHandler
+}
+
+// NewUnitOfWork returns a UnitOfWork dispatching the events raised on t to handler after commit
+func NewUnitOfWork(t *Transact, handler EventHandler) (*UnitOfWork, error) {
+	if t == nil || handler == nil {
+		return nil, errors.New("dbx: NewUnitOfWork with nil transact or handler")
+	}
+	return &UnitOfWork{t: t, handler: handler}, nil
+}
+
+// NewOutboxUnitOfWork creates the dbx_outbox table if it does not exist and returns a UnitOfWork storing the events
+// raised on t in it
+func NewOutboxUnitOfWork(ctx context.Context, t *Transact) (*UnitOfWork, error) {
+	if t == nil {
+		return nil, errors.New("dbx: NewOutboxUnitOfWork with nil transact")
+	}
+	if _, err := t.db.NewCreateTable().Model((*OutboxEvent)(nil)).IfNotExists().Exec(ctx); err != nil {
+		return nil, fmt.Errorf("failed to create outbox table: %w", err)
+	}
+	return &UnitOfWork{t: t}, nil
+}
+
+// Raise records event. Outside of a transaction it is dispatched (or stored) right away.
+func (u *UnitOfWork) Raise(ctx context.Context, event any) error {
+	if u.handler == nil {
+		payload, err := json.Marshal(event)
+		if err != nil {
+			return fmt.Errorf("failed to encode event %T: %w", event, err)
+		}
+		row := &OutboxEvent{Type: fmt.Sprintf("%T", event), Payload: payload, CreatedAt: time.Now().UTC()}
+		if _, err := u.t.Db().NewInsert().Model(row).Exec(ctx); err != nil {
+			return fmt.Errorf("failed to store event %T: %w", event, err)
+		}
+		return nil
+	}
+
+	active := u.t.active()
+	if !active {
+		return u.handler(ctx, event)
+	}
+
+	u.t.OnCommit(func(ctx context.Context) {
+		if err := u.handler(ctx, event); err != nil {
+			slog.Error("unit of work event handler", "event", fmt.Sprintf("%T", event), "err", err.Error())
+		}
+	})
+	return nil
+}
+
+// PendingOutbox returns the oldest outbox events not yet marked as dispatched
+func PendingOutbox(ctx context.Context, idb bun.IDB, limit int) ([]OutboxEvent, error) {
+	var events []OutboxEvent
+	err := idb.NewSelect().Model(&events).
+		Where("dispatched_at IS NULL").
+		Order("id ASC").
+		Limit(limit).
+		Scan(ctx)
+	return events, err
+}
+
+// MarkDispatched records that the outbox events with the given ids were published
+func MarkDispatched(ctx context.Context, idb bun.IDB, ids ...int64) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	_, err := idb.NewUpdate().Model((*OutboxEvent)(nil)).
+		Set("dispatched_at = ?", time.Now().UTC()).
+		Where("id IN (?)", bun.In(ids)).
+		Exec(ctx)
+	return err
+}
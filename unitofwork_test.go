@@ -0,0 +1,107 @@
+package dbx
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+type itemCreated struct {
+	Name string `json:"name"`
+}
+
+func TestUnitOfWork_DispatchAfterCommit(t *testing.T) {
+	ctx := context.Background()
+	db := setupTestDB(t)
+	tx := mustNewTx(t, db)
+
+	var dispatched []string
+	uow, err := NewUnitOfWork(tx, func(ctx context.Context, event any) error {
+		dispatched = append(dispatched, event.(itemCreated).Name)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("NewUnitOfWork failed: %v", err)
+	}
+
+	err = tx.Transaction(nil, func(ctx context.Context) error {
+		insertItem(t, tx.Db(), "a")
+		if err := uow.Raise(ctx, itemCreated{Name: "a"}); err != nil {
+			return err
+		}
+
+		// Events of a rolled back savepoint are dropped
+		_ = tx.Transaction(nil, func(ctx context.Context) error {
+			_ = uow.Raise(ctx, itemCreated{Name: "discarded"})
+			return errors.New("abort savepoint")
+		})
+		// Events of a committed savepoint wait for the outer commit
+		if err := tx.Transaction(nil, func(ctx context.Context) error {
+			return uow.Raise(ctx, itemCreated{Name: "b"})
+		}); err != nil {
+			return err
+		}
+
+		if len(dispatched) != 0 {
+			t.Fatalf("expected no dispatch before the outer commit, got %v", dispatched)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Transaction failed: %v", err)
+	}
+	if len(dispatched) != 2 || dispatched[0] != "a" || dispatched[1] != "b" {
+		t.Fatalf("expected a and b dispatched after commit, got %v", dispatched)
+	}
+
+	// A rolled back transaction dispatches nothing
+	dispatched = nil
+	_ = tx.Transaction(nil, func(ctx context.Context) error {
+		_ = uow.Raise(ctx, itemCreated{Name: "c"})
+		return errors.New("abort")
+	})
+	if len(dispatched) != 0 {
+		t.Fatalf("expected no dispatch after rollback, got %v", dispatched)
+	}
+
+	// Outside a transaction events go out right away
+	if err := uow.Raise(ctx, itemCreated{Name: "d"}); err != nil || len(dispatched) != 1 {
+		t.Fatalf("expected immediate dispatch, got %v (err %v)", dispatched, err)
+	}
+}
+
+func TestUnitOfWork_Outbox(t *testing.T) {
+	ctx := context.Background()
+	db := setupTestDB(t)
+	tx := mustNewTx(t, db)
+
+	uow, err := NewOutboxUnitOfWork(ctx, tx)
+	if err != nil {
+		t.Fatalf("NewOutboxUnitOfWork failed: %v", err)
+	}
+
+	_ = tx.Transaction(nil, func(ctx context.Context) error {
+		_ = uow.Raise(ctx, itemCreated{Name: "rolled back"})
+		return errors.New("abort")
+	})
+	if err := tx.Transaction(nil, func(ctx context.Context) error {
+		return uow.Raise(ctx, itemCreated{Name: "kept"})
+	}); err != nil {
+		t.Fatalf("Transaction failed: %v", err)
+	}
+
+	pending, err := PendingOutbox(ctx, db, 10)
+	if err != nil {
+		t.Fatalf("PendingOutbox failed: %v", err)
+	}
+	if len(pending) != 1 || string(pending[0].Payload) != `{"name":"kept"}` || pending[0].Type != "dbx.itemCreated" {
+		t.Fatalf("unexpected outbox %+v", pending)
+	}
+
+	if err := MarkDispatched(ctx, db, pending[0].ID); err != nil {
+		t.Fatalf("MarkDispatched failed: %v", err)
+	}
+	if pending, err = PendingOutbox(ctx, db, 10); err != nil || len(pending) != 0 {
+		t.Fatalf("expected empty outbox, got %d (err %v)", len(pending), err)
+	}
+}
@@ -0,0 +1,96 @@
+package dbx
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/dialect"
+)
+
+const autoVacuumIncremental = 2
+
+// AutoVacuumIncremental switches the SQLite database of db to incremental auto-vacuum and then, every interval
+// until ctx is cancelled, returns at most pagesPerStep free pages to the file system. Each step only locks the db
+// for as long as it takes to move that many pages, unlike a full VACUUM, so space freed by large deletes is
+// reclaimed in the background while writers keep going.
+//
+// A database created without auto-vacuum needs one full VACUUM to switch, which AutoVacuumIncremental runs
+// before returning; later calls find it enabled and skip it.
+func AutoVacuumIncremental(ctx context.Context, db *bun.DB, pagesPerStep int, interval time.Duration) error {
+	if dName := db.Dialect().Name(); dName != dialect.SQLite {
+		return fmt.Errorf("unsupported dialect: %s", dName)
+	}
+	if pagesPerStep <= 0 || interval <= 0 {
+		return fmt.Errorf("invalid incremental vacuum of %d pages every %s", pagesPerStep, interval)
+	}
+	if err := enableIncrementalVacuum(ctx, db); err != nil {
+		return err
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+
+			if err := incrementalVacuumStep(ctx, db, pagesPerStep); err != nil && ctx.Err() == nil {
+				slog.Error("dbx incremental vacuum", "err", err.Error())
+			}
+		}
+	}()
+
+	return nil
+}
+
+func enableIncrementalVacuum(ctx context.Context, db *bun.DB) error {
+	// The mode is only stored in the file by the VACUUM of the same connection
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	var mode int
+	if err := conn.QueryRowContext(ctx, "PRAGMA auto_vacuum").Scan(&mode); err != nil {
+		return fmt.Errorf("failed to read auto_vacuum: %w", err)
+	}
+	if mode == autoVacuumIncremental {
+		return nil
+	}
+
+	if _, err := conn.ExecContext(ctx, "PRAGMA auto_vacuum = INCREMENTAL"); err != nil {
+		return fmt.Errorf("failed to set auto_vacuum: %w", err)
+	}
+	if _, err := conn.ExecContext(ctx, "VACUUM"); err != nil {
+		return fmt.Errorf("failed to vacuum: %w", err)
+	}
+	return nil
+}
+
+func incrementalVacuumStep(ctx context.Context, db *bun.DB, pages int) error {
+	var free int
+	if err := db.QueryRowContext(ctx, "PRAGMA freelist_count").Scan(&free); err != nil {
+		return fmt.Errorf("failed to read freelist_count: %w", err)
+	}
+	if free == 0 {
+		return nil
+	}
+
+	// The pragma frees one page per step of the statement, so the rows must be drained
+	rows, err := db.QueryContext(ctx, fmt.Sprintf("PRAGMA incremental_vacuum(%d)", pages))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+	}
+	return rows.Err()
+}
@@ -0,0 +1,61 @@
+package dbx
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAutoVacuumIncremental(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	db := setupTestDB(t)
+
+	name := strings.Repeat("x", 4000)
+	for range 200 {
+		insertItem(t, db, name)
+	}
+	if _, err := db.ExecContext(ctx, "DELETE FROM items"); err != nil {
+		t.Fatalf("delete failed: %v", err)
+	}
+
+	freelist := func() int {
+		t.Helper()
+		var n int
+		if err := db.QueryRowContext(ctx, "PRAGMA freelist_count").Scan(&n); err != nil {
+			t.Fatalf("freelist_count failed: %v", err)
+		}
+		return n
+	}
+	if freelist() == 0 {
+		t.Fatal("expected free pages after the delete")
+	}
+
+	if err := AutoVacuumIncremental(ctx, db, 10, 5*time.Millisecond); err != nil {
+		t.Fatalf("AutoVacuumIncremental failed: %v", err)
+	}
+	var mode int
+	if err := db.QueryRowContext(ctx, "PRAGMA auto_vacuum").Scan(&mode); err != nil || mode != autoVacuumIncremental {
+		t.Fatalf("expected incremental auto_vacuum, got %d (err %v)", mode, err)
+	}
+
+	// The switching VACUUM already emptied the freelist; later deletes are reclaimed by the steps
+	for range 200 {
+		insertItem(t, db, name)
+	}
+	if _, err := db.ExecContext(ctx, "DELETE FROM items"); err != nil {
+		t.Fatalf("delete failed: %v", err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for freelist() > 0 {
+		if time.Now().After(deadline) {
+			t.Fatalf("freelist not reclaimed, %d pages left", freelist())
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	if err := AutoVacuumIncremental(ctx, db, 0, time.Second); err == nil {
+		t.Fatal("expected an error for an empty step")
+	}
+}
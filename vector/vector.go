@@ -0,0 +1,123 @@
+package vector
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/dialect"
+)
+
+var ErrDimensions = errors.New("invalid vector dimensions")
+
+// Neighbor is a row returned by NearestNeighbors
+type Neighbor struct {
+	ID       int64   `bun:"id"`
+	Distance float64 `bun:"distance"`
+}
+
+// CreateVectorTable creates a table storing embeddings of dims dimensions keyed by an int64 id.
+// On SQLite it is a vec0 virtual table from the sqlite-vec extension (load it with dbx.WithExtension);
+// on Postgres a regular table with a pgvector `vector(dims)` column, enabling the extension if needed.
+func CreateVectorTable(ctx context.Context, idb bun.IDB, table string, dims int) error {
+	if dims <= 0 {
+		return fmt.Errorf("%w: %d", ErrDimensions, dims)
+	}
+
+	switch dName := idb.Dialect().Name(); dName {
+	case dialect.SQLite:
+		_, err := idb.ExecContext(ctx,
+			fmt.Sprintf("CREATE VIRTUAL TABLE IF NOT EXISTS ? USING vec0(id INTEGER PRIMARY KEY, embedding float[%d])", dims),
+			bun.Ident(table))
+		return err
+	case dialect.PG:
+		if _, err := idb.ExecContext(ctx, "CREATE EXTENSION IF NOT EXISTS vector"); err != nil {
+			return fmt.Errorf("failed to enable pgvector: %w", err)
+		}
+		_, err := idb.ExecContext(ctx,
+			fmt.Sprintf("CREATE TABLE IF NOT EXISTS ? (id BIGINT PRIMARY KEY, embedding vector(%d) NOT NULL)", dims),
+			bun.Ident(table))
+		return err
+	default:
+		return fmt.Errorf("unsupported dialect: %s", dName)
+	}
+}
+
+// UpsertEmbedding stores vec under id, replacing any previous embedding.
+// On SQLite this is a delete followed by an insert, as vec0 tables do not support upserts;
+// pass a transaction as idb to make the pair atomic.
+func UpsertEmbedding(ctx context.Context, idb bun.IDB, table string, id int64, vec []float32) error {
+	if len(vec) == 0 {
+		return fmt.Errorf("%w: empty vector", ErrDimensions)
+	}
+
+	switch dName := idb.Dialect().Name(); dName {
+	case dialect.SQLite:
+		if _, err := idb.ExecContext(ctx, "DELETE FROM ? WHERE id = ?", bun.Ident(table), id); err != nil {
+			return err
+		}
+		_, err := idb.ExecContext(ctx, "INSERT INTO ? (id, embedding) VALUES (?, ?)", bun.Ident(table), id, formatVector(vec))
+		return err
+	case dialect.PG:
+		_, err := idb.ExecContext(ctx,
+			"INSERT INTO ? (id, embedding) VALUES (?, ?::vector) ON CONFLICT (id) DO UPDATE SET embedding = EXCLUDED.embedding",
+			bun.Ident(table), id, formatVector(vec))
+		return err
+	default:
+		return fmt.Errorf("unsupported dialect: %s", dName)
+	}
+}
+
+// DeleteEmbedding removes the embedding stored under id
+func DeleteEmbedding(ctx context.Context, idb bun.IDB, table string, id int64) error {
+	_, err := idb.ExecContext(ctx, "DELETE FROM ? WHERE id = ?", bun.Ident(table), id)
+	return err
+}
+
+// NearestNeighbors returns the k embeddings closest to vec by L2 distance, closest first
+func NearestNeighbors(ctx context.Context, idb bun.IDB, table string, vec []float32, k int) ([]Neighbor, error) {
+	if len(vec) == 0 {
+		return nil, fmt.Errorf("%w: empty vector", ErrDimensions)
+	}
+	if k <= 0 {
+		return nil, nil
+	}
+
+	var (
+		query string
+		args  []any
+	)
+	switch dName := idb.Dialect().Name(); dName {
+	case dialect.SQLite:
+		query = "SELECT id, distance FROM ? WHERE embedding MATCH ? AND k = ? ORDER BY distance"
+		args = []any{bun.Ident(table), formatVector(vec), k}
+	case dialect.PG:
+		query = "SELECT id, embedding <-> ?::vector AS distance FROM ? ORDER BY distance LIMIT ?"
+		args = []any{formatVector(vec), bun.Ident(table), k}
+	default:
+		return nil, fmt.Errorf("unsupported dialect: %s", dName)
+	}
+
+	var neighbors []Neighbor
+	if err := idb.NewRaw(query, args...).Scan(ctx, &neighbors); err != nil {
+		return nil, err
+	}
+	return neighbors, nil
+}
+
+// formatVector renders vec as the `[1,2.5,3]` text form understood by both sqlite-vec and pgvector
+func formatVector(vec []float32) string {
+	var b strings.Builder
+	b.WriteByte('[')
+	for i, v := range vec {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(strconv.FormatFloat(float64(v), 'g', -1, 32))
+	}
+	b.WriteByte(']')
+	return b.String()
+}
@@ -0,0 +1,114 @@
+package vector
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+
+	"github.com/actanonv/dbx"
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/dialect/pgdialect"
+	"github.com/uptrace/bun/dialect/sqlitedialect"
+	"github.com/uptrace/bun/schema"
+)
+
+// queryLog collects the queries run through it, failed ones included
+type queryLog struct{ queries []string }
+
+func (l *queryLog) BeforeQuery(ctx context.Context, _ *bun.QueryEvent) context.Context { return ctx }
+func (l *queryLog) AfterQuery(_ context.Context, event *bun.QueryEvent) {
+	l.queries = append(l.queries, event.Query)
+}
+
+// logDB returns a db of dialect d logging its queries; they run on an in-memory SQLite db holding a plain
+// embeddings table, so the queries only meant for sqlite-vec or pgvector fail but are logged
+func logDB(t *testing.T, d schema.Dialect) (*bun.DB, *queryLog) {
+	t.Helper()
+	sqldb, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("sql.Open failed: %v", err)
+	}
+	sqldb.SetMaxOpenConns(1)
+	db := bun.NewDB(sqldb, d)
+	t.Cleanup(func() { _ = db.Close() })
+	if _, err := db.Exec("CREATE TABLE embeddings (id INTEGER PRIMARY KEY, embedding TEXT)"); err != nil {
+		t.Fatalf("create table failed: %v", err)
+	}
+	log := &queryLog{}
+	db.AddQueryHook(log)
+	return db, log
+}
+
+func TestUpsertEmbedding_SQL(t *testing.T) {
+	ctx := context.Background()
+	vec := []float32{1, 2.5}
+
+	db, log := logDB(t, sqlitedialect.New())
+	if err := UpsertEmbedding(ctx, db, "embeddings", 7, vec); err != nil {
+		t.Fatalf("UpsertEmbedding failed: %v", err)
+	}
+	want := []string{
+		`DELETE FROM "embeddings" WHERE id = 7`,
+		`INSERT INTO "embeddings" (id, embedding) VALUES (7, '[1,2.5]')`,
+	}
+	if len(log.queries) != 2 || log.queries[0] != want[0] || log.queries[1] != want[1] {
+		t.Errorf("sqlite: expected %q, got %q", want, log.queries)
+	}
+
+	db, log = logDB(t, pgdialect.New())
+	_ = UpsertEmbedding(ctx, db, "embeddings", 7, vec)
+	want = []string{`INSERT INTO "embeddings" (id, embedding) VALUES (7, '[1,2.5]'::vector) ` +
+		`ON CONFLICT (id) DO UPDATE SET embedding = EXCLUDED.embedding`}
+	if len(log.queries) != 1 || log.queries[0] != want[0] {
+		t.Errorf("postgres: expected %q, got %q", want, log.queries)
+	}
+}
+
+func TestNearestNeighbors_SQL(t *testing.T) {
+	ctx := context.Background()
+	vec := []float32{0.5, -1}
+
+	for _, tc := range []struct {
+		dialect schema.Dialect
+		want    string
+	}{
+		{sqlitedialect.New(), `SELECT id, distance FROM "embeddings" WHERE embedding MATCH '[0.5,-1]' AND k = 3 ORDER BY distance`},
+		{pgdialect.New(), `SELECT id, embedding <-> '[0.5,-1]'::vector AS distance FROM "embeddings" ORDER BY distance LIMIT 3`},
+	} {
+		db, log := logDB(t, tc.dialect)
+		_, _ = NearestNeighbors(ctx, db, "embeddings", vec, 3)
+		if len(log.queries) != 1 || log.queries[0] != tc.want {
+			t.Errorf("%s: expected %q, got %q", tc.dialect.Name(), tc.want, log.queries)
+		}
+	}
+
+	// No query for k <= 0
+	db, log := logDB(t, sqlitedialect.New())
+	if neighbors, err := NearestNeighbors(ctx, db, "embeddings", vec, 0); err != nil || neighbors != nil || len(log.queries) != 0 {
+		t.Errorf("expected no query for k = 0, got %v, %v, %q", neighbors, err, log.queries)
+	}
+}
+
+func TestFormatVector(t *testing.T) {
+	if got := formatVector([]float32{1, 2.5, -0.125}); got != "[1,2.5,-0.125]" {
+		t.Fatalf("formatVector() got = %v", got)
+	}
+}
+
+func TestCreateVectorTable_InvalidDimensions(t *testing.T) {
+	tmp := t.TempDir()
+	if err := dbx.CreateDB("vector", dbx.CreateWithDbFolder(tmp)); err != nil {
+		t.Fatalf("CreateDB failed: %v", err)
+	}
+	db, err := dbx.OpenDB("vector", dbx.WithDbFolder(tmp))
+	if err != nil {
+		t.Fatalf("OpenDB failed: %v", err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+
+	if err := CreateVectorTable(context.Background(), db, "embeddings", 0); !errors.Is(err, ErrDimensions) {
+		t.Fatalf("expected ErrDimensions, got %v", err)
+	}
+}
@@ -0,0 +1,152 @@
+package dbx
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"runtime"
+	"runtime/debug"
+	"strconv"
+
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/dialect"
+)
+
+const modulePath = "github.com/actanonv/dbx"
+
+// driverModules are the modules of the database/sql drivers dbx knows of
+var driverModules = []string{
+	"github.com/mattn/go-sqlite3",
+	"modernc.org/sqlite",
+	"github.com/jackc/pgx/v5",
+	"github.com/jackc/pgx/v4",
+	"github.com/lib/pq",
+	"github.com/uptrace/bun/driver/pgdriver",
+	"github.com/go-sql-driver/mysql",
+	"github.com/microsoft/go-mssqldb",
+	"github.com/denisenkom/go-mssqldb",
+}
+
+// VersionInfo lists the versions of dbx and of what it runs with, read from the build info of the binary
+type VersionInfo struct {
+	// Version is the module version of dbx, "(devel)" when dbx is the main module or the version is unknown
+	Version   string
+	GoVersion string
+	Bun       string
+	// Drivers maps the module path of the SQL drivers linked in the binary to their version
+	Drivers map[string]string
+}
+
+// Version returns the versions of dbx, bun and the SQL drivers linked in the binary, for bug reports and
+// startup logs
+func Version() VersionInfo {
+	info := VersionInfo{Version: "(devel)", GoVersion: runtime.Version(), Drivers: make(map[string]string)}
+	bi, ok := debug.ReadBuildInfo()
+	if !ok {
+		return info
+	}
+	if bi.Main.Path == modulePath && bi.Main.Version != "" {
+		info.Version = bi.Main.Version
+	}
+	for _, dep := range bi.Deps {
+		if dep.Replace != nil {
+			dep = dep.Replace
+		}
+		switch {
+		case dep.Path == modulePath:
+			info.Version = dep.Version
+		case dep.Path == "github.com/uptrace/bun":
+			info.Bun = dep.Version
+		default:
+			for _, m := range driverModules {
+				if dep.Path == m {
+					info.Drivers[m] = dep.Version
+				}
+			}
+		}
+	}
+	return info
+}
+
+// ServerInfo is the database engine version of a db, with the features of dbx it lacks
+type ServerInfo struct {
+	Dialect string
+	// Version is the version string reported by the engine, e.g. "3.45.1" or "PostgreSQL 16.2 on x86_64-pc-linux-gnu"
+	Version string
+	// Major, Minor and Patch are parsed from Version, zero when it has no version number
+	Major, Minor, Patch int
+	// Warnings describe the features of dbx that do not work with this version
+	Warnings []string
+}
+
+// AtLeast reports whether the engine version is major.minor.patch or later
+func (s *ServerInfo) AtLeast(major, minor, patch int) bool {
+	if s.Major != major {
+		return s.Major > major
+	}
+	if s.Minor != minor {
+		return s.Minor > minor
+	}
+	return s.Patch >= patch
+}
+
+// compatRule is a feature of dbx needing a minimal engine version
+type compatRule struct {
+	dialect             dialect.Name
+	major, minor, patch int
+	feature             string
+}
+
+var compatRules = []compatRule{
+	{dialect.SQLite, 3, 24, 0, "upserts (MergeDB and the subpackages) need ON CONFLICT DO UPDATE"},
+	{dialect.SQLite, 3, 35, 0, "NextSequence and ratelimit need RETURNING"},
+	{dialect.PG, 9, 5, 0, "upserts (MergeDB and the subpackages) need ON CONFLICT DO UPDATE"},
+}
+
+var versionNumber = regexp.MustCompile(`(\d+)\.(\d+)(?:\.(\d+))?`)
+
+// ServerVersion returns the engine version of db, with a warning for each feature of dbx it is too old for.
+// Supported dialects are SQLite, Postgres and MySQL.
+func ServerVersion(ctx context.Context, db *bun.DB) (*ServerInfo, error) {
+	info := &ServerInfo{Dialect: db.Dialect().Name().String()}
+	switch dName := db.Dialect().Name(); dName {
+	case dialect.SQLite:
+		if err := db.NewRaw("SELECT sqlite_version()").Scan(ctx, &info.Version); err != nil {
+			return nil, err
+		}
+	case dialect.PG, dialect.MySQL:
+		if err := db.NewRaw("SELECT version()").Scan(ctx, &info.Version); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unsupported dialect: %s", dName)
+	}
+	info.parse()
+	info.Warnings = compatWarnings(db.Dialect().Name(), info)
+	return info, nil
+}
+
+func (s *ServerInfo) parse() {
+	m := versionNumber.FindStringSubmatch(s.Version)
+	if m == nil {
+		return
+	}
+	s.Major, _ = strconv.Atoi(m[1])
+	s.Minor, _ = strconv.Atoi(m[2])
+	s.Patch, _ = strconv.Atoi(m[3])
+}
+
+// compatWarnings returns the features of compatRules the version of info is too old for; none when it is unknown
+func compatWarnings(dName dialect.Name, info *ServerInfo) []string {
+	if info.Major == 0 {
+		return nil
+	}
+	var warnings []string
+	for _, r := range compatRules {
+		if r.dialect == dName && !info.AtLeast(r.major, r.minor, r.patch) {
+			warnings = append(warnings, fmt.Sprintf("%s %d.%d.%d: %s %d.%d.%d+", info.Dialect,
+				info.Major, info.Minor, info.Patch, r.feature, r.major, r.minor, r.patch))
+		}
+	}
+	return warnings
+}
@@ -0,0 +1,59 @@
+package dbx
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/uptrace/bun/dialect"
+)
+
+func TestVersion(t *testing.T) {
+	v := Version()
+	if v.Version == "" || v.GoVersion == "" {
+		t.Fatalf("expected dbx and Go versions, got %+v", v)
+	}
+	if v.Bun == "" {
+		t.Fatalf("expected the bun version, got %+v", v)
+	}
+	if _, ok := v.Drivers["github.com/mattn/go-sqlite3"]; !ok {
+		t.Fatalf("expected go-sqlite3 among the drivers, got %v", v.Drivers)
+	}
+}
+
+func TestServerVersion(t *testing.T) {
+	db := setupTestDB(t)
+	info, err := ServerVersion(context.Background(), db)
+	if err != nil {
+		t.Fatalf("ServerVersion failed: %v", err)
+	}
+	if info.Dialect != "sqlite" || info.Major != 3 || !strings.HasPrefix(info.Version, "3.") {
+		t.Fatalf("unexpected server info %+v", info)
+	}
+	if len(info.Warnings) != 0 {
+		t.Fatalf("expected no warnings for the bundled SQLite, got %v", info.Warnings)
+	}
+}
+
+func TestCompatWarnings(t *testing.T) {
+	for _, tc := range []struct {
+		dialect dialect.Name
+		version string
+		want    int
+	}{
+		{dialect.SQLite, "3.45.1", 0},
+		{dialect.SQLite, "3.35.0", 0},
+		{dialect.SQLite, "3.31.1", 1},
+		{dialect.SQLite, "3.22.0", 2},
+		{dialect.PG, "PostgreSQL 9.4.26 on x86_64-pc-linux-gnu", 1},
+		{dialect.PG, "PostgreSQL 16.2 (Debian 16.2-1.pgdg120+2)", 0},
+		{dialect.MySQL, "5.7.44", 0},
+		{dialect.SQLite, "unknown", 0},
+	} {
+		info := &ServerInfo{Dialect: tc.dialect.String(), Version: tc.version}
+		info.parse()
+		if got := compatWarnings(tc.dialect, info); len(got) != tc.want {
+			t.Errorf("%s %s: expected %d warnings, got %v", tc.dialect, tc.version, tc.want, got)
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package dbx
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/uptrace/bun"
+)
+
+// VersionedView returns the name of version n of the views over table: table_vN
+func VersionedView(table string, version int) string {
+	return fmt.Sprintf("%s_v%d", table, version)
+}
+
+// CreateVersionedView creates, or replaces, the view table_vN exposing table in the shape of schema version n.
+// columns are the SQL expressions of the view, e.g. "full_name AS name" for a column renamed after version n or
+// "0 AS legacy_flag" for a dropped one; none selects all the columns of table.
+//
+// Blue/green deployments read through the view of their version (see WithSchemaVersion) while a migration evolves
+// the table: create the view of the old version in the migration changing the table, and drop it with
+// DropVersionedView once no running release reads it. table gets the prefix and suffix of WithTablePrefix and
+// WithTableSuffix, like TableName. Run it inside a migration, like MaintainCounter.
+func CreateVersionedView(ctx context.Context, idb bun.IDB, table string, version int, columns ...string) error {
+	table = TableName(idb, table)
+	view := VersionedView(table, version)
+	selected := "*"
+	if len(columns) > 0 {
+		selected = strings.Join(columns, ", ")
+	}
+	// DROP then CREATE, as CREATE OR REPLACE is missing on SQLite and cannot drop columns on Postgres
+	statements := []string{`DROP VIEW IF EXISTS ?0`, `CREATE VIEW ?0 AS SELECT ` + selected + ` FROM ?1`}
+	for _, stmt := range statements {
+		if _, err := idb.ExecContext(ctx, stmt, bun.Ident(view), bun.Ident(table)); err != nil {
+			return fmt.Errorf("failed to create view %s: %w", view, err)
+		}
+	}
+	return nil
+}
+
+// DropVersionedView drops the view table_vN, if it exists
+func DropVersionedView(ctx context.Context, idb bun.IDB, table string, version int) error {
+	view := VersionedView(TableName(idb, table), version)
+	if _, err := idb.ExecContext(ctx, `DROP VIEW IF EXISTS ?`, bun.Ident(view)); err != nil {
+		return fmt.Errorf("failed to drop view %s: %w", view, err)
+	}
+	return nil
+}
+
+// WithSchemaVersion returns a select modifier reading the model of the query from version n of its views, created
+// by CreateVersionedView: db.NewSelect().Model(&items).Apply(dbx.WithSchemaVersion(1)) reads items_v1.
+// A version of 0 reads the table itself.
+func WithSchemaVersion(version int) func(*bun.SelectQuery) *bun.SelectQuery {
+	return func(q *bun.SelectQuery) *bun.SelectQuery {
+		table := q.GetTableName()
+		if version == 0 || table == "" {
+			return q
+		}
+		return q.ModelTableExpr("? AS ?TableAlias", bun.Ident(VersionedView(table, version)))
+	}
+}
@@ -0,0 +1,66 @@
+package dbx
+
+import (
+	"context"
+	"testing"
+
+	"github.com/uptrace/bun"
+)
+
+func TestVersionedView(t *testing.T) {
+	db := setupTestDB(t)
+	ctx := context.Background()
+	insertItem(t, db, "a")
+
+	// Version 2 renames name to title; releases of version 1 keep reading name through items_v1
+	if _, err := db.ExecContext(ctx, `ALTER TABLE items RENAME COLUMN name TO title`); err != nil {
+		t.Fatal(err)
+	}
+	if err := CreateVersionedView(ctx, db, "items", 1, "id", "title AS name"); err != nil {
+		t.Fatalf("CreateVersionedView: %v", err)
+	}
+	// Replacing the view is allowed
+	if err := CreateVersionedView(ctx, db, "items", 1, "id", "title AS name"); err != nil {
+		t.Fatalf("CreateVersionedView again: %v", err)
+	}
+
+	type itemV1 struct {
+		bun.BaseModel `bun:"table:items"`
+		ID            int64
+		Name          string
+	}
+	var v1 []itemV1
+	q := db.NewSelect().Model(&v1).Apply(WithSchemaVersion(1))
+	if got := q.String(); got != `SELECT "item_v1"."id", "item_v1"."name" FROM "items_v1" AS "item_v1"` {
+		t.Errorf("unexpected query %s", got)
+	}
+	if err := q.Scan(ctx); err != nil {
+		t.Fatalf("select v1: %v", err)
+	}
+	if len(v1) != 1 || v1[0].Name != "a" {
+		t.Errorf("expected item a through items_v1, got %+v", v1)
+	}
+
+	type itemV2 struct {
+		bun.BaseModel `bun:"table:items"`
+		ID            int64
+		Title         string
+	}
+	var v2 []itemV2
+	if err := db.NewSelect().Model(&v2).Apply(WithSchemaVersion(0)).Scan(ctx); err != nil {
+		t.Fatalf("select table: %v", err)
+	}
+	if len(v2) != 1 || v2[0].Title != "a" {
+		t.Errorf("expected item a in items, got %+v", v2)
+	}
+
+	if err := DropVersionedView(ctx, db, "items", 1); err != nil {
+		t.Fatalf("DropVersionedView: %v", err)
+	}
+	if err := db.NewSelect().Model(&v1).Apply(WithSchemaVersion(1)).Scan(ctx); err == nil {
+		t.Error("expected an error reading a dropped view")
+	}
+	if err := DropVersionedView(ctx, db, "items", 1); err != nil {
+		t.Errorf("dropping a missing view: %v", err)
+	}
+}